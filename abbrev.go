@@ -0,0 +1,136 @@
+package git
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// minShortHashLength is the minimum length of an abbreviated hash.
+const minShortHashLength = 4
+
+// ErrInvalidShortHash is returned by ResolveShortHash when the abbreviated
+// hash is not hexadecimal, or is shorter than four characters.
+var ErrInvalidShortHash = errors.New("invalid abbreviated hash")
+
+// ResolveShortHash returns the hash of the object whose hash starts with the
+// given hexadecimal prefix, of at least four characters. If the prefix
+// matches no object plumbing.ErrObjectNotFound is returned, if it matches
+// more than one an *ErrAmbiguous with the candidates is returned.
+func (r *Repository) ResolveShortHash(prefix string) (plumbing.Hash, error) {
+	if len(prefix) < minShortHashLength || len(prefix) > 40 || !isHex(prefix) {
+		return plumbing.ZeroHash, ErrInvalidShortHash
+	}
+
+	hashes, err := r.hashesWithPrefix(prefix)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	switch len(hashes) {
+	case 0:
+		return plumbing.ZeroHash, plumbing.ErrObjectNotFound
+	case 1:
+		return hashes[0], nil
+	default:
+		return plumbing.ZeroHash, &ErrAmbiguous{Prefix: prefix, Candidates: hashes}
+	}
+}
+
+// Abbreviate returns the shortest prefix of the hexadecimal form of h, of at
+// least minLen characters, not shared with any other object of the
+// repository. minLen is raised to four if lower, git's minimum.
+func (r *Repository) Abbreviate(h plumbing.Hash, minLen int) (string, error) {
+	if minLen < minShortHashLength {
+		minLen = minShortHashLength
+	}
+
+	if minLen >= 40 {
+		return h.String(), nil
+	}
+
+	hashes, err := storer.HashesWithPrefix(r.Storer, h[:minLen/2])
+	if err != nil {
+		return "", err
+	}
+
+	length := minLen
+	for _, other := range hashes {
+		if other == h {
+			continue
+		}
+
+		if n := commonHexPrefix(h, other) + 1; n > length {
+			length = n
+		}
+	}
+
+	return h.String()[:length], nil
+}
+
+// hashesWithPrefix returns the sorted hashes of the objects starting with the
+// given hexadecimal prefix.
+func (r *Repository) hashesWithPrefix(prefix string) ([]plumbing.Hash, error) {
+	if len(prefix) == 40 {
+		h := plumbing.NewHash(prefix)
+		err := r.Storer.HasEncodedObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []plumbing.Hash{h}, nil
+	}
+
+	// an odd number of digits is looked up by the whole bytes, filtering
+	// the candidates by the last digit later
+	b, err := hex.DecodeString(prefix[:len(prefix)&^1])
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := storer.HashesWithPrefix(r.Storer, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	for _, h := range candidates {
+		if !seen[h] && strings.HasPrefix(h.String(), prefix) {
+			seen[h] = true
+			hashes = append(hashes, h)
+		}
+	}
+
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].String() < hashes[j].String()
+	})
+
+	return hashes, nil
+}
+
+// commonHexPrefix returns the number of leading hexadecimal digits shared by
+// a and b.
+func commonHexPrefix(a, b plumbing.Hash) int {
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+
+		if a[i]>>4 == b[i]>>4 {
+			return i*2 + 1
+		}
+
+		return i * 2
+	}
+
+	return len(a) * 2
+}
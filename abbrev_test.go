@@ -0,0 +1,120 @@
+package git
+
+import (
+	"fmt"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type AbbrevSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&AbbrevSuite{})
+
+// storeCollidingBlobs stores blobs in the repository until two of them share
+// the first four hexadecimal digits of their hashes, and returns both.
+func storeCollidingBlobs(c *C, r *Repository) (plumbing.Hash, plumbing.Hash) {
+	seen := make(map[string]plumbing.Hash)
+	for i := 0; ; i++ {
+		obj := r.Storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		c.Assert(err, IsNil)
+		_, err = w.Write([]byte(fmt.Sprintf("blob %d", i)))
+		c.Assert(err, IsNil)
+		c.Assert(w.Close(), IsNil)
+
+		h, err := r.Storer.SetEncodedObject(obj)
+		c.Assert(err, IsNil)
+
+		prefix := h.String()[:minShortHashLength]
+		if other, ok := seen[prefix]; ok {
+			return other, h
+		}
+
+		seen[prefix] = h
+	}
+}
+
+func (s *AbbrevSuite) TestResolveShortHash(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	for _, prefix := range []string{"6ecf", "6ecf0", "6ecf0ef2c2", head.String()} {
+		h, err := r.ResolveShortHash(prefix)
+		c.Assert(err, IsNil, Commentf("while checking %s", prefix))
+		c.Assert(h, Equals, head)
+	}
+
+	for _, prefix := range []string{"6ec", "6ecg", "6ECF", head.String() + "0"} {
+		_, err := r.ResolveShortHash(prefix)
+		c.Assert(err, Equals, ErrInvalidShortHash, Commentf("while checking %s", prefix))
+	}
+
+	_, err := r.ResolveShortHash("6ecf1")
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *AbbrevSuite) TestResolveShortHashAmbiguous(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	a, b := storeCollidingBlobs(c, r)
+	prefix := a.String()[:minShortHashLength]
+
+	_, err = r.ResolveShortHash(prefix)
+	c.Assert(err, FitsTypeOf, &ErrAmbiguous{})
+	c.Assert(err.(*ErrAmbiguous).Prefix, Equals, prefix)
+	c.Assert(err.(*ErrAmbiguous).Candidates, HasLen, 2)
+	c.Assert(err.(*ErrAmbiguous).Candidates, DeepEquals, sortedHashes(a, b))
+
+	h, err := r.ResolveShortHash(a.String()[:10])
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, a)
+}
+
+func (s *AbbrevSuite) TestAbbreviate(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	for minLen, expected := range map[int]string{
+		0:  "6ecf",
+		4:  "6ecf",
+		7:  "6ecf0ef",
+		40: head.String(),
+		50: head.String(),
+	} {
+		abbrev, err := r.Abbreviate(head, minLen)
+		c.Assert(err, IsNil)
+		c.Assert(abbrev, Equals, expected)
+	}
+}
+
+func (s *AbbrevSuite) TestAbbreviateAmbiguous(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	a, b := storeCollidingBlobs(c, r)
+	for _, h := range []plumbing.Hash{a, b} {
+		abbrev, err := r.Abbreviate(h, 4)
+		c.Assert(err, IsNil)
+		c.Assert(len(abbrev) > minShortHashLength, Equals, true)
+
+		resolved, err := r.ResolveShortHash(abbrev)
+		c.Assert(err, IsNil)
+		c.Assert(resolved, Equals, h)
+
+		_, err = r.ResolveShortHash(abbrev[:len(abbrev)-1])
+		c.Assert(err, FitsTypeOf, &ErrAmbiguous{})
+	}
+}
+
+func sortedHashes(hashes ...plumbing.Hash) []plumbing.Hash {
+	plumbing.HashesSort(hashes)
+	return hashes
+}
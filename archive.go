@@ -0,0 +1,529 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	exportIgnoreAttribute = "export-ignore"
+	exportSubstAttribute  = "export-subst"
+
+	archiveUser = "root"
+	// archiveUmask is the umask applied to the mode of the files, as the
+	// default tar.umask of git.
+	archiveUmask = 0002
+)
+
+var archiveAttributes = []string{exportIgnoreAttribute, exportSubstAttribute}
+
+// Archive writes to w an archive with the files of a tree, like `git
+// archive`. The files with the export-ignore attribute are not archived, and
+// the $Format:...$ placeholders in the files with the export-subst attribute
+// are expanded with the commit information.
+func (r *Repository) Archive(w io.Writer, o *ArchiveOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	t := o.Tree
+	mtime := time.Now()
+
+	var c *object.Commit
+	if t == nil {
+		h, err := r.ResolveRevision(o.Revision)
+		if err != nil {
+			return err
+		}
+
+		c, err = r.CommitObject(*h)
+		if err != nil {
+			return err
+		}
+
+		t, err = c.Tree()
+		if err != nil {
+			return err
+		}
+
+		mtime = c.Committer.When
+	}
+
+	stack, err := readTreePatterns(t, nil)
+	if err != nil {
+		return err
+	}
+
+	stack, err = r.attributesStack(stack)
+	if err != nil {
+		return err
+	}
+
+	a, err := newArchiver(w, o.Format, c, mtime)
+	if err != nil {
+		return err
+	}
+
+	aw := &archiveWriter{
+		archiver: a,
+		matcher:  gitattributes.NewMatcher(stack),
+		commit:   c,
+		prefix:   o.Prefix,
+		paths:    o.Paths,
+		dirs:     make(map[string]bool),
+	}
+
+	if err := aw.writeTree(t); err != nil {
+		_ = a.Close()
+		return err
+	}
+
+	return a.Close()
+}
+
+// archiveWriter writes the entries of a tree to an archiver.
+type archiveWriter struct {
+	archiver
+	matcher gitattributes.Matcher
+	commit  *object.Commit
+	prefix  string
+	paths   []string
+
+	// dirs are the written directories
+	dirs map[string]bool
+	// ignored are the directories with the export-ignore attribute
+	ignored []string
+}
+
+func (w *archiveWriter) writeTree(t *object.Tree) error {
+	walker := object.NewTreeWalker(t, true, nil)
+	defer walker.Close()
+
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if w.isIgnored(name) {
+			continue
+		}
+
+		attrs, _ := w.matcher.Match(attributesPath(name), archiveAttributes)
+		if a, ok := attrs[exportIgnoreAttribute]; ok && a.IsSet() {
+			if e.Mode == filemode.Dir {
+				w.ignored = append(w.ignored, name)
+			}
+
+			continue
+		}
+
+		if e.Mode == filemode.Dir || !w.matchPaths(name) {
+			continue
+		}
+
+		if err := w.writeParents(name); err != nil {
+			return err
+		}
+
+		subst := false
+		if a, ok := attrs[exportSubstAttribute]; ok && a.IsSet() {
+			subst = true
+		}
+
+		if err := w.writeEntry(t, name, &e, subst); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *archiveWriter) writeEntry(t *object.Tree, name string, e *object.TreeEntry, subst bool) (err error) {
+	if e.Mode == filemode.Submodule {
+		w.dirs[name] = true
+		return w.writeDir(w.prefix + name + "/")
+	}
+
+	b, err := t.TreeEntryFile(e)
+	if err != nil {
+		return err
+	}
+
+	// the name of the file is its path in the tree
+	b.Name = name
+
+	r, err := b.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	if e.Mode == filemode.Symlink {
+		target, err := stdioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		return w.writeSymlink(w.prefix+name, string(target))
+	}
+
+	size := b.Size
+	if subst && w.commit != nil {
+		content, err := stdioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		content = expandFormatPlaceholders(content, w.commit)
+		size = int64(len(content))
+		r = stdioutil.NopCloser(bytes.NewReader(content))
+	}
+
+	return w.writeFile(w.prefix+name, e.Mode == filemode.Executable, size, r)
+}
+
+// writeParents writes the entries of the parent directories of the path, if
+// they were not written yet.
+func (w *archiveWriter) writeParents(name string) error {
+	dir := path.Dir(name)
+	if dir == "." || w.dirs[dir] {
+		return nil
+	}
+
+	if err := w.writeParents(dir); err != nil {
+		return err
+	}
+
+	w.dirs[dir] = true
+	return w.writeDir(w.prefix + dir + "/")
+}
+
+func (w *archiveWriter) isIgnored(name string) bool {
+	for _, dir := range w.ignored {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPaths returns true if the path is one of the paths of the options, or
+// it is inside one of them.
+func (w *archiveWriter) matchPaths(name string) bool {
+	if len(w.paths) == 0 {
+		return true
+	}
+
+	for _, p := range w.paths {
+		p = strings.Trim(path.Clean("/"+p), "/")
+		if p == "" || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// archiver writes the entries of an archive.
+type archiver interface {
+	writeDir(name string) error
+	writeFile(name string, executable bool, size int64, r io.Reader) error
+	writeSymlink(name, target string) error
+	Close() error
+}
+
+func newArchiver(w io.Writer, format ArchiveFormat, c *object.Commit, mtime time.Time) (archiver, error) {
+	var comment string
+	if c != nil {
+		comment = c.Hash.String()
+	}
+
+	switch format {
+	case TarArchive:
+		return newTarArchiver(w, nil, comment, mtime)
+	case TarGzArchive:
+		gz := gzip.NewWriter(w)
+		return newTarArchiver(gz, gz, comment, mtime)
+	case ZipArchive:
+		return newZipArchiver(w, comment, mtime)
+	default:
+		return nil, ErrUnsupportedArchiveFormat
+	}
+}
+
+type tarArchiver struct {
+	w     *tar.Writer
+	c     io.Closer
+	mtime time.Time
+}
+
+// newTarArchiver returns a tar archiver, the commit ID is written in a pax
+// global header as git does. The closer, if any, is closed after the archive.
+func newTarArchiver(w io.Writer, c io.Closer, comment string, mtime time.Time) (archiver, error) {
+	a := &tarArchiver{w: tar.NewWriter(w), c: c, mtime: mtime}
+	if comment == "" {
+		return a, nil
+	}
+
+	err := a.w.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": comment},
+	})
+
+	return a, err
+}
+
+func (a *tarArchiver) header(typeflag byte, name string, mode int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: typeflag,
+		Name:     name,
+		Mode:     mode &^ archiveUmask,
+		ModTime:  a.mtime,
+		Uname:    archiveUser,
+		Gname:    archiveUser,
+	}
+}
+
+func (a *tarArchiver) writeDir(name string) error {
+	return a.w.WriteHeader(a.header(tar.TypeDir, name, 0777))
+}
+
+func (a *tarArchiver) writeFile(name string, executable bool, size int64, r io.Reader) error {
+	h := a.header(tar.TypeReg, name, 0666)
+	if executable {
+		h.Mode = 0777 &^ archiveUmask
+	}
+
+	h.Size = size
+	if err := a.w.WriteHeader(h); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(a.w, r)
+	return err
+}
+
+func (a *tarArchiver) writeSymlink(name, target string) error {
+	h := a.header(tar.TypeSymlink, name, 0777)
+	h.Mode = 0777
+	h.Linkname = target
+	return a.w.WriteHeader(h)
+}
+
+func (a *tarArchiver) Close() error {
+	if err := a.w.Close(); err != nil {
+		return err
+	}
+
+	if a.c != nil {
+		return a.c.Close()
+	}
+
+	return nil
+}
+
+type zipArchiver struct {
+	w     *zip.Writer
+	mtime time.Time
+}
+
+// newZipArchiver returns a zip archiver, the commit ID is written as the
+// comment of the archive as git does.
+func newZipArchiver(w io.Writer, comment string, mtime time.Time) (archiver, error) {
+	zw := zip.NewWriter(w)
+	if err := zw.SetComment(comment); err != nil {
+		return nil, err
+	}
+
+	return &zipArchiver{w: zw, mtime: mtime}, nil
+}
+
+func (a *zipArchiver) create(name string, mode os.FileMode, method uint16) (io.Writer, error) {
+	h := &zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: a.mtime,
+	}
+
+	h.SetMode(mode)
+	return a.w.CreateHeader(h)
+}
+
+func (a *zipArchiver) writeDir(name string) error {
+	_, err := a.create(name, os.ModeDir|0755, zip.Store)
+	return err
+}
+
+func (a *zipArchiver) writeFile(name string, executable bool, size int64, r io.Reader) error {
+	var mode os.FileMode = 0644
+	if executable {
+		mode = 0755
+	}
+
+	w, err := a.create(name, mode, zip.Deflate)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (a *zipArchiver) writeSymlink(name, target string) error {
+	w, err := a.create(name, os.ModeSymlink|0777, zip.Store)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (a *zipArchiver) Close() error {
+	return a.w.Close()
+}
+
+var formatPlaceholder = regexp.MustCompile(`\$Format:([^$\n]*)\$`)
+
+// expandFormatPlaceholders expands the $Format:...$ placeholders of the
+// content with the commit information, as the export-subst attribute does.
+func expandFormatPlaceholders(content []byte, c *object.Commit) []byte {
+	return formatPlaceholder.ReplaceAllFunc(content, func(m []byte) []byte {
+		format := formatPlaceholder.FindSubmatch(m)[1]
+		return []byte(formatCommit(string(format), c))
+	})
+}
+
+// formatCommit expands the placeholders of a pretty format, like `git log
+// --pretty=format:`, the unknown ones are left as they are.
+func formatCommit(format string, c *object.Commit) string {
+	buf := bytes.NewBuffer(nil)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			buf.WriteByte(format[i])
+			continue
+		}
+
+		n, value := formatPlaceholderValue(format[i+1:], c)
+		if n == 0 {
+			buf.WriteByte(format[i])
+			continue
+		}
+
+		buf.WriteString(value)
+		i += n
+	}
+
+	return buf.String()
+}
+
+// formatPlaceholderValue returns the value of the placeholder at the
+// beginning of the format, and its length; the length is zero if it is
+// unknown.
+func formatPlaceholderValue(format string, c *object.Commit) (int, string) {
+	switch format[0] {
+	case '%':
+		return 1, "%"
+	case 'n':
+		return 1, "\n"
+	case 'H':
+		return 1, c.Hash.String()
+	case 'h':
+		return 1, c.Hash.String()[:7]
+	case 'T':
+		return 1, c.TreeHash.String()
+	case 't':
+		return 1, c.TreeHash.String()[:7]
+	case 'P', 'p':
+		parents := make([]string, len(c.ParentHashes))
+		for i, h := range c.ParentHashes {
+			parents[i] = h.String()
+			if format[0] == 'p' {
+				parents[i] = parents[i][:7]
+			}
+		}
+
+		return 1, strings.Join(parents, " ")
+	case 's':
+		subject, _ := splitCommitMessage(c.Message)
+		return 1, subject
+	case 'b':
+		_, body := splitCommitMessage(c.Message)
+		return 1, body
+	case 'B':
+		return 1, c.Message
+	case 'a', 'c':
+		if len(format) < 2 {
+			return 0, ""
+		}
+
+		s := c.Author
+		if format[0] == 'c' {
+			s = c.Committer
+		}
+
+		value, ok := formatSignature(format[1], &s)
+		if !ok {
+			return 0, ""
+		}
+
+		return 2, value
+	}
+
+	return 0, ""
+}
+
+func formatSignature(placeholder byte, s *object.Signature) (string, bool) {
+	switch placeholder {
+	case 'n':
+		return s.Name, true
+	case 'e':
+		return s.Email, true
+	case 'd':
+		return s.When.Format("Mon Jan 2 15:04:05 2006 -0700"), true
+	case 'D':
+		return s.When.Format("Mon, 2 Jan 2006 15:04:05 -0700"), true
+	case 'i':
+		return s.When.Format("2006-01-02 15:04:05 -0700"), true
+	case 'I':
+		return s.When.Format("2006-01-02T15:04:05-07:00"), true
+	case 't':
+		return fmt.Sprint(s.When.Unix()), true
+	}
+
+	return "", false
+}
+
+// splitCommitMessage returns the subject of a commit message, its first
+// paragraph in a single line, and its body, the rest of the message.
+func splitCommitMessage(msg string) (subject, body string) {
+	msg = strings.TrimLeft(msg, "\n")
+	parts := strings.SplitN(msg, "\n\n", 2)
+	subject = strings.Replace(strings.TrimSpace(parts[0]), "\n", " ", -1)
+	if len(parts) == 2 {
+		body = strings.TrimLeft(parts[1], "\n")
+	}
+
+	return subject, body
+}
@@ -0,0 +1,220 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type ArchiveSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ArchiveSuite{})
+
+func (s *ArchiveSuite) readTar(c *C, r io.Reader) (headers []*tar.Header, contents map[string]string) {
+	contents = make(map[string]string)
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return
+		}
+
+		c.Assert(err, IsNil)
+
+		content, err := ioutil.ReadAll(tr)
+		c.Assert(err, IsNil)
+
+		headers = append(headers, h)
+		contents[h.Name] = string(content)
+	}
+}
+
+func (s *ArchiveSuite) TestArchiveTar(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.Archive(buf, &ArchiveOptions{Prefix: "basic/"})
+	c.Assert(err, IsNil)
+
+	headers, contents := s.readTar(c, buf)
+	c.Assert(headers, HasLen, 14)
+
+	c.Assert(headers[0].Typeflag, Equals, byte(tar.TypeXGlobalHeader))
+	c.Assert(headers[0].PAXRecords["comment"], Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	var names []string
+	for _, h := range headers[1:] {
+		names = append(names, h.Name)
+		c.Assert(h.Uname, Equals, "root")
+		c.Assert(h.ModTime.Unix(), Equals, int64(1428269447))
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			c.Assert(h.Mode, Equals, int64(0775))
+		case tar.TypeReg:
+			c.Assert(h.Mode, Equals, int64(0664))
+		}
+	}
+
+	c.Assert(names, DeepEquals, []string{
+		"basic/.gitignore",
+		"basic/CHANGELOG",
+		"basic/LICENSE",
+		"basic/binary.jpg",
+		"basic/go/",
+		"basic/go/example.go",
+		"basic/json/",
+		"basic/json/long.json",
+		"basic/json/short.json",
+		"basic/php/",
+		"basic/php/crappy.php",
+		"basic/vendor/",
+		"basic/vendor/foo.go",
+	})
+
+	c.Assert(contents["basic/CHANGELOG"], Equals, "Initial changelog\n")
+}
+
+func (s *ArchiveSuite) TestArchiveTarGzPaths(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.Archive(buf, &ArchiveOptions{
+		Revision: "refs/heads/master~1",
+		Format:   TarGzArchive,
+		Paths:    []string{"json/", "CHANGELOG"},
+	})
+
+	c.Assert(err, IsNil)
+
+	gz, err := gzip.NewReader(buf)
+	c.Assert(err, IsNil)
+
+	headers, _ := s.readTar(c, gz)
+	c.Assert(headers, HasLen, 5)
+	c.Assert(headers[0].PAXRecords["comment"], Equals, "918c48b83bd081e863dbe1b80f8998f058cd8294")
+	c.Assert(headers[1].Name, Equals, "CHANGELOG")
+	c.Assert(headers[2].Name, Equals, "json/")
+	c.Assert(headers[3].Name, Equals, "json/long.json")
+	c.Assert(headers[4].Name, Equals, "json/short.json")
+}
+
+func (s *ArchiveSuite) TestArchiveZip(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.Archive(buf, &ArchiveOptions{Format: ZipArchive, Prefix: "basic/"})
+	c.Assert(err, IsNil)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, IsNil)
+	c.Assert(zr.Comment, Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(zr.File, HasLen, 13)
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	c.Assert(files["basic/go/"].Mode().IsDir(), Equals, true)
+	c.Assert(files["basic/go/example.go"].Mode(), Equals, os.FileMode(0644))
+
+	r, err := files["basic/CHANGELOG"].Open()
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "Initial changelog\n")
+}
+
+func (s *ArchiveSuite) TestArchiveTree(c *C) {
+	commit, err := s.Repository.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+
+	tree, err = tree.Tree("json")
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = s.Repository.Archive(buf, &ArchiveOptions{Tree: tree})
+	c.Assert(err, IsNil)
+
+	headers, _ := s.readTar(c, buf)
+	c.Assert(headers, HasLen, 2)
+	c.Assert(headers[0].Name, Equals, "long.json")
+	c.Assert(headers[1].Name, Equals, "short.json")
+}
+
+func (s *ArchiveSuite) TestArchiveAttributes(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, ".gitattributes",
+		[]byte("secret export-ignore\n*.tmp export-ignore\nVERSION export-subst\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "VERSION", []byte("$Format:%h %an$\n$Format:%s$\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "run.sh", []byte("#!/bin/sh\n"), 0755), IsNil)
+	c.Assert(util.WriteFile(fs, "foo.tmp", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "secret/key", []byte("foo"), 0644), IsNil)
+	c.Assert(fs.Symlink("run.sh", "run"), IsNil)
+
+	c.Assert(w.AddGlob("*"), IsNil)
+
+	hash, err := w.Commit("foo\nbar\n\nqux\n", &CommitOptions{Author: &object.Signature{
+		Name: "foo", Email: "foo@foo.foo", When: time.Unix(1500000000, 0),
+	}})
+
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = r.Archive(buf, &ArchiveOptions{})
+	c.Assert(err, IsNil)
+
+	headers, contents := s.readTar(c, buf)
+	c.Assert(headers, HasLen, 5)
+	c.Assert(headers[1].Name, Equals, ".gitattributes")
+	c.Assert(headers[2].Name, Equals, "VERSION")
+	c.Assert(headers[3].Name, Equals, "run")
+	c.Assert(headers[3].Typeflag, Equals, byte(tar.TypeSymlink))
+	c.Assert(headers[3].Linkname, Equals, "run.sh")
+	c.Assert(headers[4].Name, Equals, "run.sh")
+	c.Assert(headers[4].Mode, Equals, int64(0775))
+
+	c.Assert(contents["VERSION"], Equals, hash.String()[:7]+" foo\nfoo bar\n")
+}
+
+func (s *ArchiveSuite) TestArchiveOptions(c *C) {
+	err := s.Repository.Archive(ioutil.Discard, &ArchiveOptions{
+		Revision: "HEAD",
+		Tree:     &object.Tree{},
+	})
+
+	c.Assert(err, Equals, ErrTreeAndRevision)
+
+	err = s.Repository.Archive(ioutil.Discard, &ArchiveOptions{Format: "rar"})
+	c.Assert(err, Equals, ErrUnsupportedArchiveFormat)
+}
+
+func (s *ArchiveSuite) TestFormatCommit(c *C) {
+	commit, err := s.Repository.CommitObject(plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"))
+	c.Assert(err, IsNil)
+
+	c.Assert(formatCommit("%H %h %T %p%n%an <%ae> %at %ci %% %x %", commit), Equals,
+		"918c48b83bd081e863dbe1b80f8998f058cd8294 918c48b "+
+			commit.TreeHash.String()+" af2d6a6\n"+
+			"Máximo Cuadros Ortiz <mcuadros@gmail.com> 1427802978 2015-03-31 13:56:18 +0200 % %x %")
+}
@@ -0,0 +1,239 @@
+package git
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+const (
+	gitattributesFile     = ".gitattributes"
+	infoAttributesFile    = "info/attributes"
+	coreAttributesFileKey = "attributesfile"
+)
+
+// Attributes returns the gitattributes of the given path of the worktree,
+// like `git check-attr`, only the ones in names if any given. They are read
+// from the .gitattributes files of the path directory and its parents in the
+// worktree, the info/attributes file of the repository, and the global and
+// system attributes files.
+func (w *Worktree) Attributes(path string, names ...string) (map[string]gitattributes.Attribute, error) {
+	dirs := attributesDirs(path)
+
+	var stack []gitattributes.MatchAttribute
+	for i := 0; i <= len(dirs); i++ {
+		attributes, err := gitattributes.ReadAttributesFile(
+			w.Filesystem, dirs[:i], gitattributesFile, i == 0)
+		if err != nil {
+			return nil, err
+		}
+
+		stack = append(stack, attributes...)
+	}
+
+	return w.r.matchAttributes(stack, path, names)
+}
+
+// Attributes returns the gitattributes of the given path at the given tree,
+// like `git check-attr --source`, only the ones in names if any given. They
+// are read from the .gitattributes files of the path directory and its
+// parents in the tree, the info/attributes file of the repository, and the
+// global and system attributes files.
+func (r *Repository) Attributes(t *object.Tree, path string, names ...string) (map[string]gitattributes.Attribute, error) {
+	dirs := attributesDirs(path)
+
+	var stack []gitattributes.MatchAttribute
+	for i := 0; i <= len(dirs); i++ {
+		attributes, err := readTreeAttributes(t, dirs[:i])
+		if err != nil {
+			return nil, err
+		}
+
+		stack = append(stack, attributes...)
+	}
+
+	return r.matchAttributes(stack, path, names)
+}
+
+// matchAttributes matches the path to the given attributes of the worktree
+// or a tree, with the repository and global ones around them.
+func (r *Repository) matchAttributes(
+	stack []gitattributes.MatchAttribute, path string, names []string,
+) (map[string]gitattributes.Attribute, error) {
+	stack, err := r.attributesStack(stack)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, _ := gitattributes.NewMatcher(stack).Match(attributesPath(path), names)
+	return attrs, nil
+}
+
+// attributesStack returns the given attributes of the worktree or a tree,
+// with the repository and global ones around them.
+func (r *Repository) attributesStack(
+	stack []gitattributes.MatchAttribute,
+) ([]gitattributes.MatchAttribute, error) {
+	global, err := r.globalAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := r.infoAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(global, stack...), info...), nil
+}
+
+// globalAttributes returns the attributes of the system file and the global
+// file, or the one declared by the core.attributesFile of the repository.
+func (r *Repository) globalAttributes() ([]gitattributes.MatchAttribute, error) {
+	root := osfs.New("/")
+	system, err := gitattributes.LoadSystemPatterns(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var global []gitattributes.MatchAttribute
+	if name := cfg.Raw.Section("core").Options.Get(coreAttributesFileKey); name != "" {
+		global, err = gitattributes.LoadPatterns(root, name, r.attributesBase())
+	} else {
+		global, err = gitattributes.LoadGlobalPatterns(root)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(system, global...), nil
+}
+
+// attributesBase returns the absolute path of the directory the relative
+// core.attributesFile of the repository is relative to, as git does: the root
+// of the worktree, or the git directory of a bare repository.
+func (r *Repository) attributesBase() string {
+	var base string
+	if r.wt != nil {
+		base = r.wt.Root()
+	} else if s, ok := r.Storer.(interface {
+		Filesystem() billy.Filesystem
+	}); ok {
+		base = s.Filesystem().Root()
+	}
+
+	if abs, err := filepath.Abs(base); err == nil {
+		return abs
+	}
+
+	return base
+}
+
+// infoAttributes returns the attributes of the info/attributes file of the
+// repository, if its storage is based on a filesystem.
+func (r *Repository) infoAttributes() ([]gitattributes.MatchAttribute, error) {
+	s, ok := r.Storer.(interface {
+		Filesystem() billy.Filesystem
+	})
+
+	if !ok {
+		return nil, nil
+	}
+
+	return gitattributes.ReadAttributesFile(s.Filesystem(), nil, infoAttributesFile, true)
+}
+
+func readTreeAttributes(t *object.Tree, dir []string) (attributes []gitattributes.MatchAttribute, err error) {
+	if len(dir) > 0 {
+		t, err = t.Tree(strings.Join(dir, "/"))
+		if err == object.ErrDirectoryNotFound {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return readTreeAttributesFile(t, dir)
+}
+
+// readTreeAttributesFile reads the .gitattributes file of the tree t, being
+// dir its path.
+func readTreeAttributesFile(t *object.Tree, dir []string) (attributes []gitattributes.MatchAttribute, err error) {
+	f, err := t.File(gitattributesFile)
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(rd, &err)
+
+	return gitattributes.ReadAttributes(rd, dir, len(dir) == 0)
+}
+
+// readTreePatterns reads the .gitattributes files of the tree t recursively,
+// being dir its path, like gitattributes.ReadPatterns does in a filesystem.
+func readTreePatterns(t *object.Tree, dir []string) ([]gitattributes.MatchAttribute, error) {
+	attributes, err := readTreeAttributesFile(t, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range t.Entries {
+		if e.Mode != filemode.Dir {
+			continue
+		}
+
+		sub, err := t.Tree(e.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		// the dir is copied, since it is the domain of the read attributes
+		subdir := make([]string, len(dir), len(dir)+1)
+		copy(subdir, dir)
+
+		subattributes, err := readTreePatterns(sub, append(subdir, e.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		attributes = append(attributes, subattributes...)
+	}
+
+	return attributes, nil
+}
+
+// attributesPath splits a path of the worktree in its components.
+func attributesPath(path string) []string {
+	return strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/")
+}
+
+// attributesDirs returns the components of the directory containing the
+// given path, its prefixes are the path of the directory and its parents.
+func attributesDirs(path string) []string {
+	parts := attributesPath(path)
+	return parts[: len(parts)-1 : len(parts)-1]
+}
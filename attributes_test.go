@@ -0,0 +1,148 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) TestAttributes(c *C) {
+	w, err := s.Repository.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, ".gitattributes",
+		[]byte("[attr]generated linguist-generated -diff\n*.go text eol=lf\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(w.Filesystem, "api/.gitattributes",
+		[]byte("*.pb.go generated\n*.go eol=crlf\n"), 0644), IsNil)
+
+	attrs, err := w.Attributes("api/foo.pb.go")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsSet(), Equals, true)
+	c.Assert(attrs["eol"].Value(), Equals, "crlf")
+	c.Assert(attrs["generated"].IsSet(), Equals, true)
+	c.Assert(attrs["linguist-generated"].IsSet(), Equals, true)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+
+	attrs, err = w.Attributes("main.go", "eol", "diff")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs["eol"].Value(), Equals, "lf")
+
+	attrs, err = w.Attributes("README")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 0)
+}
+
+func (s *WorktreeSuite) TestAttributesInfo(c *C) {
+	fs := s.Repository.Storer.(interface {
+		Filesystem() billy.Filesystem
+	}).Filesystem()
+
+	c.Assert(util.WriteFile(fs, "info/attributes",
+		[]byte("*.go -text\n*.png binary\n"), 0644), IsNil)
+
+	w, err := s.Repository.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, ".gitattributes",
+		[]byte("*.go text\n"), 0644), IsNil)
+
+	attrs, err := w.Attributes("main.go")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	attrs, err = w.Attributes("img/logo.png", "diff")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestRepositoryAttributes(c *C) {
+	w, err := s.Repository.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, ".gitattributes",
+		[]byte("*.json -diff\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(w.Filesystem, "php/.gitattributes",
+		[]byte("* export-ignore\n"), 0644), IsNil)
+
+	_, err = w.Add(".gitattributes")
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("php/.gitattributes")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("attributes", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	// the worktree changes are not seen in the committed tree
+	c.Assert(util.WriteFile(w.Filesystem, ".gitattributes",
+		[]byte("*.json diff\n"), 0644), IsNil)
+
+	commit, err := s.Repository.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+
+	attrs, err := s.Repository.Attributes(tree, "json/long.json")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+
+	attrs, err = s.Repository.Attributes(tree, "php/crappy.php")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["export-ignore"].IsSet(), Equals, true)
+
+	attrs, err = s.Repository.Attributes(tree, "go/example.go")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 0)
+
+	// a tree without attributes
+	commit, err = s.Repository.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+
+	tree, err = commit.Tree()
+	c.Assert(err, IsNil)
+
+	attrs, err = s.Repository.Attributes(tree, "json/long.json")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 0)
+
+	attrs, err = w.Attributes("json/long.json")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["diff"].IsSet(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestAttributesCoreAttributesFile(c *C) {
+	dir, err := ioutil.TempDir("", "attributes-file")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+
+	// relative paths are relative to the root of the worktree
+	cfg.Raw.Section("core").SetOption("attributesFile", "attributes")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "attributes"), []byte("*.png binary\n"), 0644)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	attrs, err := w.Attributes("img/logo.png", "diff")
+	c.Assert(err, IsNil)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+)
+
+// ErrEmptyBundle is returned by CreateBundle when no references are given.
+var ErrEmptyBundle = errors.New("refusing to create an empty bundle")
+
+// CreateBundle writes to w a bundle with the given references, like `git
+// bundle create`. The packfile of the bundle has the objects reachable from
+// the references that are not reachable from the basis commits, the basis
+// commits are listed as prerequisites of the bundle. With no basis the bundle
+// has the full history of the references and can be cloned.
+//
+// The bundle can be cloned and fetched using its path as URL.
+func (r *Repository) CreateBundle(w io.Writer, refs []plumbing.ReferenceName, basis []plumbing.Hash) error {
+	if len(refs) == 0 {
+		return ErrEmptyBundle
+	}
+
+	b := &bundle.Bundle{Version: bundle.V2}
+
+	var wants []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	for _, name := range refs {
+		ref, err := r.Reference(name, true)
+		if err != nil {
+			return err
+		}
+
+		b.References = append(b.References, plumbing.NewHashReference(name, ref.Hash()))
+		if !seen[ref.Hash()] {
+			seen[ref.Hash()] = true
+			wants = append(wants, ref.Hash())
+		}
+	}
+
+	var haves []plumbing.Hash
+	for _, h := range basis {
+		h, err := r.resolveToCommitHash(h)
+		if err != nil {
+			return err
+		}
+
+		c, err := r.CommitObject(h)
+		if err != nil {
+			return err
+		}
+
+		haves = append(haves, h)
+		b.Prerequisites = append(b.Prerequisites, bundle.Prerequisite{
+			Hash:    h,
+			Comment: strings.SplitN(c.Message, "\n", 2)[0],
+		})
+	}
+
+	objs, err := revlist.Objects(r.Storer, wants, haves)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := packfile.NewEncoder(pw, r.Storer, false).Encode(objs, 10)
+		pw.CloseWithError(err)
+	}()
+
+	b.Packfile = pr
+	err = bundle.NewEncoder(w).Encode(b)
+	if err != nil {
+		pr.CloseWithError(err)
+	}
+
+	return err
+}
@@ -0,0 +1,181 @@
+package git
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	transportbundle "gopkg.in/src-d/go-git.v4/plumbing/transport/bundle"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type BundleSuite struct {
+	BaseSuite
+	dir string
+}
+
+var _ = Suite(&BundleSuite{})
+
+func (s *BundleSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "bundle")
+	c.Assert(err, IsNil)
+	s.dir = dir
+}
+
+func (s *BundleSuite) TearDownTest(c *C) {
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *BundleSuite) createBundle(c *C, name string, refs []plumbing.ReferenceName,
+	basis []plumbing.Hash) string {
+
+	path := filepath.Join(s.dir, name)
+	f, err := os.Create(path)
+	c.Assert(err, IsNil)
+
+	err = s.Repository.CreateBundle(f, refs, basis)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	return path
+}
+
+func (s *BundleSuite) TestCreateBundle(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.Repository.CreateBundle(buf, []plumbing.ReferenceName{
+		plumbing.HEAD,
+		plumbing.Master,
+	}, []plumbing.Hash{plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")})
+	c.Assert(err, IsNil)
+
+	b := &bundle.Bundle{}
+	err = bundle.NewDecoder(buf).Decode(b)
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, bundle.V2)
+	c.Assert(b.Prerequisites, DeepEquals, []bundle.Prerequisite{{
+		Hash:    plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+		Comment: "some code",
+	}})
+
+	c.Assert(b.References, HasLen, 2)
+	c.Assert(b.References[0].Name(), Equals, plumbing.HEAD)
+	c.Assert(b.References[0].Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(b.References[1].Name(), Equals, plumbing.Master)
+	c.Assert(b.References[1].Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	pack, err := ioutil.ReadAll(b.Packfile)
+	c.Assert(err, IsNil)
+	c.Assert(string(pack[:4]), Equals, "PACK")
+}
+
+func (s *BundleSuite) TestCreateBundleEmpty(c *C) {
+	err := s.Repository.CreateBundle(ioutil.Discard, nil, nil)
+	c.Assert(err, Equals, ErrEmptyBundle)
+}
+
+func (s *BundleSuite) TestCloneBundle(c *C) {
+	path := s.createBundle(c, "basic.bundle", []plumbing.ReferenceName{
+		plumbing.HEAD,
+		plumbing.Master,
+		"refs/heads/branch",
+	}, nil)
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{URL: path})
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+	c.Assert(head.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	branch, err := r.Reference("refs/remotes/origin/branch", false)
+	c.Assert(err, IsNil)
+	c.Assert(branch.Hash().String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	iter, err := r.Log(&LogOptions{})
+	c.Assert(err, IsNil)
+
+	count := 0
+	c.Assert(iter.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	}), IsNil)
+
+	c.Assert(count, Equals, 8)
+}
+
+func (s *BundleSuite) TestFetchBundle(c *C) {
+	base := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	err := s.Repository.Storer.SetReference(plumbing.NewHashReference("refs/heads/base", base))
+	c.Assert(err, IsNil)
+
+	full := s.createBundle(c, "base.bundle", []plumbing.ReferenceName{"refs/heads/base"}, nil)
+	incremental := s.createBundle(c, "master.bundle", []plumbing.ReferenceName{
+		plumbing.Master,
+	}, []plumbing.Hash{base})
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:           full,
+		ReferenceName: "refs/heads/base",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "incremental",
+		URLs: []string{incremental},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.Fetch(&FetchOptions{RemoteName: "incremental"})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference("refs/remotes/incremental/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	commit, err := r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+
+	_, err = commit.Tree()
+	c.Assert(err, IsNil)
+
+	err = r.Push(&PushOptions{RemoteName: "incremental"})
+	c.Assert(err, NotNil)
+}
+
+func (s *BundleSuite) TestFetchBundleMissingPrerequisites(c *C) {
+	incremental := s.createBundle(c, "master.bundle", []plumbing.ReferenceName{
+		plumbing.Master,
+	}, []plumbing.Hash{plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")})
+
+	_, err := Clone(memory.NewStorage(), nil, &CloneOptions{URL: incremental})
+	c.Assert(err, Equals, transportbundle.ErrMissingPrerequisites)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "incremental",
+		URLs: []string{incremental},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.Fetch(&FetchOptions{RemoteName: "incremental"})
+	c.Assert(err, Equals, transportbundle.ErrMissingPrerequisites)
+
+	_, err = r.Reference("refs/remotes/incremental/master", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = r.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
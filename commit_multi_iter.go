@@ -0,0 +1,119 @@
+package git
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// commitMultiIter iterates the commits of several iterators, visiting every
+// commit once. The iterators are consumed one after the other or, if byTime
+// is true, merged by committer time.
+type commitMultiIter struct {
+	iters  []object.CommitIter
+	heads  []*object.Commit
+	seen   map[plumbing.Hash]bool
+	byTime bool
+}
+
+func newCommitMultiIter(iters []object.CommitIter, seen map[plumbing.Hash]bool, byTime bool) object.CommitIter {
+	if seen == nil {
+		seen = make(map[plumbing.Hash]bool)
+	}
+
+	return &commitMultiIter{
+		iters:  iters,
+		heads:  make([]*object.Commit, len(iters)),
+		seen:   seen,
+		byTime: byTime,
+	}
+}
+
+func (it *commitMultiIter) Next() (*object.Commit, error) {
+	for {
+		i, err := it.next()
+		if err != nil {
+			return nil, err
+		}
+
+		c := it.heads[i]
+		it.heads[i] = nil
+
+		if it.seen[c.Hash] {
+			continue
+		}
+
+		it.seen[c.Hash] = true
+		return c, nil
+	}
+}
+
+// next returns the index of the iterator with the next commit in its head.
+func (it *commitMultiIter) next() (int, error) {
+	for i := 0; i < len(it.iters); i++ {
+		if it.heads[i] != nil {
+			continue
+		}
+
+		c, err := it.iters[i].Next()
+		if err == io.EOF {
+			it.iters[i].Close()
+			it.iters = append(it.iters[:i], it.iters[i+1:]...)
+			it.heads = append(it.heads[:i], it.heads[i+1:]...)
+			i--
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		it.heads[i] = c
+		if !it.byTime {
+			return i, nil
+		}
+	}
+
+	if len(it.iters) == 0 {
+		return 0, io.EOF
+	}
+
+	next := 0
+	for i, c := range it.heads {
+		if c.Committer.When.After(it.heads[next].Committer.When) {
+			next = i
+		}
+	}
+
+	return next, nil
+}
+
+func (it *commitMultiIter) ForEach(cb func(*object.Commit) error) error {
+	for {
+		c, err := it.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = cb(c)
+		if err == storer.ErrStop {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (it *commitMultiIter) Close() {
+	for _, iter := range it.iters {
+		iter.Close()
+	}
+}
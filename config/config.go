@@ -44,6 +44,25 @@ type Config struct {
 		// CommentChar is the character indicating the start of a
 		// comment for commands like commit and tag
 		CommentChar string
+		// SparseCheckout if true only the paths matching the patterns of the
+		// info/sparse-checkout file are checked out in the worktree.
+		SparseCheckout bool
+		// SparseCheckoutCone if true the sparse checkout patterns are in cone
+		// mode, a list of directories instead of gitignore patterns.
+		SparseCheckoutCone bool
+		// AutoCRLF is the value of core.autocrlf, if "true" the line endings of
+		// the text files are converted to CRLF on checkout and to LF when they
+		// are added, if "input" they are only converted to LF when added.
+		AutoCRLF string
+		// EOL is the value of core.eol, the line ending used on checkout for
+		// the text files without an eol attribute: "lf", "crlf" or "native".
+		EOL string
+		// UseReplaceRefs if true the objects with a replace reference
+		// (refs/replace/<hash>) and the commits of the info/grafts file are
+		// replaced when they are looked up through the Repository. Unlike
+		// in git, it is disabled unless set. The Repository reads it on the
+		// first lookup, use Repository.SetUseReplaceRefs to change it.
+		UseReplaceRefs bool
 	}
 
 	Pack struct {
@@ -118,6 +137,11 @@ const (
 	bareKey          = "bare"
 	worktreeKey      = "worktree"
 	commentCharKey   = "commentChar"
+	sparseKey        = "sparseCheckout"
+	sparseConeKey    = "sparseCheckoutCone"
+	autoCRLFKey      = "autocrlf"
+	eolKey           = "eol"
+	useReplaceKey    = "useReplaceRefs"
 	windowKey        = "window"
 	mergeKey         = "merge"
 
@@ -157,6 +181,11 @@ func (c *Config) unmarshalCore() {
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
+	c.Core.SparseCheckout = s.Options.Get(sparseKey) == "true"
+	c.Core.SparseCheckoutCone = s.Options.Get(sparseConeKey) == "true"
+	c.Core.AutoCRLF = s.Options.Get(autoCRLFKey)
+	c.Core.EOL = s.Options.Get(eolKey)
+	c.Core.UseReplaceRefs = s.Options.Get(useReplaceKey) == "true"
 }
 
 func (c *Config) unmarshalPack() error {
@@ -239,6 +268,36 @@ func (c *Config) marshalCore() {
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.SparseCheckout {
+		s.SetOption(sparseKey, "true")
+	} else {
+		s.RemoveOption(sparseKey)
+	}
+
+	if c.Core.SparseCheckoutCone {
+		s.SetOption(sparseConeKey, "true")
+	} else {
+		s.RemoveOption(sparseConeKey)
+	}
+
+	if c.Core.AutoCRLF != "" {
+		s.SetOption(autoCRLFKey, c.Core.AutoCRLF)
+	} else {
+		s.RemoveOption(autoCRLFKey)
+	}
+
+	if c.Core.EOL != "" {
+		s.SetOption(eolKey, c.Core.EOL)
+	} else {
+		s.RemoveOption(eolKey)
+	}
+
+	if c.Core.UseReplaceRefs {
+		s.SetOption(useReplaceKey, "true")
+	} else {
+		s.RemoveOption(useReplaceKey)
+	}
 }
 
 func (c *Config) marshalPack() {
@@ -119,6 +119,61 @@ func (s *ConfigSuite) TestMarshall(c *C) {
 	c.Assert(string(b), Equals, string(output))
 }
 
+func (s *ConfigSuite) TestSparseCheckout(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte(`[core]
+	sparseCheckout = true
+	sparseCheckoutCone = true
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.SparseCheckout, Equals, true)
+	c.Assert(cfg.Core.SparseCheckoutCone, Equals, true)
+
+	cfg.Core.SparseCheckoutCone = false
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `[core]
+	sparseCheckout = true
+	bare = false
+`)
+}
+
+func (s *ConfigSuite) TestUseReplaceRefs(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte(`[core]
+	useReplaceRefs = true
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.UseReplaceRefs, Equals, true)
+
+	cfg.Core.UseReplaceRefs = false
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `[core]
+	bare = false
+`)
+}
+
+func (s *ConfigSuite) TestAutoCRLF(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte(`[core]
+	autocrlf = input
+	eol = crlf
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.AutoCRLF, Equals, "input")
+	c.Assert(cfg.Core.EOL, Equals, "crlf")
+
+	cfg.Core.AutoCRLF = "true"
+	cfg.Core.EOL = ""
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `[core]
+	bare = false
+	autocrlf = true
+`)
+}
+
 func (s *ConfigSuite) TestUnmarshallMarshall(c *C) {
 	input := []byte(`[core]
 	bare = true
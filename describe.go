@@ -0,0 +1,582 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// maxDescribeCandidates is the maximum number of candidates, every candidate
+// is tracked with a bit of an uint64 while walking the history.
+const maxDescribeCandidates = 64
+
+var (
+	// ErrDescribeNotFound is returned by Describe when no reference can
+	// describe the commit.
+	ErrDescribeNotFound = errors.New("no names found, cannot describe anything")
+	// ErrDescribeDirtyCommit is returned by Describe when the Dirty suffix is
+	// requested describing a commit other than HEAD.
+	ErrDescribeDirtyCommit = errors.New("dirty suffix can only be used describing HEAD")
+)
+
+// Description is the result of describing a commit, the nearest reference
+// and how far the commit is from it.
+type Description struct {
+	// Reference is the reference used to describe the commit.
+	Reference *plumbing.Reference
+	// Name is the name of the reference used in the description, the short
+	// name of the tag, or the reference name without the refs/ prefix if
+	// DescribeOptions.All is set.
+	Name string
+	// Distance is the number of commits between the commit and the
+	// reference.
+	Distance int
+	// Hash is the hash of the described commit.
+	Hash plumbing.Hash
+	// Abbrev is the abbreviated hash of the described commit, empty if
+	// DescribeOptions.Abbrev is negative.
+	Abbrev string
+	// Dirty is the dirty suffix, set if the worktree has changes.
+	Dirty string
+
+	contains string
+}
+
+// String returns the description in the format of git describe, such as
+// v1.0.0-3-g6ecf0ef, or v1.0.0~3 when the description is of a tag containing
+// the commit.
+func (d *Description) String() string {
+	if d.contains != "" {
+		return d.Name + d.contains + d.Dirty
+	}
+
+	if d.Distance == 0 || d.Abbrev == "" {
+		return d.Name + d.Dirty
+	}
+
+	return fmt.Sprintf("%s-%d-g%s%s", d.Name, d.Distance, d.Abbrev, d.Dirty)
+}
+
+// Describe finds the most recent reference reachable from a commit, by
+// default the most recent annotated tag. If the commit is not tagged, the
+// description includes the number of commits since the tag and the
+// abbreviated hash of the commit, as git describe does.
+func (r *Repository) Describe(o *DescribeOptions) (*Description, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	h := o.Commit
+	if h.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+
+		h = head.Hash()
+	}
+
+	commit, err := r.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := r.describeNames(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var d *Description
+	if o.Contains {
+		d, err = r.describeContains(commit, names)
+	} else {
+		d, err = describe(commit, names, o.Candidates)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Hash = commit.Hash
+	if o.Abbrev > 0 {
+		d.Abbrev, err = r.Abbreviate(commit.Hash, o.Abbrev)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Dirty == "" {
+		return d, nil
+	}
+
+	dirty, err := r.isDirty()
+	if err != nil {
+		return nil, err
+	}
+
+	if dirty {
+		d.Dirty = o.Dirty
+	}
+
+	return d, nil
+}
+
+// isDirty returns if the worktree has changes, the untracked files are not
+// taken into account.
+func (r *Repository) isDirty() (bool, error) {
+	w, err := r.Worktree()
+	if err != nil {
+		return false, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range status {
+		if s.Worktree == Untracked {
+			continue
+		}
+
+		if s.Worktree != Unmodified || s.Staging != Unmodified {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// describeName is a reference that can describe a commit.
+type describeName struct {
+	ref  *plumbing.Reference
+	name string
+	// prio is 2 for annotated tags, 1 for lightweight tags and 0 for any
+	// other reference.
+	prio int
+	tag  *object.Tag
+}
+
+// better returns if n is preferred to describe a commit over other.
+func (n *describeName) better(other *describeName) bool {
+	if n.prio != other.prio {
+		return n.prio > other.prio
+	}
+
+	if n.tag != nil && !n.tag.Tagger.When.Equal(other.tag.Tagger.When) {
+		return n.tag.Tagger.When.After(other.tag.Tagger.When)
+	}
+
+	return n.name < other.name
+}
+
+// describeNames returns the references that can describe a commit, by the
+// commit they point to.
+func (r *Repository) describeNames(o *DescribeOptions) (map[plumbing.Hash]*describeName, error) {
+	iter, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[plumbing.Hash]*describeName)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || ref.Name() == plumbing.HEAD {
+			return nil
+		}
+
+		refName := ref.Name()
+		if !o.All && !refName.IsTag() {
+			return nil
+		}
+
+		if !describeMatch(refName, o.Match) {
+			return nil
+		}
+
+		n := &describeName{ref: ref, name: refName.Short()}
+		if o.All {
+			n.name = strings.TrimPrefix(refName.String(), "refs/")
+		}
+
+		h := ref.Hash()
+		tag, err := r.TagObject(h)
+		switch err {
+		case nil:
+			commit, err := tag.Commit()
+			if err == object.ErrUnsupportedObject {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			n.tag, n.prio, h = tag, 2, commit.Hash
+		case plumbing.ErrObjectNotFound:
+			if refName.IsTag() {
+				n.prio = 1
+			}
+		default:
+			return err
+		}
+
+		if n.prio < 2 && !o.Tags && !o.All {
+			return nil
+		}
+
+		if current, ok := names[h]; !ok || n.better(current) {
+			names[h] = n
+		}
+
+		return nil
+	})
+
+	return names, err
+}
+
+// describeMatch returns if the reference matches any of the patterns, without
+// the refs/tags/, refs/heads/ or refs/remotes/ prefix. As in git, the
+// wildcards match any character, including the slashes.
+func describeMatch(n plumbing.ReferenceName, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	name := n.String()
+	for _, prefix := range []string{"refs/tags/", "refs/heads/", "refs/remotes/"} {
+		if strings.HasPrefix(name, prefix) {
+			name = name[len(prefix):]
+			break
+		}
+	}
+
+	for _, p := range patterns {
+		if ok, _ := path.Match(describePattern(p), describePattern(name)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describePattern replaces the slashes of the pattern or name by a character
+// the references can not contain, since git matches them without the
+// pathname flag, where the wildcards match the slashes too, unlike path.Match.
+func describePattern(s string) string {
+	return strings.Replace(s, "/", "\x00", -1)
+}
+
+// describeCandidate is a reference found walking the history from the
+// described commit.
+type describeCandidate struct {
+	name  *describeName
+	depth int
+	order int
+	flag  uint64
+}
+
+// describe walks the history from commit, by commit date, until the given
+// number of candidates are found. The best candidate is the one with less
+// commits, reachable from the commit, that are not reachable from it.
+func describe(commit *object.Commit, names map[plumbing.Hash]*describeName,
+	max int) (*Description, error) {
+
+	if n, ok := names[commit.Hash]; ok {
+		return &Description{Reference: n.ref, Name: n.name}, nil
+	}
+
+	w := newDescribeWalker(commit)
+
+	var (
+		candidates []*describeCandidate
+		gaveUp     *object.Commit
+	)
+
+	for {
+		c, ok := w.pop()
+		if !ok {
+			break
+		}
+
+		if n, ok := names[c.Hash]; ok {
+			if len(candidates) == max {
+				gaveUp = c
+				break
+			}
+
+			cand := &describeCandidate{
+				name:  n,
+				depth: w.seen - 1,
+				order: len(candidates),
+				flag:  1 << uint(len(candidates)),
+			}
+
+			w.flags[c.Hash] |= cand.flag
+			candidates = append(candidates, cand)
+		}
+
+		for _, cand := range candidates {
+			if w.flags[c.Hash]&cand.flag == 0 {
+				cand.depth++
+			}
+		}
+
+		if len(candidates) > 0 && w.heap.Empty() {
+			break
+		}
+
+		if err := w.pushParents(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrDescribeNotFound
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].depth != candidates[j].depth {
+			return candidates[i].depth < candidates[j].depth
+		}
+
+		return candidates[i].order < candidates[j].order
+	})
+
+	best := candidates[0]
+	if gaveUp != nil {
+		w.heap.Push(gaveUp)
+	}
+
+	if err := w.finishDepth(best); err != nil {
+		return nil, err
+	}
+
+	return &Description{
+		Reference: best.name.ref,
+		Name:      best.name.name,
+		Distance:  best.depth,
+	}, nil
+}
+
+// describeWalker walks the history by commit date, propagating the flags of
+// the candidates reaching every commit to its parents.
+type describeWalker struct {
+	heap   *binaryheap.Heap
+	queued map[plumbing.Hash]bool
+	flags  map[plumbing.Hash]uint64
+	seen   int
+}
+
+func newDescribeWalker(c *object.Commit) *describeWalker {
+	w := &describeWalker{
+		heap: binaryheap.NewWith(func(a, b interface{}) int {
+			if a.(*object.Commit).Committer.When.Before(b.(*object.Commit).Committer.When) {
+				return 1
+			}
+			return -1
+		}),
+		queued: map[plumbing.Hash]bool{c.Hash: true},
+		flags:  make(map[plumbing.Hash]uint64),
+	}
+
+	w.heap.Push(c)
+	return w
+}
+
+func (w *describeWalker) pop() (*object.Commit, bool) {
+	c, ok := w.heap.Pop()
+	if !ok {
+		return nil, false
+	}
+
+	w.seen++
+	return c.(*object.Commit), true
+}
+
+func (w *describeWalker) pushParents(c *object.Commit) error {
+	return c.Parents().ForEach(func(p *object.Commit) error {
+		w.flags[p.Hash] |= w.flags[c.Hash]
+		if !w.queued[p.Hash] {
+			w.queued[p.Hash] = true
+			w.heap.Push(p)
+		}
+
+		return nil
+	})
+}
+
+// finishDepth keeps walking the history, counting the commits not reachable
+// from the best candidate, until all the pending commits are reachable from
+// it.
+func (w *describeWalker) finishDepth(best *describeCandidate) error {
+	for {
+		c, ok := w.pop()
+		if !ok {
+			return nil
+		}
+
+		if w.flags[c.Hash]&best.flag != 0 {
+			if w.allFlagged(best.flag) {
+				return nil
+			}
+		} else {
+			best.depth++
+		}
+
+		if err := w.pushParents(c); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *describeWalker) allFlagged(flag uint64) bool {
+	for _, v := range w.heap.Values() {
+		if w.flags[v.(*object.Commit).Hash]&flag == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeContains finds the reference closest to the commit among the ones
+// containing it, describing the commit by the path from the reference, such
+// as v1.0.0~2^2~1, as git name-rev does.
+func (r *Repository) describeContains(commit *object.Commit,
+	names map[plumbing.Hash]*describeName) (*Description, error) {
+
+	var (
+		best     *describeName
+		bestPath []int
+	)
+
+	for h, n := range names {
+		steps, err := r.pathToCommit(h, commit)
+		if err != nil {
+			return nil, err
+		}
+
+		if steps == nil {
+			continue
+		}
+
+		if best == nil || len(steps) < len(bestPath) ||
+			len(steps) == len(bestPath) && n.better(best) {
+			best, bestPath = n, steps
+		}
+	}
+
+	if best == nil {
+		return nil, ErrDescribeNotFound
+	}
+
+	return &Description{
+		Reference: best.ref,
+		Name:      best.name,
+		Distance:  len(bestPath),
+		contains:  formatRevPath(bestPath),
+	}, nil
+}
+
+// pathToCommit returns the shortest path from the commit from to the commit
+// target, as the number of the parent followed in every step, starting at 1.
+// It returns nil if target is not reachable from from. As git does, commits
+// older than the target by more than a day are not walked.
+func (r *Repository) pathToCommit(from plumbing.Hash, target *object.Commit) ([]int, error) {
+	if from == target.Hash {
+		return []int{}, nil
+	}
+
+	start, err := r.CommitObject(from)
+	if err != nil {
+		return nil, err
+	}
+
+	type step struct {
+		commit *object.Commit
+		path   []int
+	}
+
+	cutoff := target.Committer.When.Add(-24 * time.Hour)
+	seen := map[plumbing.Hash]bool{from: true}
+	queue := []step{{commit: start}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current.commit.Committer.When.Before(cutoff) {
+			continue
+		}
+
+		i := 0
+		err := current.commit.Parents().ForEach(func(p *object.Commit) error {
+			i++
+			if seen[p.Hash] {
+				return nil
+			}
+
+			seen[p.Hash] = true
+			path := append(append([]int{}, current.path...), i)
+			if p.Hash == target.Hash {
+				queue = nil
+				return errFoundPath{path}
+			}
+
+			queue = append(queue, step{commit: p, path: path})
+			return nil
+		})
+
+		if found, ok := err.(errFoundPath); ok {
+			return found.path, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+type errFoundPath struct {
+	path []int
+}
+
+func (errFoundPath) Error() string {
+	return "path found"
+}
+
+// formatRevPath formats a path of parents as a revision suffix, such as
+// ~2^2~1.
+func formatRevPath(steps []int) string {
+	var b bytes.Buffer
+	first := 0
+	for _, s := range steps {
+		if s == 1 {
+			first++
+			continue
+		}
+
+		if first > 0 {
+			fmt.Fprintf(&b, "~%d", first)
+			first = 0
+		}
+
+		fmt.Fprintf(&b, "^%d", s)
+	}
+
+	if first > 0 {
+		fmt.Fprintf(&b, "~%d", first)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package git
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type DescribeSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&DescribeSuite{})
+
+var (
+	describeMerge   = plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea")
+	describeCode    = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	describeInitial = plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+)
+
+func (s *DescribeSuite) newRepository(c *C) *Repository {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	_, err := r.CreateTag("v1.0", describeMerge, &CreateTagOptions{
+		Tagger: &object.Signature{
+			Name:  "foo",
+			Email: "foo@foo.foo",
+			When:  time.Unix(1500000000, 0),
+		},
+		Message: "v1.0",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateTag("light", describeCode, nil)
+	c.Assert(err, IsNil)
+
+	return r
+}
+
+func (s *DescribeSuite) describe(c *C, r *Repository, o *DescribeOptions) string {
+	d, err := r.Describe(o)
+	c.Assert(err, IsNil)
+	return d.String()
+}
+
+func (s *DescribeSuite) TestDescribe(c *C) {
+	r := s.newRepository(c)
+
+	d, err := r.Describe(&DescribeOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(d.Name, Equals, "v1.0")
+	c.Assert(d.Reference.Name(), Equals, plumbing.ReferenceName("refs/tags/v1.0"))
+	c.Assert(d.Distance, Equals, 3)
+	c.Assert(d.Hash.String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(d.Abbrev, Equals, "6ecf0ef")
+	c.Assert(d.String(), Equals, "v1.0-3-g6ecf0ef")
+
+	c.Assert(s.describe(c, r, &DescribeOptions{Abbrev: 10}), Equals, "v1.0-3-g6ecf0ef2c2")
+	c.Assert(s.describe(c, r, &DescribeOptions{Abbrev: -1}), Equals, "v1.0")
+	c.Assert(s.describe(c, r, &DescribeOptions{Commit: describeMerge}), Equals, "v1.0")
+	c.Assert(s.describe(c, r, &DescribeOptions{Commit: describeCode}), Equals, "v1.0-2-g918c48b")
+}
+
+func (s *DescribeSuite) TestDescribeTagsAndAll(c *C) {
+	r := s.newRepository(c)
+
+	c.Assert(s.describe(c, r, &DescribeOptions{Tags: true}), Equals, "v1.0.0")
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Tags:   true,
+		Commit: describeCode,
+	}), Equals, "light")
+
+	c.Assert(s.describe(c, r, &DescribeOptions{All: true}), Equals, "tags/v1.0.0")
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		All:    true,
+		Commit: plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+	}), Equals, "heads/branch")
+}
+
+func (s *DescribeSuite) TestDescribeMatch(c *C) {
+	r := s.newRepository(c)
+
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Tags:  true,
+		Match: []string{"foo", "v1.0"},
+	}), Equals, "v1.0-3-g6ecf0ef")
+
+	_, err := r.Describe(&DescribeOptions{Match: []string{"v2*"}})
+	c.Assert(err, Equals, ErrDescribeNotFound)
+
+	_, err = r.Describe(&DescribeOptions{Commit: describeInitial})
+	c.Assert(err, Equals, ErrDescribeNotFound)
+
+	_, err = r.Describe(&DescribeOptions{Match: []string{"["}})
+	c.Assert(err, NotNil)
+}
+
+func (s *DescribeSuite) TestDescribeMatchSlashes(c *C) {
+	r := s.newRepository(c)
+
+	_, err := r.CreateTag("release/1.2", describeCode, nil)
+	c.Assert(err, IsNil)
+
+	for _, p := range []string{"release*", "rel*2", "release?1.2", "release/*"} {
+		c.Assert(s.describe(c, r, &DescribeOptions{
+			Tags:  true,
+			Match: []string{p},
+		}), Equals, "release/1.2-1-g6ecf0ef")
+	}
+
+	_, err = r.Describe(&DescribeOptions{Tags: true, Match: []string{"release"}})
+	c.Assert(err, Equals, ErrDescribeNotFound)
+}
+
+func (s *DescribeSuite) TestDescribeCandidates(c *C) {
+	r := s.newRepository(c)
+
+	_, err := r.CreateTag("v0.9", plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69"),
+		&CreateTagOptions{
+			Tagger: &object.Signature{
+				Name:  "foo",
+				Email: "foo@foo.foo",
+				When:  time.Unix(1400000000, 0),
+			},
+			Message: "v0.9",
+		})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.describe(c, r, &DescribeOptions{}), Equals, "v1.0-3-g6ecf0ef")
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Match: []string{"v0.*"},
+	}), Equals, "v0.9-5-g6ecf0ef")
+
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Commit:     describeCode,
+		Candidates: 1,
+	}), Equals, "v1.0-2-g918c48b")
+}
+
+func (s *DescribeSuite) TestDescribeContains(c *C) {
+	r := s.newRepository(c)
+
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Contains: true,
+		Commit:   describeInitial,
+	}), Equals, "v1.0~2")
+
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Contains: true,
+		Commit:   plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+	}), Equals, "v1.0^2^2")
+
+	c.Assert(s.describe(c, r, &DescribeOptions{
+		Contains: true,
+		Tags:     true,
+		Commit:   plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"),
+	}), Equals, "light~1")
+
+	_, err := r.Describe(&DescribeOptions{Contains: true})
+	c.Assert(err, Equals, ErrDescribeNotFound)
+}
+
+func (s *DescribeSuite) TestDescribeDirty(c *C) {
+	r := s.newRepository(c)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset}), IsNil)
+
+	err = util.WriteFile(w.Filesystem, "untracked", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.describe(c, r, &DescribeOptions{Dirty: "-dirty"}), Equals, "v1.0-3-g6ecf0ef")
+
+	err = util.WriteFile(w.Filesystem, "LICENSE", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.describe(c, r, &DescribeOptions{Dirty: "-dirty"}), Equals, "v1.0-3-g6ecf0ef-dirty")
+
+	_, err = r.Describe(&DescribeOptions{Dirty: "-dirty", Commit: describeCode})
+	c.Assert(err, Equals, ErrDescribeDirtyCommit)
+}
@@ -0,0 +1,352 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	stdioutil "io/ioutil"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/fastimport"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+// FastExport writes to w a fast-import stream with the history of the given
+// branches and tags, like `git fast-export`. The stream can be imported into
+// another repository with fastimport.Importer or `git fast-import`.
+//
+// The commits are exported in topological order, each one with its changes
+// from its first parent. With the marks of a previous run the export is
+// incremental, only the new objects are exported.
+func (r *Repository) FastExport(w io.Writer, o *FastExportOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	refs, err := r.fastExportReferences(o.References)
+	if err != nil {
+		return err
+	}
+
+	e := &fastExporter{
+		r:       r,
+		enc:     fastimport.NewEncoder(w),
+		marks:   o.Marks,
+		next:    o.Marks.Next(),
+		byHash:  make(map[plumbing.Hash]int, len(o.Marks)),
+		lastRef: make(map[plumbing.ReferenceName]plumbing.Hash),
+	}
+
+	for mark, h := range o.Marks {
+		e.byHash[h] = mark
+	}
+
+	return e.export(refs)
+}
+
+// fastExportReferences returns the resolved references with the given names,
+// or all the branches and tags if none is given.
+func (r *Repository) fastExportReferences(names []plumbing.ReferenceName) ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	if len(names) != 0 {
+		for _, name := range names {
+			ref, err := r.Reference(name, true)
+			if err != nil {
+				return nil, err
+			}
+
+			refs = append(refs, ref)
+		}
+
+		return refs, nil
+	}
+
+	iter, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && (ref.Name().IsBranch() || ref.Name().IsTag()) {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name() < refs[j].Name()
+	})
+
+	return refs, nil
+}
+
+type fastExporter struct {
+	r     *Repository
+	enc   *fastimport.Encoder
+	marks fastimport.Marks
+	next  int
+	// byHash are the marks of the exported objects by its hash.
+	byHash map[plumbing.Hash]int
+	// lastRef is the last commit exported on each reference.
+	lastRef map[plumbing.ReferenceName]plumbing.Hash
+}
+
+// exportTip is a reference and the commit it points to, peeling the
+// annotated tags.
+type exportTip struct {
+	ref    *plumbing.Reference
+	tag    *object.Tag
+	commit *object.Commit
+	blob   *object.Blob
+}
+
+func (e *fastExporter) export(refs []*plumbing.Reference) error {
+	var tips []*exportTip
+	for _, ref := range refs {
+		tip, err := e.peel(ref)
+		if err != nil {
+			return err
+		}
+
+		tips = append(tips, tip)
+	}
+
+	var commits []*object.Commit
+	var names []plumbing.ReferenceName
+	for _, tip := range tips {
+		if tip.commit != nil {
+			commits = append(commits, tip.commit)
+			names = append(names, tip.ref.Name())
+		}
+	}
+
+	// the commits are exported on the reference of the first tip reaching them
+	err := walkCommitsTopologically(e.r, commits, e.isExported, func(i int, c *object.Commit) error {
+		return e.exportCommit(c, names[i])
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, tip := range tips {
+		if err := e.exportTip(tip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *fastExporter) peel(ref *plumbing.Reference) (*exportTip, error) {
+	tip := &exportTip{ref: ref}
+	o, err := e.r.Storer.EncodedObject(plumbing.AnyObject, ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Type() == plumbing.TagObject {
+		if tip.tag, err = object.DecodeTag(e.r.Storer, o); err != nil {
+			return nil, err
+		}
+
+		if o, err = e.r.Storer.EncodedObject(plumbing.AnyObject, tip.tag.Target); err != nil {
+			return nil, err
+		}
+	}
+
+	switch o.Type() {
+	case plumbing.CommitObject:
+		tip.commit, err = object.DecodeCommit(e.r.Storer, o)
+	case plumbing.BlobObject:
+		tip.blob, err = object.DecodeBlob(o)
+	default:
+		err = fmt.Errorf("reference %s: unsupported %s target", ref.Name(), o.Type())
+	}
+
+	return tip, err
+}
+
+// isExported returns true if the object was already exported.
+func (e *fastExporter) isExported(h plumbing.Hash) bool {
+	_, ok := e.byHash[h]
+	return ok
+}
+
+func (e *fastExporter) exportCommit(c *object.Commit, ref plumbing.ReferenceName) error {
+	var parent *object.Tree
+	if len(c.ParentHashes) != 0 {
+		p, err := e.r.CommitObject(c.ParentHashes[0])
+		if err != nil {
+			return err
+		}
+
+		if parent, err = p.Tree(); err != nil {
+			return err
+		}
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	changes, err := object.DiffTree(parent, tree)
+	if err != nil {
+		return err
+	}
+
+	cmd := &fastimport.Commit{
+		Ref:       ref,
+		Author:    &c.Author,
+		Committer: c.Committer,
+		Message:   c.Message,
+	}
+
+	var modifies []fastimport.FileChange
+	for _, ch := range changes {
+		action, err := ch.Action()
+		if err != nil {
+			return err
+		}
+
+		if action == merkletrie.Delete {
+			cmd.Changes = append(cmd.Changes, &fastimport.FileDelete{Path: ch.From.Name})
+			continue
+		}
+
+		fm, err := e.fileModify(ch.To)
+		if err != nil {
+			return err
+		}
+
+		modifies = append(modifies, fm)
+	}
+
+	cmd.Changes = append(cmd.Changes, modifies...)
+	for i, p := range c.ParentHashes {
+		if i == 0 {
+			cmd.From = e.commitish(p)
+		} else {
+			cmd.Merge = append(cmd.Merge, e.commitish(p))
+		}
+	}
+
+	cmd.Mark = e.mark(c.Hash)
+	e.lastRef[ref] = c.Hash
+	return e.enc.Encode(cmd)
+}
+
+// fileModify returns the filemodify of an entry, exporting its blob if
+// needed.
+func (e *fastExporter) fileModify(ch object.ChangeEntry) (*fastimport.FileModify, error) {
+	entry := ch.TreeEntry
+	fm := &fastimport.FileModify{Mode: entry.Mode, Path: ch.Name}
+	if entry.Mode == filemode.Submodule {
+		fm.DataRef = entry.Hash.String()
+		return fm, nil
+	}
+
+	mark, err := e.exportBlob(entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	fm.DataRef = fmt.Sprintf(":%d", mark)
+	return fm, nil
+}
+
+// exportBlob exports a blob if it was not exported yet, returning its mark.
+func (e *fastExporter) exportBlob(h plumbing.Hash) (int, error) {
+	if mark, ok := e.byHash[h]; ok {
+		return mark, nil
+	}
+
+	b, err := e.r.BlobObject(h)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := readBlob(b)
+	if err != nil {
+		return 0, err
+	}
+
+	mark := e.mark(h)
+	return mark, e.enc.Encode(&fastimport.Blob{Mark: mark, Data: data})
+}
+
+// exportTip exports the annotated tag of a tip, or resets its reference if
+// its commit was not exported on it.
+func (e *fastExporter) exportTip(tip *exportTip) error {
+	target := plumbing.ZeroHash
+	switch {
+	case tip.commit != nil:
+		target = tip.commit.Hash
+	case tip.blob != nil:
+		if _, err := e.exportBlob(tip.blob.Hash); err != nil {
+			return err
+		}
+
+		target = tip.blob.Hash
+	}
+
+	if tip.tag == nil {
+		if e.lastRef[tip.ref.Name()] == target {
+			return nil
+		}
+
+		return e.enc.Encode(&fastimport.Reset{
+			Ref:  tip.ref.Name(),
+			From: e.commitish(target),
+		})
+	}
+
+	if _, ok := e.byHash[tip.tag.Hash]; ok {
+		return nil
+	}
+
+	tagger := tip.tag.Tagger
+	return e.enc.Encode(&fastimport.Tag{
+		Name:    tip.ref.Name().Short(),
+		Mark:    e.mark(tip.tag.Hash),
+		From:    e.commitish(target),
+		Tagger:  &tagger,
+		Message: tip.tag.Message,
+	})
+}
+
+// commitish returns the mark of an object, or its hash if it has none.
+func (e *fastExporter) commitish(h plumbing.Hash) string {
+	if mark, ok := e.byHash[h]; ok {
+		return fmt.Sprintf(":%d", mark)
+	}
+
+	return h.String()
+}
+
+// mark assigns a new mark to an object.
+func (e *fastExporter) mark(h plumbing.Hash) int {
+	mark := e.next
+	e.next++
+	e.marks[mark] = h
+	e.byHash[h] = mark
+	return mark
+}
+
+func readBlob(b *object.Blob) ([]byte, error) {
+	r, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return stdioutil.ReadAll(r)
+}
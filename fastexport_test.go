@@ -0,0 +1,125 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/fastimport"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type FastExportSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&FastExportSuite{})
+
+func (s *FastExportSuite) TestFastExport(c *C) {
+	buf := bytes.NewBuffer(nil)
+	o := &FastExportOptions{References: []plumbing.ReferenceName{
+		plumbing.Master,
+		"refs/heads/branch",
+	}}
+
+	err := s.Repository.FastExport(buf, o)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Count(buf.String(), "\ncommit refs/heads/master\n"), Equals, 8)
+	c.Assert(strings.Count(buf.String(), "\ncommit refs/heads/branch\n"), Equals, 1)
+
+	st := memory.NewStorage()
+	i := fastimport.NewImporter(st)
+	c.Assert(i.Import(buf), IsNil)
+
+	// the commits are exported as they are, so they have the same hashes
+	for _, name := range []plumbing.ReferenceName{plumbing.Master, "refs/heads/branch"} {
+		expected, err := s.Repository.Reference(name, false)
+		c.Assert(err, IsNil)
+
+		ref, err := st.Reference(name)
+		c.Assert(err, IsNil)
+		c.Assert(ref.Hash(), Equals, expected.Hash())
+
+		mark := o.Marks.Next() - 1
+		c.Assert(o.Marks[mark].IsZero(), Equals, false)
+	}
+
+	for mark, h := range o.Marks {
+		c.Assert(i.Marks[mark], Equals, h)
+	}
+}
+
+func (s *FastExportSuite) TestFastExportIncremental(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+	err := r.Storer.SetReference(plumbing.NewHashReference("refs/heads/base",
+		plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")))
+	c.Assert(err, IsNil)
+
+	st := memory.NewStorage()
+	i := fastimport.NewImporter(st)
+
+	buf := bytes.NewBuffer(nil)
+	o := &FastExportOptions{References: []plumbing.ReferenceName{"refs/heads/base"}}
+	c.Assert(r.FastExport(buf, o), IsNil)
+	c.Assert(strings.Count(buf.String(), "\ncommit "), Equals, 7)
+	c.Assert(i.Import(buf), IsNil)
+
+	buf.Reset()
+	o.References = []plumbing.ReferenceName{plumbing.Master}
+	c.Assert(r.FastExport(buf, o), IsNil)
+	c.Assert(strings.Count(buf.String(), "\ncommit "), Equals, 1)
+	c.Assert(strings.Contains(buf.String(), "from :"), Equals, true)
+
+	i.Marks = o.Marks
+	c.Assert(i.Import(buf), IsNil)
+
+	ref, err := st.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	// no commits are exported again, the branch is reset to its mark
+	buf.Reset()
+	c.Assert(r.FastExport(buf, o), IsNil)
+	c.Assert(buf.String(), Matches, "reset refs/heads/master\nfrom :[0-9]+\n\n")
+}
+
+func (s *FastExportSuite) TestFastExportTags(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	_, err := r.CreateTag("annotated", head, &CreateTagOptions{
+		Tagger:  &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1500000000, 0)},
+		Message: "foo\n",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateTag("lightweight", head, nil)
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = r.FastExport(buf, &FastExportOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Matches, "(?s).*\ntag annotated\nmark :[0-9]+\nfrom :[0-9]+\n.*")
+	c.Assert(buf.String(), Matches, "(?s).*\nreset refs/tags/lightweight\nfrom :[0-9]+\n.*")
+
+	st := memory.NewStorage()
+	c.Assert(fastimport.NewImporter(st).Import(buf), IsNil)
+
+	iter, err := r.References()
+	c.Assert(err, IsNil)
+	err = iter.ForEach(func(expected *plumbing.Reference) error {
+		if !expected.Name().IsBranch() && !expected.Name().IsTag() {
+			return nil
+		}
+
+		ref, err := st.Reference(expected.Name())
+		c.Assert(err, IsNil)
+		c.Assert(ref.Hash(), Equals, expected.Hash(), Commentf("%s", expected.Name()))
+		return nil
+	})
+	c.Assert(err, IsNil)
+}
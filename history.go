@@ -0,0 +1,489 @@
+package git
+
+import (
+	"io"
+	"path"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// originalRefPrefix is the prefix of the backups of the rewritten references.
+const originalRefPrefix = "refs/original/"
+
+// RewriteHistory rewrites the history of the given branches and tags, like
+// `git filter-branch`, returning the hashes of the rewritten commits by the
+// hashes of the original ones. The commits dropped map to the rewritten
+// commit replacing them, or to the zero hash if none.
+//
+// The commits are rewritten in topological order, parents first, applying
+// the filters of the options. The trees and blobs are rewritten once for
+// each path, so the subtrees not changed between commits are reused, and
+// the commits that do not change keep their hash. The annotated tags are
+// rewritten to point to the rewritten commits. The worktree and the index
+// are not updated.
+func (r *Repository) RewriteHistory(o *RewriteHistoryOptions) (map[plumbing.Hash]plumbing.Hash, error) {
+	refs, err := r.rewriteReferences(o.References)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &historyRewriter{
+		r:       r,
+		o:       o,
+		trees:   make(map[pathHash]plumbing.Hash),
+		blobs:   make(map[pathHash]plumbing.Hash),
+		commits: make(map[plumbing.Hash]plumbing.Hash),
+	}
+
+	var tips []*object.Commit
+	for _, ref := range refs {
+		c, err := r.peelToCommit(ref.Hash())
+		if err == ErrUnableToResolveCommit {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		tips = append(tips, c)
+	}
+
+	err = walkCommitsTopologically(r, tips, h.isRewritten, func(_ int, c *object.Commit) error {
+		return h.rewriteCommit(c)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range refs {
+		if err := h.updateReference(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.commits, nil
+}
+
+// rewriteReferences returns the references with the given names, or all the
+// branches and tags if none is given.
+func (r *Repository) rewriteReferences(names []plumbing.ReferenceName) ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	for _, name := range names {
+		ref, err := r.Reference(name, true)
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, ref)
+	}
+
+	if len(names) != 0 {
+		return refs, nil
+	}
+
+	iter, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && (ref.Name().IsBranch() || ref.Name().IsTag()) {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name() < refs[j].Name()
+	})
+
+	return refs, nil
+}
+
+// peelToCommit returns the commit of a hash, peeling the tags.
+func (r *Repository) peelToCommit(h plumbing.Hash) (*object.Commit, error) {
+	h, err := r.resolveToCommitHash(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(h)
+}
+
+// walkCommitsTopologically calls fn for every commit reachable from the
+// tips, once and after its parents, with the index of the first tip reaching
+// it. The commits for which skip returns true, and their ancestors, are not
+// walked.
+func walkCommitsTopologically(r *Repository, tips []*object.Commit,
+	skip func(plumbing.Hash) bool, fn func(tip int, c *object.Commit) error) error {
+
+	type frame struct {
+		commit *object.Commit
+		parent int
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	for i, tip := range tips {
+		if seen[tip.Hash] || skip(tip.Hash) {
+			continue
+		}
+
+		seen[tip.Hash] = true
+		stack := []*frame{{commit: tip}}
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			if f.parent == len(f.commit.ParentHashes) {
+				if err := fn(i, f.commit); err != nil {
+					return err
+				}
+
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			h := f.commit.ParentHashes[f.parent]
+			f.parent++
+			if seen[h] || skip(h) {
+				continue
+			}
+
+			c, err := r.CommitObject(h)
+			if err != nil {
+				return err
+			}
+
+			seen[h] = true
+			stack = append(stack, &frame{commit: c})
+		}
+	}
+
+	return nil
+}
+
+type pathHash struct {
+	path string
+	hash plumbing.Hash
+}
+
+type historyRewriter struct {
+	r *Repository
+	o *RewriteHistoryOptions
+	// trees and blobs are the rewritten trees and blobs by its path and
+	// original hash, the dropped trees map to the zero hash.
+	trees   map[pathHash]plumbing.Hash
+	blobs   map[pathHash]plumbing.Hash
+	commits map[plumbing.Hash]plumbing.Hash
+}
+
+func (h *historyRewriter) isRewritten(c plumbing.Hash) bool {
+	_, ok := h.commits[c]
+	return ok
+}
+
+func (h *historyRewriter) rewriteCommit(c *object.Commit) error {
+	nc := *c
+	nc.ParentHashes = nil
+	for _, p := range c.ParentHashes {
+		np := h.commits[p]
+		if np.IsZero() || containsHash(nc.ParentHashes, np) {
+			continue
+		}
+
+		nc.ParentHashes = append(nc.ParentHashes, np)
+	}
+
+	tree, err := h.rewriteTree("", c.TreeHash)
+	if err != nil {
+		return err
+	}
+
+	nc.TreeHash = tree
+	if h.o.CommitFilter != nil {
+		if err := h.o.CommitFilter(&nc); err != nil {
+			return err
+		}
+	}
+
+	if h.o.PruneEmpty && len(nc.ParentHashes) <= 1 {
+		empty, err := h.isEmpty(&nc)
+		if err != nil {
+			return err
+		}
+
+		if empty {
+			h.commits[c.Hash] = plumbing.ZeroHash
+			if len(nc.ParentHashes) == 1 {
+				h.commits[c.Hash] = nc.ParentHashes[0]
+			}
+
+			return nil
+		}
+	}
+
+	hash, err := h.storeCommit(c, &nc)
+	if err != nil {
+		return err
+	}
+
+	h.commits[c.Hash] = hash
+	return nil
+}
+
+// isEmpty returns true if the commit does not change the tree of its parent,
+// or it has an empty tree if it has no parents.
+func (h *historyRewriter) isEmpty(c *object.Commit) (bool, error) {
+	if len(c.ParentHashes) == 0 {
+		t, err := h.r.TreeObject(c.TreeHash)
+		if err != nil {
+			return false, err
+		}
+
+		return len(t.Entries) == 0, nil
+	}
+
+	p, err := h.r.CommitObject(c.ParentHashes[0])
+	if err != nil {
+		return false, err
+	}
+
+	return p.TreeHash == c.TreeHash, nil
+}
+
+// storeCommit stores the rewritten commit, unless it is the same as the
+// original one. The signature of the changed commits is dropped.
+func (h *historyRewriter) storeCommit(c, nc *object.Commit) (plumbing.Hash, error) {
+	o := &plumbing.MemoryObject{}
+	if err := nc.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if o.Hash() == c.Hash {
+		return c.Hash, nil
+	}
+
+	nc.PGPSignature = ""
+	return h.storeObject(nc)
+}
+
+// rewriteTree returns the rewritten tree of a directory, or the zero hash if
+// it is dropped because it is empty.
+func (h *historyRewriter) rewriteTree(dir string, hash plumbing.Hash) (plumbing.Hash, error) {
+	if h.o.TreeEntryFilter == nil && h.o.BlobFilter == nil {
+		return hash, nil
+	}
+
+	key := pathHash{dir, hash}
+	if nh, ok := h.trees[key]; ok {
+		return nh, nil
+	}
+
+	t, err := h.r.TreeObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	nt := &object.Tree{}
+	for _, e := range t.Entries {
+		ne, err := h.rewriteEntry(path.Join(dir, e.Name), e)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if ne != nil {
+			nt.Entries = append(nt.Entries, *ne)
+		}
+	}
+
+	nh := hash
+	switch {
+	case len(nt.Entries) == 0 && dir != "":
+		nh = plumbing.ZeroHash
+	case !equalTreeEntries(t.Entries, nt.Entries):
+		sort.Sort(sortableEntries(nt.Entries))
+		if nh, err = h.storeObject(nt); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	h.trees[key] = nh
+	return nh, nil
+}
+
+func (h *historyRewriter) rewriteEntry(fullpath string, e object.TreeEntry) (*object.TreeEntry, error) {
+	ne := &e
+	if h.o.TreeEntryFilter != nil {
+		var err error
+		if ne, err = h.o.TreeEntryFilter(fullpath, e); err != nil || ne == nil {
+			return nil, err
+		}
+
+		fullpath = path.Join(path.Dir(fullpath), ne.Name)
+	}
+
+	var err error
+	switch ne.Mode {
+	case filemode.Dir:
+		if ne.Hash, err = h.rewriteTree(fullpath, ne.Hash); err != nil || ne.Hash.IsZero() {
+			return nil, err
+		}
+	case filemode.Submodule:
+	default:
+		if ne.Hash, err = h.rewriteBlob(fullpath, ne.Hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return ne, nil
+}
+
+func (h *historyRewriter) rewriteBlob(fullpath string, hash plumbing.Hash) (plumbing.Hash, error) {
+	if h.o.BlobFilter == nil {
+		return hash, nil
+	}
+
+	key := pathHash{fullpath, hash}
+	if nh, ok := h.blobs[key]; ok {
+		return nh, nil
+	}
+
+	b, err := h.r.BlobObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	content, err := h.o.BlobFilter(fullpath, b)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	nh := hash
+	if content != nil {
+		if nh, err = h.storeBlob(content); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	h.blobs[key] = nh
+	return nh, nil
+}
+
+// updateReference points a reference to its rewritten commit, rewriting the
+// annotated tag it points to, if any.
+func (h *historyRewriter) updateReference(ref *plumbing.Reference) error {
+	target, err := h.rewriteTag(ref.Hash())
+	if err != nil || target == ref.Hash() {
+		return err
+	}
+
+	if h.o.Backup {
+		backup := plumbing.ReferenceName(originalRefPrefix + ref.Name().String())
+		err := h.r.Storer.SetReference(plumbing.NewHashReference(backup, ref.Hash()))
+		if err != nil {
+			return err
+		}
+	}
+
+	if target.IsZero() {
+		return h.r.Storer.RemoveReference(ref.Name())
+	}
+
+	return h.r.Storer.SetReference(plumbing.NewHashReference(ref.Name(), target))
+}
+
+// rewriteTag returns the rewritten object of a reference, a commit or an
+// annotated tag pointing to it.
+func (h *historyRewriter) rewriteTag(hash plumbing.Hash) (plumbing.Hash, error) {
+	if nh, ok := h.commits[hash]; ok {
+		return nh, nil
+	}
+
+	t, err := h.r.TagObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return hash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	target, err := h.rewriteTag(t.Target)
+	if err != nil || target == t.Target {
+		return hash, err
+	}
+
+	if target.IsZero() {
+		return plumbing.ZeroHash, nil
+	}
+
+	nt := *t
+	nt.Target = target
+	nt.PGPSignature = ""
+	return h.storeObject(&nt)
+}
+
+func (h *historyRewriter) storeBlob(r io.Reader) (plumbing.Hash, error) {
+	o := h.r.Storer.NewEncodedObject()
+	o.SetType(plumbing.BlobObject)
+
+	w, err := o.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	o.SetSize(n)
+	return h.r.Storer.SetEncodedObject(o)
+}
+
+func (h *historyRewriter) storeObject(obj interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	o := h.r.Storer.NewEncodedObject()
+	if err := obj.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h.r.Storer.SetEncodedObject(o)
+}
+
+func equalTreeEntries(a, b []object.TreeEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsHash(hashes []plumbing.Hash, h plumbing.Hash) bool {
+	for _, hash := range hashes {
+		if hash == h {
+			return true
+		}
+	}
+
+	return false
+}
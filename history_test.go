@@ -0,0 +1,208 @@
+package git
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type HistorySuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&HistorySuite{})
+
+var (
+	basicHead = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	basicBase = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+func (s *HistorySuite) TestRewriteHistoryUnchanged(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	commits, err := r.RewriteHistory(&RewriteHistoryOptions{Backup: true})
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 9)
+	for old, h := range commits {
+		c.Assert(h, Equals, old)
+	}
+
+	_, err = r.Reference("refs/original/refs/heads/master", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *HistorySuite) TestRewriteHistoryDropPath(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	commits, err := r.RewriteHistory(&RewriteHistoryOptions{
+		References: []plumbing.ReferenceName{plumbing.Master},
+		TreeEntryFilter: func(path string, e object.TreeEntry) (*object.TreeEntry, error) {
+			if path == "LICENSE" {
+				return nil, nil
+			}
+
+			return &e, nil
+		},
+		Backup: true,
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 8)
+
+	head, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, commits[basicHead])
+	c.Assert(head.Hash(), Not(Equals), basicHead)
+
+	backup, err := r.Reference("refs/original/refs/heads/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(backup.Hash(), Equals, basicHead)
+
+	iter, err := r.Log(&LogOptions{From: head.Hash()})
+	c.Assert(err, IsNil)
+
+	count := 0
+	err = iter.ForEach(func(commit *object.Commit) error {
+		count++
+		_, err := commit.File("LICENSE")
+		c.Assert(err, Equals, object.ErrFileNotFound)
+		c.Assert(commit.PGPSignature, Equals, "")
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 8)
+
+	// the branch not rewritten is not changed
+	branch, err := r.Reference("refs/heads/branch", false)
+	c.Assert(err, IsNil)
+	c.Assert(branch.Hash().String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+}
+
+func (s *HistorySuite) TestRewriteHistoryPruneEmpty(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	commits, err := r.RewriteHistory(&RewriteHistoryOptions{
+		References: []plumbing.ReferenceName{plumbing.Master},
+		TreeEntryFilter: func(path string, e object.TreeEntry) (*object.TreeEntry, error) {
+			if path == "vendor" {
+				return nil, nil
+			}
+
+			return &e, nil
+		},
+		PruneEmpty: true,
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(commits[basicHead], Equals, basicBase)
+	c.Assert(commits[basicBase], Equals, basicBase)
+
+	head, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, basicBase)
+
+	_, err = r.Reference("refs/original/refs/heads/master", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *HistorySuite) TestRewriteHistoryBlobFilter(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	var paths []string
+	commits, err := r.RewriteHistory(&RewriteHistoryOptions{
+		References: []plumbing.ReferenceName{plumbing.Master},
+		BlobFilter: func(path string, b *object.Blob) (io.Reader, error) {
+			paths = append(paths, path)
+			if path != "CHANGELOG" {
+				return nil, nil
+			}
+
+			return strings.NewReader("redacted\n"), nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// every blob is filtered once by path
+	c.Assert(paths, HasLen, 9)
+
+	old, err := r.CommitObject(basicHead)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(commits[basicHead])
+	c.Assert(err, IsNil)
+
+	f, err := commit.File("CHANGELOG")
+	c.Assert(err, IsNil)
+	content, err := f.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, "redacted\n")
+
+	// the subtrees not changed are reused
+	oldTree, err := old.Tree()
+	c.Assert(err, IsNil)
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+
+	oldGo, err := oldTree.FindEntry("go")
+	c.Assert(err, IsNil)
+	newGo, err := tree.FindEntry("go")
+	c.Assert(err, IsNil)
+	c.Assert(newGo.Hash, Equals, oldGo.Hash)
+	c.Assert(tree.Hash, Not(Equals), oldTree.Hash)
+
+	// the commits before the changelog was created are not changed
+	c.Assert(commits[plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")], Equals,
+		plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"))
+}
+
+func (s *HistorySuite) TestRewriteHistoryCommitFilter(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	_, err := r.CreateTag("annotated", basicHead, &CreateTagOptions{
+		Tagger:  &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1500000000, 0)},
+		Message: "foo\n",
+	})
+	c.Assert(err, IsNil)
+
+	commits, err := r.RewriteHistory(&RewriteHistoryOptions{
+		References: []plumbing.ReferenceName{plumbing.Master, "refs/tags/annotated"},
+		CommitFilter: func(commit *object.Commit) error {
+			if commit.Author.Email == "mcuadros@gmail.com" {
+				commit.Author.Email = "maximo@example.com"
+			}
+
+			commit.Message = strings.ToUpper(commit.Message)
+			return nil
+		},
+		Backup: true,
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(commits[basicHead])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "VENDOR STUFF\n")
+	c.Assert(commit.Author.Email, Equals, "maximo@example.com")
+
+	parent, err := commit.Parent(0)
+	c.Assert(err, IsNil)
+	c.Assert(parent.Hash, Equals, commits[basicBase])
+	c.Assert(parent.Message, Equals, "SOME CODE\n")
+
+	ref, err := r.Reference("refs/tags/annotated", false)
+	c.Assert(err, IsNil)
+
+	tag, err := r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.Name, Equals, "annotated")
+	c.Assert(tag.Target, Equals, commit.Hash)
+
+	backup, err := r.Reference("refs/original/refs/tags/annotated", false)
+	c.Assert(err, IsNil)
+	c.Assert(backup.Hash(), Not(Equals), ref.Hash())
+}
@@ -130,7 +130,7 @@ func (s *ParserSuite) TestParseWithValidExpression(c *C) {
 		},
 		"v0.99.8^{}": []Revisioner{
 			Ref("v0.99.8"),
-			CaretType{"tag"},
+			CaretType{""},
 		},
 		"HEAD^{/fix nasty bug}": []Revisioner{
 			Ref("HEAD"),
@@ -249,7 +249,7 @@ func (s *ParserSuite) TestParseCaretWithValidExpression(c *C) {
 	datas := map[string]Revisioner{
 		"":                    CaretPath{1},
 		"2":                   CaretPath{2},
-		"{}":                  CaretType{"tag"},
+		"{}":                  CaretType{""},
 		"{commit}":            CaretType{"commit"},
 		"{tree}":              CaretType{"tree"},
 		"{blob}":              CaretType{"blob"},
@@ -0,0 +1,483 @@
+package git
+
+import (
+	"errors"
+	"io"
+	stdioutil "io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// DefaultNotesRef is the notes reference used when none is given.
+const DefaultNotesRef plumbing.ReferenceName = "refs/notes/commits"
+
+var (
+	// ErrNoteNotFound is returned when an object has no note.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrNoteExists is returned by AddNote when the object already has a
+	// note and the strategy is NotesFailOnExisting.
+	ErrNoteExists = errors.New("note already exists")
+)
+
+// Note is a note attached to an object.
+type Note struct {
+	// Object is the hash of the object the note is attached to.
+	Object plumbing.Hash
+	// Hash is the hash of the blob with the content of the note.
+	Hash plumbing.Hash
+	// Message is the content of the note.
+	Message string
+}
+
+// Note returns the note attached to the object h in the notes reference
+// ref, DefaultNotesRef if empty. If the object has no note ErrNoteNotFound
+// is returned.
+func (r *Repository) Note(ref plumbing.ReferenceName, h plumbing.Hash) (*Note, error) {
+	t, err := r.notesTree(notesRef(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	if t == nil {
+		return nil, ErrNoteNotFound
+	}
+
+	blob, err := findNote(t, h.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.readNote(h, blob)
+}
+
+// AddNote attaches a note with the given message to the object h, in the
+// notes reference ref, DefaultNotesRef if empty. If the object does not exist
+// plumbing.ErrObjectNotFound is returned. A commit with the updated
+// notes tree is created on the notes reference, its hash is returned.
+//
+// The notes are stored as git does, in a tree with a blob for each note
+// named after the hex hash of the object, split in directories of two
+// characters (fan-out) when the number of notes is large.
+func (r *Repository) AddNote(ref plumbing.ReferenceName, h plumbing.Hash, message string,
+	o *AddNoteOptions) (plumbing.Hash, error) {
+
+	if err := o.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := r.Storer.HasEncodedObject(h); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref = notesRef(ref)
+	nt, err := r.readNotes(ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+
+	if existing, ok := nt.notes[h]; ok {
+		switch o.Strategy {
+		case NotesOverwrite:
+		case NotesConcatenate:
+			n, err := r.readNote(h, existing)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			message = n.Message + "\n" + message
+		default:
+			return plumbing.ZeroHash, ErrNoteExists
+		}
+	}
+
+	blob, err := r.storeNoteBlob(message)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	nt.notes[h] = blob
+	return r.commitNotes(ref, nt, "Notes added by 'git notes add'\n", o.Author, o.Committer)
+}
+
+// RemoveNote removes the note attached to the object h, in the notes
+// reference ref, DefaultNotesRef if empty. A commit with the updated notes
+// tree is created on the notes reference, its hash is returned. If the
+// object has no note ErrNoteNotFound is returned.
+func (r *Repository) RemoveNote(ref plumbing.ReferenceName, h plumbing.Hash,
+	o *RemoveNoteOptions) (plumbing.Hash, error) {
+
+	if err := o.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref = notesRef(ref)
+	nt, err := r.readNotes(ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, ok := nt.notes[h]; !ok {
+		return plumbing.ZeroHash, ErrNoteNotFound
+	}
+
+	delete(nt.notes, h)
+	return r.commitNotes(ref, nt, "Notes removed by 'git notes remove'\n", o.Author, o.Committer)
+}
+
+// IterNotes returns an iterator over the notes of the notes reference ref,
+// DefaultNotesRef if empty, sorted by the hash of the annotated objects.
+func (r *Repository) IterNotes(ref plumbing.ReferenceName) (*NoteIter, error) {
+	nt, err := r.readNotes(notesRef(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	iter := &NoteIter{r: r}
+	for h := range nt.notes {
+		iter.objects = append(iter.objects, h)
+	}
+
+	sort.Slice(iter.objects, func(i, j int) bool {
+		return iter.objects[i].String() < iter.objects[j].String()
+	})
+
+	iter.blobs = nt.notes
+	return iter, nil
+}
+
+// NoteIter is an iterator over the notes of a notes reference.
+type NoteIter struct {
+	r       *Repository
+	objects []plumbing.Hash
+	blobs   map[plumbing.Hash]plumbing.Hash
+	pos     int
+}
+
+// Next returns the next note, io.EOF is returned at the end.
+func (iter *NoteIter) Next() (*Note, error) {
+	if iter.pos >= len(iter.objects) {
+		return nil, io.EOF
+	}
+
+	h := iter.objects[iter.pos]
+	iter.pos++
+	return iter.r.readNote(h, iter.blobs[h])
+}
+
+// ForEach calls cb for each note, until an error happens or the end of the
+// iterator is reached. If storer.ErrStop is returned by cb the iteration
+// stops without error.
+func (iter *NoteIter) ForEach(cb func(*Note) error) error {
+	defer iter.Close()
+	for {
+		n, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := cb(n); err != nil {
+			if err == storer.ErrStop {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// Close releases the resources of the iterator.
+func (iter *NoteIter) Close() {
+	iter.pos = len(iter.objects)
+}
+
+// notesRef returns the full name of a notes reference.
+func notesRef(ref plumbing.ReferenceName) plumbing.ReferenceName {
+	if ref == "" {
+		return DefaultNotesRef
+	}
+
+	if !ref.IsNote() {
+		return plumbing.NewNoteReferenceName(ref.String())
+	}
+
+	return ref
+}
+
+// notesTree returns the tree of the notes reference, nil if it does not
+// exist.
+func (r *Repository) notesTree(ref plumbing.ReferenceName) (*object.Tree, error) {
+	c, err := r.notesCommit(ref)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	return c.Tree()
+}
+
+func (r *Repository) notesCommit(ref plumbing.ReferenceName) (*object.Commit, error) {
+	h, err := r.Reference(ref, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(h.Hash())
+}
+
+// findNote returns the blob of the note of the object with the given hex
+// hash, looking in the fan-out directories.
+func findNote(t *object.Tree, hex string) (plumbing.Hash, error) {
+	for _, e := range t.Entries {
+		if e.Mode != filemode.Dir {
+			if e.Name == hex {
+				return e.Hash, nil
+			}
+
+			continue
+		}
+
+		if len(e.Name) != 2 || !strings.HasPrefix(hex, e.Name) {
+			continue
+		}
+
+		sub, err := t.Tree(e.Name)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		h, err := findNote(sub, hex[2:])
+		if err != ErrNoteNotFound {
+			return h, err
+		}
+	}
+
+	return plumbing.ZeroHash, ErrNoteNotFound
+}
+
+func (r *Repository) readNote(object, blob plumbing.Hash) (*Note, error) {
+	b, err := r.BlobObject(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rd.Close()
+	content, err := stdioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Note{Object: object, Hash: blob, Message: string(content)}, nil
+}
+
+// notesTreeEntries are the content of a notes tree, the notes and the rest
+// of entries, kept as they are.
+type notesTreeEntries struct {
+	parent plumbing.Hash
+	notes  map[plumbing.Hash]plumbing.Hash
+	others map[string]object.TreeEntry
+}
+
+func (r *Repository) readNotes(ref plumbing.ReferenceName) (*notesTreeEntries, error) {
+	nt := &notesTreeEntries{
+		notes:  make(map[plumbing.Hash]plumbing.Hash),
+		others: make(map[string]object.TreeEntry),
+	}
+
+	c, err := r.notesCommit(ref)
+	if err != nil || c == nil {
+		return nt, err
+	}
+
+	nt.parent = c.Hash
+	t, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return nt, nt.read(t, "", "")
+}
+
+func (nt *notesTreeEntries) read(t *object.Tree, dir, prefix string) error {
+	for _, e := range t.Entries {
+		hex := prefix + e.Name
+		if e.Mode == filemode.Dir && len(e.Name) == 2 && isHex(hex) && len(hex) < 40 {
+			sub, err := t.Tree(e.Name)
+			if err != nil {
+				return err
+			}
+
+			if err := nt.read(sub, path.Join(dir, e.Name), hex); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if e.Mode != filemode.Dir && len(hex) == 40 && isHex(hex) {
+			nt.notes[plumbing.NewHash(hex)] = e.Hash
+			continue
+		}
+
+		nt.others[path.Join(dir, e.Name)] = e
+	}
+
+	return nil
+}
+
+// fanout returns the number of directory levels of the notes, one for each
+// 256 times the number of notes grows past 256, as git does.
+func (nt *notesTreeEntries) fanout() int {
+	fanout := 0
+	for n := len(nt.notes); n > 256; n /= 256 {
+		fanout++
+	}
+
+	return fanout
+}
+
+func (r *Repository) commitNotes(ref plumbing.ReferenceName, nt *notesTreeEntries,
+	msg string, author, committer *object.Signature) (plumbing.Hash, error) {
+
+	entries := make(map[string]object.TreeEntry, len(nt.notes)+len(nt.others))
+	for p, e := range nt.others {
+		entries[p] = e
+	}
+
+	fanout := nt.fanout()
+	for h, blob := range nt.notes {
+		hex := h.String()
+		var parts []string
+		for i := 0; i < fanout; i++ {
+			parts = append(parts, hex[i*2:i*2+2])
+		}
+
+		parts = append(parts, hex[fanout*2:])
+		entries[strings.Join(parts, "/")] = object.TreeEntry{Mode: filemode.Regular, Hash: blob}
+	}
+
+	tree, err := r.storeNotesTree(entries)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	c := &object.Commit{
+		Author:    *author,
+		Committer: *committer,
+		Message:   msg,
+		TreeHash:  tree,
+	}
+
+	if !nt.parent.IsZero() {
+		c.ParentHashes = []plumbing.Hash{nt.parent}
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := c.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	newRef := plumbing.NewHashReference(ref, h)
+	if nt.parent.IsZero() {
+		return h, r.Storer.SetReference(newRef)
+	}
+
+	oldRef := plumbing.NewHashReference(ref, nt.parent)
+	return h, r.Storer.CheckAndSetReference(newRef, oldRef)
+}
+
+// storeNotesTree stores the trees with the given entries by path.
+func (r *Repository) storeNotesTree(entries map[string]object.TreeEntry) (plumbing.Hash, error) {
+	t := &object.Tree{}
+	dirs := make(map[string]map[string]object.TreeEntry)
+	for p, e := range entries {
+		i := strings.IndexByte(p, '/')
+		if i < 0 {
+			e.Name = p
+			t.Entries = append(t.Entries, e)
+			continue
+		}
+
+		dir := p[:i]
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]object.TreeEntry)
+		}
+
+		dirs[dir][p[i+1:]] = e
+	}
+
+	for dir, sub := range dirs {
+		h, err := r.storeNotesTree(sub)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		t.Entries = append(t.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: h})
+	}
+
+	sort.Sort(sortableEntries(t.Entries))
+
+	o := r.Storer.NewEncodedObject()
+	if err := t.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.Storer.SetEncodedObject(o)
+}
+
+func (r *Repository) storeNoteBlob(message string) (plumbing.Hash, error) {
+	o := r.Storer.NewEncodedObject()
+	o.SetType(plumbing.BlobObject)
+	o.SetSize(int64(len(message)))
+
+	w, err := o.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := w.Write([]byte(message)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.Storer.SetEncodedObject(o)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,235 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type NotesSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&NotesSuite{})
+
+var noteSignature = &object.Signature{
+	Name:  "foo",
+	Email: "foo@foo.foo",
+	When:  time.Unix(1500000000, 0),
+}
+
+func (s *NotesSuite) TestAddNote(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	h, err := r.AddNote("", basicHead, "build: ok", &AddNoteOptions{Author: noteSignature})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Notes added by 'git notes add'\n")
+	c.Assert(commit.NumParents(), Equals, 0)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 1)
+	c.Assert(tree.Entries[0].Name, Equals, basicHead.String())
+
+	note, err := r.Note("", basicHead)
+	c.Assert(err, IsNil)
+	c.Assert(note.Object, Equals, basicHead)
+	c.Assert(note.Hash, Equals, tree.Entries[0].Hash)
+	c.Assert(note.Message, Equals, "build: ok\n")
+
+	_, err = r.Note("", basicBase)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	_, err = r.Note("other", basicHead)
+	c.Assert(err, Equals, ErrNoteNotFound)
+}
+
+func (s *NotesSuite) TestAddNoteStrategies(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+	o := &AddNoteOptions{Author: noteSignature}
+
+	_, err := r.AddNote("ci", basicHead, "foo\n", o)
+	c.Assert(err, IsNil)
+
+	_, err = r.AddNote("ci", basicHead, "bar\n", o)
+	c.Assert(err, Equals, ErrNoteExists)
+
+	o.Strategy = NotesConcatenate
+	_, err = r.AddNote("ci", basicHead, "bar\n", o)
+	c.Assert(err, IsNil)
+
+	note, err := r.Note("refs/notes/ci", basicHead)
+	c.Assert(err, IsNil)
+	c.Assert(note.Message, Equals, "foo\n\nbar\n")
+
+	o.Strategy = NotesOverwrite
+	h, err := r.AddNote("refs/notes/ci", basicHead, "qux\n", o)
+	c.Assert(err, IsNil)
+
+	note, err = r.Note("ci", basicHead)
+	c.Assert(err, IsNil)
+	c.Assert(note.Message, Equals, "qux\n")
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 1)
+}
+
+func (s *NotesSuite) TestAddNoteErrors(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	_, err := r.AddNote("", basicHead, "foo", &AddNoteOptions{})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	_, err = r.AddNote("", plumbing.NewHash("0000000000000000000000000000000000000001"), "foo",
+		&AddNoteOptions{Author: noteSignature})
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *NotesSuite) TestRemoveNote(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	_, err := r.AddNote("", basicHead, "foo", &AddNoteOptions{Author: noteSignature})
+	c.Assert(err, IsNil)
+	_, err = r.AddNote("", basicBase, "bar", &AddNoteOptions{Author: noteSignature})
+	c.Assert(err, IsNil)
+
+	h, err := r.RemoveNote("", basicHead, &RemoveNoteOptions{Author: noteSignature})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Notes removed by 'git notes remove'\n")
+
+	_, err = r.Note("", basicHead)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	note, err := r.Note("", basicBase)
+	c.Assert(err, IsNil)
+	c.Assert(note.Message, Equals, "bar\n")
+
+	_, err = r.RemoveNote("", basicHead, &RemoveNoteOptions{Author: noteSignature})
+	c.Assert(err, Equals, ErrNoteNotFound)
+}
+
+func (s *NotesSuite) TestIterNotes(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	_, err := r.AddNote("", basicHead, "foo", &AddNoteOptions{Author: noteSignature})
+	c.Assert(err, IsNil)
+	_, err = r.AddNote("", basicBase, "bar", &AddNoteOptions{Author: noteSignature})
+	c.Assert(err, IsNil)
+
+	iter, err := r.IterNotes("")
+	c.Assert(err, IsNil)
+
+	var notes []*Note
+	err = iter.ForEach(func(n *Note) error {
+		notes = append(notes, n)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(notes, HasLen, 2)
+	c.Assert(notes[0].Object, Equals, basicHead)
+	c.Assert(notes[0].Message, Equals, "foo\n")
+	c.Assert(notes[1].Object, Equals, basicBase)
+	c.Assert(notes[1].Message, Equals, "bar\n")
+
+	iter, err = r.IterNotes("")
+	c.Assert(err, IsNil)
+
+	count := 0
+	err = iter.ForEach(func(n *Note) error {
+		count++
+		return storer.ErrStop
+	})
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+
+	iter, err = r.IterNotes("missing")
+	c.Assert(err, IsNil)
+	_, err = iter.Next()
+	c.Assert(err, NotNil)
+}
+
+func (s *NotesSuite) TestNotesFanout(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	var objects []plumbing.Hash
+	for i := 0; i < 300; i++ {
+		h, err := r.storeNoteBlob(fmt.Sprintf("object %d", i))
+		c.Assert(err, IsNil)
+		objects = append(objects, h)
+	}
+
+	// a notes tree with fan-out and other files, as other tools write it
+	readme, err := r.storeNoteBlob("notes")
+	c.Assert(err, IsNil)
+
+	hex := objects[0].String()
+	tree, err := r.storeNotesTree(map[string]object.TreeEntry{
+		hex[:2] + "/" + hex[2:]: {Mode: filemode.Regular, Hash: readme},
+		"README":                {Mode: filemode.Regular, Hash: readme},
+	})
+	c.Assert(err, IsNil)
+
+	commit := &object.Commit{Author: *noteSignature, Committer: *noteSignature, TreeHash: tree}
+	obj := r.Storer.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+	h, err := r.Storer.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference(DefaultNotesRef, h)), IsNil)
+
+	note, err := r.Note("", objects[0])
+	c.Assert(err, IsNil)
+	c.Assert(note.Message, Equals, "notes")
+
+	for _, h := range objects[1:] {
+		_, err := r.AddNote("", h, h.String(), &AddNoteOptions{Author: noteSignature})
+		c.Assert(err, IsNil)
+	}
+
+	notes, err := r.notesTree(DefaultNotesRef)
+	c.Assert(err, IsNil)
+
+	// 300 notes are split in one level of directories
+	_, err = notes.FindEntry("README")
+	c.Assert(err, IsNil)
+	_, err = notes.FindEntry(hex[:2] + "/" + hex[2:])
+	c.Assert(err, IsNil)
+
+	for _, e := range notes.Entries {
+		c.Assert(e.Mode == filemode.Dir || e.Name == "README", Equals, true, Commentf("%s", e.Name))
+	}
+
+	iter, err := r.IterNotes("")
+	c.Assert(err, IsNil)
+
+	count := 0
+	c.Assert(iter.ForEach(func(n *Note) error {
+		count++
+		return nil
+	}), IsNil)
+	c.Assert(count, Equals, 300)
+
+	note, err = r.Note("", objects[299])
+	c.Assert(err, IsNil)
+	c.Assert(note.Message, Equals, objects[299].String()+"\n")
+}
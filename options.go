@@ -2,12 +2,16 @@ package git
 
 import (
 	"errors"
+	"io"
+	"path"
 	"regexp"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/openpgp"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/fastimport"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
@@ -318,6 +322,8 @@ const (
 	LogOrderDFSPost
 	LogOrderBSF
 	LogOrderCommitterTime
+	LogOrderTopo
+	LogOrderAuthorTime
 )
 
 // LogOptions describes how a log action should be performed.
@@ -330,16 +336,80 @@ type LogOptions struct {
 	// The default traversal algorithm is Depth-first search
 	// set Order=LogOrderCommitterTime for ordering by committer time (more compatible with `git log`)
 	// set Order=LogOrderBSF for Breadth-first search
+	// set Order=LogOrderTopo for showing no parent before all its children (`git log --topo-order`)
+	// set Order=LogOrderAuthorTime for the same, but otherwise by author time (`git log --author-date-order`)
 	Order LogOrder
 
 	// Show only those commits in which the specified file was inserted/updated.
-	// It is equivalent to running `git log -- <file-name>`.
+	// It is equivalent to running `git log -- <file-name>`, simplifying the
+	// history as git does by default: a merge with the same file as one of
+	// its parents is not shown, and only that parent is followed.
 	FileName *string
 
 	// Pretend as if all the refs in refs/, along with HEAD, are listed on the command line as <commit>.
 	// It is equivalent to running `git log --all`.
 	// If set on true, the From option will be ignored.
 	All bool
+
+	// Include are more commits to start the log from, besides From, as when
+	// several revisions are given to `git log`. HEAD is not used if Include
+	// is set and From is not.
+	Include []plumbing.Hash
+
+	// Exclude excludes the commits reachable from any of the given commits,
+	// as `git log ^<rev>` does. The RevisionRange returned by
+	// Repository.ResolveRange holds the Include and Exclude commits of ranges
+	// such as `A..B`.
+	Exclude []plumbing.Hash
+
+	// Show only those commits changing any of the given paths, a path
+	// matching also the files under it if it is a directory. It is
+	// equivalent to running `git log -- <path>...`, and can be combined
+	// with FileName. The history is simplified as with FileName.
+	PathSpecs []string
+
+	// Show only the commits with a committer date more recent than Since,
+	// or equal to it. It is equivalent to running `git log --since`. With
+	// Order=LogOrderCommitterTime the walk stops at the first older commit.
+	Since *time.Time
+
+	// Show only the commits with a committer date older than Until, or equal
+	// to it. It is equivalent to running `git log --until`.
+	Until *time.Time
+
+	// Show only the commits whose author, formatted as `Name <email>`,
+	// matches the regular expression. It is equivalent to running
+	// `git log --author`.
+	Author *regexp.Regexp
+
+	// Show only the commits whose committer, formatted as `Name <email>`,
+	// matches the regular expression. It is equivalent to running
+	// `git log --committer`.
+	Committer *regexp.Regexp
+
+	// Show only the commits whose message matches any of the regular
+	// expressions. It is equivalent to running `git log --grep`.
+	Grep []*regexp.Regexp
+
+	// Follow only the first parent of the merge commits. It is equivalent
+	// to running `git log --first-parent`.
+	FirstParent bool
+
+	// Hide the commits with more than one parent. It is equivalent to
+	// running `git log --no-merges`.
+	NoMerges bool
+
+	// Show only the commits with more than one parent. It is equivalent to
+	// running `git log --merges`.
+	MergesOnly bool
+
+	// Skip the given number of commits before starting to show them. It is
+	// equivalent to running `git log --skip`.
+	Skip int
+
+	// Show at most the given number of commits, all of them if zero. It is
+	// equivalent to running `git log --max-count`.
+	MaxCount int
 }
 
 var (
@@ -472,6 +542,121 @@ func (o *GrepOptions) Validate(w *Worktree) error {
 	return nil
 }
 
+// UntrackedFilesMode is the way the untracked files are reported by
+// Worktree.StatusWithOptions.
+type UntrackedFilesMode int
+
+const (
+	// UntrackedFilesAll reports every untracked file, as
+	// `git status --untracked-files=all` and Worktree.Status do.
+	UntrackedFilesAll UntrackedFilesMode = iota
+	// UntrackedFilesNormal reports the untracked directories without
+	// tracked files as a single entry, the path of the directory with a
+	// trailing slash, as `git status --untracked-files=normal` does.
+	UntrackedFilesNormal
+	// UntrackedFilesNo does not report untracked files, nor read the
+	// directories without tracked files, as `git status --untracked-files=no`
+	// does.
+	UntrackedFilesNo
+)
+
+// StatusOptions describes how a status should be performed.
+type StatusOptions struct {
+	// Untracked is the way the untracked files are reported, all of them
+	// by default.
+	Untracked UntrackedFilesMode
+	// Ignored reports the ignored untracked files with the Ignored status
+	// code, as `git status --ignored` does.
+	Ignored bool
+	// Paths, if set, only the files matching these paths, or inside them, are
+	// reported, and the other directories of the worktree are not read.
+	Paths []string
+	// DetectRenames reports the files deleted from the index and added with
+	// the same or a similar content as renamed, as `git status` does. The
+	// FileStatus of the new path has the Renamed staging status code, and
+	// the previous path as Extra.
+	DetectRenames bool
+	// RefreshIndex updates the stat data of the index entries of the files
+	// whose stat data changed but not their content, and writes the index,
+	// so the next status does not read them again, as `git status` does.
+	RefreshIndex bool
+}
+
+// DiffOptions describes how a diff of the worktree should be performed.
+type DiffOptions struct {
+	// Cached compares the index with the Against revision, HEAD by default,
+	// instead of comparing the worktree, as `git diff --cached` does.
+	Cached bool
+	// Against is the revision compared with the worktree, or with the index
+	// if Cached, as `git diff <rev>` and `git diff --cached <rev>` do. If
+	// empty, the worktree is compared with the index.
+	Against plumbing.Revision
+	// Paths, if set, only the files matching these paths, or inside them, are
+	// compared.
+	Paths []string
+	// PatchOptions describes how the line differences of the files are
+	// computed and shown, the default ones if nil.
+	PatchOptions *object.PatchOptions
+}
+
+// ArchiveFormat is the format of an archive.
+type ArchiveFormat string
+
+const (
+	// TarArchive is a tar archive.
+	TarArchive ArchiveFormat = "tar"
+	// TarGzArchive is a gzip compressed tar archive.
+	TarGzArchive ArchiveFormat = "tar.gz"
+	// ZipArchive is a zip archive.
+	ZipArchive ArchiveFormat = "zip"
+)
+
+var (
+	ErrTreeAndRevision          = errors.New("ambiguous options, only one of Tree or Revision can be passed")
+	ErrUnsupportedArchiveFormat = errors.New("unsupported archive format")
+)
+
+// ArchiveOptions describes how an archive should be created.
+type ArchiveOptions struct {
+	// Revision is the commit, or a tag pointing to it, whose tree is
+	// archived. The commit ID is embedded in the archive, and its commit time
+	// is the modification time of the files. If neither Revision nor Tree
+	// are set, HEAD is used.
+	Revision plumbing.Revision
+	// Tree is the tree to archive, instead of the tree of a commit. The
+	// modification time of the files is the current time.
+	Tree *object.Tree
+	// Format is the format of the archive, if empty TarArchive is used.
+	Format ArchiveFormat
+	// Prefix is prepended to the path of every file in the archive, usually
+	// a directory name like "project-1.0/".
+	Prefix string
+	// Paths, if set, only the files matching these paths, or inside them, are
+	// archived.
+	Paths []string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ArchiveOptions) Validate() error {
+	if o.Tree != nil && o.Revision != "" {
+		return ErrTreeAndRevision
+	}
+
+	if o.Tree == nil && o.Revision == "" {
+		o.Revision = plumbing.Revision(plumbing.HEAD)
+	}
+
+	switch o.Format {
+	case "":
+		o.Format = TarArchive
+	case TarArchive, TarGzArchive, ZipArchive:
+	default:
+		return ErrUnsupportedArchiveFormat
+	}
+
+	return nil
+}
+
 // PlainOpenOptions describes how opening a plain repository should be
 // performed.
 type PlainOpenOptions struct {
@@ -482,3 +667,178 @@ type PlainOpenOptions struct {
 
 // Validate validates the fields and sets the default values.
 func (o *PlainOpenOptions) Validate() error { return nil }
+
+// FastExportOptions describes how a fast-export stream should be written.
+type FastExportOptions struct {
+	// References are the branches and tags to export, if empty all the
+	// branches and tags are exported.
+	References []plumbing.ReferenceName
+	// Marks are the marks of the objects exported by a previous run, these
+	// objects are not exported again and are referenced by its mark. The
+	// marks of the exported objects are added to it, so it can be saved as
+	// a marks file for the next incremental run.
+	Marks fastimport.Marks
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FastExportOptions) Validate() error {
+	if o.Marks == nil {
+		o.Marks = make(fastimport.Marks)
+	}
+
+	return nil
+}
+
+// RewriteHistoryOptions describes how the history should be rewritten. The
+// filters are optional, the history is kept as it is when none is set.
+type RewriteHistoryOptions struct {
+	// References are the branches and tags to rewrite, if empty all the
+	// branches and tags are rewritten.
+	References []plumbing.ReferenceName
+	// TreeEntryFilter is called for every entry of the trees, with its full
+	// path. It returns the entry to write instead, that may have a different
+	// name, mode or hash, or nil to drop the entry. The entries of the
+	// directories are filtered after the directory.
+	TreeEntryFilter func(path string, e object.TreeEntry) (*object.TreeEntry, error)
+	// BlobFilter is called for every file, with its full path. It returns
+	// the new content of the file, or nil to keep the blob as it is.
+	BlobFilter func(path string, b *object.Blob) (io.Reader, error)
+	// CommitFilter is called for every commit, with its tree and parents
+	// already rewritten. It can modify the message, author, committer,
+	// tree and parents of the commit.
+	CommitFilter func(c *object.Commit) error
+	// PruneEmpty, if true, the commits that are not merges and do not change
+	// the tree of their parent are dropped.
+	PruneEmpty bool
+	// Backup, if true, the original references are saved under
+	// refs/original/ before being updated.
+	Backup bool
+}
+
+// NotesStrategy defines how a note is added to an object that already has a
+// note.
+type NotesStrategy int8
+
+const (
+	// NotesFailOnExisting fails with ErrNoteExists, the default strategy.
+	NotesFailOnExisting NotesStrategy = iota
+	// NotesOverwrite replaces the existing note.
+	NotesOverwrite
+	// NotesConcatenate appends the note to the existing one, separated by an
+	// empty line.
+	NotesConcatenate
+)
+
+// AddNoteOptions describes how a note should be added.
+type AddNoteOptions struct {
+	// Author is the author of the notes commit, it is required.
+	Author *object.Signature
+	// Committer is the committer of the notes commit, if nil the Author is
+	// used.
+	Committer *object.Signature
+	// Strategy is how the note is added if the object already has a note.
+	Strategy NotesStrategy
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddNoteOptions) Validate() error {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+// RemoveNoteOptions describes how a note should be removed.
+type RemoveNoteOptions struct {
+	// Author is the author of the notes commit, it is required.
+	Author *object.Signature
+	// Committer is the committer of the notes commit, if nil the Author is
+	// used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RemoveNoteOptions) Validate() error {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+const (
+	// DefaultDescribeAbbrev is the number of hexadecimal digits of the
+	// abbreviated hash of a description.
+	DefaultDescribeAbbrev = 7
+	// DefaultDescribeCandidates is the number of tags considered to describe
+	// a commit.
+	DefaultDescribeCandidates = 10
+)
+
+// DescribeOptions describes how a commit is described.
+type DescribeOptions struct {
+	// Commit is the commit to describe, HEAD if it is not set.
+	Commit plumbing.Hash
+	// Tags uses the lightweight tags too, by default only the annotated
+	// tags are used.
+	Tags bool
+	// All uses any reference, not only the tags.
+	All bool
+	// Contains finds the tag that comes after the commit, instead of the
+	// tag before it.
+	Contains bool
+	// Abbrev is the minimum number of hexadecimal digits of the abbreviated
+	// hash, DefaultDescribeAbbrev if zero. More digits are used if needed to
+	// keep the hash unique. If negative, only the name of the tag is given.
+	Abbrev int
+	// Candidates is the number of the most recent tags considered,
+	// DefaultDescribeCandidates if zero.
+	Candidates int
+	// Match only uses the tags matching any of the given glob patterns,
+	// without the refs/tags/ prefix. As in git, the wildcards also match
+	// the slashes, so "release*" matches "release/1.2".
+	Match []string
+	// Dirty is appended to the description if the worktree has changes, it
+	// can only be used describing HEAD.
+	Dirty string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *DescribeOptions) Validate() error {
+	if o.Abbrev == 0 {
+		o.Abbrev = DefaultDescribeAbbrev
+	}
+
+	if o.Abbrev > 40 {
+		o.Abbrev = 40
+	}
+
+	if o.Candidates <= 0 {
+		o.Candidates = DefaultDescribeCandidates
+	}
+
+	if o.Candidates > maxDescribeCandidates {
+		o.Candidates = maxDescribeCandidates
+	}
+
+	for _, p := range o.Match {
+		if _, err := path.Match(p, ""); err != nil {
+			return err
+		}
+	}
+
+	if o.Dirty != "" && !o.Commit.IsZero() {
+		return ErrDescribeDirtyCommit
+	}
+
+	return nil
+}
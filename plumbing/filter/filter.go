@@ -0,0 +1,20 @@
+// Package filter defines the clean and smudge filter drivers, the content of
+// the files declared with the filter=<driver> gitattribute is converted by the
+// driver when the files are added and checked out.
+//
+// https://git-scm.com/docs/gitattributes#_filter
+package filter
+
+import (
+	"io"
+)
+
+// Filter is a clean and smudge filter driver.
+type Filter interface {
+	// Clean writes to w the content stored in the repository for the content
+	// of the file at path of the worktree, read from r.
+	Clean(path string, r io.Reader, w io.Writer) error
+	// Smudge writes to w the content of the file at path of the worktree for
+	// the content stored in the repository, read from r.
+	Smudge(path string, r io.Reader, w io.Writer) error
+}
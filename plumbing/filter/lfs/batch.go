@@ -0,0 +1,217 @@
+package lfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	mediaType      = "application/vnd.git-lfs+json"
+	batchPath      = "/objects/batch"
+	downloadAction = "download"
+	basicTransfer  = "basic"
+)
+
+var (
+	// ErrNoEndpoint is returned when an object is fetched from a Filter
+	// without Endpoint.
+	ErrNoEndpoint = errors.New("lfs endpoint not set")
+	// ErrObjectNotFound is returned when an object is not found in the LFS
+	// server.
+	ErrObjectNotFound = errors.New("lfs object not found")
+)
+
+type batchRequest struct {
+	Operation string         `json:"operation"`
+	Transfers []string       `json:"transfers,omitempty"`
+	Objects   []*batchObject `json:"objects"`
+}
+
+type batchResponse struct {
+	Transfer string         `json:"transfer,omitempty"`
+	Objects  []*batchObject `json:"objects"`
+	Message  string         `json:"message,omitempty"`
+}
+
+type batchObject struct {
+	Oid     string                  `json:"oid"`
+	Size    int64                   `json:"size"`
+	Actions map[string]*batchAction `json:"actions,omitempty"`
+	Error   *batchError             `json:"error,omitempty"`
+}
+
+type batchAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type batchError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Endpoint returns the URL of the LFS server API for a git remote URL, as the
+// LFS clients do when the lfs.url config is not set. The SSH URLs are
+// converted to HTTPS ones.
+func Endpoint(url string) (string, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "", err
+	}
+
+	switch ep.Protocol {
+	case "http", "https":
+	case "ssh", "git":
+		ep.Protocol = "https"
+		ep.User = ""
+		ep.Password = ""
+		ep.Port = 0
+	default:
+		return "", fmt.Errorf("unsupported lfs endpoint protocol %q", ep.Protocol)
+	}
+
+	ep.Path = "/" + strings.TrimPrefix(strings.TrimSuffix(ep.Path, "/"), "/")
+	if !strings.HasSuffix(ep.Path, ".git") {
+		ep.Path += ".git"
+	}
+
+	ep.Path += "/info/lfs"
+	return ep.String(), nil
+}
+
+// batch requests the download action of the object of the pointer to the
+// batch API of the LFS server.
+func (f *Filter) batch(p *Pointer) (a *batchAction, err error) {
+	if f.Endpoint == "" {
+		return nil, ErrNoEndpoint
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = json.NewEncoder(buf).Encode(&batchRequest{
+		Operation: downloadAction,
+		Transfers: []string{basicTransfer},
+		Objects:   []*batchObject{{Oid: p.Oid, Size: p.Size}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(f.Endpoint, "/") + batchPath
+	req, err := http.NewRequest(http.MethodPost, url, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+
+	res, err := f.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(res.Body, &err)
+
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+
+	var batch batchResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	if batch.Transfer != "" && batch.Transfer != basicTransfer {
+		return nil, fmt.Errorf("unsupported lfs transfer %q", batch.Transfer)
+	}
+
+	for _, o := range batch.Objects {
+		if o.Oid != p.Oid {
+			continue
+		}
+
+		if o.Error != nil {
+			if o.Error.Code == http.StatusNotFound {
+				return nil, ErrObjectNotFound
+			}
+
+			return nil, fmt.Errorf("lfs object %s: %s", o.Oid, o.Error.Message)
+		}
+
+		if a := o.Actions[downloadAction]; a != nil {
+			return a, nil
+		}
+	}
+
+	return nil, ErrObjectNotFound
+}
+
+// download writes to w the content of the object of the pointer, fetched
+// from the LFS server.
+func (f *Filter) download(p *Pointer, w io.Writer) (err error) {
+	a, err := f.batch(p)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, a.Href, nil)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range a.Header {
+		req.Header.Set(k, v)
+	}
+
+	res, err := f.client().Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(res.Body, &err)
+
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+func (f *Filter) client() *http.Client {
+	if f.Client == nil {
+		return http.DefaultClient
+	}
+
+	return f.Client
+}
+
+func checkResponse(res *http.Response) error {
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+
+	var e struct {
+		Message string `json:"message"`
+	}
+
+	_ = json.NewDecoder(res.Body).Decode(&e)
+	if e.Message == "" {
+		e.Message = res.Status
+	}
+
+	return fmt.Errorf("lfs server error: %s", e.Message)
+}
@@ -0,0 +1,22 @@
+package lfs
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *FilterSuite) TestEndpoint(c *C) {
+	for url, expected := range map[string]string{
+		"https://github.com/foo/bar.git":   "https://github.com/foo/bar.git/info/lfs",
+		"https://github.com/foo/bar":       "https://github.com/foo/bar.git/info/lfs",
+		"http://example.com:8080/bar.git/": "http://example.com:8080/bar.git/info/lfs",
+		"git@github.com:foo/bar.git":       "https://github.com/foo/bar.git/info/lfs",
+		"ssh://git@example.com:2222/bar":   "https://example.com/bar.git/info/lfs",
+	} {
+		ep, err := Endpoint(url)
+		c.Assert(err, IsNil)
+		c.Assert(ep, Equals, expected, Commentf("%s", url))
+	}
+
+	_, err := Endpoint("/tmp/foo")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,246 @@
+// Package lfs implements a filter driver for Git LFS, the content of the
+// large files is stored out of the repository, in place of it the repository
+// stores a pointer file with the hash and size of the content.
+//
+// The filter stores the content of the cleaned files in its local storage,
+// the lfs directory of the git directory, and the content of the smudged
+// files missing in it is fetched from the LFS server with the batch API.
+//
+// https://github.com/git-lfs/git-lfs/tree/master/docs
+package lfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/filter"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	// Name is the name of the filter driver declared for the LFS files, as
+	// filter=lfs in the gitattributes.
+	Name = "lfs"
+
+	objectsPath = "objects"
+	tmpPath     = "tmp"
+)
+
+// Filter is the LFS filter driver.
+type Filter struct {
+	// Storage is the local storage of the LFS objects, usually the lfs
+	// directory of the git directory.
+	Storage billy.Filesystem
+	// Endpoint is the URL of the LFS server API, the objects missing in the
+	// Storage are fetched from it.
+	Endpoint string
+	// Client is the HTTP client used to fetch the objects, if nil
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+var _ filter.Filter = &Filter{}
+
+// NewFilter returns a Filter storing the objects in storage and fetching the
+// missing ones from the LFS server API at endpoint, with the given client.
+func NewFilter(storage billy.Filesystem, endpoint string, client *http.Client) *Filter {
+	return &Filter{Storage: storage, Endpoint: endpoint, Client: client}
+}
+
+// Clean stores the content read from r in the local storage, and writes its
+// pointer file to w. If the content is already a pointer file it is written
+// as is.
+func (f *Filter) Clean(path string, r io.Reader, w io.Writer) error {
+	head, r, err := readHead(r)
+	if err != nil {
+		return err
+	}
+
+	// the empty files are not stored, as the LFS clients do
+	if len(head) == 0 || IsPointer(head) {
+		_, err := w.Write(head)
+		return err
+	}
+
+	p, err := f.store(io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return err
+	}
+
+	return p.Encode(w)
+}
+
+// Smudge writes to w the content of the object of the pointer file read from
+// r, fetching it from the LFS server if it is missing in the local storage.
+// If the content is not a pointer file it is written as is.
+func (f *Filter) Smudge(path string, r io.Reader, w io.Writer) error {
+	head, r, err := readHead(r)
+	if err != nil {
+		return err
+	}
+
+	p, err := DecodePointer(head)
+	if err != nil {
+		_, err := io.Copy(w, io.MultiReader(bytes.NewReader(head), r))
+		return err
+	}
+
+	if p.Size == 0 {
+		return nil
+	}
+
+	ok, err := f.HasObject(p)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if err := f.fetch(p); err != nil {
+			return err
+		}
+	}
+
+	return f.readObject(p, w)
+}
+
+// HasObject returns true if the object of the pointer is in the local
+// storage.
+func (f *Filter) HasObject(p *Pointer) (bool, error) {
+	_, err := f.Storage.Stat(objectPath(p.Oid))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (f *Filter) readObject(p *Pointer, w io.Writer) (err error) {
+	o, err := f.Storage.Open(objectPath(p.Oid))
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(o, &err)
+
+	_, err = io.Copy(w, o)
+	return err
+}
+
+// store writes the content read from r to the local storage, returning its
+// pointer.
+func (f *Filter) store(r io.Reader) (*Pointer, error) {
+	var p *Pointer
+	err := f.writeObject(func(w io.Writer) (*Pointer, error) {
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(w, h), r)
+		if err != nil {
+			return nil, err
+		}
+
+		p = &Pointer{Oid: hex.EncodeToString(h.Sum(nil)), Size: n}
+		return p, nil
+	})
+
+	return p, err
+}
+
+// fetch downloads the object of the pointer from the LFS server to the local
+// storage, its content is verified with the hash and size of the pointer.
+func (f *Filter) fetch(p *Pointer) error {
+	return f.writeObject(func(w io.Writer) (*Pointer, error) {
+		h := sha256.New()
+		c := &countWriter{}
+		if err := f.download(p, io.MultiWriter(w, h, c)); err != nil {
+			return nil, err
+		}
+
+		oid := hex.EncodeToString(h.Sum(nil))
+		if oid != p.Oid || c.n != p.Size {
+			return nil, fmt.Errorf("lfs object %s: content does not match, got %s of size %d",
+				p.Oid, oid, c.n)
+		}
+
+		return p, nil
+	})
+}
+
+// writeObject writes an object to a temporary file with the given function,
+// the file is moved to the path of the returned pointer when it succeeds.
+func (f *Filter) writeObject(write func(w io.Writer) (*Pointer, error)) (err error) {
+	if err := f.Storage.MkdirAll(tmpPath, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := f.Storage.TempFile(tmpPath, "object")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = f.Storage.Remove(tmp.Name())
+		}
+	}()
+
+	p, err := write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ok, err := f.HasObject(p)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return f.Storage.Remove(tmp.Name())
+	}
+
+	name := objectPath(p.Oid)
+	if err := f.Storage.MkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	return f.Storage.Rename(tmp.Name(), name)
+}
+
+// objectPath returns the path of an object in the local storage.
+func objectPath(oid string) string {
+	return path.Join(objectsPath, oid[0:2], oid[2:4], oid)
+}
+
+// readHead reads the first bytes of r, enough to detect a pointer file, the
+// returned reader reads the rest of the content.
+func readHead(r io.Reader) ([]byte, io.Reader, error) {
+	head := make([]byte, MaxPointerSize+1)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+
+	return head[:n], r, nil
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
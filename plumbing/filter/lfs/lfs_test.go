@@ -0,0 +1,172 @@
+package lfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type FilterSuite struct {
+	server  *httptest.Server
+	objects map[string]string
+	batches int
+}
+
+var _ = Suite(&FilterSuite{})
+
+func (s *FilterSuite) SetUpTest(c *C) {
+	s.objects = map[string]string{fooOid: "foo"}
+	s.batches = 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repo.git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		s.batches++
+		c.Assert(r.Method, Equals, http.MethodPost)
+		c.Assert(r.Header.Get("Accept"), Equals, mediaType)
+
+		var req batchRequest
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		c.Assert(req.Operation, Equals, downloadAction)
+
+		res := &batchResponse{Transfer: basicTransfer}
+		for _, o := range req.Objects {
+			ro := &batchObject{Oid: o.Oid, Size: o.Size}
+			if _, ok := s.objects[o.Oid]; ok {
+				ro.Actions = map[string]*batchAction{downloadAction: {
+					Href:   s.server.URL + "/objects/" + o.Oid,
+					Header: map[string]string{"Authorization": "Bearer foo"},
+				}}
+			} else {
+				ro.Error = &batchError{Code: http.StatusNotFound, Message: "not found"}
+			}
+
+			res.Objects = append(res.Objects, ro)
+		}
+
+		w.Header().Set("Content-Type", mediaType)
+		c.Assert(json.NewEncoder(w).Encode(res), IsNil)
+	})
+
+	mux.HandleFunc("/objects/", func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.Header.Get("Authorization"), Equals, "Bearer foo")
+		content, ok := s.objects[strings.TrimPrefix(r.URL.Path, "/objects/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte(content))
+	})
+
+	s.server = httptest.NewServer(mux)
+}
+
+func (s *FilterSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *FilterSuite) newFilter() *Filter {
+	return NewFilter(memfs.New(), s.server.URL+"/repo.git/info/lfs", s.server.Client())
+}
+
+func (s *FilterSuite) TestClean(c *C) {
+	f := s.newFilter()
+
+	buf := bytes.NewBuffer(nil)
+	err := f.Clean("foo", strings.NewReader("foo"), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, fooPointer)
+
+	ok, err := f.HasObject(&Pointer{Oid: fooOid, Size: 3})
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+
+	// a pointer is not cleaned again
+	buf.Reset()
+	err = f.Clean("foo", strings.NewReader(fooPointer), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, fooPointer)
+
+	buf.Reset()
+	err = f.Clean("foo", strings.NewReader(""), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.Len(), Equals, 0)
+
+	fis, err := f.Storage.ReadDir(tmpPath)
+	c.Assert(err, IsNil)
+	c.Assert(fis, HasLen, 0)
+}
+
+func (s *FilterSuite) TestCleanLarge(c *C) {
+	f := s.newFilter()
+	content := strings.Repeat("foo\n", MaxPointerSize)
+
+	buf := bytes.NewBuffer(nil)
+	err := f.Clean("foo", strings.NewReader(content), buf)
+	c.Assert(err, IsNil)
+
+	p, err := DecodePointer(buf.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(p.Size, Equals, int64(len(content)))
+
+	buf.Reset()
+	err = f.Smudge("foo", strings.NewReader(p.String()), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, content)
+	c.Assert(s.batches, Equals, 0)
+}
+
+func (s *FilterSuite) TestSmudge(c *C) {
+	f := s.newFilter()
+
+	buf := bytes.NewBuffer(nil)
+	err := f.Smudge("foo", strings.NewReader(fooPointer), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "foo")
+	c.Assert(s.batches, Equals, 1)
+
+	// the fetched object is stored
+	buf.Reset()
+	err = f.Smudge("foo", strings.NewReader(fooPointer), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "foo")
+	c.Assert(s.batches, Equals, 1)
+
+	// the content of not pointer files is not changed
+	buf.Reset()
+	err = f.Smudge("foo", strings.NewReader("bar"), buf)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "bar")
+}
+
+func (s *FilterSuite) TestSmudgeNotFound(c *C) {
+	f := s.newFilter()
+	p := &Pointer{Oid: strings.Repeat("a", 64), Size: 3}
+
+	err := f.Smudge("foo", strings.NewReader(p.String()), bytes.NewBuffer(nil))
+	c.Assert(err, Equals, ErrObjectNotFound)
+}
+
+func (s *FilterSuite) TestSmudgeCorrupted(c *C) {
+	s.objects[fooOid] = "bar"
+	f := s.newFilter()
+
+	err := f.Smudge("foo", strings.NewReader(fooPointer), bytes.NewBuffer(nil))
+	c.Assert(err, ErrorMatches, "lfs object .*: content does not match.*")
+
+	ok, err := f.HasObject(&Pointer{Oid: fooOid, Size: 3})
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *FilterSuite) TestSmudgeNoEndpoint(c *C) {
+	f := NewFilter(memfs.New(), "", nil)
+
+	err := f.Smudge("foo", strings.NewReader(fooPointer), bytes.NewBuffer(nil))
+	c.Assert(err, Equals, ErrNoEndpoint)
+}
@@ -0,0 +1,103 @@
+package lfs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the version of the LFS pointer files written by Encode.
+	Version = "https://git-lfs.github.com/spec/v1"
+	// legacyVersion is the version written by the early LFS clients.
+	legacyVersion = "https://hawser.github.com/spec/v1"
+
+	oidType = "sha256"
+	// MaxPointerSize is the maximum size of a pointer file, any bigger file
+	// is not a pointer.
+	MaxPointerSize = 1024
+)
+
+// ErrInvalidPointer is returned by DecodePointer when the content is not a
+// valid LFS pointer file.
+var ErrInvalidPointer = errors.New("invalid lfs pointer")
+
+// Pointer is the content of a LFS pointer file, stored in the repository in
+// place of the content of the file.
+type Pointer struct {
+	// Oid is the SHA-256 hash of the content, hex encoded.
+	Oid string
+	// Size is the size of the content.
+	Size int64
+}
+
+// DecodePointer decodes the content of a pointer file.
+func DecodePointer(content []byte) (*Pointer, error) {
+	if len(content) > MaxPointerSize {
+		return nil, ErrInvalidPointer
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 3 {
+		return nil, ErrInvalidPointer
+	}
+
+	values := make(map[string]string, len(lines))
+	for i, line := range lines {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, ErrInvalidPointer
+		}
+
+		// the version is always the first key
+		if (i == 0) != (parts[0] == "version") {
+			return nil, ErrInvalidPointer
+		}
+
+		values[parts[0]] = parts[1]
+	}
+
+	if v := values["version"]; v != Version && v != legacyVersion {
+		return nil, ErrInvalidPointer
+	}
+
+	oid := strings.TrimPrefix(values["oid"], oidType+":")
+	if oid == values["oid"] || len(oid) != 64 {
+		return nil, ErrInvalidPointer
+	}
+
+	if _, err := hex.DecodeString(oid); err != nil {
+		return nil, ErrInvalidPointer
+	}
+
+	size, err := strconv.ParseInt(values["size"], 10, 64)
+	if err != nil || size < 0 {
+		return nil, ErrInvalidPointer
+	}
+
+	return &Pointer{Oid: oid, Size: size}, nil
+}
+
+// IsPointer returns true if the content is a valid pointer file.
+func IsPointer(content []byte) bool {
+	_, err := DecodePointer(content)
+	return err == nil
+}
+
+// Encode writes the pointer file to w.
+func (p *Pointer) Encode(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "version %s\noid %s:%s\nsize %d\n",
+		Version, oidType, p.Oid, p.Size)
+
+	return err
+}
+
+func (p *Pointer) String() string {
+	buf := bytes.NewBuffer(nil)
+	_ = p.Encode(buf)
+	return buf.String()
+}
@@ -0,0 +1,55 @@
+package lfs
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PointerSuite struct{}
+
+var _ = Suite(&PointerSuite{})
+
+const (
+	fooOid     = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	fooPointer = "version https://git-lfs.github.com/spec/v1\n" +
+		"oid sha256:" + fooOid + "\n" +
+		"size 3\n"
+)
+
+func (s *PointerSuite) TestDecodePointer(c *C) {
+	p, err := DecodePointer([]byte(fooPointer))
+	c.Assert(err, IsNil)
+	c.Assert(p.Oid, Equals, fooOid)
+	c.Assert(p.Size, Equals, int64(3))
+	c.Assert(p.String(), Equals, fooPointer)
+}
+
+func (s *PointerSuite) TestDecodePointerExtraKeys(c *C) {
+	p, err := DecodePointer([]byte("version https://hawser.github.com/spec/v1\n" +
+		"ext-0-foo sha256:" + fooOid + "\n" +
+		"oid sha256:" + fooOid + "\n" +
+		"size 3\n"))
+
+	c.Assert(err, IsNil)
+	c.Assert(p.Oid, Equals, fooOid)
+}
+
+func (s *PointerSuite) TestDecodePointerInvalid(c *C) {
+	for _, content := range []string{
+		"",
+		"foo",
+		"oid sha256:" + fooOid + "\nversion https://git-lfs.github.com/spec/v1\nsize 3\n",
+		"version https://example.com/spec/v1\noid sha256:" + fooOid + "\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid md5:" + fooOid + "\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:foo\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + fooOid + "\nsize -3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + fooOid + "\n",
+	} {
+		_, err := DecodePointer([]byte(content))
+		c.Assert(err, Equals, ErrInvalidPointer, Commentf("%q", content))
+		c.Assert(IsPointer([]byte(content)), Equals, false)
+	}
+}
@@ -0,0 +1,314 @@
+package bitmap
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+)
+
+const (
+	// VersionSupported is the only bitmap version supported.
+	VersionSupported = 1
+
+	// OptFullDAG is set when the bitmaps of the index are closed under
+	// reachability, this is always the case for bitmaps written by git.
+	OptFullDAG = 0x1
+	// OptHashCache is set when the index contains a name-hash cache.
+	OptHashCache = 0x4
+	// OptLookupTable is set when the index contains a lookup table for the
+	// commit entries.
+	OptLookupTable = 0x10
+
+	// maxXorOffset is the maximum distance between an entry and the entry
+	// it is XORed against.
+	maxXorOffset = 160
+)
+
+var (
+	bitmapHeader = []byte{'B', 'I', 'T', 'M'}
+
+	// ErrBitmapNotFound is returned when there is no bitmap for a commit.
+	ErrBitmapNotFound = errors.New("bitmap not found")
+	// ErrPackfileMismatch is returned when a bitmap index does not belong to
+	// the given packfile index.
+	ErrPackfileMismatch = errors.New("bitmap does not match packfile")
+	// ErrObjectNotInPack is returned when an object reachable from a walk is
+	// not in the packfile, and so it cannot be represented in a bitmap.
+	ErrObjectNotInPack = errors.New("object not in packfile")
+)
+
+// Index is the in memory representation of a .bitmap file.
+type Index struct {
+	Version uint16
+	Options uint16
+	// PackfileChecksum is the checksum of the packfile the bitmaps refer to.
+	PackfileChecksum [20]byte
+	// Commits, Trees, Blobs and Tags have the bits set for the objects of
+	// each type in the packfile.
+	Commits *EWAH
+	Trees   *EWAH
+	Blobs   *EWAH
+	Tags    *EWAH
+	// Entries are the reachability bitmaps of the selected commits.
+	Entries []*Entry
+	// HashCache holds the name-hash of every object, in the order of the
+	// packfile index. It is only present if OptHashCache is set.
+	HashCache []uint32
+	Checksum  [20]byte
+}
+
+// Entry is the reachability bitmap of a commit.
+type Entry struct {
+	// ObjectPos is the position of the commit in the packfile index, the
+	// list of objects sorted by hash.
+	ObjectPos uint32
+	// XorOffset is the number of entries back in the list of the entry this
+	// bitmap is XORed against, zero if it is stored as is.
+	XorOffset uint8
+	Flags     uint8
+	Bitmap    *EWAH
+}
+
+// Storer is an optional interface for object storages that keep reachability
+// bitmaps for their packfiles.
+type Storer interface {
+	// PackBitmaps returns the bitmaps of the packfiles that have one.
+	PackBitmaps() ([]*PackBitmap, error)
+	// ObjectPackIndex returns the index of the given packfile, needed to
+	// build its bitmaps.
+	ObjectPackIndex(pack plumbing.Hash) (idxfile.Index, error)
+	// SetPackBitmap stores the given bitmap index next to its packfile.
+	SetPackBitmap(*Index) error
+}
+
+// PackBitmap resolves the bitmaps of an Index against the packfile index it
+// belongs to, translating bit positions into object hashes and back.
+type PackBitmap struct {
+	index idxfile.Index
+
+	// packOrder are the hashes of the objects sorted by offset, the bit
+	// positions of the bitmaps.
+	packOrder []plumbing.Hash
+	positions map[plumbing.Hash]uint32
+
+	commits, trees, blobs, tags *Bitmap
+
+	// entries maps the commits to their position in order.
+	entries map[plumbing.Hash]int
+	order   []*Entry
+
+	m     sync.Mutex
+	cache []*Bitmap
+}
+
+// NewPackBitmap returns a PackBitmap for the given packfile index and
+// bitmap index.
+func NewPackBitmap(idx idxfile.Index, bi *Index) (*PackBitmap, error) {
+	if mi, ok := idx.(*idxfile.MemoryIndex); ok &&
+		mi.PackfileChecksum != bi.PackfileChecksum {
+		return nil, ErrPackfileMismatch
+	}
+
+	pb := &PackBitmap{
+		index:   idx,
+		entries: make(map[plumbing.Hash]int, len(bi.Entries)),
+		order:   bi.Entries,
+		cache:   make([]*Bitmap, len(bi.Entries)),
+	}
+
+	if err := pb.loadPositions(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	types := []struct {
+		dst **Bitmap
+		src *EWAH
+	}{
+		{&pb.commits, bi.Commits},
+		{&pb.trees, bi.Trees},
+		{&pb.blobs, bi.Blobs},
+		{&pb.tags, bi.Tags},
+	}
+
+	for _, t := range types {
+		if *t.dst, err = t.src.Bitmap(); err != nil {
+			return nil, err
+		}
+	}
+
+	names, err := IndexHashes(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range bi.Entries {
+		if int(e.ObjectPos) >= len(names) {
+			return nil, ErrMalformedBitmapFile
+		}
+
+		pb.entries[names[e.ObjectPos]] = i
+	}
+
+	return pb, nil
+}
+
+func (pb *PackBitmap) loadPositions() error {
+	iter, err := pb.index.EntriesByOffset()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+
+	pb.positions = make(map[plumbing.Hash]uint32)
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		pb.positions[e.Hash] = uint32(len(pb.packOrder))
+		pb.packOrder = append(pb.packOrder, e.Hash)
+	}
+}
+
+// IndexHashes returns the hashes of the objects in the packfile index, sorted
+// by hash. The position of a commit in this list is the one stored in the
+// bitmap entries.
+func IndexHashes(idx idxfile.Index) ([]plumbing.Hash, error) {
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	var hashes []plumbing.Hash
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return hashes, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, e.Hash)
+	}
+}
+
+// Index returns the packfile index of the bitmaps.
+func (pb *PackBitmap) Index() idxfile.Index {
+	return pb.index
+}
+
+// Count returns the number of objects in the packfile.
+func (pb *PackBitmap) Count() int {
+	return len(pb.packOrder)
+}
+
+// Position returns the bit position of the given object, false if the object
+// is not in the packfile.
+func (pb *PackBitmap) Position(h plumbing.Hash) (uint32, bool) {
+	pos, ok := pb.positions[h]
+	return pos, ok
+}
+
+// Hash returns the hash of the object at the given bit position.
+func (pb *PackBitmap) Hash(pos uint32) plumbing.Hash {
+	return pb.packOrder[pos]
+}
+
+// Type returns the type of the object at the given bit position.
+func (pb *PackBitmap) Type(pos uint32) plumbing.ObjectType {
+	switch {
+	case pb.commits.Has(pos):
+		return plumbing.CommitObject
+	case pb.trees.Has(pos):
+		return plumbing.TreeObject
+	case pb.blobs.Has(pos):
+		return plumbing.BlobObject
+	case pb.tags.Has(pos):
+		return plumbing.TagObject
+	}
+
+	return plumbing.InvalidObject
+}
+
+// Hashes returns the hashes of the objects set in b, in packfile order.
+func (pb *PackBitmap) Hashes(b *Bitmap) []plumbing.Hash {
+	result := make([]plumbing.Hash, 0, b.Count())
+	b.ForEach(func(pos uint32) error {
+		if int(pos) < len(pb.packOrder) {
+			result = append(result, pb.packOrder[pos])
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+// Commit returns the reachability bitmap of the given commit, containing
+// every object reachable from it. ErrBitmapNotFound is returned if the
+// commit has no bitmap. The returned bitmap must not be modified.
+func (pb *PackBitmap) Commit(h plumbing.Hash) (*Bitmap, error) {
+	i, ok := pb.entries[h]
+	if !ok {
+		return nil, ErrBitmapNotFound
+	}
+
+	pb.m.Lock()
+	defer pb.m.Unlock()
+
+	return pb.resolve(i)
+}
+
+// Commits returns the hashes of the commits with a bitmap.
+func (pb *PackBitmap) Commits() []plumbing.Hash {
+	hashes := make([]plumbing.Hash, 0, len(pb.entries))
+	for h := range pb.entries {
+		hashes = append(hashes, h)
+	}
+
+	plumbing.HashesSort(hashes)
+	return hashes
+}
+
+func (pb *PackBitmap) resolve(i int) (*Bitmap, error) {
+	if b := pb.cache[i]; b != nil {
+		return b, nil
+	}
+
+	e := pb.order[i]
+	b, err := e.Bitmap.Bitmap()
+	if err != nil {
+		return nil, err
+	}
+
+	if e.XorOffset != 0 {
+		j := i - int(e.XorOffset)
+		if j < 0 {
+			return nil, ErrMalformedBitmapFile
+		}
+
+		base, err := pb.resolve(j)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Xor(base)
+	}
+
+	pb.cache[i] = b
+	return b, nil
+}
@@ -0,0 +1,184 @@
+package bitmap_test
+
+import (
+	"bytes"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	. "gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type BitmapSuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&BitmapSuite{})
+
+func (s *BitmapSuite) build(c *C, tips ...plumbing.Hash) (
+	*filesystem.Storage, *idxfile.MemoryIndex, *Index) {
+	f := fixtures.Basic().One()
+	sto := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+
+	idx := idxfile.NewMemoryIndex()
+	c.Assert(idxfile.NewDecoder(f.Idx()).Decode(idx), IsNil)
+
+	bi, err := revlist.BuildBitmap(sto, idx, tips)
+	c.Assert(err, IsNil)
+
+	return sto, idx, bi
+}
+
+func (s *BitmapSuite) TestBuild(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	branch := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	_, idx, bi := s.build(c, head, branch)
+
+	c.Assert(bi.Version, Equals, uint16(VersionSupported))
+	c.Assert(bi.Options, Equals, uint16(OptFullDAG))
+	c.Assert(bi.PackfileChecksum, Equals, idx.PackfileChecksum)
+	c.Assert(bi.Entries, HasLen, 2)
+
+	commits, err := bi.Commits.Bitmap()
+	c.Assert(err, IsNil)
+	c.Assert(commits.Count(), Equals, 9)
+
+	blobs, err := bi.Blobs.Bitmap()
+	c.Assert(err, IsNil)
+	trees, err := bi.Trees.Bitmap()
+	c.Assert(err, IsNil)
+	c.Assert(commits.Count()+trees.Count()+blobs.Count(), Equals, 31)
+}
+
+func (s *BitmapSuite) TestReachable(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	sto, idx, bi := s.build(c, head)
+
+	pb, err := NewPackBitmap(idx, bi)
+	c.Assert(err, IsNil)
+	c.Assert(pb.Commits(), DeepEquals, []plumbing.Hash{head})
+
+	for _, h := range []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	} {
+		hash := plumbing.NewHash(h)
+		b, err := revlist.BitmapObjects(sto, pb, []plumbing.Hash{hash})
+		c.Assert(err, IsNil)
+
+		expected, err := revlist.Objects(sto, []plumbing.Hash{hash}, nil)
+		c.Assert(err, IsNil)
+
+		c.Assert(hashSet(pb.Hashes(b)), DeepEquals, hashSet(expected))
+	}
+
+	stored, err := pb.Commit(head)
+	c.Assert(err, IsNil)
+	c.Assert(stored.Count(), Equals, 28)
+
+	_, err = pb.Commit(plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+	c.Assert(err, Equals, ErrBitmapNotFound)
+}
+
+func (s *BitmapSuite) TestReachableNotInPack(c *C) {
+	sto, idx, bi := s.build(c)
+
+	pb, err := NewPackBitmap(idx, bi)
+	c.Assert(err, IsNil)
+
+	_, err = revlist.BitmapObjects(sto, pb, []plumbing.Hash{plumbing.NewHash("0000000000000000000000000000000000000001")})
+	c.Assert(err, Equals, ErrObjectNotInPack)
+}
+
+func (s *BitmapSuite) TestType(c *C) {
+	_, idx, bi := s.build(c)
+
+	pb, err := NewPackBitmap(idx, bi)
+	c.Assert(err, IsNil)
+
+	pos, ok := pb.Position(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(ok, Equals, true)
+	c.Assert(pb.Type(pos), Equals, plumbing.CommitObject)
+	c.Assert(pb.Hash(pos), Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	pos, ok = pb.Position(plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa"))
+	c.Assert(ok, Equals, true)
+	c.Assert(pb.Type(pos), Equals, plumbing.BlobObject)
+}
+
+func (s *BitmapSuite) TestEncodeDecode(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	branch := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	_, idx, bi := s.build(c, head, branch)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(bi), IsNil)
+
+	count, err := idx.Count()
+	c.Assert(err, IsNil)
+
+	decoded := &Index{}
+	c.Assert(NewDecoder(bytes.NewReader(buf.Bytes()), count).Decode(decoded), IsNil)
+	c.Assert(decoded, DeepEquals, bi)
+
+	pb, err := NewPackBitmap(idx, decoded)
+	c.Assert(err, IsNil)
+
+	for _, h := range []plumbing.Hash{head, branch} {
+		b, err := pb.Commit(h)
+		c.Assert(err, IsNil)
+		c.Assert(b.Count() > 0, Equals, true)
+	}
+}
+
+func (s *BitmapSuite) TestDecodeBadChecksum(c *C) {
+	_, idx, bi := s.build(c)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(bi), IsNil)
+
+	data := buf.Bytes()
+	data[len(data)-1]++
+
+	count, err := idx.Count()
+	c.Assert(err, IsNil)
+
+	err = NewDecoder(bytes.NewReader(data), count).Decode(&Index{})
+	c.Assert(err, Equals, ErrMalformedBitmapFile)
+}
+
+func (s *BitmapSuite) TestDecodeBadHeader(c *C) {
+	err := NewDecoder(bytes.NewReader([]byte("BITX\x00\x01")), 0).Decode(&Index{})
+	c.Assert(err, Equals, ErrMalformedBitmapFile)
+
+	err = NewDecoder(bytes.NewReader([]byte("BITM\x00\x02")), 0).Decode(&Index{})
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+
+	// more entries than objects in the packfile
+	err = NewDecoder(bytes.NewReader([]byte("BITM\x00\x01\x00\x00\xff\xff\xff\xff")), 10).Decode(&Index{})
+	c.Assert(err, Equals, ErrMalformedBitmapFile)
+}
+
+func (s *BitmapSuite) TestNewPackBitmapMismatch(c *C) {
+	_, idx, bi := s.build(c)
+	bi.PackfileChecksum[0]++
+
+	_, err := NewPackBitmap(idx, bi)
+	c.Assert(err, Equals, ErrPackfileMismatch)
+}
+
+func hashSet(hashes []plumbing.Hash) map[plumbing.Hash]bool {
+	set := make(map[plumbing.Hash]bool)
+	for _, h := range hashes {
+		set[h] = true
+	}
+
+	return set
+}
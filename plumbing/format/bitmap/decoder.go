@@ -0,0 +1,185 @@
+package bitmap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the bitmap file
+	// version is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrMalformedBitmapFile is returned by Decode when the bitmap file is
+	// corrupted.
+	ErrMalformedBitmapFile = errors.New("malformed bitmap file")
+)
+
+const lookupTableEntrySize = 4 + 8 + 4
+
+// Decoder reads and decodes bitmap files from an input stream.
+type Decoder struct {
+	r    io.Reader
+	hash hash.Hash
+	// count is the number of objects in the packfile, needed to read the
+	// name-hash cache.
+	count int64
+}
+
+// NewDecoder builds a new bitmap stream decoder, that reads from r. count is
+// the number of objects in the packfile the bitmap belongs to.
+func NewDecoder(r io.Reader, count int64) *Decoder {
+	h := sha1.New()
+	return &Decoder{
+		r:     io.TeeReader(bufio.NewReader(r), h),
+		hash:  h,
+		count: count,
+	}
+}
+
+// Decode reads from the stream and decode the content into the Index struct.
+func (d *Decoder) Decode(idx *Index) error {
+	flow := []func(*Index) error{
+		d.readHeader,
+		d.readTypeBitmaps,
+		d.readEntries,
+		d.readLookupTable,
+		d.readHashCache,
+		d.readChecksum,
+	}
+
+	for _, f := range flow {
+		if err := f(idx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Decoder) readHeader(idx *Index) error {
+	var h = make([]byte, 4)
+	if _, err := io.ReadFull(d.r, h); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h, bitmapHeader) {
+		return ErrMalformedBitmapFile
+	}
+
+	v, err := binary.ReadUint16(d.r)
+	if err != nil {
+		return err
+	}
+
+	if v != VersionSupported {
+		return ErrUnsupportedVersion
+	}
+
+	idx.Version = v
+	if idx.Options, err = binary.ReadUint16(d.r); err != nil {
+		return err
+	}
+
+	count, err := binary.ReadUint32(d.r)
+	if err != nil {
+		return err
+	}
+
+	// only the commits of the packfile have an entry
+	if int64(count) > d.count {
+		return ErrMalformedBitmapFile
+	}
+
+	idx.Entries = make([]*Entry, count)
+	_, err = io.ReadFull(d.r, idx.PackfileChecksum[:])
+	return err
+}
+
+func (d *Decoder) readTypeBitmaps(idx *Index) error {
+	for _, dst := range []**EWAH{&idx.Commits, &idx.Trees, &idx.Blobs, &idx.Tags} {
+		e, err := decodeEWAH(d.r, d.count)
+		if err != nil {
+			return err
+		}
+
+		*dst = e
+	}
+
+	return nil
+}
+
+func (d *Decoder) readEntries(idx *Index) error {
+	for i := range idx.Entries {
+		e := &Entry{}
+		var err error
+		if e.ObjectPos, err = binary.ReadUint32(d.r); err != nil {
+			return err
+		}
+
+		if err := binary.Read(d.r, &e.XorOffset, &e.Flags); err != nil {
+			return err
+		}
+
+		if int(e.XorOffset) > i || e.XorOffset > maxXorOffset {
+			return ErrMalformedBitmapFile
+		}
+
+		if e.Bitmap, err = decodeEWAH(d.r, d.count); err != nil {
+			return err
+		}
+
+		idx.Entries[i] = e
+	}
+
+	return nil
+}
+
+// readLookupTable skips the lookup table, it only speeds up finding a single
+// entry without reading the whole file and we always read all of them.
+func (d *Decoder) readLookupTable(idx *Index) error {
+	if idx.Options&OptLookupTable == 0 {
+		return nil
+	}
+
+	n := int64(len(idx.Entries)) * lookupTableEntrySize
+	_, err := io.CopyN(ioutil.Discard, d.r, n)
+	return err
+}
+
+func (d *Decoder) readHashCache(idx *Index) error {
+	if idx.Options&OptHashCache == 0 {
+		return nil
+	}
+
+	idx.HashCache = make([]uint32, d.count)
+	for i := range idx.HashCache {
+		v, err := binary.ReadUint32(d.r)
+		if err != nil {
+			return err
+		}
+
+		idx.HashCache[i] = v
+	}
+
+	return nil
+}
+
+func (d *Decoder) readChecksum(idx *Index) error {
+	sum := d.hash.Sum(nil)
+	if _, err := io.ReadFull(d.r, idx.Checksum[:]); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(sum, idx.Checksum[:]) {
+		return ErrMalformedBitmapFile
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package bitmap implements encoding and decoding of packfile reachability
+// bitmaps, the .bitmap files git writes next to the .idx files.
+//
+// A bitmap has one bit for every object in the packfile, the position of
+// the bit being the position of the object in the packfile when the objects
+// are sorted by offset. The bitmap of a commit has set the bits of every
+// object reachable from it, so the objects needed to go from a set of
+// commits to another are computed with a few bitwise operations instead of
+// walking the history.
+//
+//  == pack-*.bitmap files have the following format:
+//
+//    - A header appears at the beginning:
+//
+//      4-byte signature: {'B', 'I', 'T', 'M'}
+//
+//      2-byte version number (network byte order): The current
+//      implementation only supports version 1 of the bitmap index.
+//
+//      2-byte flags (network byte order): BITMAP_OPT_FULL_DAG (0x1),
+//      BITMAP_OPT_HASH_CACHE (0x4) and BITMAP_OPT_LOOKUP_TABLE (0x10).
+//
+//      4-byte entry count (network byte order): The total count of entries
+//      (bitmapped commits) in this bitmap index.
+//
+//      20-byte checksum: The SHA1 checksum of the pack this bitmap index
+//      belongs to.
+//
+//    - 4 EWAH bitmaps that act as type indexes, with the objects of type
+//      commit, tree, blob and tag of the packfile.
+//
+//    - N entries with compressed bitmaps, one for each indexed commit:
+//
+//      4-byte object position (network byte order): The position in the
+//      index for the packfile where the bitmap for this commit is found.
+//
+//      1-byte XOR-offset: The xor offset used to compress this bitmap. For
+//      an entry in position x, a XOR offset of y means that the actual
+//      bitmap representing this commit is composed by XORing the bitmap
+//      for this entry with the bitmap in entry x-y.
+//
+//      1-byte flag for this bitmap.
+//
+//      EWAH bitmap: The compressed bitmap for the commit.
+//
+//    - An optional lookup table, with one 16-byte row per entry, if
+//      BITMAP_OPT_LOOKUP_TABLE is set.
+//
+//    - An optional name-hash cache, one 4-byte hash per object in the
+//      packfile, if BITMAP_OPT_HASH_CACHE is set.
+//
+//    - A 20-byte SHA1 checksum of all of the above.
+//
+//  == EWAH bitmaps are serialized as:
+//
+//    - 4-byte number of bits of the uncompressed bitmap.
+//
+//    - 4-byte number of words of the compressed bitmap.
+//
+//    - The compressed words, 8 bytes each.
+//
+//    - 4-byte position of the current marker word.
+//
+//  All the integers are stored in network byte order.
+package bitmap
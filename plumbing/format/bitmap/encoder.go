@@ -0,0 +1,102 @@
+package bitmap
+
+import (
+	"crypto/sha1"
+	"hash"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+)
+
+// Encoder writes Index structs to an output stream.
+type Encoder struct {
+	w    io.Writer
+	hash hash.Hash
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	h := sha1.New()
+	mw := io.MultiWriter(w, h)
+	return &Encoder{mw, h}
+}
+
+// Encode encodes an Index to the encoder writer. The lookup table is never
+// written, so OptLookupTable is cleared from the index options.
+func (e *Encoder) Encode(idx *Index) error {
+	idx.Options &^= OptLookupTable
+	if len(idx.HashCache) == 0 {
+		idx.Options &^= OptHashCache
+	}
+
+	flow := []func(*Index) error{
+		e.encodeHeader,
+		e.encodeTypeBitmaps,
+		e.encodeEntries,
+		e.encodeHashCache,
+		e.encodeChecksum,
+	}
+
+	for _, f := range flow {
+		if err := f(idx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeHeader(idx *Index) error {
+	if _, err := e.w.Write(bitmapHeader); err != nil {
+		return err
+	}
+
+	if err := binary.Write(e.w, idx.Version, idx.Options); err != nil {
+		return err
+	}
+
+	if err := binary.WriteUint32(e.w, uint32(len(idx.Entries))); err != nil {
+		return err
+	}
+
+	_, err := e.w.Write(idx.PackfileChecksum[:])
+	return err
+}
+
+func (e *Encoder) encodeTypeBitmaps(idx *Index) error {
+	for _, b := range []*EWAH{idx.Commits, idx.Trees, idx.Blobs, idx.Tags} {
+		if err := encodeEWAH(e.w, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeEntries(idx *Index) error {
+	for _, entry := range idx.Entries {
+		if err := binary.Write(e.w, entry.ObjectPos, entry.XorOffset, entry.Flags); err != nil {
+			return err
+		}
+
+		if err := encodeEWAH(e.w, entry.Bitmap); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeHashCache(idx *Index) error {
+	if idx.Options&OptHashCache == 0 {
+		return nil
+	}
+
+	return binary.Write(e.w, idx.HashCache)
+}
+
+func (e *Encoder) encodeChecksum(idx *Index) error {
+	copy(idx.Checksum[:], e.hash.Sum(nil))
+	_, err := e.w.Write(idx.Checksum[:])
+	return err
+}
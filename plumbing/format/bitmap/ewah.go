@@ -0,0 +1,330 @@
+package bitmap
+
+import (
+	encbin "encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+)
+
+const (
+	wordBits = 64
+
+	rlwRunningBits = 32
+	rlwLiteralBits = 31
+
+	rlwMaxRunningLength = 1<<rlwRunningBits - 1
+	rlwMaxLiteralWords  = 1<<rlwLiteralBits - 1
+
+	// decodeChunkWords is the number of words of an EWAH bitmap read at
+	// once, so a corrupted word count does not allocate more memory than
+	// the data actually read.
+	decodeChunkWords = 1024
+)
+
+// ErrMalformedEWAH is returned when an EWAH compressed bitmap is corrupted.
+var ErrMalformedEWAH = errors.New("malformed EWAH bitmap")
+
+// Bitmap is an uncompressed set of bit positions. Positions in a pack bitmap
+// are the positions of the objects in the packfile, ordered by offset.
+type Bitmap struct {
+	words []uint64
+}
+
+// NewBitmap returns an empty Bitmap.
+func NewBitmap() *Bitmap {
+	return &Bitmap{}
+}
+
+// Set sets the bit at the given position.
+func (b *Bitmap) Set(pos uint32) {
+	w := int(pos / wordBits)
+	if w >= len(b.words) {
+		b.grow(w + 1)
+	}
+
+	b.words[w] |= 1 << (pos % wordBits)
+}
+
+// Has returns true if the bit at the given position is set.
+func (b *Bitmap) Has(pos uint32) bool {
+	w := int(pos / wordBits)
+	if w >= len(b.words) {
+		return false
+	}
+
+	return b.words[w]&(1<<(pos%wordBits)) != 0
+}
+
+// Or sets every bit that is set in o.
+func (b *Bitmap) Or(o *Bitmap) {
+	if len(o.words) > len(b.words) {
+		b.grow(len(o.words))
+	}
+
+	for i, w := range o.words {
+		b.words[i] |= w
+	}
+}
+
+// Xor flips every bit that is set in o.
+func (b *Bitmap) Xor(o *Bitmap) {
+	if len(o.words) > len(b.words) {
+		b.grow(len(o.words))
+	}
+
+	for i, w := range o.words {
+		b.words[i] ^= w
+	}
+}
+
+// And clears every bit that is not set in o.
+func (b *Bitmap) And(o *Bitmap) {
+	for i := range b.words {
+		if i < len(o.words) {
+			b.words[i] &= o.words[i]
+		} else {
+			b.words[i] = 0
+		}
+	}
+}
+
+// AndNot clears every bit that is set in o.
+func (b *Bitmap) AndNot(o *Bitmap) {
+	for i := range b.words {
+		if i >= len(o.words) {
+			break
+		}
+
+		b.words[i] &^= o.words[i]
+	}
+}
+
+// Count returns the number of bits set.
+func (b *Bitmap) Count() int {
+	var n int
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+
+	return n
+}
+
+// Clone returns a copy of the bitmap.
+func (b *Bitmap) Clone() *Bitmap {
+	c := &Bitmap{words: make([]uint64, len(b.words))}
+	copy(c.words, b.words)
+	return c
+}
+
+// ForEach calls fn with the position of every bit set, in ascending order.
+// If fn returns an error the iteration stops and the error is returned.
+func (b *Bitmap) ForEach(fn func(pos uint32) error) error {
+	for i, w := range b.words {
+		for w != 0 {
+			t := bits.TrailingZeros64(w)
+			if err := fn(uint32(i*wordBits + t)); err != nil {
+				return err
+			}
+
+			w &= w - 1
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of bits covered by the bitmap, this is the position
+// of the highest bit set plus one.
+func (b *Bitmap) Len() uint32 {
+	for i := len(b.words) - 1; i >= 0; i-- {
+		if b.words[i] != 0 {
+			return uint32(i*wordBits + wordBits - bits.LeadingZeros64(b.words[i]))
+		}
+	}
+
+	return 0
+}
+
+func (b *Bitmap) grow(n int) {
+	words := make([]uint64, n)
+	copy(words, b.words)
+	b.words = words
+}
+
+// EWAH is a bitmap compressed using the Enhanced Word-Aligned Hybrid scheme,
+// as stored in git .bitmap files.
+//
+// The compressed buffer is a sequence of 64-bit words. Each marker word
+// (RLW) holds, from the least significant bit, the running bit, a 32-bit
+// count of clean words filled with the running bit and a 31-bit count of
+// literal words following the marker.
+type EWAH struct {
+	// BitSize is the number of bits covered by the bitmap.
+	BitSize uint32
+	// Words is the compressed buffer.
+	Words []uint64
+	// RLW is the position in Words of the last marker word.
+	RLW uint32
+}
+
+// NewEWAH compresses the given bitmap.
+func NewEWAH(b *Bitmap) *EWAH {
+	e := &EWAH{BitSize: b.Len()}
+
+	words := b.words[:(e.BitSize+wordBits-1)/wordBits]
+	if len(words) == 0 {
+		e.Words = []uint64{0}
+		return e
+	}
+
+	for i := 0; i < len(words); {
+		var running uint64
+		var run uint64
+		if clean(words[i]) {
+			fill := words[i]
+			if fill != 0 {
+				running = 1
+			}
+
+			for i < len(words) && words[i] == fill && run < rlwMaxRunningLength {
+				run++
+				i++
+			}
+		}
+
+		start := i
+		for i < len(words) && !clean(words[i]) && uint64(i-start) < rlwMaxLiteralWords {
+			i++
+		}
+
+		e.RLW = uint32(len(e.Words))
+		literals := uint64(i - start)
+		e.Words = append(e.Words, running|run<<1|literals<<(1+rlwRunningBits))
+		e.Words = append(e.Words, words[start:i]...)
+	}
+
+	return e
+}
+
+func clean(w uint64) bool {
+	return w == 0 || w == ^uint64(0)
+}
+
+// Bitmap returns the uncompressed bitmap. ErrMalformedEWAH is returned if
+// the words of the bitmap cover more bits than BitSize.
+func (e *EWAH) Bitmap() (*Bitmap, error) {
+	n := (uint64(e.BitSize) + wordBits - 1) / wordBits
+	b := &Bitmap{words: make([]uint64, 0, n)}
+	for i := 0; i < len(e.Words); {
+		rlw := e.Words[i]
+		i++
+
+		var fill uint64
+		if rlw&1 != 0 {
+			fill = ^uint64(0)
+		}
+
+		run := (rlw >> 1) & rlwMaxRunningLength
+		literals := rlw >> (1 + rlwRunningBits)
+		if uint64(len(b.words))+run+literals > n || uint64(i)+literals > uint64(len(e.Words)) {
+			return nil, ErrMalformedEWAH
+		}
+
+		for ; run > 0; run-- {
+			b.words = append(b.words, fill)
+		}
+
+		b.words = append(b.words, e.Words[i:i+int(literals)]...)
+		i += int(literals)
+	}
+
+	// Clear the bits of the last word beyond the bitmap size that a run of
+	// ones may have set.
+	if r := e.BitSize % wordBits; r != 0 && uint64(len(b.words)) == n {
+		b.words[len(b.words)-1] &= 1<<r - 1
+	}
+
+	return b, nil
+}
+
+// decodeEWAH reads an EWAH bitmap with the layout used by git: the bit size,
+// the number of words, the words and the position of the last marker word,
+// all of them in network byte order. The bitmap must cover at most count
+// positions, rounded up to a whole word.
+func decodeEWAH(r io.Reader, count int64) (*EWAH, error) {
+	e := &EWAH{}
+	var err error
+	if e.BitSize, err = binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	maxWords := (count + wordBits - 1) / wordBits
+	if int64(e.BitSize) > maxWords*wordBits {
+		return nil, ErrMalformedEWAH
+	}
+
+	n, err := binary.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// every literal word of the bitmap needs at most a marker word
+	if int64(n) > 2*maxWords+1 {
+		return nil, ErrMalformedEWAH
+	}
+
+	chunk := int(n)
+	if chunk > decodeChunkWords {
+		chunk = decodeChunkWords
+	}
+
+	buf := make([]byte, chunk*8)
+	e.Words = make([]uint64, 0, chunk)
+	for left := int(n); left > 0; left -= chunk {
+		if left < chunk {
+			chunk = left
+		}
+
+		if _, err := io.ReadFull(r, buf[:chunk*8]); err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < chunk; i++ {
+			e.Words = append(e.Words, encbin.BigEndian.Uint64(buf[i*8:]))
+		}
+	}
+
+	if e.RLW, err = binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	if n > 0 && e.RLW >= n {
+		return nil, ErrMalformedEWAH
+	}
+
+	return e, nil
+}
+
+func encodeEWAH(w io.Writer, e *EWAH) error {
+	if err := binary.WriteUint32(w, e.BitSize); err != nil {
+		return err
+	}
+
+	if err := binary.WriteUint32(w, uint32(len(e.Words))); err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(e.Words)*8)
+	for i, word := range e.Words {
+		encbin.BigEndian.PutUint64(buf[i*8:], word)
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	return binary.WriteUint32(w, e.RLW)
+}
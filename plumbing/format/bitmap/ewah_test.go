@@ -0,0 +1,155 @@
+package bitmap
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type EWAHSuite struct{}
+
+var _ = Suite(&EWAHSuite{})
+
+func (s *EWAHSuite) TestBitmapOperations(c *C) {
+	a := NewBitmap()
+	a.Set(1)
+	a.Set(64)
+	a.Set(200)
+
+	b := NewBitmap()
+	b.Set(64)
+	b.Set(65)
+
+	c.Assert(a.Has(64), Equals, true)
+	c.Assert(a.Has(65), Equals, false)
+	c.Assert(a.Has(10000), Equals, false)
+	c.Assert(a.Count(), Equals, 3)
+	c.Assert(a.Len(), Equals, uint32(201))
+
+	or := a.Clone()
+	or.Or(b)
+	c.Assert(positions(or), DeepEquals, []uint32{1, 64, 65, 200})
+
+	and := a.Clone()
+	and.And(b)
+	c.Assert(positions(and), DeepEquals, []uint32{64})
+
+	andNot := a.Clone()
+	andNot.AndNot(b)
+	c.Assert(positions(andNot), DeepEquals, []uint32{1, 200})
+
+	xor := a.Clone()
+	xor.Xor(b)
+	c.Assert(positions(xor), DeepEquals, []uint32{1, 65, 200})
+
+	c.Assert(positions(a), DeepEquals, []uint32{1, 64, 200})
+}
+
+func (s *EWAHSuite) TestCompressEmpty(c *C) {
+	e := NewEWAH(NewBitmap())
+	c.Assert(e.BitSize, Equals, uint32(0))
+	c.Assert(e.Words, DeepEquals, []uint64{0})
+
+	b, err := e.Bitmap()
+	c.Assert(err, IsNil)
+	c.Assert(b.Count(), Equals, 0)
+}
+
+func (s *EWAHSuite) TestCompressRuns(c *C) {
+	b := NewBitmap()
+	for i := uint32(128); i < 640; i++ {
+		b.Set(i)
+	}
+
+	b.Set(700)
+
+	e := NewEWAH(b)
+	c.Assert(e.BitSize, Equals, uint32(701))
+	c.Assert(e.Words, HasLen, 3)
+	// two clean words of zeros, eight of ones followed by a literal
+	c.Assert(e.Words[0], Equals, uint64(2<<1))
+	c.Assert(e.Words[1], Equals, uint64(1|8<<1|1<<33))
+	c.Assert(e.Words[2], Equals, uint64(1<<(700-640)))
+	c.Assert(e.RLW, Equals, uint32(1))
+
+	d, err := e.Bitmap()
+	c.Assert(err, IsNil)
+	c.Assert(positions(d), DeepEquals, positions(b))
+}
+
+func (s *EWAHSuite) TestRoundTrip(c *C) {
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 50; i++ {
+		b := NewBitmap()
+		size := r.Intn(10000) + 1
+		for j := 0; j < size; j++ {
+			// mix of dense and sparse areas
+			if (j/512)%2 == 0 || r.Intn(100) == 0 {
+				b.Set(uint32(j))
+			}
+		}
+
+		buf := bytes.NewBuffer(nil)
+		c.Assert(encodeEWAH(buf, NewEWAH(b)), IsNil)
+
+		e, err := decodeEWAH(buf, int64(size))
+		c.Assert(err, IsNil)
+		c.Assert(buf.Len(), Equals, 0)
+
+		d, err := e.Bitmap()
+		c.Assert(err, IsNil)
+		c.Assert(positions(d), DeepEquals, positions(b))
+	}
+}
+
+func (s *EWAHSuite) TestBitmapMalformed(c *C) {
+	e := &EWAH{BitSize: 64, Words: []uint64{2 << 33}}
+	_, err := e.Bitmap()
+	c.Assert(err, Equals, ErrMalformedEWAH)
+
+	// a run longer than the bitmap size is not expanded
+	e = &EWAH{BitSize: 64, Words: []uint64{1 | rlwMaxRunningLength<<1}}
+	_, err = e.Bitmap()
+	c.Assert(err, Equals, ErrMalformedEWAH)
+
+	e = &EWAH{BitSize: 64, Words: []uint64{1<<1 | 1<<33, 1}}
+	_, err = e.Bitmap()
+	c.Assert(err, Equals, ErrMalformedEWAH)
+}
+
+func (s *EWAHSuite) TestDecodeMalformed(c *C) {
+	for _, t := range []struct {
+		bitSize, words uint32
+		count          int64
+		err            error
+	}{
+		{bitSize: 129, words: 1, count: 128, err: ErrMalformedEWAH},
+		{bitSize: 128, words: 6, count: 128, err: ErrMalformedEWAH},
+		{bitSize: 64, words: 1 << 27, count: 1 << 32, err: io.ErrUnexpectedEOF},
+	} {
+		buf := bytes.NewBuffer(nil)
+		c.Assert(binary.WriteUint32(buf, t.bitSize), IsNil)
+		c.Assert(binary.WriteUint32(buf, t.words), IsNil)
+		c.Assert(binary.WriteUint64(buf, 1<<1), IsNil)
+
+		_, err := decodeEWAH(buf, t.count)
+		c.Assert(err, Equals, t.err)
+	}
+}
+
+func positions(b *Bitmap) []uint32 {
+	var result []uint32
+	b.ForEach(func(pos uint32) error {
+		result = append(result, pos)
+		return nil
+	})
+
+	return result
+}
@@ -0,0 +1,81 @@
+package bundle
+
+import (
+	"errors"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	// V2 is the version 2 of the bundle format, the default one.
+	V2 = 2
+	// V3 is the version 3 of the bundle format, the header supports
+	// capabilities.
+	V3 = 3
+
+	// ObjectFormat is the name of the capability with the hash algorithm of
+	// the objects.
+	ObjectFormat = "object-format"
+	// SHA1 is the value of the ObjectFormat capability for SHA1 hashes, the
+	// only one supported.
+	SHA1 = "sha1"
+
+	v2Signature = "# v2 git bundle"
+	v3Signature = "# v3 git bundle"
+)
+
+var (
+	// ErrUnsupportedVersion is returned when the version of the bundle is
+	// not supported.
+	ErrUnsupportedVersion = errors.New("unsupported bundle version")
+	// ErrUnsupportedObjectFormat is returned by Decode when the objects of
+	// the bundle are not hashed with SHA1.
+	ErrUnsupportedObjectFormat = errors.New("unsupported bundle object format")
+	// ErrMalformedBundle is returned by Decode when the header of the bundle
+	// is corrupted.
+	ErrMalformedBundle = errors.New("malformed bundle header")
+)
+
+// Bundle is the content of a bundle file.
+type Bundle struct {
+	// Version is the version of the bundle format, V2 if zero.
+	Version int
+	// Capabilities are the capabilities of the bundle, only supported by
+	// the version 3.
+	Capabilities []Capability
+	// Prerequisites are the objects required to unpack the packfile, the
+	// packfile has no objects reachable from them.
+	Prerequisites []Prerequisite
+	// References are the references contained in the bundle.
+	References []*plumbing.Reference
+	// Packfile is the packfile with the objects of the bundle. It is read
+	// from the input after the header when decoding, and copied to the
+	// output after the header when encoding, if not nil.
+	Packfile io.Reader
+}
+
+// Capability is a capability of a version 3 bundle.
+type Capability struct {
+	Name  string
+	Value string
+}
+
+// Prerequisite is an object required by the packfile of a bundle.
+type Prerequisite struct {
+	Hash plumbing.Hash
+	// Comment is an optional description, usually the subject of the commit.
+	Comment string
+}
+
+// Head returns the reference of the bundle named HEAD, or nil if it does not
+// contain it.
+func (b *Bundle) Head() *plumbing.Reference {
+	for _, ref := range b.References {
+		if ref.Name() == plumbing.HEAD {
+			return ref
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package bundle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type BundleSuite struct{}
+
+var _ = Suite(&BundleSuite{})
+
+const (
+	fooHash = "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	barHash = "918c48b83bd081e863dbe1b80f8998f058cd8294"
+)
+
+func (s *BundleSuite) TestDecode(c *C) {
+	input := "# v2 git bundle\n" +
+		"-" + barHash + " some subject\n" +
+		fooHash + " refs/heads/master\n" +
+		fooHash + " HEAD\n" +
+		"\n" +
+		"PACK"
+
+	b := &Bundle{}
+	err := NewDecoder(strings.NewReader(input)).Decode(b)
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, V2)
+	c.Assert(b.Capabilities, HasLen, 0)
+	c.Assert(b.Prerequisites, DeepEquals, []Prerequisite{
+		{Hash: plumbing.NewHash(barHash), Comment: "some subject"},
+	})
+
+	c.Assert(b.References, HasLen, 2)
+	c.Assert(b.References[0].Name(), Equals, plumbing.Master)
+	c.Assert(b.References[0].Hash().String(), Equals, fooHash)
+	c.Assert(b.Head().Hash().String(), Equals, fooHash)
+
+	pack, err := ioutil.ReadAll(b.Packfile)
+	c.Assert(err, IsNil)
+	c.Assert(string(pack), Equals, "PACK")
+}
+
+func (s *BundleSuite) TestDecodeV3(c *C) {
+	input := "# v3 git bundle\n" +
+		"@object-format=sha1\n" +
+		"@filter\n" +
+		fooHash + " refs/heads/master\n" +
+		"\n"
+
+	b := &Bundle{}
+	err := NewDecoder(strings.NewReader(input)).Decode(b)
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, V3)
+	c.Assert(b.Capabilities, DeepEquals, []Capability{
+		{Name: ObjectFormat, Value: SHA1},
+		{Name: "filter"},
+	})
+
+	c.Assert(b.References, HasLen, 1)
+	c.Assert(b.Head(), IsNil)
+}
+
+func (s *BundleSuite) TestDecodeErrors(c *C) {
+	for input, expected := range map[string]error{
+		"":                                     ErrMalformedBundle,
+		"# v4 git bundle\n\n":                  ErrUnsupportedVersion,
+		"# v2 git bundle\n":                    ErrMalformedBundle,
+		"# v2 git bundle\n@foo\n\n":            ErrMalformedBundle,
+		"# v2 git bundle\nfoo HEAD\n\n":        ErrMalformedBundle,
+		"# v2 git bundle\n" + fooHash + "\n\n": ErrMalformedBundle,
+		"# v2 git bundle\n" + fooHash + " HEAD\n-" + barHash + "\n\n": ErrMalformedBundle,
+		"# v3 git bundle\n@object-format=sha256\n\n":                  ErrUnsupportedObjectFormat,
+	} {
+		err := NewDecoder(strings.NewReader(input)).Decode(&Bundle{})
+		c.Assert(err, Equals, expected, Commentf("%q", input))
+	}
+}
+
+func (s *BundleSuite) TestEncode(c *C) {
+	b := &Bundle{
+		Prerequisites: []Prerequisite{
+			{Hash: plumbing.NewHash(barHash), Comment: "some subject"},
+			{Hash: plumbing.NewHash(fooHash)},
+		},
+		References: []*plumbing.Reference{
+			plumbing.NewHashReference(plumbing.Master, plumbing.NewHash(fooHash)),
+		},
+		Packfile: strings.NewReader("PACK"),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(b), IsNil)
+	c.Assert(buf.String(), Equals, "# v2 git bundle\n"+
+		"-"+barHash+" some subject\n"+
+		"-"+fooHash+"\n"+
+		fooHash+" refs/heads/master\n"+
+		"\n"+
+		"PACK")
+}
+
+func (s *BundleSuite) TestEncodeV3(c *C) {
+	b := &Bundle{
+		Version:      V3,
+		Capabilities: []Capability{{Name: ObjectFormat, Value: SHA1}},
+		References: []*plumbing.Reference{
+			plumbing.NewHashReference(plumbing.HEAD, plumbing.NewHash(fooHash)),
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(b), IsNil)
+	c.Assert(buf.String(), Equals, "# v3 git bundle\n"+
+		"@object-format=sha1\n"+
+		fooHash+" HEAD\n"+
+		"\n")
+
+	decoded := &Bundle{}
+	c.Assert(NewDecoder(buf).Decode(decoded), IsNil)
+	c.Assert(decoded.Capabilities, DeepEquals, b.Capabilities)
+	c.Assert(decoded.References, DeepEquals, b.References)
+}
+
+func (s *BundleSuite) TestEncodeErrors(c *C) {
+	err := NewEncoder(ioutil.Discard).Encode(&Bundle{Version: 1})
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+
+	err = NewEncoder(ioutil.Discard).Encode(&Bundle{
+		Capabilities: []Capability{{Name: ObjectFormat, Value: SHA1}},
+	})
+
+	c.Assert(err, NotNil)
+}
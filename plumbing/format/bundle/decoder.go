@@ -0,0 +1,138 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// Decoder reads and decodes bundle files from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder builds a new bundle stream decoder, that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads the header of the bundle from the stream, the Packfile of the
+// bundle reads the rest of the stream.
+func (d *Decoder) Decode(b *Bundle) error {
+	line, err := d.readLine()
+	if err != nil {
+		return err
+	}
+
+	switch line {
+	case v2Signature:
+		b.Version = V2
+	case v3Signature:
+		b.Version = V3
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return err
+		}
+
+		if line == "" {
+			break
+		}
+
+		if err := d.decodeLine(b, line); err != nil {
+			return err
+		}
+	}
+
+	b.Packfile = d.r
+	return nil
+}
+
+func (d *Decoder) decodeLine(b *Bundle, line string) error {
+	switch line[0] {
+	case '@':
+		if b.Version != V3 || len(b.Prerequisites) != 0 || len(b.References) != 0 {
+			return ErrMalformedBundle
+		}
+
+		c := Capability{Name: line[1:]}
+		if i := strings.IndexByte(c.Name, '='); i >= 0 {
+			c.Name, c.Value = c.Name[:i], c.Name[i+1:]
+		}
+
+		if c.Name == ObjectFormat && c.Value != SHA1 {
+			return ErrUnsupportedObjectFormat
+		}
+
+		b.Capabilities = append(b.Capabilities, c)
+	case '-':
+		if len(b.References) != 0 {
+			return ErrMalformedBundle
+		}
+
+		hash, comment := splitLine(line[1:])
+		h, err := parseHash(hash)
+		if err != nil {
+			return err
+		}
+
+		b.Prerequisites = append(b.Prerequisites, Prerequisite{Hash: h, Comment: comment})
+	default:
+		hash, name := splitLine(line)
+		h, err := parseHash(hash)
+		if err != nil {
+			return err
+		}
+
+		if name == "" {
+			return ErrMalformedBundle
+		}
+
+		b.References = append(b.References,
+			plumbing.NewHashReference(plumbing.ReferenceName(name), h))
+	}
+
+	return nil
+}
+
+func (d *Decoder) readLine() (string, error) {
+	line, err := d.r.ReadBytes('\n')
+	if err == io.EOF {
+		return "", ErrMalformedBundle
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimSuffix(line, []byte("\n"))), nil
+}
+
+func splitLine(line string) (string, string) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return line, ""
+	}
+
+	return line[:i], line[i+1:]
+}
+
+func parseHash(s string) (plumbing.Hash, error) {
+	if len(s) != 40 {
+		return plumbing.ZeroHash, ErrMalformedBundle
+	}
+
+	h := plumbing.NewHash(s)
+	if h.String() != s {
+		return plumbing.ZeroHash, ErrMalformedBundle
+	}
+
+	return h, nil
+}
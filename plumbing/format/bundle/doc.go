@@ -0,0 +1,30 @@
+// Package bundle implements encoding and decoding of git bundle files.
+//
+// A bundle is a file with a header listing references and the objects they
+// require, followed by a packfile with the objects needed to go from the
+// prerequisites to the references. Bundles are used to transfer objects
+// between repositories without a network connection, they can be cloned
+// and fetched like any other remote.
+//
+//  == The header of the bundle files has the following format:
+//
+//    - A signature line, "# v2 git bundle" or "# v3 git bundle".
+//
+//    - Only in version 3, any number of capability lines, "@" followed
+//      by the capability name and an optional "=" and value, such as
+//      "@object-format=sha1".
+//
+//    - Any number of prerequisite lines, "-" followed by an object id and
+//      an optional space and comment, usually the subject of the commit.
+//      The repository reading the bundle must already have the
+//      prerequisites.
+//
+//    - Any number of reference lines, an object id followed by a space and
+//      the name of the reference.
+//
+//    - An empty line, ending the header.
+//
+//  The packfile follows the header till the end of the file.
+//
+// https://git-scm.com/docs/gitformat-bundle
+package bundle
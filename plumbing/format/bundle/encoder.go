@@ -0,0 +1,81 @@
+package bundle
+
+import (
+	"fmt"
+	"io"
+)
+
+// Encoder writes Bundle structs to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes the header of the bundle to the stream, followed by the
+// content of its packfile, if any.
+func (e *Encoder) Encode(b *Bundle) error {
+	if err := e.encodeHeader(b); err != nil {
+		return err
+	}
+
+	if b.Packfile == nil {
+		return nil
+	}
+
+	_, err := io.Copy(e.w, b.Packfile)
+	return err
+}
+
+func (e *Encoder) encodeHeader(b *Bundle) error {
+	switch b.Version {
+	case 0, V2:
+		if len(b.Capabilities) != 0 {
+			return fmt.Errorf("capabilities require a version %d bundle", V3)
+		}
+
+		if _, err := fmt.Fprintln(e.w, v2Signature); err != nil {
+			return err
+		}
+	case V3:
+		if _, err := fmt.Fprintln(e.w, v3Signature); err != nil {
+			return err
+		}
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	for _, c := range b.Capabilities {
+		line := "@" + c.Name
+		if c.Value != "" {
+			line += "=" + c.Value
+		}
+
+		if _, err := fmt.Fprintln(e.w, line); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range b.Prerequisites {
+		line := "-" + p.Hash.String()
+		if p.Comment != "" {
+			line += " " + p.Comment
+		}
+
+		if _, err := fmt.Fprintln(e.w, line); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range b.References {
+		if _, err := fmt.Fprintf(e.w, "%s %s\n", ref.Hash(), ref.Name()); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(e.w)
+	return err
+}
@@ -0,0 +1,355 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+)
+
+const (
+	binaryPatchHeader = "GIT binary patch"
+	binaryLiteral     = "literal"
+	binaryDelta       = "delta"
+
+	// binaryLineBytes is the maximum number of bytes of a line of data of a
+	// binary patch.
+	binaryLineBytes = 52
+)
+
+var (
+	// ErrMalformedBinaryPatch is returned when a binary patch can not be
+	// decoded.
+	ErrMalformedBinaryPatch = errors.New("malformed binary patch")
+	// ErrNoReverseHunk is returned when reverting a binary patch without a
+	// reverse hunk.
+	ErrNoReverseHunk = errors.New("binary patch without reverse hunk")
+)
+
+// BinaryHunkType is the kind of the data of a BinaryHunk.
+type BinaryHunkType int
+
+const (
+	// BinaryLiteral hunks have the whole content of the resulting file.
+	BinaryLiteral BinaryHunkType = iota
+	// BinaryDelta hunks have a delta, as the ones of the packfiles, from the
+	// original file to the resulting one.
+	BinaryDelta
+)
+
+// BinaryHunk is a hunk of a binary patch, turning the content of a file into
+// another one.
+type BinaryHunk struct {
+	Type BinaryHunkType
+	// Data is the content of the resulting file, or the delta, inflated.
+	Data []byte
+}
+
+// Apply returns the result of applying the hunk to the given content.
+func (h *BinaryHunk) Apply(src []byte) ([]byte, error) {
+	if h.Type == BinaryDelta {
+		return packfile.PatchDelta(src, h.Data)
+	}
+
+	return h.Data, nil
+}
+
+// BinaryPatch is a binary patch of a file, in the `GIT binary patch` format
+// written by `git diff --binary`.
+type BinaryPatch struct {
+	// Forward turns the content of the file from into the one of the file to.
+	Forward *BinaryHunk
+	// Reverse turns the content of the file to into the one of the file
+	// from, nil if the patch has none.
+	Reverse *BinaryHunk
+}
+
+// Apply returns the content of the file to, given the one of the file from.
+func (p *BinaryPatch) Apply(from []byte) ([]byte, error) {
+	return p.Forward.Apply(from)
+}
+
+// Revert returns the content of the file from, given the one of the file to.
+func (p *BinaryPatch) Revert(to []byte) ([]byte, error) {
+	if p.Reverse == nil {
+		return nil, ErrNoReverseHunk
+	}
+
+	return p.Reverse.Apply(to)
+}
+
+// writeBinaryPatch writes the binary patch of the given contents, as git
+// does: each hunk has the delta or the whole content, whichever is smaller
+// once deflated.
+func writeBinaryPatch(w *bytes.Buffer, from, to []byte) error {
+	w.WriteString(binaryPatchHeader + "\n")
+	if err := writeBinaryHunk(w, from, to); err != nil {
+		return err
+	}
+
+	return writeBinaryHunk(w, to, from)
+}
+
+func writeBinaryHunk(w *bytes.Buffer, src, dst []byte) error {
+	data, err := deflate(dst)
+	if err != nil {
+		return err
+	}
+
+	kind, size := binaryLiteral, len(dst)
+	if len(src) != 0 && len(dst) != 0 {
+		delta := packfile.DiffDelta(src, dst)
+		if len(delta) <= len(data) {
+			deflated, err := deflate(delta)
+			if err != nil {
+				return err
+			}
+
+			if len(deflated) < len(data) {
+				kind, size, data = binaryDelta, len(delta), deflated
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%s %d\n", kind, size)
+	for len(data) > 0 {
+		n := min(len(data), binaryLineBytes)
+		if n <= 26 {
+			w.WriteByte(byte('A' + n - 1))
+		} else {
+			w.WriteByte(byte('a' + n - 27))
+		}
+
+		w.Write(encode85(data[:n]))
+		w.WriteByte('\n')
+		data = data[n:]
+	}
+
+	w.WriteByte('\n')
+	return nil
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// BinaryPatchDecoder decodes the binary patches of a patch.
+type BinaryPatchDecoder struct {
+	r *bufio.Reader
+}
+
+// NewBinaryPatchDecoder returns a new decoder reading from r. The lines
+// after each binary patch are not read, so r can be a bufio.Reader shared
+// with a parser of the rest of the patch.
+func NewBinaryPatchDecoder(r io.Reader) *BinaryPatchDecoder {
+	return &BinaryPatchDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next binary patch, skipping the lines before its
+// `GIT binary patch` line, and stores it in p. It returns io.EOF if there are
+// no more binary patches.
+func (d *BinaryPatchDecoder) Decode(p *BinaryPatch) error {
+	for {
+		line, err := d.readLine()
+		if line == binaryPatchHeader {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	forward, err := d.decodeHunk()
+	if err != nil {
+		return err
+	}
+
+	p.Forward, p.Reverse = forward, nil
+	next, err := d.r.Peek(len(binaryLiteral))
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if !bytes.HasPrefix(next, []byte(binaryLiteral)) &&
+		!bytes.HasPrefix(next, []byte(binaryDelta+" ")) {
+		return nil
+	}
+
+	p.Reverse, err = d.decodeHunk()
+	return err
+}
+
+// decodeHunk reads a hunk, until the empty line after its data.
+func (d *BinaryPatchDecoder) decodeHunk() (*BinaryHunk, error) {
+	line, err := d.readLine()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	h := &BinaryHunk{}
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return nil, ErrMalformedBinaryPatch
+	}
+
+	switch fields[0] {
+	case binaryLiteral:
+		h.Type = BinaryLiteral
+	case binaryDelta:
+		h.Type = BinaryDelta
+	default:
+		return nil, ErrMalformedBinaryPatch
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return nil, ErrMalformedBinaryPatch
+	}
+
+	var deflated []byte
+	for {
+		line, err := d.readLine()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if line == "" {
+			break
+		}
+
+		data, ok := decodeBinaryLine(line)
+		if !ok {
+			return nil, ErrMalformedBinaryPatch
+		}
+
+		deflated = append(deflated, data...)
+		if err == io.EOF {
+			break
+		}
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(deflated))
+	if err != nil {
+		return nil, ErrMalformedBinaryPatch
+	}
+
+	h.Data, err = ioutil.ReadAll(zr)
+	if err != nil || int64(len(h.Data)) != size {
+		return nil, ErrMalformedBinaryPatch
+	}
+
+	return h, nil
+}
+
+// decodeBinaryLine returns the data of a line of a hunk.
+func decodeBinaryLine(line string) ([]byte, bool) {
+	var n int
+	switch c := line[0]; {
+	case c >= 'A' && c <= 'Z':
+		n = int(c-'A') + 1
+	case c >= 'a' && c <= 'z':
+		n = int(c-'a') + 27
+	default:
+		return nil, false
+	}
+
+	data, ok := decode85(line[1:])
+	if !ok || len(data) < n || len(data)-n >= 4 {
+		return nil, false
+	}
+
+	return data[:n], true
+}
+
+// readLine returns the next line, without its line ending.
+func (d *BinaryPatchDecoder) readLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// base85Alphabet are the digits of the base 85 encoding of git.
+const base85Alphabet = "0123456789" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz" +
+	"!#$%&()*+-;<=>?@^_`{|}~"
+
+// encode85 encodes the data in base 85, each group of 4 bytes, the last one
+// padded with zeros, as 5 digits.
+func encode85(data []byte) []byte {
+	out := make([]byte, 0, (len(data)+3)/4*5)
+	for len(data) > 0 {
+		var acc uint32
+		for i := 0; i < 4; i++ {
+			acc <<= 8
+			if i < len(data) {
+				acc |= uint32(data[i])
+			}
+		}
+
+		var digits [5]byte
+		for i := 4; i >= 0; i-- {
+			digits[i] = base85Alphabet[acc%85]
+			acc /= 85
+		}
+
+		out = append(out, digits[:]...)
+		if len(data) < 4 {
+			break
+		}
+
+		data = data[4:]
+	}
+
+	return out
+}
+
+// decode85 decodes a text encoded by encode85, returning false if it is not
+// valid.
+func decode85(text string) ([]byte, bool) {
+	if len(text)%5 != 0 {
+		return nil, false
+	}
+
+	out := make([]byte, 0, len(text)/5*4)
+	for ; len(text) > 0; text = text[5:] {
+		var acc uint64
+		for i := 0; i < 5; i++ {
+			d := strings.IndexByte(base85Alphabet, text[i])
+			if d == -1 {
+				return nil, false
+			}
+
+			acc = acc*85 + uint64(d)
+		}
+
+		if acc > 0xffffffff {
+			return nil, false
+		}
+
+		out = append(out, byte(acc>>24), byte(acc>>16), byte(acc>>8), byte(acc))
+	}
+
+	return out, true
+}
@@ -0,0 +1,200 @@
+package diff
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+
+	. "gopkg.in/check.v1"
+)
+
+type BinaryPatchSuite struct{}
+
+var _ = Suite(&BinaryPatchSuite{})
+
+// binaryPatchFrom and binaryPatchTo are the contents of the files of
+// gitBinaryPatch, written by `git diff --binary`.
+var (
+	binaryPatchFrom = bytes.Repeat(binaryBytes(256), 2)
+	binaryPatchTo   = append(append(append([]byte{},
+		binaryPatchFrom[:300]...), "changed"...), binaryPatchFrom[310:]...)
+)
+
+const gitBinaryPatch = `diff --git a/b1 b/b2
+index 553a99f955221f149c3a4ee0df0b19c117d744bf..9f318918adc6ed218bc25ec94b0cf814e9494070 100644
+GIT binary patch
+delta 18
+ZcmZo*` + "`" + `OCaPhmk!wBQY;MHD#jNDF8e42E+gW
+
+delta 10
+Rcmey%+` + "`" + `zIyhjF6L6#y0j1M>g?
+
+`
+
+func binaryBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	return b
+}
+
+func (s *BinaryPatchSuite) TestDecode(c *C) {
+	d := NewBinaryPatchDecoder(strings.NewReader(gitBinaryPatch))
+
+	var p BinaryPatch
+	c.Assert(d.Decode(&p), IsNil)
+	c.Assert(p.Forward.Type, Equals, BinaryDelta)
+	c.Assert(p.Forward.Data, HasLen, 18)
+	c.Assert(p.Reverse.Type, Equals, BinaryDelta)
+	c.Assert(p.Reverse.Data, HasLen, 10)
+
+	to, err := p.Apply(binaryPatchFrom)
+	c.Assert(err, IsNil)
+	c.Assert(to, DeepEquals, binaryPatchTo)
+
+	from, err := p.Revert(binaryPatchTo)
+	c.Assert(err, IsNil)
+	c.Assert(from, DeepEquals, binaryPatchFrom)
+
+	c.Assert(d.Decode(&p), Equals, io.EOF)
+}
+
+func (s *BinaryPatchSuite) TestDecodeMalformed(c *C) {
+	for _, patch := range []string{
+		"GIT binary patch\nliteral\n",
+		"GIT binary patch\ncopy 3\nHcmV?d00001\n\n",
+		"GIT binary patch\nliteral 1\nHcmV?d00001\n\n",
+		"GIT binary patch\nliteral 0\nIcmV?d00001\n\n",
+		"GIT binary patch\nliteral 0\nHcmV?d0000\n\n",
+		"GIT binary patch\nliteral 0\nHcmV\"d00001\n\n",
+	} {
+		var p BinaryPatch
+		err := NewBinaryPatchDecoder(strings.NewReader(patch)).Decode(&p)
+		c.Assert(err, Equals, ErrMalformedBinaryPatch, Commentf("patch: %q", patch))
+	}
+}
+
+func (s *BinaryPatchSuite) TestRevertWithoutReverseHunk(c *C) {
+	var p BinaryPatch
+	d := NewBinaryPatchDecoder(strings.NewReader("GIT binary patch\nliteral 0\nHcmV?d00001\n\n"))
+	c.Assert(d.Decode(&p), IsNil)
+	c.Assert(p.Reverse, IsNil)
+
+	to, err := p.Apply([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(to, HasLen, 0)
+
+	_, err = p.Revert(to)
+	c.Assert(err, Equals, ErrNoReverseHunk)
+}
+
+func (s *BinaryPatchSuite) TestEncode85(c *C) {
+	for _, data := range [][]byte{
+		{},
+		{0},
+		{0xff, 0xff, 0xff, 0xff},
+		[]byte("hello, world"),
+		binaryBytes(256),
+	} {
+		text := encode85(data)
+		c.Assert(text, HasLen, (len(data)+3)/4*5)
+
+		decoded, ok := decode85(string(text))
+		c.Assert(ok, Equals, true)
+		c.Assert(decoded[:len(data)], DeepEquals, data)
+	}
+}
+
+func (s *BinaryPatchSuite) TestEncodeRoundTrip(c *C) {
+	newFile := append(binaryBytes(100), bytes.Repeat([]byte("data"), 50)...)
+	for _, t := range []struct {
+		from, to []byte
+		forward  BinaryHunkType
+	}{
+		{binaryPatchFrom, binaryPatchTo, BinaryDelta},
+		{nil, newFile, BinaryLiteral},
+		{newFile, nil, BinaryLiteral},
+		{binaryBytes(10), binaryBytes(200), BinaryLiteral},
+	} {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, DefaultContextLines).SetBinary(true)
+		c.Assert(e.Encode(testBinaryPatch{newTestBinaryFilePatch(t.from, t.to)}), IsNil)
+		c.Assert(strings.Contains(buffer.String(), "\nGIT binary patch\n"), Equals, true)
+		c.Assert(strings.Contains(buffer.String(), "Binary files"), Equals, false)
+
+		var p BinaryPatch
+		d := NewBinaryPatchDecoder(buffer)
+		c.Assert(d.Decode(&p), IsNil)
+		c.Assert(p.Forward.Type, Equals, t.forward)
+
+		to, err := p.Apply(t.from)
+		c.Assert(err, IsNil)
+		c.Assert(to, DeepEquals, append([]byte{}, t.to...))
+
+		from, err := p.Revert(t.to)
+		c.Assert(err, IsNil)
+		c.Assert(from, DeepEquals, append([]byte{}, t.from...))
+	}
+}
+
+func (s *BinaryPatchSuite) TestEncodeNewFile(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, DefaultContextLines).SetBinary(true)
+	c.Assert(e.Encode(testBinaryPatch{newTestBinaryFilePatch(nil, []byte{0})}), IsNil)
+
+	patch := buffer.String()
+	c.Assert(strings.HasPrefix(patch, `diff --git a/binary b/binary
+new file mode 100644
+index 0000000000000000000000000000000000000000..f76dd238ade08917e6712764a16a22005a50573d
+GIT binary patch
+literal 1
+`), Equals, true)
+	c.Assert(strings.HasSuffix(patch, "\n\nliteral 0\nHcmV?d00001\n\n"), Equals, true)
+}
+
+func (s *BinaryPatchSuite) TestEncodeWithoutContents(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, DefaultContextLines).SetBinary(true)
+	c.Assert(e.Encode(testPatch{filePatches: []testFilePatch{{
+		from: &testFile{mode: filemode.Regular, path: "binary", seed: "from"},
+		to:   &testFile{mode: filemode.Regular, path: "binary", seed: "to"},
+	}}}), IsNil)
+
+	c.Assert(strings.HasSuffix(buffer.String(), "\nBinary files a/binary and b/binary differ\n"), Equals, true)
+}
+
+type testBinaryPatch []FilePatch
+
+func (p testBinaryPatch) FilePatches() []FilePatch {
+	return p
+}
+
+func (p testBinaryPatch) Message() string {
+	return ""
+}
+
+type testBinaryFilePatch struct {
+	testFilePatch
+	fromContent, toContent []byte
+}
+
+func newTestBinaryFilePatch(from, to []byte) testBinaryFilePatch {
+	p := testBinaryFilePatch{fromContent: from, toContent: to}
+	if from != nil {
+		p.from = &testFile{mode: filemode.Regular, path: "binary", seed: string(from)}
+	}
+
+	if to != nil {
+		p.to = &testFile{mode: filemode.Regular, path: "binary", seed: string(to)}
+	}
+
+	return p
+}
+
+func (p testBinaryFilePatch) BinaryContents() (from, to []byte, err error) {
+	return p.fromContent, p.toContent, nil
+}
@@ -0,0 +1,79 @@
+package diff
+
+// ColorKey is the name of a part of a diff with its own color, the same as
+// its key in the diff.color section of the git config.
+type ColorKey string
+
+const (
+	// Context is the color of the unchanged lines.
+	Context ColorKey = "context"
+	// Meta is the color of the header lines of the files.
+	Meta ColorKey = "meta"
+	// Frag is the color of the line ranges of the hunk headers.
+	Frag ColorKey = "frag"
+	// Func is the color of the function names of the hunk headers.
+	Func ColorKey = "func"
+	// Old is the color of the deleted lines.
+	Old ColorKey = "old"
+	// New is the color of the added lines.
+	New ColorKey = "new"
+	// Whitespace is the color of the whitespace errors of the added lines.
+	Whitespace ColorKey = "whitespace"
+	// OldMoved is the color of the deleted lines added somewhere else.
+	OldMoved ColorKey = "oldMoved"
+	// OldMovedAlternative is the color of the deleted lines added somewhere
+	// else, for the blocks next to others painted in OldMoved.
+	OldMovedAlternative ColorKey = "oldMovedAlternative"
+	// NewMoved is the color of the added lines deleted somewhere else.
+	NewMoved ColorKey = "newMoved"
+	// NewMovedAlternative is the color of the added lines deleted somewhere
+	// else, for the blocks next to others painted in NewMoved.
+	NewMovedAlternative ColorKey = "newMovedAlternative"
+)
+
+// ANSI escape sequences of the default colors.
+const (
+	colorReset       = "\033[m"
+	colorNormal      = ""
+	colorBold        = "\033[1m"
+	colorRed         = "\033[31m"
+	colorGreen       = "\033[32m"
+	colorCyan        = "\033[36m"
+	colorBgRed       = "\033[41m"
+	colorBoldMagenta = "\033[1;35m"
+	colorBoldBlue    = "\033[1;34m"
+	colorBoldCyan    = "\033[1;36m"
+	colorBoldYellow  = "\033[1;33m"
+)
+
+// ColorConfig are the ANSI escape sequences starting the color of each part
+// of a diff. The parts without a color are written as they are, followed by
+// a reset sequence, as git does. A nil ColorConfig writes no colors at all.
+type ColorConfig map[ColorKey]string
+
+// NewColorConfig returns a ColorConfig with the default colors of git.
+func NewColorConfig() ColorConfig {
+	return ColorConfig{
+		Context:             colorNormal,
+		Meta:                colorBold,
+		Frag:                colorCyan,
+		Func:                colorNormal,
+		Old:                 colorRed,
+		New:                 colorGreen,
+		Whitespace:          colorBgRed,
+		OldMoved:            colorBoldMagenta,
+		OldMovedAlternative: colorBoldBlue,
+		NewMoved:            colorBoldCyan,
+		NewMovedAlternative: colorBoldYellow,
+	}
+}
+
+// reset returns the sequence going back to the default color, empty if cc
+// writes no colors.
+func (cc ColorConfig) reset() string {
+	if cc == nil {
+		return ""
+	}
+
+	return colorReset
+}
@@ -0,0 +1,200 @@
+package diff
+
+// ColorMovedMode is the way a UnifiedEncoder paints the moved lines of a
+// patch, deleted in one place and added in another, as
+// `git diff --color-moved` does.
+type ColorMovedMode int
+
+const (
+	// ColorMovedNo paints the moved lines as any other changed line.
+	ColorMovedNo ColorMovedMode = iota
+	// ColorMovedPlain paints every moved line in the OldMoved or NewMoved
+	// colors.
+	ColorMovedPlain
+	// ColorMovedBlocks paints the blocks of consecutive moved lines with at
+	// least 20 alphanumeric characters in the OldMoved or NewMoved colors.
+	ColorMovedBlocks
+	// ColorMovedZebra paints the blocks of moved lines as ColorMovedBlocks,
+	// but in the OldMovedAlternative or NewMovedAlternative colors if they
+	// follow another block.
+	ColorMovedZebra
+)
+
+// movedMinAlnumCount is the minimum number of alphanumeric characters of the
+// blocks of moved lines.
+const movedMinAlnumCount = 20
+
+// the flags of the moved lines
+const (
+	movedLine = 1 << iota
+	movedLineAlternative
+)
+
+// movedEntry is a deleted or added line, linked to the next line right after
+// it if it is of the same kind, and to the previous line equal to it of the
+// same kind.
+type movedEntry struct {
+	op        *op
+	nextLine  *movedEntry
+	nextMatch *movedEntry
+}
+
+// markMoved sets the moved flags of the deleted and added lines among the
+// given lines of a patch, as git does: the added lines equal to a deleted one
+// are moved, and the other way around. Except with ColorMovedPlain, they are
+// grouped greedily in blocks, the blocks with too few alphanumeric characters
+// not being moved. The lines which are neither deleted nor added, or nil,
+// separate the blocks.
+func markMoved(lines []*op, mode ColorMovedMode) {
+	added, deleted := movedEntries(lines)
+
+	// the current lines of the potential blocks the current line is in
+	var blocks []*movedEntry
+	blockLength := 0
+	flipped := false
+	movedOp := Equal
+	for n := 0; n < len(lines); n++ {
+		l := lines[n]
+		var match *movedEntry
+		switch {
+		case l == nil || l.t == Equal:
+			flipped = false
+		case l.t == Add:
+			match = deleted[l.text]
+		case l.t == Delete:
+			match = added[l.text]
+		}
+
+		if len(blocks) > 0 && (match == nil || l.t != movedOp) {
+			if !adjustLastBlock(lines, n, blockLength, mode) && blockLength > 1 {
+				// another block may start at the second line of this one
+				match = nil
+				n -= blockLength
+			}
+
+			blocks = nil
+			blockLength = 0
+			flipped = false
+		}
+
+		if match == nil {
+			movedOp = Equal
+			continue
+		}
+
+		if mode == ColorMovedPlain {
+			l.moved |= movedLine
+			continue
+		}
+
+		blocks = advanceBlocks(blocks, l)
+		if len(blocks) == 0 {
+			contiguous := adjustLastBlock(lines, n, blockLength, mode)
+			if !contiguous && blockLength > 1 {
+				n -= blockLength
+			} else {
+				for m := match; m != nil; m = m.nextMatch {
+					blocks = append(blocks, m)
+				}
+			}
+
+			flipped = contiguous && len(blocks) > 0 && movedOp == l.t && !flipped
+
+			movedOp = Equal
+			if len(blocks) > 0 {
+				movedOp = l.t
+			}
+
+			blockLength = 0
+		}
+
+		if len(blocks) > 0 {
+			blockLength++
+			l.moved |= movedLine
+			if flipped && mode != ColorMovedBlocks {
+				l.moved |= movedLineAlternative
+			}
+		}
+	}
+
+	adjustLastBlock(lines, len(lines), blockLength, mode)
+}
+
+// movedEntries returns the entries of the added and deleted lines by their
+// text, the last one of them linking to the previous ones.
+func movedEntries(lines []*op) (added, deleted map[string]*movedEntry) {
+	added = make(map[string]*movedEntry)
+	deleted = make(map[string]*movedEntry)
+
+	var previous *movedEntry
+	for _, l := range lines {
+		if l == nil || l.t == Equal {
+			previous = nil
+			continue
+		}
+
+		e := &movedEntry{op: l}
+		if previous != nil && previous.op.t == l.t {
+			previous.nextLine = e
+		}
+
+		previous = e
+
+		entries := added
+		if l.t == Delete {
+			entries = deleted
+		}
+
+		e.nextMatch = entries[l.text]
+		entries[l.text] = e
+	}
+
+	return added, deleted
+}
+
+// advanceBlocks moves the potential blocks to their next line, dropping the
+// ones whose next line is not equal to l.
+func advanceBlocks(blocks []*movedEntry, l *op) []*movedEntry {
+	advanced := blocks[:0]
+	for _, b := range blocks {
+		if b.nextLine != nil && b.nextLine.op.text == l.text {
+			advanced = append(advanced, b.nextLine)
+		}
+	}
+
+	return advanced
+}
+
+// adjustLastBlock returns whether the block of the given length before the
+// line n has enough alphanumeric characters, unmarking its lines as moved
+// otherwise.
+func adjustLastBlock(lines []*op, n, length int, mode ColorMovedMode) bool {
+	if mode == ColorMovedPlain {
+		return length > 0
+	}
+
+	count := 0
+	for i := 1; i <= length; i++ {
+		text := lines[n-i].text
+		for j := 0; j < len(text); j++ {
+			if !isAlnum(text[j]) {
+				continue
+			}
+
+			count++
+			if count >= movedMinAlnumCount {
+				return true
+			}
+		}
+	}
+
+	for i := 1; i <= length; i++ {
+		lines[n-i].moved &^= movedLine
+	}
+
+	return false
+}
+
+func isAlnum(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
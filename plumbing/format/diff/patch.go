@@ -56,3 +56,12 @@ type Chunk interface {
 	// Type contains the Operation to do with this Chunk.
 	Type() Operation
 }
+
+// BinaryFilePatch is a binary FilePatch able to return the contents of its
+// files, needed to encode it as a binary patch.
+type BinaryFilePatch interface {
+	FilePatch
+	// BinaryContents returns the contents of the from and to Files, nil for
+	// the missing ones.
+	BinaryContents() (from, to []byte, err error)
+}
@@ -14,7 +14,7 @@ const (
 
 	chunkStart  = "@@ -"
 	chunkMiddle = " +"
-	chunkEnd    = " @@%s\n"
+	chunkEnd    = " @@"
 	chunkCount  = "%d,%d"
 
 	noFilePath = "/dev/null"
@@ -25,10 +25,6 @@ const (
 	tPath  = "+++ %s\n"
 	binary = "Binary files %s and %s differ\n"
 
-	addLine    = "+%s\n"
-	deleteLine = "-%s\n"
-	equalLine  = " %s\n"
-
 	oldMode         = "old mode %o\n"
 	newMode         = "new mode %o\n"
 	deletedFileMode = "deleted file mode %o\n"
@@ -55,6 +51,23 @@ type UnifiedEncoder struct {
 	// surrounding a change.
 	ctxLines int
 
+	// ignoreBlankLines leaves out the changes of blank lines which are not
+	// close to other changes, the lines with only whitespace being blank if
+	// ignoreWhitespace is set.
+	ignoreBlankLines bool
+	ignoreWhitespace bool
+
+	// color are the colors of the output, nil to write no colors.
+	color      ColorConfig
+	colorMoved ColorMovedMode
+
+	// binary writes the binary patches of the BinaryFilePatches.
+	binary bool
+
+	// words is the word diff of the changed lines, nil to write them as
+	// they are.
+	words *wordDiff
+
 	buf bytes.Buffer
 }
 
@@ -62,6 +75,42 @@ func NewUnifiedEncoder(w io.Writer, ctxLines int) *UnifiedEncoder {
 	return &UnifiedEncoder{ctxLines: ctxLines, Writer: w}
 }
 
+// SetIgnoreBlankLines makes the encoder leave out the changes whose lines
+// are all blank if they are not close to other changes, as
+// `git diff --ignore-blank-lines` does. The blank lines are the empty ones
+// or, if ignoreWhitespace is set, the ones with only whitespace.
+func (e *UnifiedEncoder) SetIgnoreBlankLines(ignore, ignoreWhitespace bool) *UnifiedEncoder {
+	e.ignoreBlankLines = ignore
+	e.ignoreWhitespace = ignoreWhitespace
+	return e
+}
+
+// SetColor makes the encoder write the diff with the given colors, as
+// `git diff --color` does. The whitespace errors of the added lines are
+// painted in the Whitespace color: the whitespace at the end of the lines,
+// the spaces before tabs in the indentation and the blank lines added at the
+// end of the file.
+func (e *UnifiedEncoder) SetColor(colors ColorConfig) *UnifiedEncoder {
+	e.color = colors
+	return e
+}
+
+// SetColorMoved makes the encoder paint the moved lines with the given mode,
+// as `git diff --color-moved` does. The lines are compared across all the
+// files of the patch. It has no effect without colors.
+func (e *UnifiedEncoder) SetColorMoved(mode ColorMovedMode) *UnifiedEncoder {
+	e.colorMoved = mode
+	return e
+}
+
+// SetBinary makes the encoder write the binary FilePatches implementing
+// BinaryFilePatch as binary patches, which can be applied, as
+// `git diff --binary` does, instead of just telling the files differ.
+func (e *UnifiedEncoder) SetBinary(binary bool) *UnifiedEncoder {
+	e.binary = binary
+	return e
+}
+
 func (e *UnifiedEncoder) Encode(patch Patch) error {
 	e.printMessage(patch.Message())
 
@@ -74,22 +123,84 @@ func (e *UnifiedEncoder) Encode(patch Patch) error {
 	return err
 }
 
+// fileHunks are the hunks of a FilePatch.
+type fileHunks struct {
+	from, to File
+	isBinary bool
+	hunks    []*hunk
+	// blankAtEOF are the first lines of the blank lines at the end of both
+	// files, nil unless the file to has more of them.
+	blankAtEOF *blankAtEOF
+
+	// binaryPatch is set to write the binary patch of the contents.
+	binaryPatch                bool
+	fromContent, toContent []byte
+}
+
 func (e *UnifiedEncoder) encodeFilePatch(filePatches []FilePatch) error {
+	var files []*fileHunks
 	for _, p := range filePatches {
 		f, t := p.Files()
-		if err := e.header(f, t, p.IsBinary()); err != nil {
+		g := newHunksGenerator(p.Chunks(), e.ctxLines)
+		g.ignoreBlankLines = e.ignoreBlankLines
+		g.ignoreWhitespace = e.ignoreWhitespace
+		fh := &fileHunks{
+			from:       f,
+			to:         t,
+			isBinary:   p.IsBinary(),
+			hunks:      g.Generate(),
+			blankAtEOF: g.blankAtEOF(),
+		}
+
+		if bp, ok := p.(BinaryFilePatch); ok && e.binary && fh.isBinary {
+			var err error
+			fh.fromContent, fh.toContent, err = bp.BinaryContents()
+			if err != nil {
+				return err
+			}
+
+			fh.binaryPatch = true
+		}
+
+		files = append(files, fh)
+	}
+
+	if e.color != nil && e.colorMoved != ColorMovedNo && e.words == nil {
+		markMoved(movableLines(files), e.colorMoved)
+	}
+
+	for _, f := range files {
+		if err := e.header(f); err != nil {
 			return err
 		}
 
-		g := newHunksGenerator(p.Chunks(), e.ctxLines)
-		for _, c := range g.Generate() {
-			c.WriteTo(&e.buf)
+		for _, h := range f.hunks {
+			e.writeHunkHeader(h)
+			if e.words != nil {
+				e.writeWordDiff(h)
+			} else {
+				e.writeHunkLines(h, f.blankAtEOF)
+			}
 		}
 	}
 
 	return nil
 }
 
+// movableLines returns the lines of the hunks of the files, with a nil line
+// before each hunk.
+func movableLines(files []*fileHunks) []*op {
+	var lines []*op
+	for _, f := range files {
+		for _, h := range f.hunks {
+			lines = append(lines, nil)
+			lines = append(lines, h.ops...)
+		}
+	}
+
+	return lines
+}
+
 func (e *UnifiedEncoder) printMessage(message string) {
 	isEmpty := message == ""
 	hasSuffix := strings.HasSuffix(message, "\n")
@@ -100,183 +211,344 @@ func (e *UnifiedEncoder) printMessage(message string) {
 	e.buf.WriteString(message)
 }
 
-func (e *UnifiedEncoder) header(from, to File, isBinary bool) error {
+func (e *UnifiedEncoder) header(f *fileHunks) error {
+	from, to := f.from, f.to
 	switch {
 	case from == nil && to == nil:
 		return nil
 	case from != nil && to != nil:
 		hashEquals := from.Hash() == to.Hash()
 
-		fmt.Fprintf(&e.buf, diffInit, from.Path(), to.Path())
+		e.printMeta(diffInit, from.Path(), to.Path())
 
 		if from.Mode() != to.Mode() {
-			fmt.Fprintf(&e.buf, oldMode+newMode, from.Mode(), to.Mode())
+			e.printMeta(oldMode+newMode, from.Mode(), to.Mode())
 		}
 
 		if from.Path() != to.Path() {
-			fmt.Fprintf(&e.buf,
+			e.printMeta(
 				renameFileMode+renameFileMode,
 				renameFrom, from.Path(), renameTo, to.Path())
 		}
 
 		if from.Mode() != to.Mode() && !hashEquals {
-			fmt.Fprintf(&e.buf, indexNoMode, from.Hash(), to.Hash())
+			e.printMeta(indexNoMode, from.Hash(), to.Hash())
 		} else if !hashEquals {
-			fmt.Fprintf(&e.buf, indexAndMode, from.Hash(), to.Hash(), from.Mode())
+			e.printMeta(indexAndMode, from.Hash(), to.Hash(), from.Mode())
 		}
 
 		if !hashEquals {
-			e.pathLines(isBinary, aDir+from.Path(), bDir+to.Path())
+			return e.pathLines(f, aDir+from.Path(), bDir+to.Path())
 		}
 	case from == nil:
-		fmt.Fprintf(&e.buf, diffInit, to.Path(), to.Path())
-		fmt.Fprintf(&e.buf, newFileMode, to.Mode())
-		fmt.Fprintf(&e.buf, indexNoMode, plumbing.ZeroHash, to.Hash())
-		e.pathLines(isBinary, noFilePath, bDir+to.Path())
+		e.printMeta(diffInit, to.Path(), to.Path())
+		e.printMeta(newFileMode, to.Mode())
+		e.printMeta(indexNoMode, plumbing.ZeroHash, to.Hash())
+		return e.pathLines(f, noFilePath, bDir+to.Path())
 	case to == nil:
-		fmt.Fprintf(&e.buf, diffInit, from.Path(), from.Path())
-		fmt.Fprintf(&e.buf, deletedFileMode, from.Mode())
-		fmt.Fprintf(&e.buf, indexNoMode, from.Hash(), plumbing.ZeroHash)
-		e.pathLines(isBinary, aDir+from.Path(), noFilePath)
+		e.printMeta(diffInit, from.Path(), from.Path())
+		e.printMeta(deletedFileMode, from.Mode())
+		e.printMeta(indexNoMode, from.Hash(), plumbing.ZeroHash)
+		return e.pathLines(f, aDir+from.Path(), noFilePath)
 	}
 
 	return nil
 }
 
-func (e *UnifiedEncoder) pathLines(isBinary bool, fromPath, toPath string) {
-	format := fPath + tPath
-	if isBinary {
-		format = binary
+func (e *UnifiedEncoder) pathLines(f *fileHunks, fromPath, toPath string) error {
+	switch {
+	case f.binaryPatch:
+		return writeBinaryPatch(&e.buf, f.fromContent, f.toContent)
+	case f.isBinary:
+		fmt.Fprintf(&e.buf, binary, fromPath, toPath)
+	default:
+		e.printMeta(fPath+tPath, fromPath, toPath)
 	}
 
-	fmt.Fprintf(&e.buf, format, fromPath, toPath)
+	return nil
 }
 
+// printMeta writes the given header lines in the Meta color.
+func (e *UnifiedEncoder) printMeta(format string, a ...interface{}) {
+	for _, l := range splitLines(fmt.Sprintf(format, a...)) {
+		e.buf.WriteString(e.color[Meta] + l + e.color.reset() + "\n")
+	}
+}
+
+// funcNameMaxLength is the maximum length of the function names of the hunk
+// headers.
+const funcNameMaxLength = 80
+
 type hunksGenerator struct {
-	fromLine, toLine            int
-	ctxLines                    int
-	chunks                      []Chunk
-	current                     *hunk
-	hunks                       []*hunk
-	beforeContext, afterContext []string
+	ctxLines           int
+	ignoreBlankLines   bool
+	ignoreWhitespace   bool
+	chunks             []Chunk
+	fromLines, toLines []string
+	changes            []*change
+	funcName           string
+	funcNameLimit      int
+}
+
+// change is a group of consecutive deleted and added lines, from the line
+// fromLine of the file from and toLine of the file to, starting at 0.
+type change struct {
+	fromLine, fromCount int
+	toLine, toCount     int
+	// ignore is set for the changes of blank lines, with ignoreBlankLines
+	ignore bool
 }
 
 func newHunksGenerator(chunks []Chunk, ctxLines int) *hunksGenerator {
 	return &hunksGenerator{
-		chunks:   chunks,
-		ctxLines: ctxLines,
+		chunks:        chunks,
+		ctxLines:      ctxLines,
+		funcNameLimit: -1,
 	}
 }
 
+// Generate returns the hunks of the changes of the chunks, as git does: the
+// changes are shown with ctxLines of context, in the same hunk if their
+// contexts overlap, and the header of each hunk has the last line looking
+// like a function name before it.
 func (c *hunksGenerator) Generate() []*hunk {
-	for i, chunk := range c.chunks {
+	c.splitChanges()
+
+	var hunks []*hunk
+	for changes := c.changes; len(changes) > 0; {
+		first, last := c.nextHunk(changes)
+		if first == -1 {
+			break
+		}
+
+		hunks = append(hunks, c.hunk(changes[first:last+1]))
+		changes = changes[last+1:]
+	}
+
+	return hunks
+}
+
+// splitChanges splits the lines of the chunks and groups the deleted and
+// added ones in changes.
+func (c *hunksGenerator) splitChanges() {
+	var current *change
+	for _, chunk := range c.chunks {
 		ls := splitLines(chunk.Content())
-		lsLen := len(ls)
+		if len(ls) == 0 {
+			continue
+		}
 
-		switch chunk.Type() {
-		case Equal:
-			c.fromLine += lsLen
-			c.toLine += lsLen
-			c.processEqualsLines(ls, i)
-		case Delete:
-			if lsLen != 0 {
-				c.fromLine++
+		if chunk.Type() == Equal {
+			current = nil
+			c.fromLines = append(c.fromLines, ls...)
+			c.toLines = append(c.toLines, ls...)
+			continue
+		}
+
+		if current == nil {
+			current = &change{
+				fromLine: len(c.fromLines),
+				toLine:   len(c.toLines),
+				ignore:   c.ignoreBlankLines,
 			}
 
-			c.processHunk(i, chunk.Type())
-			c.fromLine += lsLen - 1
-			c.current.AddOp(chunk.Type(), ls...)
+			c.changes = append(c.changes, current)
+		}
+
+		switch chunk.Type() {
+		case Delete:
+			current.fromCount += len(ls)
+			c.fromLines = append(c.fromLines, ls...)
 		case Add:
-			if lsLen != 0 {
-				c.toLine++
-			}
-			c.processHunk(i, chunk.Type())
-			c.toLine += lsLen - 1
-			c.current.AddOp(chunk.Type(), ls...)
+			current.toCount += len(ls)
+			c.toLines = append(c.toLines, ls...)
 		}
 
-		if i == len(c.chunks)-1 && c.current != nil {
-			c.hunks = append(c.hunks, c.current)
+		for _, l := range ls {
+			current.ignore = current.ignore && c.isBlank(l)
 		}
 	}
+}
 
-	return c.hunks
+// blankAtEOF are the numbers of the first lines of the blank lines at the
+// end of the files from and to, starting at 1.
+type blankAtEOF struct {
+	from, to int
 }
 
-func (c *hunksGenerator) processHunk(i int, op Operation) {
-	if c.current != nil {
-		return
+// blankAtEOF returns the first lines of the blank lines at the end of the
+// files, or nil unless the file to has more of them than the file from.
+func (c *hunksGenerator) blankAtEOF() *blankAtEOF {
+	from, to := countTrailingBlank(c.fromLines), countTrailingBlank(c.toLines)
+	if to <= from {
+		return nil
 	}
 
-	var ctxPrefix string
-	linesBefore := len(c.beforeContext)
-	if linesBefore > c.ctxLines {
-		ctxPrefix = " " + c.beforeContext[linesBefore-c.ctxLines-1]
-		c.beforeContext = c.beforeContext[linesBefore-c.ctxLines:]
-		linesBefore = c.ctxLines
+	return &blankAtEOF{
+		from: len(c.fromLines) - from + 1,
+		to:   len(c.toLines) - to + 1,
 	}
+}
 
-	c.current = &hunk{ctxPrefix: ctxPrefix}
-	c.current.AddOp(Equal, c.beforeContext...)
+// countTrailingBlank returns the number of blank lines at the end of the
+// lines, counted as git does: the first line only counts if it has two
+// characters or more, and the second one if it is not empty or the first
+// one is not.
+func countTrailingBlank(lines []string) int {
+	count := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		if i == 0 && len(lines[0]) < 2 ||
+			i == 1 && lines[1] == "" && lines[0] == "" ||
+			!isBlank(lines[i]) {
+			break
+		}
 
-	switch op {
-	case Delete:
-		c.current.fromLine, c.current.toLine =
-			c.addLineNumbers(c.fromLine, c.toLine, linesBefore, i, Add)
-	case Add:
-		c.current.toLine, c.current.fromLine =
-			c.addLineNumbers(c.toLine, c.fromLine, linesBefore, i, Delete)
+		count++
 	}
 
-	c.beforeContext = nil
+	return count
 }
 
-// addLineNumbers obtains the line numbers in a new chunk
-func (c *hunksGenerator) addLineNumbers(la, lb int, linesBefore int, i int, op Operation) (cla, clb int) {
-	cla = la - linesBefore
-	// we need to search for a reference for the next diff
-	switch {
-	case linesBefore != 0 && c.ctxLines != 0:
-		if lb > c.ctxLines {
-			clb = lb - c.ctxLines + 1
-		} else {
-			clb = 1
+func (c *hunksGenerator) isBlank(l string) bool {
+	if !c.ignoreWhitespace {
+		return l == ""
+	}
+
+	return strings.TrimSpace(l) == ""
+}
+
+// nextHunk returns the index of the first and the last changes of the next
+// hunk, or -1 if there are no more hunks. The ignored changes are only shown
+// if they are close to other changes.
+func (c *hunksGenerator) nextHunk(changes []*change) (first, last int) {
+	maxCommon := 2 * c.ctxLines
+	maxIgnorable := c.ctxLines
+
+	// skip the ignored changes far from the next change
+	for i := 0; i < len(changes) && changes[i].ignore; i++ {
+		if i+1 == len(changes) ||
+			changes[i+1].fromLine-changes[i].fromEnd() >= maxIgnorable {
+			first = i + 1
 		}
-	case c.ctxLines == 0:
-		clb = lb
-	case i != len(c.chunks)-1:
-		next := c.chunks[i+1]
-		if next.Type() == op || next.Type() == Equal {
-			// this diff will be into this chunk
-			clb = lb + 1
+	}
+
+	if first == len(changes) {
+		return -1, -1
+	}
+
+	last = first
+	ignored := 0
+	for i := first + 1; i < len(changes); i++ {
+		ch := changes[i]
+		distance := ch.fromLine - changes[i-1].fromEnd()
+		if distance > maxCommon {
+			break
+		}
+
+		switch {
+		case distance < maxIgnorable && (!ch.ignore || last == i-1):
+			last = i
+			ignored = 0
+		case distance < maxIgnorable:
+			ignored += ch.toCount
+		case last != i-1 && ch.fromLine+ignored-changes[last].fromEnd() > maxCommon:
+			return first, last
+		case !ch.ignore:
+			last = i
+			ignored = 0
+		default:
+			ignored += ch.toCount
 		}
 	}
 
-	return
+	return first, last
 }
 
-func (c *hunksGenerator) processEqualsLines(ls []string, i int) {
-	if c.current == nil {
-		c.beforeContext = append(c.beforeContext, ls...)
-		return
+func (ch *change) fromEnd() int {
+	return ch.fromLine + ch.fromCount
+}
+
+func (ch *change) toEnd() int {
+	return ch.toLine + ch.toCount
+}
+
+// hunk returns the hunk of the given changes, with their context.
+func (c *hunksGenerator) hunk(changes []*change) *hunk {
+	first, last := changes[0], changes[len(changes)-1]
+	fromStart := max(first.fromLine-c.ctxLines, 0)
+	toStart := max(first.toLine-c.ctxLines, 0)
+
+	ctxLines := c.ctxLines
+	ctxLines = min(ctxLines, len(c.fromLines)-last.fromEnd())
+	ctxLines = min(ctxLines, len(c.toLines)-last.toEnd())
+
+	h := &hunk{funcName: c.findFuncName(fromStart - 1)}
+
+	h.AddOp(Equal, c.toLines[toStart:first.toLine]...)
+	for i, ch := range changes {
+		if i > 0 {
+			h.AddOp(Equal, c.toLines[changes[i-1].toEnd():ch.toLine]...)
+		}
+
+		h.AddOp(Delete, c.fromLines[ch.fromLine:ch.fromEnd()]...)
+		h.AddOp(Add, c.toLines[ch.toLine:ch.toEnd()]...)
 	}
 
-	c.afterContext = append(c.afterContext, ls...)
-	if len(c.afterContext) <= c.ctxLines*2 && i != len(c.chunks)-1 {
-		c.current.AddOp(Equal, c.afterContext...)
-		c.afterContext = nil
-	} else {
-		ctxLines := c.ctxLines
-		if ctxLines > len(c.afterContext) {
-			ctxLines = len(c.afterContext)
+	h.AddOp(Equal, c.toLines[last.toEnd():last.toEnd()+ctxLines]...)
+
+	h.fromLine = fromStart
+	if h.fromCount != 0 {
+		h.fromLine++
+	}
+
+	h.toLine = toStart
+	if h.toCount != 0 {
+		h.toLine++
+	}
+
+	return h
+}
+
+// findFuncName returns the last line looking like a function name in the
+// file from, starting at the given line, as git does by default: the lines
+// starting with a letter, '_' or '$'.
+func (c *hunksGenerator) findFuncName(start int) string {
+	for l := start; l > c.funcNameLimit && l >= 0; l-- {
+		line := c.fromLines[l]
+		if line == "" || !isFuncNameStart(line[0]) {
+			continue
 		}
-		c.current.AddOp(Equal, c.afterContext[:ctxLines]...)
-		c.hunks = append(c.hunks, c.current)
 
-		c.current = nil
-		c.beforeContext = c.afterContext[ctxLines:]
-		c.afterContext = nil
+		if len(line) > funcNameMaxLength {
+			line = line[:funcNameMaxLength]
+		}
+
+		c.funcName = strings.TrimRight(line, " \t\n\v\f\r")
+		break
+	}
+
+	c.funcNameLimit = start
+	return c.funcName
+}
+
+func isFuncNameStart(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '_' || b == '$'
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
 	}
+
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
 }
 
 func splitLines(s string) []string {
@@ -295,32 +567,135 @@ type hunk struct {
 	fromCount int
 	toCount   int
 
-	ctxPrefix string
-	ops       []*op
+	funcName string
+	ops      []*op
 }
 
-func (c *hunk) WriteTo(buf *bytes.Buffer) {
-	buf.WriteString(chunkStart)
+// writeHunkHeader writes the header of the hunk, with the line ranges in
+// the Frag color and the function name in the Func one.
+func (e *UnifiedEncoder) writeHunkHeader(h *hunk) {
+	reset := e.color.reset()
+	e.buf.WriteString(e.color[Frag] + chunkStart)
 
-	if c.fromCount == 1 {
-		fmt.Fprintf(buf, "%d", c.fromLine)
+	if h.fromCount == 1 {
+		fmt.Fprintf(&e.buf, "%d", h.fromLine)
 	} else {
-		fmt.Fprintf(buf, chunkCount, c.fromLine, c.fromCount)
+		fmt.Fprintf(&e.buf, chunkCount, h.fromLine, h.fromCount)
 	}
 
-	buf.WriteString(chunkMiddle)
+	e.buf.WriteString(chunkMiddle)
 
-	if c.toCount == 1 {
-		fmt.Fprintf(buf, "%d", c.toLine)
+	if h.toCount == 1 {
+		fmt.Fprintf(&e.buf, "%d", h.toLine)
 	} else {
-		fmt.Fprintf(buf, chunkCount, c.toLine, c.toCount)
+		fmt.Fprintf(&e.buf, chunkCount, h.toLine, h.toCount)
+	}
+
+	e.buf.WriteString(chunkEnd + reset)
+	if h.funcName != "" {
+		e.buf.WriteString(e.color[Context] + " " + reset)
+		e.buf.WriteString(e.color[Func] + h.funcName + reset)
+	}
+
+	e.buf.WriteString("\n")
+}
+
+// writeHunkLines writes the lines of the hunk. The blank lines added at the
+// end of the file are found as git does, which counts the lines from the
+// ones in the header of the hunk.
+func (e *UnifiedEncoder) writeHunkLines(h *hunk, blank *blankAtEOF) {
+	fromLine, toLine := h.fromLine, h.toLine
+	for _, o := range h.ops {
+		switch o.t {
+		case Equal:
+			fromLine++
+			toLine++
+			e.writeLine(e.color[Context], ' ', o.text)
+		case Delete:
+			fromLine++
+			e.writeLine(e.color[lineColor(o, Old, OldMoved, OldMovedAlternative)], '-', o.text)
+		case Add:
+			toLine++
+			atEOF := blank != nil && blank.from <= fromLine && blank.to <= toLine
+			set := e.color[lineColor(o, New, NewMoved, NewMovedAlternative)]
+			e.writeAddedLine(set, o.text, atEOF)
+		}
+	}
+}
+
+// lineColor returns the color of a deleted or added line, depending on
+// whether it is moved.
+func lineColor(o *op, color, moved, alternative ColorKey) ColorKey {
+	switch o.moved {
+	case movedLine:
+		return moved
+	case movedLine | movedLineAlternative:
+		return alternative
+	}
+
+	return color
+}
+
+// writeLine writes a line with its sign in the given color.
+func (e *UnifiedEncoder) writeLine(set string, sign byte, text string) {
+	e.buf.WriteString(set)
+	e.buf.WriteByte(sign)
+	e.buf.WriteString(text + e.color.reset() + "\n")
+}
+
+// writeAddedLine writes an added line in the given color, with its
+// whitespace errors in the Whitespace color. The blank lines added at the
+// end of the file are whitespace errors as a whole.
+func (e *UnifiedEncoder) writeAddedLine(set, text string, atEOF bool) {
+	ws, reset := e.color[Whitespace], e.color.reset()
+	switch {
+	case ws == "":
+		e.writeLine(set, '+', text)
+		return
+	case atEOF && isBlank(text):
+		e.writeLine(ws, '+', text)
+		return
+	}
+
+	e.buf.WriteString(set + "+" + reset)
+
+	trailing := len(strings.TrimRight(text, whitespace))
+	written := 0
+	for i := 0; i < trailing; i++ {
+		if text[i] == ' ' {
+			continue
+		}
+
+		if text[i] != '\t' {
+			break
+		}
+
+		if written < i {
+			// spaces before a tab in the indentation
+			e.buf.WriteString(ws + text[written:i] + reset + "\t")
+		} else {
+			e.buf.WriteString(text[written : i+1])
+		}
+
+		written = i + 1
 	}
 
-	fmt.Fprintf(buf, chunkEnd, c.ctxPrefix)
+	if written < trailing {
+		e.buf.WriteString(set + text[written:trailing] + reset)
+	}
 
-	for _, d := range c.ops {
-		buf.WriteString(d.String())
+	if trailing < len(text) {
+		e.buf.WriteString(ws + text[trailing:] + reset)
 	}
+
+	e.buf.WriteString("\n")
+}
+
+// whitespace are the characters git considers whitespace.
+const whitespace = " \t\n\r"
+
+func isBlank(l string) bool {
+	return strings.TrimLeft(l, whitespace) == ""
 }
 
 func (c *hunk) AddOp(t Operation, s ...string) {
@@ -336,25 +711,13 @@ func (c *hunk) AddOp(t Operation, s ...string) {
 	}
 
 	for _, l := range s {
-		c.ops = append(c.ops, &op{l, t})
+		c.ops = append(c.ops, &op{text: l, t: t})
 	}
 }
 
 type op struct {
 	text string
 	t    Operation
-}
-
-func (o *op) String() string {
-	var prefix string
-	switch o.t {
-	case Add:
-		prefix = addLine
-	case Delete:
-		prefix = deleteLine
-	case Equal:
-		prefix = equalLine
-	}
-
-	return fmt.Sprintf(prefix, o.text)
+	// moved are the flags of the line if it is moved
+	moved int
 }
@@ -2,6 +2,7 @@ package diff
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -65,6 +66,201 @@ func (s *UnifiedEncoderTestSuite) TestEncode(c *C) {
 	}
 }
 
+func (s *UnifiedEncoderTestSuite) TestEncodeFuncName(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1)
+	err := e.Encode(newTestPatch("foo.go", []testChunk{
+		{"package foo\n\nfunc a() {\n\tx := 1\n\ty := 2\n", Equal},
+		{"\tz := 3\n", Delete},
+		{"\tz := 4\n", Add},
+		{"\treturn\n}\n", Equal},
+	}))
+
+	c.Assert(err, IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -5,3 +5,3 @@ func a() {
+ 	y := 2
+-	z := 3
++	z := 4
+ 	return
+`)
+}
+
+func (s *UnifiedEncoderTestSuite) TestEncodeIgnoreBlankLines(c *C) {
+	chunks := []testChunk{
+		{"a\n", Equal},
+		{"\n", Delete},
+		{"b\nc\nd\n", Equal},
+		{"\n", Add},
+		{"e\n", Equal},
+		{"f\n", Delete},
+		{"F\n", Add},
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1)
+	c.Assert(e.Encode(newTestPatch("foo", chunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -1,3 +1,2 @@
+ a
+-
+ b
+@@ -5,3 +4,4 @@ c
+ d
++
+ e
+-f
++F
+`)
+
+	buffer.Reset()
+	e = NewUnifiedEncoder(buffer, 1).SetIgnoreBlankLines(true, false)
+	c.Assert(e.Encode(newTestPatch("foo", chunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -6,2 +6,2 @@ d
+ e
+-f
++F
+`)
+
+	buffer.Reset()
+	e = NewUnifiedEncoder(buffer, 0).SetIgnoreBlankLines(true, false)
+	c.Assert(e.Encode(newTestPatch("foo", chunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -7 +7 @@ e
+-f
++F
+`)
+}
+
+func (s *UnifiedEncoderTestSuite) TestEncodeColor(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1).SetColor(NewColorConfig())
+	err := e.Encode(newTestPatch("foo", []testChunk{
+		{"func a() {\n", Equal},
+		{"\tfoo bar\n", Delete},
+		{"\tfoo baz  \n", Add},
+		{"\tctx\n", Equal},
+		{"\tnew\n", Add},
+		{"}\n", Equal},
+	}))
+
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, ""+
+		"\x1b[1mdiff --git a/foo b/foo\x1b[m\n"+
+		"\x1b[1mindex f90c39e988fcd5938642ff931c5b5d5869a72b91..788636ffba34694361f7d7ee1970d96fb92c3168 100644\x1b[m\n"+
+		"\x1b[1m--- a/foo\x1b[m\n"+
+		"\x1b[1m+++ b/foo\x1b[m\n"+
+		"\x1b[36m@@ -1,4 +1,5 @@\x1b[m\n"+
+		" func a() {\x1b[m\n"+
+		"\x1b[31m-\tfoo bar\x1b[m\n"+
+		"\x1b[32m+\x1b[m\t\x1b[32mfoo baz\x1b[m\x1b[41m  \x1b[m\n"+
+		" \tctx\x1b[m\n"+
+		"\x1b[32m+\x1b[m\t\x1b[32mnew\x1b[m\n"+
+		" }\x1b[m\n")
+
+	buffer.Reset()
+	err = e.Encode(newTestPatch("foo", []testChunk{
+		{"func a() {\n\tx\n\ty\n\tz\n\tw\n", Equal},
+		{"\tv\n", Delete},
+		{"\tV\n", Add},
+		{"}\n", Equal},
+	}))
+
+	c.Assert(err, IsNil)
+	c.Assert(hunks(buffer.String()), Equals, ""+
+		"@@ -5,3 +5,3 @@\x1b[m \x1b[mfunc a() {\x1b[m\n"+
+		" \tw\x1b[m\n"+
+		"\x1b[31m-\tv\x1b[m\n"+
+		"\x1b[32m+\x1b[m\t\x1b[32mV\x1b[m\n"+
+		" }\x1b[m\n")
+}
+
+func (s *UnifiedEncoderTestSuite) TestEncodeColorMoved(c *C) {
+	chunks := []testChunk{
+		{"alpha := compute(one)\nbeta := compute(two)\n", Delete},
+		{"x\n", Equal},
+		{"gamma := compute(three)\ndelta := compute(four)\n", Delete},
+		{"y\nz\n", Equal},
+		{"gamma := compute(three)\ndelta := compute(four)\n", Add},
+		{"alpha := compute(one)\nbeta := compute(two)\n", Add},
+	}
+
+	deleted := "" +
+		"\x1b[1;35m-alpha := compute(one)\x1b[m\n" +
+		"\x1b[1;35m-beta := compute(two)\x1b[m\n" +
+		" x\x1b[m\n" +
+		"\x1b[1;35m-gamma := compute(three)\x1b[m\n" +
+		"\x1b[1;35m-delta := compute(four)\x1b[m\n" +
+		" y\x1b[m\n" +
+		" z\x1b[m\n"
+
+	for _, t := range []struct {
+		mode  ColorMovedMode
+		added string
+	}{{
+		mode: ColorMovedBlocks,
+		added: "" +
+			"\x1b[1;36m+\x1b[m\x1b[1;36mgamma := compute(three)\x1b[m\n" +
+			"\x1b[1;36m+\x1b[m\x1b[1;36mdelta := compute(four)\x1b[m\n" +
+			"\x1b[1;36m+\x1b[m\x1b[1;36malpha := compute(one)\x1b[m\n" +
+			"\x1b[1;36m+\x1b[m\x1b[1;36mbeta := compute(two)\x1b[m\n",
+	}, {
+		mode: ColorMovedZebra,
+		added: "" +
+			"\x1b[1;36m+\x1b[m\x1b[1;36mgamma := compute(three)\x1b[m\n" +
+			"\x1b[1;36m+\x1b[m\x1b[1;36mdelta := compute(four)\x1b[m\n" +
+			"\x1b[1;33m+\x1b[m\x1b[1;33malpha := compute(one)\x1b[m\n" +
+			"\x1b[1;33m+\x1b[m\x1b[1;33mbeta := compute(two)\x1b[m\n",
+	}} {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, 3).SetColor(NewColorConfig()).SetColorMoved(t.mode)
+		c.Assert(e.Encode(newTestPatch("foo", chunks)), IsNil)
+		c.Assert(hunks(buffer.String()), Equals, "@@ -1,7 +1,7 @@\x1b[m\n"+deleted+t.added)
+	}
+
+	// the blocks with less than 20 alphanumeric characters are not moved
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 3).SetColor(NewColorConfig()).SetColorMoved(ColorMovedZebra)
+	err := e.Encode(newTestPatch("foo", []testChunk{
+		{"end\n", Delete},
+		{"keep\n", Equal},
+		{"end\n", Add},
+	}))
+
+	c.Assert(err, IsNil)
+	c.Assert(hunks(buffer.String()), Equals, ""+
+		"@@ -1,2 +1,2 @@\x1b[m\n"+
+		"\x1b[31m-end\x1b[m\n"+
+		" keep\x1b[m\n"+
+		"\x1b[32m+\x1b[m\x1b[32mend\x1b[m\n")
+
+	buffer.Reset()
+	e.SetColorMoved(ColorMovedPlain)
+	err = e.Encode(newTestPatch("foo", []testChunk{
+		{"end\n", Delete},
+		{"keep\n", Equal},
+		{"end\n", Add},
+	}))
+
+	c.Assert(err, IsNil)
+	c.Assert(hunks(buffer.String()), Equals, ""+
+		"@@ -1,2 +1,2 @@\x1b[m\n"+
+		"\x1b[1;35m-end\x1b[m\n"+
+		" keep\x1b[m\n"+
+		"\x1b[1;36m+\x1b[m\x1b[1;36mend\x1b[m\n")
+}
+
+// newTestPatch returns a patch modifying the file at the given path.
+func newTestPatch(path string, chunks []testChunk) testPatch {
+	return testPatch{filePatches: []testFilePatch{{
+		from:   &testFile{mode: filemode.Regular, path: path, seed: "from"},
+		to:     &testFile{mode: filemode.Regular, path: path, seed: "to"},
+		chunks: chunks,
+	}}}
+}
+
+// hunks returns the hunks of an encoded patch, without its header.
+func hunks(patch string) string {
+	return patch[strings.Index(patch, "@@"):]
+}
+
 var oneChunkPatch Patch = testPatch{
 	message: "",
 	filePatches: []testFilePatch{{
@@ -0,0 +1,319 @@
+package diff
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	udiff "gopkg.in/src-d/go-git.v4/utils/diff"
+)
+
+// WordDiffMode is the format of the changed words of a WordDiffEncoder.
+type WordDiffMode int
+
+const (
+	// WordDiffPlain writes the deleted words as [-words-] and the added ones
+	// as {+words+}, as `git diff --word-diff=plain` does.
+	WordDiffPlain WordDiffMode = iota
+	// WordDiffPorcelain writes the unchanged, deleted and added words in
+	// lines starting with ' ', '-' and '+', and the end of each line of the
+	// text as a line with '~', as `git diff --word-diff=porcelain` does.
+	WordDiffPorcelain
+	// WordDiffColor writes the deleted and added words in the Old and New
+	// colors, as `git diff --word-diff=color` does.
+	WordDiffColor
+)
+
+// wordStyle is the way the unchanged, deleted or added words are written.
+type wordStyle struct {
+	prefix, suffix string
+	color          ColorKey
+}
+
+// wordDiff is the word diff of the changed lines of a UnifiedEncoder.
+type wordDiff struct {
+	mode   WordDiffMode
+	regexp *regexp.Regexp
+
+	ctx, old, new wordStyle
+	// newline is written for the end of each line of the text.
+	newline string
+}
+
+func newWordDiff(mode WordDiffMode) *wordDiff {
+	w := &wordDiff{
+		mode:    mode,
+		ctx:     wordStyle{color: Context},
+		old:     wordStyle{color: Old},
+		new:     wordStyle{color: New},
+		newline: "\n",
+	}
+
+	switch mode {
+	case WordDiffPlain:
+		w.old.prefix, w.old.suffix = "[-", "-]"
+		w.new.prefix, w.new.suffix = "{+", "+}"
+	case WordDiffPorcelain:
+		w.ctx.prefix, w.ctx.suffix = " ", "\n"
+		w.old.prefix, w.old.suffix = "-", "\n"
+		w.new.prefix, w.new.suffix = "+", "\n"
+		w.newline = "~\n"
+	}
+
+	return w
+}
+
+// WordDiffEncoder encodes an unified diff into the provided Writer, with the
+// deleted and added lines of each hunk compared word by word, as
+// `git diff --word-diff` does.
+type WordDiffEncoder struct {
+	e *UnifiedEncoder
+}
+
+// NewWordDiffEncoder returns a WordDiffEncoder writing the changed words in
+// the given mode. The words are the runs of non-whitespace characters,
+// unless other ones are set with SetWordRegexp. WordDiffColor writes the
+// default colors of NewColorConfig, the other modes write no colors unless
+// they are set with SetColor.
+func NewWordDiffEncoder(w io.Writer, ctxLines int, mode WordDiffMode) *WordDiffEncoder {
+	e := NewUnifiedEncoder(w, ctxLines)
+	e.words = newWordDiff(mode)
+	if mode == WordDiffColor {
+		e.color = NewColorConfig()
+	}
+
+	return &WordDiffEncoder{e: e}
+}
+
+// SetWordRegexp sets the regexp matching the words, as
+// `git diff --word-diff-regex` does. The characters between the matches are
+// whitespace, and the matches are cut at the end of the lines. A regexp
+// matching any character, such as ".", compares the lines character by
+// character. The regexps compiled with regexp.CompilePOSIX match the same
+// words as git does. A nil regexp sets back the runs of non-whitespace
+// characters.
+func (e *WordDiffEncoder) SetWordRegexp(re *regexp.Regexp) *WordDiffEncoder {
+	e.e.words.regexp = re
+	return e
+}
+
+// SetColor makes the encoder write the diff with the given colors, or with
+// no colors if it is nil.
+func (e *WordDiffEncoder) SetColor(colors ColorConfig) *WordDiffEncoder {
+	e.e.SetColor(colors)
+	return e
+}
+
+func (e *WordDiffEncoder) Encode(patch Patch) error {
+	return e.e.Encode(patch)
+}
+
+// writeWordDiff writes the lines of the hunk, with its consecutive deleted
+// and added lines compared word by word.
+func (e *UnifiedEncoder) writeWordDiff(h *hunk) {
+	var minus, plus bytes.Buffer
+	for _, o := range h.ops {
+		switch o.t {
+		case Delete:
+			minus.WriteString(o.text + "\n")
+		case Add:
+			plus.WriteString(o.text + "\n")
+		case Equal:
+			e.writeChangedWords(minus.String(), plus.String())
+			minus.Reset()
+			plus.Reset()
+
+			e.writeWordsContext(o.text)
+		}
+	}
+
+	e.writeChangedWords(minus.String(), plus.String())
+}
+
+// writeWordsContext writes an unchanged line.
+func (e *UnifiedEncoder) writeWordsContext(text string) {
+	if e.words.mode == WordDiffPorcelain {
+		e.writeLine(e.color[Context], ' ', text)
+		e.buf.WriteString(e.words.newline)
+		return
+	}
+
+	if text == "" {
+		e.buf.WriteString("\n")
+		return
+	}
+
+	e.buf.WriteString(e.color[Context] + text + e.color.reset() + "\n")
+}
+
+// wordRange is the position of a word in a text, from begin to end, not
+// included.
+type wordRange struct {
+	begin, end int
+}
+
+// writeChangedWords writes the text of the deleted and added lines with the
+// deleted and added words marked. The text between the words is written as
+// in the added lines.
+func (e *UnifiedEncoder) writeChangedWords(minus, plus string) {
+	w := e.words
+	if plus == "" {
+		if minus != "" {
+			e.writeWords(w.old, minus)
+		}
+
+		return
+	}
+
+	minusWords, plusWords := w.split(minus), w.split(plus)
+	diffs := udiff.DoWithOptions(
+		joinWords(minus, minusWords),
+		joinWords(plus, plusWords),
+		&udiff.Options{},
+	)
+
+	current := 0
+	var i, j int
+	for k := 0; k < len(diffs); {
+		if diffs[k].Type == diffmatchpatch.DiffEqual {
+			n := strings.Count(diffs[k].Text, "\n")
+			i += n
+			j += n
+			k++
+			continue
+		}
+
+		i0, j0 := i, j
+		for ; k < len(diffs) && diffs[k].Type != diffmatchpatch.DiffEqual; k++ {
+			n := strings.Count(diffs[k].Text, "\n")
+			if diffs[k].Type == diffmatchpatch.DiffDelete {
+				i += n
+			} else {
+				j += n
+			}
+		}
+
+		minusBegin, minusEnd := wordsRange(minusWords, i0, i)
+		plusBegin, plusEnd := wordsRange(plusWords, j0, j)
+		if current != plusBegin {
+			e.writeWords(w.ctx, plus[current:plusBegin])
+		}
+
+		if minusBegin != minusEnd {
+			e.writeWords(w.old, minus[minusBegin:minusEnd])
+		}
+
+		if plusBegin != plusEnd {
+			e.writeWords(w.new, plus[plusBegin:plusEnd])
+		}
+
+		current = plusEnd
+	}
+
+	if current != len(plus) {
+		e.writeWords(w.ctx, plus[current:])
+	}
+}
+
+// writeWords writes the text in the given style, line by line.
+func (e *UnifiedEncoder) writeWords(s wordStyle, text string) {
+	color, reset := e.color[s.color], ""
+	if color != "" {
+		reset = colorReset
+	}
+
+	for len(text) > 0 {
+		line := text
+		i := strings.IndexByte(text, '\n')
+		if i != -1 {
+			line = text[:i]
+		}
+
+		if line != "" {
+			e.buf.WriteString(color + s.prefix + line + s.suffix + reset)
+		}
+
+		if i == -1 {
+			return
+		}
+
+		e.buf.WriteString(e.words.newline)
+		text = text[i+1:]
+	}
+}
+
+// split returns the words of the text.
+func (w *wordDiff) split(text string) []wordRange {
+	var words []wordRange
+	for i := 0; i < len(text); {
+		word, ok := w.nextWord(text, i)
+		if !ok {
+			break
+		}
+
+		words = append(words, word)
+		i = word.end
+	}
+
+	return words
+}
+
+// nextWord returns the first word of the text from i, and false if there
+// are no more words.
+func (w *wordDiff) nextWord(text string, i int) (wordRange, bool) {
+	if w.regexp != nil {
+		loc := w.regexp.FindStringIndex(text[i:])
+		if loc == nil {
+			return wordRange{}, false
+		}
+
+		word := wordRange{begin: i + loc[0], end: i + loc[1]}
+		if nl := strings.IndexByte(text[word.begin:word.end], '\n'); nl != -1 {
+			word.end = word.begin + nl
+		}
+
+		return word, word.begin < word.end
+	}
+
+	for i < len(text) && strings.IndexByte(whitespace, text[i]) != -1 {
+		i++
+	}
+
+	if i == len(text) {
+		return wordRange{}, false
+	}
+
+	word := wordRange{begin: i, end: i + 1}
+	for word.end < len(text) && strings.IndexByte(whitespace, text[word.end]) == -1 {
+		word.end++
+	}
+
+	return word, true
+}
+
+// joinWords returns the words of the text, one per line.
+func joinWords(text string, words []wordRange) string {
+	var buf bytes.Buffer
+	for _, w := range words {
+		buf.WriteString(text[w.begin:w.end])
+		buf.WriteByte('\n')
+	}
+
+	return buf.String()
+}
+
+// wordsRange returns the position in the text of the words from i to j, not
+// included, or the end of the word before i if there are none.
+func wordsRange(words []wordRange, i, j int) (begin, end int) {
+	if i < j {
+		return words[i].begin, words[j-1].end
+	}
+
+	if i == 0 {
+		return 0, 0
+	}
+
+	return words[i-1].end, words[i-1].end
+}
@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"bytes"
+	"regexp"
+
+	. "gopkg.in/check.v1"
+)
+
+type WordDiffEncoderTestSuite struct{}
+
+var _ = Suite(&WordDiffEncoderTestSuite{})
+
+var wordDiffChunks = []testChunk{
+	{"func a() {\n", Equal},
+	{"\tfoo bar\n", Delete},
+	{"\tfoo baz  \n", Add},
+	{"\tctx\n", Equal},
+	{"\tnew\n", Add},
+	{"}\n", Equal},
+}
+
+func (s *WordDiffEncoderTestSuite) TestEncodePlain(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewWordDiffEncoder(buffer, 1, WordDiffPlain)
+	c.Assert(e.Encode(newTestPatch("foo", wordDiffChunks)), IsNil)
+	c.Assert(buffer.String(), Equals, ""+
+		"diff --git a/foo b/foo\n"+
+		"index f90c39e988fcd5938642ff931c5b5d5869a72b91..788636ffba34694361f7d7ee1970d96fb92c3168 100644\n"+
+		"--- a/foo\n"+
+		"+++ b/foo\n"+
+		"@@ -1,4 +1,5 @@\n"+
+		"func a() {\n"+
+		"\tfoo [-bar-]{+baz+}  \n"+
+		"\tctx\n"+
+		"\t{+new+}\n"+
+		"}\n")
+}
+
+func (s *WordDiffEncoderTestSuite) TestEncodePorcelain(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewWordDiffEncoder(buffer, 1, WordDiffPorcelain)
+	c.Assert(e.Encode(newTestPatch("foo", wordDiffChunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, "@@ -1,4 +1,5 @@\n"+
+		" func a() {\n"+
+		"~\n"+
+		" \tfoo \n"+
+		"-bar\n"+
+		"+baz\n"+
+		"   \n"+
+		"~\n"+
+		" \tctx\n"+
+		"~\n"+
+		" \t\n"+
+		"+new\n"+
+		"~\n"+
+		" }\n"+
+		"~\n")
+}
+
+func (s *WordDiffEncoderTestSuite) TestEncodeColor(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewWordDiffEncoder(buffer, 1, WordDiffColor)
+	c.Assert(e.Encode(newTestPatch("foo", wordDiffChunks)), IsNil)
+	c.Assert(buffer.String(), Equals, ""+
+		"\x1b[1mdiff --git a/foo b/foo\x1b[m\n"+
+		"\x1b[1mindex f90c39e988fcd5938642ff931c5b5d5869a72b91..788636ffba34694361f7d7ee1970d96fb92c3168 100644\x1b[m\n"+
+		"\x1b[1m--- a/foo\x1b[m\n"+
+		"\x1b[1m+++ b/foo\x1b[m\n"+
+		"\x1b[36m@@ -1,4 +1,5 @@\x1b[m\n"+
+		"func a() {\x1b[m\n"+
+		"\tfoo \x1b[31mbar\x1b[m\x1b[32mbaz\x1b[m  \n"+
+		"\tctx\x1b[m\n"+
+		"\t\x1b[32mnew\x1b[m\n"+
+		"}\x1b[m\n")
+}
+
+func (s *WordDiffEncoderTestSuite) TestEncodeWordRegexp(c *C) {
+	chunks := []testChunk{
+		{"foo(bar, baz)\n", Delete},
+		{"foo(bar, qux)\n", Add},
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	e := NewWordDiffEncoder(buffer, 3, WordDiffPlain)
+	c.Assert(e.Encode(newTestPatch("foo", chunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -1 +1 @@
+foo(bar, [-baz)-]{+qux)+}
+`)
+
+	buffer.Reset()
+	e.SetWordRegexp(regexp.MustCompilePOSIX(`[a-z]+|[^[:space:]]`))
+	c.Assert(e.Encode(newTestPatch("foo", chunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -1 +1 @@
+foo(bar, [-baz-]{+qux+})
+`)
+
+	buffer.Reset()
+	e = NewWordDiffEncoder(buffer, 1, WordDiffPlain).SetWordRegexp(regexp.MustCompile(`.`))
+	c.Assert(e.Encode(newTestPatch("foo", wordDiffChunks)), IsNil)
+	c.Assert(hunks(buffer.String()), Equals, `@@ -1,4 +1,5 @@
+func a() {
+	foo ba[-r-]{+z  +}
+	ctx
+{+	new+}
+}
+`)
+}
@@ -0,0 +1,148 @@
+package fastimport
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Command is a command of a fast-import stream, one of *Blob, *Commit, *Tag,
+// *Reset, *Checkpoint, *Progress, *Done or *Feature.
+type Command interface {
+	command()
+}
+
+// Blob declares the content of a file.
+type Blob struct {
+	// Mark is the mark of the blob, zero if it has none.
+	Mark int
+	// OriginalOID is the object id of the blob in the source system, if any.
+	OriginalOID string
+	// Data is the content of the blob.
+	Data []byte
+}
+
+// Commit creates a commit on a branch.
+type Commit struct {
+	// Ref is the branch updated by the commit.
+	Ref plumbing.ReferenceName
+	// Mark is the mark of the commit, zero if it has none.
+	Mark int
+	// OriginalOID is the object id of the commit in the source system, if
+	// any.
+	OriginalOID string
+	// Author is the author of the commit, if nil the committer is used.
+	Author *object.Signature
+	// Committer is the committer of the commit.
+	Committer object.Signature
+	// Encoding is the encoding of the message, if any. It is ignored by the
+	// Importer.
+	Encoding string
+	// Message is the commit message.
+	Message string
+	// From is the commit-ish of the first parent, if empty the current
+	// commit of the branch is used.
+	From string
+	// Merge are the commit-ish of the rest of parents.
+	Merge []string
+	// Changes are the changes to the tree of the first parent.
+	Changes []FileChange
+}
+
+// FileChange is a change to the tree of a commit, one of *FileModify,
+// *FileDelete, *FileCopy, *FileRename or *FileDeleteAll.
+type FileChange interface {
+	fileChange()
+}
+
+// FileModify creates or modifies a file.
+type FileModify struct {
+	// Mode is the mode of the file.
+	Mode filemode.FileMode
+	// DataRef is the mark or the object id of the content of the file, if
+	// empty the content is given by Data.
+	DataRef string
+	// Data is the inline content of the file, used when DataRef is empty.
+	Data []byte
+	// Path is the path of the file.
+	Path string
+}
+
+// FileDelete deletes a file or a directory.
+type FileDelete struct {
+	Path string
+}
+
+// FileCopy copies a file or a directory.
+type FileCopy struct {
+	Source string
+	Dest   string
+}
+
+// FileRename renames a file or a directory.
+type FileRename struct {
+	Source string
+	Dest   string
+}
+
+// FileDeleteAll deletes all the files, leaving an empty tree.
+type FileDeleteAll struct{}
+
+// Tag creates an annotated tag.
+type Tag struct {
+	// Name is the name of the tag, without the refs/tags/ prefix.
+	Name string
+	// Mark is the mark of the tag, zero if it has none.
+	Mark int
+	// From is the commit-ish tagged.
+	From string
+	// OriginalOID is the object id of the tag in the source system, if any.
+	OriginalOID string
+	// Tagger is the tagger of the tag, if any.
+	Tagger *object.Signature
+	// Message is the tag message.
+	Message string
+}
+
+// Reset creates or resets a branch.
+type Reset struct {
+	// Ref is the branch to reset.
+	Ref plumbing.ReferenceName
+	// From is the commit-ish of the new head of the branch, if empty the
+	// next commit of the branch has no parents.
+	From string
+}
+
+// Checkpoint requests the objects and references imported so far to be
+// saved.
+type Checkpoint struct{}
+
+// Progress is a message to print when the command is processed.
+type Progress struct {
+	Message string
+}
+
+// Done marks the end of the stream.
+type Done struct{}
+
+// Feature declares a feature required by the stream.
+type Feature struct {
+	Name string
+	// Value is the argument of the feature, if any.
+	Value string
+}
+
+func (*Blob) command()       {}
+func (*Commit) command()     {}
+func (*Tag) command()        {}
+func (*Reset) command()      {}
+func (*Checkpoint) command() {}
+func (*Progress) command()   {}
+func (*Done) command()       {}
+func (*Feature) command()    {}
+
+func (*FileModify) fileChange()    {}
+func (*FileDelete) fileChange()    {}
+func (*FileCopy) fileChange()      {}
+func (*FileRename) fileChange()    {}
+func (*FileDeleteAll) fileChange() {}
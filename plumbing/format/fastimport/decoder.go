@@ -0,0 +1,510 @@
+package fastimport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// SyntaxError is returned by the Decoder when the stream is malformed.
+type SyntaxError struct {
+	// Line is the number of the line with the error.
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("fast-import stream, line %d: %s", e.Line, e.Msg)
+}
+
+// Decoder reads and decodes the commands of a fast-import stream.
+type Decoder struct {
+	r       *bufio.Reader
+	line    int
+	pending *string
+}
+
+// NewDecoder returns a new fast-import stream decoder, that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Next reads and decodes the next command of the stream, io.EOF is returned
+// at the end of the stream.
+func (d *Decoder) Next() (Command, error) {
+	var line string
+	for {
+		var err error
+		line, err = d.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if line != "" {
+			break
+		}
+	}
+
+	cmd, arg := splitCommand(line)
+	switch cmd {
+	case "blob":
+		return d.decodeBlob()
+	case "commit":
+		return d.decodeCommit(arg)
+	case "tag":
+		return d.decodeTag(arg)
+	case "reset":
+		return d.decodeReset(arg)
+	case "checkpoint":
+		return &Checkpoint{}, nil
+	case "progress":
+		return &Progress{Message: arg}, nil
+	case "done":
+		return &Done{}, nil
+	case "feature":
+		f := &Feature{Name: arg}
+		if i := strings.IndexByte(arg, '='); i >= 0 {
+			f.Name, f.Value = arg[:i], arg[i+1:]
+		}
+
+		return f, nil
+	}
+
+	return nil, d.errorf("unsupported command %q", cmd)
+}
+
+func (d *Decoder) decodeBlob() (*Blob, error) {
+	b := &Blob{}
+	for {
+		line, err := d.readCommandLine()
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, arg := splitCommand(line)
+		switch cmd {
+		case "mark":
+			if b.Mark, err = d.parseMark(arg); err != nil {
+				return nil, err
+			}
+		case "original-oid":
+			b.OriginalOID = arg
+		case "data":
+			b.Data, err = d.readData(arg)
+			return b, err
+		default:
+			return nil, d.errorf("expected data, found %q", line)
+		}
+	}
+}
+
+func (d *Decoder) decodeCommit(ref string) (*Commit, error) {
+	if ref == "" {
+		return nil, d.errorf("missing commit reference")
+	}
+
+	c := &Commit{Ref: plumbing.ReferenceName(ref)}
+	hasCommitter := false
+	for {
+		line, err := d.readCommandLine()
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, arg := splitCommand(line)
+		switch cmd {
+		case "mark":
+			if c.Mark, err = d.parseMark(arg); err != nil {
+				return nil, err
+			}
+		case "original-oid":
+			c.OriginalOID = arg
+		case "author":
+			c.Author = &object.Signature{}
+			c.Author.Decode([]byte(arg))
+		case "committer":
+			c.Committer.Decode([]byte(arg))
+			hasCommitter = true
+		case "encoding":
+			c.Encoding = arg
+		case "data":
+			if !hasCommitter {
+				return nil, d.errorf("missing committer")
+			}
+
+			msg, err := d.readData(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			c.Message = string(msg)
+			if err := d.decodeCommitBody(c); err != nil {
+				return nil, err
+			}
+
+			return c, nil
+		default:
+			return nil, d.errorf("expected data, found %q", line)
+		}
+	}
+}
+
+// decodeCommitBody decodes the parents and changes of a commit, the lines
+// following its message.
+func (d *Decoder) decodeCommitBody(c *Commit) error {
+	for {
+		line, err := d.readLine()
+		if err == io.EOF || line == "" && err == nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		cmd, arg := splitCommand(line)
+		switch cmd {
+		case "from":
+			if c.From != "" || len(c.Merge) != 0 || len(c.Changes) != 0 {
+				return d.errorf("unexpected from")
+			}
+
+			c.From = arg
+		case "merge":
+			if len(c.Changes) != 0 {
+				return d.errorf("unexpected merge")
+			}
+
+			c.Merge = append(c.Merge, arg)
+		case "M":
+			fm, err := d.decodeFileModify(arg)
+			if err != nil {
+				return err
+			}
+
+			c.Changes = append(c.Changes, fm)
+		case "D":
+			path, err := d.parsePath(arg)
+			if err != nil {
+				return err
+			}
+
+			c.Changes = append(c.Changes, &FileDelete{Path: path})
+		case "C", "R":
+			src, dst, err := d.parsePaths(arg)
+			if err != nil {
+				return err
+			}
+
+			if cmd == "C" {
+				c.Changes = append(c.Changes, &FileCopy{Source: src, Dest: dst})
+			} else {
+				c.Changes = append(c.Changes, &FileRename{Source: src, Dest: dst})
+			}
+		case "deleteall":
+			c.Changes = append(c.Changes, &FileDeleteAll{})
+		default:
+			d.unreadLine(line)
+			return nil
+		}
+	}
+}
+
+func (d *Decoder) decodeFileModify(arg string) (*FileModify, error) {
+	parts := strings.SplitN(arg, " ", 3)
+	if len(parts) != 3 {
+		return nil, d.errorf("malformed filemodify %q", arg)
+	}
+
+	mode, err := parseMode(parts[0])
+	if err != nil {
+		return nil, d.errorf("invalid mode %q", parts[0])
+	}
+
+	path, err := d.parsePath(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	fm := &FileModify{Mode: mode, DataRef: parts[1], Path: path}
+	if fm.DataRef != "inline" {
+		return fm, nil
+	}
+
+	fm.DataRef = ""
+	line, err := d.readCommandLine()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, arg := splitCommand(line)
+	if cmd != "data" {
+		return nil, d.errorf("expected data, found %q", line)
+	}
+
+	fm.Data, err = d.readData(arg)
+	return fm, err
+}
+
+func (d *Decoder) decodeTag(name string) (*Tag, error) {
+	if name == "" {
+		return nil, d.errorf("missing tag name")
+	}
+
+	t := &Tag{Name: name}
+	for {
+		line, err := d.readCommandLine()
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, arg := splitCommand(line)
+		switch cmd {
+		case "mark":
+			if t.Mark, err = d.parseMark(arg); err != nil {
+				return nil, err
+			}
+		case "from":
+			t.From = arg
+		case "original-oid":
+			t.OriginalOID = arg
+		case "tagger":
+			t.Tagger = &object.Signature{}
+			t.Tagger.Decode([]byte(arg))
+		case "data":
+			if t.From == "" {
+				return nil, d.errorf("missing tag from")
+			}
+
+			msg, err := d.readData(arg)
+			t.Message = string(msg)
+			return t, err
+		default:
+			return nil, d.errorf("expected data, found %q", line)
+		}
+	}
+}
+
+func (d *Decoder) decodeReset(ref string) (*Reset, error) {
+	if ref == "" {
+		return nil, d.errorf("missing reset reference")
+	}
+
+	r := &Reset{Ref: plumbing.ReferenceName(ref)}
+	line, err := d.readLine()
+	if err == io.EOF || line == "" && err == nil {
+		return r, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, arg := splitCommand(line)
+	if cmd == "from" {
+		r.From = arg
+		return r, nil
+	}
+
+	d.unreadLine(line)
+	return r, nil
+}
+
+// readData reads the content of a data command with the given argument,
+// the exact byte count or the <<delimiter.
+func (d *Decoder) readData(arg string) ([]byte, error) {
+	if strings.HasPrefix(arg, "<<") {
+		return d.readDelimitedData(arg[2:])
+	}
+
+	n, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return nil, d.errorf("invalid data count %q", arg)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, d.errorf("unexpected end of data")
+		}
+
+		return nil, err
+	}
+
+	d.line += bytes.Count(data, []byte("\n"))
+
+	// the data may be followed by an optional LF
+	next, err := d.r.Peek(1)
+	if err == nil && next[0] == '\n' {
+		_, _ = d.r.ReadByte()
+		d.line++
+	}
+
+	return data, nil
+}
+
+func (d *Decoder) readDelimitedData(delim string) ([]byte, error) {
+	if delim == "" {
+		return nil, d.errorf("missing data delimiter")
+	}
+
+	var buf bytes.Buffer
+	for {
+		line, err := d.readRawLine()
+		if err == io.EOF {
+			return nil, d.errorf("missing data delimiter %q", delim)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if line == delim {
+			return buf.Bytes(), nil
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
+
+// readCommandLine reads the next line, returning an error on EOF.
+func (d *Decoder) readCommandLine() (string, error) {
+	line, err := d.readLine()
+	if err == io.EOF {
+		return "", d.errorf("unexpected end of stream")
+	}
+
+	return line, err
+}
+
+// readLine reads the next line skipping the comments.
+func (d *Decoder) readLine() (string, error) {
+	if d.pending != nil {
+		line := *d.pending
+		d.pending = nil
+		return line, nil
+	}
+
+	for {
+		line, err := d.readRawLine()
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+}
+
+func (d *Decoder) readRawLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	d.line++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (d *Decoder) unreadLine(line string) {
+	d.pending = &line
+}
+
+func (d *Decoder) parseMark(arg string) (int, error) {
+	if !strings.HasPrefix(arg, ":") {
+		return 0, d.errorf("invalid mark %q", arg)
+	}
+
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n <= 0 {
+		return 0, d.errorf("invalid mark %q", arg)
+	}
+
+	return n, nil
+}
+
+func (d *Decoder) parsePath(arg string) (string, error) {
+	if !strings.HasPrefix(arg, `"`) {
+		return arg, nil
+	}
+
+	path, rest, err := unquote(arg)
+	if err != nil || rest != "" {
+		return "", d.errorf("malformed path %q", arg)
+	}
+
+	return path, nil
+}
+
+func (d *Decoder) parsePaths(arg string) (string, string, error) {
+	var src, rest string
+	if strings.HasPrefix(arg, `"`) {
+		var err error
+		src, rest, err = unquote(arg)
+		if err != nil || !strings.HasPrefix(rest, " ") {
+			return "", "", d.errorf("malformed path %q", arg)
+		}
+
+		rest = rest[1:]
+	} else {
+		i := strings.IndexByte(arg, ' ')
+		if i < 0 {
+			return "", "", d.errorf("missing destination path %q", arg)
+		}
+
+		src, rest = arg[:i], arg[i+1:]
+	}
+
+	dst, err := d.parsePath(rest)
+	return src, dst, err
+}
+
+func (d *Decoder) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: d.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func splitCommand(line string) (string, string) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return line, ""
+	}
+
+	return line[:i], line[i+1:]
+}
+
+// parseMode parses the mode of a filemodify, in octal with the short forms
+// 644 and 755 allowed.
+func parseMode(s string) (filemode.FileMode, error) {
+	switch s {
+	case "644":
+		return filemode.Regular, nil
+	case "755":
+		return filemode.Executable, nil
+	}
+
+	m, err := filemode.New(s)
+	if err != nil {
+		return filemode.Empty, err
+	}
+
+	switch m {
+	case filemode.Regular, filemode.Executable, filemode.Symlink,
+		filemode.Submodule, filemode.Dir:
+		return m, nil
+	}
+
+	return filemode.Empty, fmt.Errorf("invalid mode %q", s)
+}
@@ -0,0 +1,187 @@
+package fastimport
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type DecoderSuite struct{}
+
+var _ = Suite(&DecoderSuite{})
+
+const stream = `# a comment
+feature done
+blob
+mark :1
+original-oid 8ab686eafeb1f44702738c8b0f24f2567c36da6d
+data 4
+foo
+
+commit refs/heads/master
+mark :2
+author Foo <foo@example.com> 1500000000 +0200
+committer Bar <bar@example.com> 1500000100 -0100
+data <<EOM
+first
+commit
+EOM
+M 100644 :1 foo
+M 644 inline "bar\tqux"
+data 3
+bar
+M 120000 :1 link
+D old
+R "a b" c
+C d "e\"f"
+
+commit refs/heads/master
+committer Bar <bar@example.com> 1500000200 +0000
+data 6
+second
+from :2
+merge refs/heads/other^0
+deleteall
+
+tag v1.0.0
+from :2
+tagger Bar <bar@example.com> 1500000300 +0000
+data 3
+tag
+reset refs/heads/other
+from :2
+
+reset refs/heads/empty
+progress 50%
+checkpoint
+done
+`
+
+func (s *DecoderSuite) decodeAll(c *C, input string) []Command {
+	d := NewDecoder(strings.NewReader(input))
+
+	var cmds []Command
+	for {
+		cmd, err := d.Next()
+		if err == io.EOF {
+			return cmds
+		}
+
+		c.Assert(err, IsNil)
+		cmds = append(cmds, cmd)
+	}
+}
+
+func (s *DecoderSuite) TestNext(c *C) {
+	cmds := s.decodeAll(c, stream)
+	c.Assert(cmds, HasLen, 10)
+
+	c.Assert(cmds[0], DeepEquals, &Feature{Name: "done"})
+	c.Assert(cmds[1], DeepEquals, &Blob{
+		Mark:        1,
+		OriginalOID: "8ab686eafeb1f44702738c8b0f24f2567c36da6d",
+		Data:        []byte("foo\n"),
+	})
+
+	commit := cmds[2].(*Commit)
+	c.Assert(string(commit.Ref), Equals, "refs/heads/master")
+	c.Assert(commit.Mark, Equals, 2)
+	c.Assert(commit.Author.Name, Equals, "Foo")
+	c.Assert(commit.Author.Email, Equals, "foo@example.com")
+	c.Assert(commit.Author.When.Equal(time.Unix(1500000000, 0)), Equals, true)
+	c.Assert(commit.Committer.Name, Equals, "Bar")
+	c.Assert(commit.Message, Equals, "first\ncommit\n")
+	c.Assert(commit.From, Equals, "")
+	c.Assert(commit.Changes, DeepEquals, []FileChange{
+		&FileModify{Mode: filemode.Regular, DataRef: ":1", Path: "foo"},
+		&FileModify{Mode: filemode.Regular, Data: []byte("bar"), Path: "bar\tqux"},
+		&FileModify{Mode: filemode.Symlink, DataRef: ":1", Path: "link"},
+		&FileDelete{Path: "old"},
+		&FileRename{Source: "a b", Dest: "c"},
+		&FileCopy{Source: "d", Dest: `e"f`},
+	})
+
+	commit = cmds[3].(*Commit)
+	c.Assert(commit.Author, IsNil)
+	c.Assert(commit.Message, Equals, "second")
+	c.Assert(commit.From, Equals, ":2")
+	c.Assert(commit.Merge, DeepEquals, []string{"refs/heads/other^0"})
+	c.Assert(commit.Changes, DeepEquals, []FileChange{&FileDeleteAll{}})
+
+	tag := cmds[4].(*Tag)
+	c.Assert(tag.Name, Equals, "v1.0.0")
+	c.Assert(tag.From, Equals, ":2")
+	c.Assert(tag.Tagger.Name, Equals, "Bar")
+	c.Assert(tag.Message, Equals, "tag")
+
+	c.Assert(cmds[5], DeepEquals, &Reset{Ref: "refs/heads/other", From: ":2"})
+	c.Assert(cmds[6], DeepEquals, &Reset{Ref: "refs/heads/empty"})
+	c.Assert(cmds[7], DeepEquals, &Progress{Message: "50%"})
+	c.Assert(cmds[8], DeepEquals, &Checkpoint{})
+	c.Assert(cmds[9], DeepEquals, &Done{})
+}
+
+func (s *DecoderSuite) TestNextErrors(c *C) {
+	for _, input := range []string{
+		"foo\n",
+		"blob\ndata 10\nfoo\n",
+		"blob\nmark 1\ndata 0\n",
+		"blob\ndata <<EOM\nfoo\n",
+		"commit refs/heads/master\ndata 0\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nM 100600 :1 foo\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nR foo\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nD \"foo\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nM 100644 :1 foo\nfrom :1\n",
+		"tag v1\ndata 0\n",
+	} {
+		d := NewDecoder(strings.NewReader(input))
+		_, err := d.Next()
+		_, ok := err.(*SyntaxError)
+		c.Assert(ok, Equals, true, Commentf("%q: %v", input, err))
+	}
+}
+
+func (s *DecoderSuite) TestSyntaxError(c *C) {
+	d := NewDecoder(strings.NewReader("blob\ndata 0\n\nfoo\n"))
+	_, err := d.Next()
+	c.Assert(err, IsNil)
+
+	_, err = d.Next()
+	c.Assert(err, ErrorMatches, `fast-import stream, line 4: unsupported command "foo"`)
+}
+
+func (s *DecoderSuite) TestQuote(c *C) {
+	for path, quoted := range map[string]string{
+		"foo":       "foo",
+		"foo bar":   "foo bar",
+		"foo\nbar":  `"foo\nbar"`,
+		`foo"bar`:   `"foo\"bar"`,
+		`foo\bar`:   `"foo\\bar"`,
+		"foo\x01":   `"foo\001"`,
+		"föö":       "föö",
+		"\"foo bar": `"\"foo bar"`,
+	} {
+		c.Assert(quote(path, false), Equals, quoted)
+
+		if quoted[0] == '"' {
+			unquoted, rest, err := unquote(quoted)
+			c.Assert(err, IsNil)
+			c.Assert(unquoted, Equals, path)
+			c.Assert(rest, Equals, "")
+		}
+	}
+
+	c.Assert(quote("foo bar", true), Equals, `"foo bar"`)
+
+	_, _, err := unquote(`"foo`)
+	c.Assert(err, NotNil)
+	_, _, err = unquote(`"foo\x"`)
+	c.Assert(err, NotNil)
+}
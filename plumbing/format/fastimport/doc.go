@@ -0,0 +1,64 @@
+// Package fastimport implements encoding and decoding of the git fast-import
+// streams, and an Importer writing the objects of a stream to an object
+// storage.
+//
+// A fast-import stream is a sequence of commands, used to import history
+// from other version control systems or to rewrite it, as `git fast-export`
+// writes and `git fast-import` reads:
+//
+//  == Commands:
+//
+//    - blob, declares the content of a file, usually with a mark to
+//      reference it from the following commits:
+//
+//      blob
+//      mark :<idnum>
+//      data <count>
+//      <raw content>
+//
+//    - commit, creates a commit on a branch. The from and merge lines
+//      declare the parents, the following lines the changes to the tree of
+//      the first parent:
+//
+//      commit <ref>
+//      mark :<idnum>
+//      author <name> <email> <when>
+//      committer <name> <email> <when>
+//      data <count>
+//      <message>
+//      from <commit-ish>
+//      merge <commit-ish>
+//      M <mode> <dataref> <path>
+//      D <path>
+//      R <source> <dest>
+//      C <source> <dest>
+//      deleteall
+//
+//    - tag, creates an annotated tag:
+//
+//      tag <name>
+//      from <commit-ish>
+//      tagger <name> <email> <when>
+//      data <count>
+//      <message>
+//
+//    - reset, creates or resets a branch, optionally to a commit-ish:
+//
+//      reset <ref>
+//      from <commit-ish>
+//
+//    - checkpoint, progress, done and feature.
+//
+//  A commit-ish is a mark (":<idnum>"), an object id or the name of a
+//  branch. A dataref is a mark, an object id or "inline", when the data
+//  command with the content follows the line. Data is given with the exact
+//  count of bytes, or delimited as "data <<<delim>", with the content till a
+//  line containing only <delim>. Paths with special characters are quoted
+//  as C-style strings.
+//
+// The marks of the objects can be exported to a marks file, with a line
+// ":<idnum> <object id>" for each mark, and imported by a later run to make
+// incremental imports and exports.
+//
+// https://git-scm.com/docs/git-fast-import
+package fastimport
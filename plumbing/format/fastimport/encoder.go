@@ -0,0 +1,139 @@
+package fastimport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Encoder writes the commands of a fast-import stream to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new fast-import stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes a command to the stream.
+func (e *Encoder) Encode(cmd Command) error {
+	buf := bytes.NewBuffer(nil)
+	switch c := cmd.(type) {
+	case *Blob:
+		buf.WriteString("blob\n")
+		writeMark(buf, c.Mark)
+		writeLine(buf, "original-oid", c.OriginalOID)
+		writeData(buf, c.Data)
+	case *Commit:
+		if err := encodeCommit(buf, c); err != nil {
+			return err
+		}
+	case *Tag:
+		fmt.Fprintf(buf, "tag %s\n", c.Name)
+		writeMark(buf, c.Mark)
+		fmt.Fprintf(buf, "from %s\n", c.From)
+		writeLine(buf, "original-oid", c.OriginalOID)
+		writeSignature(buf, "tagger", c.Tagger)
+		writeData(buf, []byte(c.Message))
+	case *Reset:
+		fmt.Fprintf(buf, "reset %s\n", c.Ref)
+		writeLine(buf, "from", c.From)
+		buf.WriteByte('\n')
+	case *Checkpoint:
+		buf.WriteString("checkpoint\n\n")
+	case *Progress:
+		fmt.Fprintf(buf, "progress %s\n\n", c.Message)
+	case *Done:
+		buf.WriteString("done\n")
+	case *Feature:
+		buf.WriteString("feature " + c.Name)
+		if c.Value != "" {
+			buf.WriteString("=" + c.Value)
+		}
+
+		buf.WriteByte('\n')
+	default:
+		return fmt.Errorf("unsupported command %T", cmd)
+	}
+
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func encodeCommit(buf *bytes.Buffer, c *Commit) error {
+	fmt.Fprintf(buf, "commit %s\n", c.Ref)
+	writeMark(buf, c.Mark)
+	writeLine(buf, "original-oid", c.OriginalOID)
+	writeSignature(buf, "author", c.Author)
+	writeSignature(buf, "committer", &c.Committer)
+	writeLine(buf, "encoding", c.Encoding)
+	writeData(buf, []byte(c.Message))
+	writeLine(buf, "from", c.From)
+	for _, m := range c.Merge {
+		writeLine(buf, "merge", m)
+	}
+
+	for _, ch := range c.Changes {
+		switch ch := ch.(type) {
+		case *FileModify:
+			dataref := ch.DataRef
+			if dataref == "" {
+				dataref = "inline"
+			}
+
+			fmt.Fprintf(buf, "M %s %s %s\n", formatMode(ch.Mode), dataref, quote(ch.Path, false))
+			if ch.DataRef == "" {
+				writeData(buf, ch.Data)
+			}
+		case *FileDelete:
+			fmt.Fprintf(buf, "D %s\n", quote(ch.Path, false))
+		case *FileCopy:
+			fmt.Fprintf(buf, "C %s %s\n", quote(ch.Source, true), quote(ch.Dest, false))
+		case *FileRename:
+			fmt.Fprintf(buf, "R %s %s\n", quote(ch.Source, true), quote(ch.Dest, false))
+		case *FileDeleteAll:
+			buf.WriteString("deleteall\n")
+		default:
+			return fmt.Errorf("unsupported file change %T", ch)
+		}
+	}
+
+	buf.WriteByte('\n')
+	return nil
+}
+
+func writeMark(buf *bytes.Buffer, mark int) {
+	if mark != 0 {
+		fmt.Fprintf(buf, "mark :%d\n", mark)
+	}
+}
+
+func writeLine(buf *bytes.Buffer, cmd, arg string) {
+	if arg != "" {
+		fmt.Fprintf(buf, "%s %s\n", cmd, arg)
+	}
+}
+
+func writeSignature(buf *bytes.Buffer, cmd string, s *object.Signature) {
+	if s == nil {
+		return
+	}
+
+	buf.WriteString(cmd + " ")
+	_ = s.Encode(buf)
+	buf.WriteByte('\n')
+}
+
+func writeData(buf *bytes.Buffer, data []byte) {
+	fmt.Fprintf(buf, "data %d\n", len(data))
+	buf.Write(data)
+	buf.WriteByte('\n')
+}
+
+func formatMode(m filemode.FileMode) string {
+	return fmt.Sprintf("%06o", uint32(m))
+}
@@ -0,0 +1,104 @@
+package fastimport
+
+import (
+	"bytes"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	. "gopkg.in/check.v1"
+)
+
+type EncoderSuite struct{}
+
+var _ = Suite(&EncoderSuite{})
+
+func (s *EncoderSuite) TestEncode(c *C) {
+	when := time.Unix(1500000000, 0).In(time.FixedZone("", 7200))
+	cmds := []Command{
+		&Feature{Name: "done"},
+		&Blob{Mark: 1, Data: []byte("foo\n")},
+		&Commit{
+			Ref:       "refs/heads/master",
+			Mark:      2,
+			Author:    &object.Signature{Name: "Foo", Email: "foo@example.com", When: when},
+			Committer: object.Signature{Name: "Bar", Email: "bar@example.com", When: when},
+			Message:   "foo\n",
+			From:      ":1",
+			Merge:     []string{":3"},
+			Changes: []FileChange{
+				&FileModify{Mode: filemode.Regular, DataRef: ":1", Path: "foo"},
+				&FileModify{Mode: filemode.Executable, Data: []byte("bar"), Path: "foo\nbar"},
+				&FileDelete{Path: "qux"},
+				&FileRename{Source: "a b", Dest: "c d"},
+				&FileCopy{Source: "e", Dest: "f"},
+				&FileDeleteAll{},
+			},
+		},
+		&Tag{
+			Name:    "v1.0.0",
+			From:    ":2",
+			Tagger:  &object.Signature{Name: "Bar", Email: "bar@example.com", When: when},
+			Message: "tag\n",
+		},
+		&Reset{Ref: "refs/heads/other", From: ":2"},
+		&Progress{Message: "foo"},
+		&Checkpoint{},
+		&Done{},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	for _, cmd := range cmds {
+		c.Assert(e.Encode(cmd), IsNil)
+	}
+
+	c.Assert(buf.String(), Equals, `feature done
+blob
+mark :1
+data 4
+foo
+
+commit refs/heads/master
+mark :2
+author Foo <foo@example.com> 1500000000 +0200
+committer Bar <bar@example.com> 1500000000 +0200
+data 4
+foo
+
+from :1
+merge :3
+M 100644 :1 foo
+M 100755 inline "foo\nbar"
+data 3
+bar
+D qux
+R "a b" c d
+C e f
+deleteall
+
+tag v1.0.0
+from :2
+tagger Bar <bar@example.com> 1500000000 +0200
+data 4
+tag
+
+reset refs/heads/other
+from :2
+
+progress foo
+
+checkpoint
+
+done
+`)
+
+	decoded := (&DecoderSuite{}).decodeAll(c, buf.String())
+	c.Assert(decoded, HasLen, len(cmds))
+
+	commit := decoded[2].(*Commit)
+	c.Assert(commit.Changes, DeepEquals, cmds[2].(*Commit).Changes)
+	c.Assert(commit.Message, Equals, "foo\n")
+	c.Assert(commit.Merge, DeepEquals, []string{":3"})
+}
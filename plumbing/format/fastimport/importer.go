@@ -0,0 +1,445 @@
+package fastimport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// ErrMissingDone is returned by Import when the stream declares the done
+// feature but it ends without a done command.
+var ErrMissingDone = errors.New("stream ended without the done command")
+
+// Importer writes the objects of fast-import streams to an object storage.
+//
+// The commits are written as the commands are applied, the branches and tags
+// are kept in memory and, if the storage is also a storer.ReferenceStorer,
+// written at the end of the stream and at each checkpoint.
+type Importer struct {
+	// Marks are the marks of the imported objects, they can be loaded from
+	// the marks file of a previous import to continue it.
+	Marks Marks
+	// Progress receives the messages of the progress commands, if not nil.
+	Progress sideband.Progress
+
+	s     storer.EncodedObjectStorer
+	refs  map[plumbing.ReferenceName]plumbing.Hash
+	trees map[plumbing.ReferenceName]*branchTree
+	done  bool
+}
+
+// branchTree is the tree builder of the last commit of a branch, reused by
+// the next commit of the branch.
+type branchTree struct {
+	commit  plumbing.Hash
+	builder *treeBuilder
+}
+
+// NewImporter returns a new Importer writing the objects to s.
+func NewImporter(s storer.EncodedObjectStorer) *Importer {
+	return &Importer{
+		Marks: make(Marks),
+		s:     s,
+		refs:  make(map[plumbing.ReferenceName]plumbing.Hash),
+		trees: make(map[plumbing.ReferenceName]*branchTree),
+	}
+}
+
+// Import reads and applies the commands of a stream, till its end or a done
+// command, and updates the references.
+func (i *Importer) Import(r io.Reader) error {
+	d := NewDecoder(r)
+	for {
+		cmd, err := d.Next()
+		if err == io.EOF {
+			if i.done {
+				return ErrMissingDone
+			}
+
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, ok := cmd.(*Done); ok {
+			break
+		}
+
+		if err := i.Apply(cmd); err != nil {
+			return err
+		}
+	}
+
+	return i.UpdateReferences()
+}
+
+// Apply applies a command of a stream.
+func (i *Importer) Apply(cmd Command) error {
+	switch c := cmd.(type) {
+	case *Blob:
+		h, err := i.storeBlob(c.Data)
+		if err != nil {
+			return err
+		}
+
+		i.mark(c.Mark, h)
+		return nil
+	case *Commit:
+		return i.applyCommit(c)
+	case *Tag:
+		return i.applyTag(c)
+	case *Reset:
+		return i.applyReset(c)
+	case *Checkpoint:
+		return i.UpdateReferences()
+	case *Progress:
+		if i.Progress != nil {
+			_, err := fmt.Fprintf(i.Progress, "progress %s\n", c.Message)
+			return err
+		}
+
+		return nil
+	case *Done:
+		return nil
+	case *Feature:
+		return i.applyFeature(c)
+	}
+
+	return fmt.Errorf("unsupported command %T", cmd)
+}
+
+// References returns the branches and tags created or updated by the
+// imported commands, sorted by name.
+func (i *Importer) References() []*plumbing.Reference {
+	var refs []*plumbing.Reference
+	for name, h := range i.refs {
+		if h.IsZero() {
+			continue
+		}
+
+		refs = append(refs, plumbing.NewHashReference(name, h))
+	}
+
+	sort.Slice(refs, func(a, b int) bool {
+		return refs[a].Name() < refs[b].Name()
+	})
+
+	return refs
+}
+
+// UpdateReferences writes the branches and tags created or updated by the
+// imported commands, if the storage is a storer.ReferenceStorer.
+func (i *Importer) UpdateReferences() error {
+	rs, ok := i.s.(storer.ReferenceStorer)
+	if !ok {
+		return nil
+	}
+
+	for _, ref := range i.References() {
+		if err := rs.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Importer) applyFeature(f *Feature) error {
+	switch f.Name {
+	case "done":
+		i.done = true
+	case "force":
+	case "date-format":
+		if f.Value != "raw" {
+			return fmt.Errorf("unsupported date format %q", f.Value)
+		}
+	default:
+		return fmt.Errorf("unsupported feature %q", f.Name)
+	}
+
+	return nil
+}
+
+func (i *Importer) applyCommit(c *Commit) error {
+	var parents []plumbing.Hash
+	if c.From != "" {
+		h, err := i.resolve(c.From)
+		if err != nil {
+			return err
+		}
+
+		parents = append(parents, h)
+	} else if h := i.refs[c.Ref]; !h.IsZero() {
+		parents = append(parents, h)
+	}
+
+	for _, m := range c.Merge {
+		h, err := i.resolve(m)
+		if err != nil {
+			return err
+		}
+
+		parents = append(parents, h)
+	}
+
+	b, err := i.treeBuilder(c.Ref, parents)
+	if err != nil {
+		return err
+	}
+
+	// the builder is kept only if the commit succeeds
+	delete(i.trees, c.Ref)
+	for _, ch := range c.Changes {
+		if err := i.applyFileChange(b, ch); err != nil {
+			return err
+		}
+	}
+
+	tree, err := b.Write()
+	if err != nil {
+		return err
+	}
+
+	commit := &object.Commit{
+		Author:       c.Committer,
+		Committer:    c.Committer,
+		Message:      c.Message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+
+	if c.Author != nil {
+		commit.Author = *c.Author
+	}
+
+	h, err := i.storeObject(commit)
+	if err != nil {
+		return err
+	}
+
+	i.mark(c.Mark, h)
+	i.refs[c.Ref] = h
+	i.trees[c.Ref] = &branchTree{commit: h, builder: b}
+	return nil
+}
+
+// treeBuilder returns a treeBuilder with the tree of the first parent.
+func (i *Importer) treeBuilder(ref plumbing.ReferenceName, parents []plumbing.Hash) (*treeBuilder, error) {
+	if len(parents) == 0 {
+		return newTreeBuilder(i.s, plumbing.ZeroHash), nil
+	}
+
+	if bt, ok := i.trees[ref]; ok && bt.commit == parents[0] {
+		return bt.builder, nil
+	}
+
+	parent, err := object.GetCommit(i.s, parents[0])
+	if err != nil {
+		return nil, fmt.Errorf("parent %s: %s", parents[0], err)
+	}
+
+	return newTreeBuilder(i.s, parent.TreeHash), nil
+}
+
+func (i *Importer) applyFileChange(b *treeBuilder, ch FileChange) error {
+	switch ch := ch.(type) {
+	case *FileModify:
+		var h plumbing.Hash
+		var err error
+		if ch.DataRef == "" {
+			h, err = i.storeBlob(ch.Data)
+		} else {
+			h, err = i.resolveObject(ch.DataRef)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return b.Set(ch.Path, &treeEntry{mode: ch.Mode, hash: h})
+	case *FileDelete:
+		_, err := b.Remove(ch.Path)
+		return err
+	case *FileCopy:
+		e, err := b.Get(ch.Source)
+		if err != nil {
+			return err
+		}
+
+		if e == nil {
+			return fmt.Errorf("path %q not found", ch.Source)
+		}
+
+		e, err = b.Copy(e)
+		if err != nil {
+			return err
+		}
+
+		return b.Set(ch.Dest, e)
+	case *FileRename:
+		e, err := b.Remove(ch.Source)
+		if err != nil {
+			return err
+		}
+
+		if e == nil {
+			return fmt.Errorf("path %q not found", ch.Source)
+		}
+
+		return b.Set(ch.Dest, e)
+	case *FileDeleteAll:
+		b.reset(plumbing.ZeroHash)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported file change %T", ch)
+}
+
+func (i *Importer) applyTag(t *Tag) error {
+	target, err := i.resolve(t.From)
+	if err != nil {
+		return err
+	}
+
+	o, err := i.s.EncodedObject(plumbing.AnyObject, target)
+	if err != nil {
+		return err
+	}
+
+	tag := &object.Tag{
+		Name:       t.Name,
+		Message:    t.Message,
+		Target:     target,
+		TargetType: o.Type(),
+	}
+
+	if t.Tagger != nil {
+		tag.Tagger = *t.Tagger
+	}
+
+	h, err := i.storeObject(tag)
+	if err != nil {
+		return err
+	}
+
+	i.mark(t.Mark, h)
+	i.refs[plumbing.NewTagReferenceName(t.Name)] = h
+	return nil
+}
+
+func (i *Importer) applyReset(r *Reset) error {
+	delete(i.trees, r.Ref)
+	if r.From == "" {
+		i.refs[r.Ref] = plumbing.ZeroHash
+		return nil
+	}
+
+	h, err := i.resolve(r.From)
+	if err != nil {
+		return err
+	}
+
+	i.refs[r.Ref] = h
+	return nil
+}
+
+// resolve returns the object of a commit-ish: a mark, a branch of the
+// stream or the storage, or an object id.
+func (i *Importer) resolve(commitish string) (plumbing.Hash, error) {
+	name := strings.TrimSuffix(commitish, "^0")
+	if strings.HasPrefix(name, ":") {
+		return i.resolveObject(name)
+	}
+
+	if h, ok := i.refs[plumbing.ReferenceName(name)]; ok && !h.IsZero() {
+		return h, nil
+	}
+
+	if h, ok := parseHash(name); ok {
+		return h, nil
+	}
+
+	if rs, ok := i.s.(storer.ReferenceStorer); ok {
+		for _, n := range []string{name, "refs/heads/" + name} {
+			ref, err := storer.ResolveReference(rs, plumbing.ReferenceName(n))
+			if err == nil {
+				return ref.Hash(), nil
+			}
+
+			if err != plumbing.ErrReferenceNotFound {
+				return plumbing.ZeroHash, err
+			}
+		}
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("unknown commit-ish %q", commitish)
+}
+
+// resolveObject returns the object of a dataref, a mark or an object id.
+func (i *Importer) resolveObject(ref string) (plumbing.Hash, error) {
+	if strings.HasPrefix(ref, ":") {
+		var mark int
+		if _, err := fmt.Sscanf(ref, ":%d", &mark); err == nil {
+			if h, ok := i.Marks[mark]; ok {
+				return h, nil
+			}
+		}
+
+		return plumbing.ZeroHash, fmt.Errorf("unknown mark %q", ref)
+	}
+
+	h, ok := parseHash(ref)
+	if !ok {
+		return plumbing.ZeroHash, fmt.Errorf("invalid object id %q", ref)
+	}
+
+	return h, nil
+}
+
+func (i *Importer) mark(mark int, h plumbing.Hash) {
+	if mark != 0 {
+		i.Marks[mark] = h
+	}
+}
+
+func (i *Importer) storeBlob(data []byte) (plumbing.Hash, error) {
+	o := i.s.NewEncodedObject()
+	o.SetType(plumbing.BlobObject)
+	o.SetSize(int64(len(data)))
+
+	w, err := o.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return i.s.SetEncodedObject(o)
+}
+
+func (i *Importer) storeObject(obj interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	o := i.s.NewEncodedObject()
+	if err := obj.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return i.s.SetEncodedObject(o)
+}
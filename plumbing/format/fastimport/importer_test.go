@@ -0,0 +1,232 @@
+package fastimport
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type ImporterSuite struct{}
+
+var _ = Suite(&ImporterSuite{})
+
+const importStream = `blob
+mark :1
+data 4
+foo
+
+blob
+mark :2
+data 4
+bar
+
+commit refs/heads/master
+mark :3
+author Foo <foo@example.com> 1500000000 +0200
+committer Bar <bar@example.com> 1500000100 +0200
+data 6
+first
+M 100644 :1 foo
+M 100755 :2 dir/bar
+M 644 inline dir/sub/qux
+data 4
+qux
+
+commit refs/heads/master
+mark :4
+committer Bar <bar@example.com> 1500000200 +0200
+data 7
+second
+R dir/sub renamed
+C foo dir/foo
+D dir/bar
+
+commit refs/heads/other
+mark :5
+committer Bar <bar@example.com> 1500000300 +0200
+data 6
+other
+from :3
+merge :4
+deleteall
+M 120000 inline link
+data 3
+foo
+
+tag v1.0.0
+mark :6
+from :4
+tagger Bar <bar@example.com> 1500000400 +0200
+data 4
+tag
+
+reset refs/tags/light
+from refs/heads/master
+
+progress done
+done
+`
+
+func (s *ImporterSuite) files(c *C, commit *object.Commit) map[string]string {
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+
+	files := make(map[string]string)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		c.Assert(err, IsNil)
+
+		files[f.Name] = f.Mode.String() + " " + content
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	return files
+}
+
+func (s *ImporterSuite) TestImport(c *C) {
+	st := memory.NewStorage()
+	progress := bytes.NewBuffer(nil)
+
+	i := NewImporter(st)
+	i.Progress = progress
+	c.Assert(i.Import(strings.NewReader(importStream)), IsNil)
+	c.Assert(progress.String(), Equals, "progress done\n")
+	c.Assert(i.Marks, HasLen, 6)
+
+	first, err := object.GetCommit(st, i.Marks[3])
+	c.Assert(err, IsNil)
+	c.Assert(first.Author.Name, Equals, "Foo")
+	c.Assert(first.Committer.Name, Equals, "Bar")
+	c.Assert(first.Message, Equals, "first\n")
+	c.Assert(first.ParentHashes, HasLen, 0)
+	c.Assert(s.files(c, first), DeepEquals, map[string]string{
+		"foo":         "0100644 foo\n",
+		"dir/bar":     "0100755 bar\n",
+		"dir/sub/qux": "0100644 qux\n",
+	})
+
+	second, err := object.GetCommit(st, i.Marks[4])
+	c.Assert(err, IsNil)
+	c.Assert(second.Author.Name, Equals, "Bar")
+	c.Assert(second.ParentHashes, DeepEquals, []plumbing.Hash{first.Hash})
+	c.Assert(s.files(c, second), DeepEquals, map[string]string{
+		"foo":         "0100644 foo\n",
+		"dir/foo":     "0100644 foo\n",
+		"renamed/qux": "0100644 qux\n",
+	})
+
+	other, err := object.GetCommit(st, i.Marks[5])
+	c.Assert(err, IsNil)
+	c.Assert(other.ParentHashes, DeepEquals, []plumbing.Hash{first.Hash, second.Hash})
+	c.Assert(s.files(c, other), DeepEquals, map[string]string{
+		"link": "0120000 foo",
+	})
+
+	tag, err := object.GetTag(st, i.Marks[6])
+	c.Assert(err, IsNil)
+	c.Assert(tag.Name, Equals, "v1.0.0")
+	c.Assert(tag.Target, Equals, second.Hash)
+	c.Assert(tag.TargetType, Equals, plumbing.CommitObject)
+
+	refs := map[plumbing.ReferenceName]plumbing.Hash{
+		"refs/heads/master": second.Hash,
+		"refs/heads/other":  other.Hash,
+		"refs/tags/v1.0.0":  tag.Hash,
+		"refs/tags/light":   second.Hash,
+	}
+
+	c.Assert(i.References(), HasLen, len(refs))
+	for name, h := range refs {
+		ref, err := st.Reference(name)
+		c.Assert(err, IsNil)
+		c.Assert(ref.Hash(), Equals, h)
+	}
+}
+
+func (s *ImporterSuite) TestImportIncremental(c *C) {
+	st := memory.NewStorage()
+
+	i := NewImporter(st)
+	c.Assert(i.Import(strings.NewReader(importStream)), IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(i.Marks.Encode(buf), IsNil)
+
+	i = NewImporter(st)
+	c.Assert(i.Marks.Decode(buf), IsNil)
+
+	err := i.Import(strings.NewReader(`commit refs/heads/master
+mark :7
+committer Bar <bar@example.com> 1500000500 +0200
+data 5
+third
+from refs/heads/master^0
+M 100644 :2 foo
+`))
+	c.Assert(err, IsNil)
+
+	third, err := object.GetCommit(st, i.Marks[7])
+	c.Assert(err, IsNil)
+	c.Assert(third.ParentHashes, DeepEquals, []plumbing.Hash{i.Marks[4]})
+	c.Assert(s.files(c, third)["foo"], Equals, "0100644 bar\n")
+
+	ref, err := st.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, third.Hash)
+}
+
+func (s *ImporterSuite) TestImportErrors(c *C) {
+	for _, input := range []string{
+		"feature done\n",
+		"feature foo\n",
+		"reset refs/heads/master\nfrom :1\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nM 100644 :1 foo\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nR foo bar\n",
+		"commit refs/heads/master\ncommitter Foo <foo> 0 +0000\ndata 0\nfrom refs/heads/missing\n",
+	} {
+		err := NewImporter(memory.NewStorage()).Import(strings.NewReader(input))
+		c.Assert(err, NotNil, Commentf("%q", input))
+	}
+}
+
+func (s *ImporterSuite) TestTreeBuilder(c *C) {
+	st := memory.NewStorage()
+	i := NewImporter(st)
+
+	b := newTreeBuilder(st, plumbing.ZeroHash)
+	foo, err := i.storeBlob([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	c.Assert(b.Set("a/b/c", &treeEntry{mode: filemode.Regular, hash: foo}), IsNil)
+	c.Assert(b.Set("a.txt", &treeEntry{mode: filemode.Regular, hash: foo}), IsNil)
+
+	h, err := b.Write()
+	c.Assert(err, IsNil)
+
+	tree, err := object.GetTree(st, h)
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 2)
+	// the directories are sorted as if their name ends with a slash
+	c.Assert(tree.Entries[0].Name, Equals, "a.txt")
+	c.Assert(tree.Entries[1].Name, Equals, "a")
+
+	b = newTreeBuilder(st, h)
+	e, err := b.Remove("a/b/c")
+	c.Assert(err, IsNil)
+	c.Assert(e.hash, Equals, foo)
+
+	h, err = b.Write()
+	c.Assert(err, IsNil)
+
+	tree, err = object.GetTree(st, h)
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 1)
+	c.Assert(tree.Entries[0].Name, Equals, "a.txt")
+}
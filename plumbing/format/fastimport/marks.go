@@ -0,0 +1,87 @@
+package fastimport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// Marks maps the marks of a fast-import stream to the objects they
+// identify, by its number.
+type Marks map[int]plumbing.Hash
+
+// Decode reads the marks of a marks file, a ":<idnum> <object id>" line for
+// each mark, and adds them to m.
+func (m Marks) Decode(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], ":") {
+			return &SyntaxError{Line: n, Msg: fmt.Sprintf("malformed mark %q", line)}
+		}
+
+		mark, err := strconv.Atoi(parts[0][1:])
+		if err != nil || mark <= 0 {
+			return &SyntaxError{Line: n, Msg: fmt.Sprintf("invalid mark %q", parts[0])}
+		}
+
+		h, ok := parseHash(parts[1])
+		if !ok {
+			return &SyntaxError{Line: n, Msg: fmt.Sprintf("invalid object id %q", parts[1])}
+		}
+
+		m[mark] = h
+	}
+
+	return s.Err()
+}
+
+// Encode writes the marks as a marks file, sorted by number.
+func (m Marks) Encode(w io.Writer) error {
+	marks := make([]int, 0, len(m))
+	for mark := range m {
+		marks = append(marks, mark)
+	}
+
+	sort.Ints(marks)
+
+	bw := bufio.NewWriter(w)
+	for _, mark := range marks {
+		if _, err := fmt.Fprintf(bw, ":%d %s\n", mark, m[mark]); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Next returns the lowest number greater than the numbers of all the marks.
+func (m Marks) Next() int {
+	next := 1
+	for mark := range m {
+		if mark >= next {
+			next = mark + 1
+		}
+	}
+
+	return next
+}
+
+func parseHash(s string) (plumbing.Hash, bool) {
+	if len(s) != 40 {
+		return plumbing.ZeroHash, false
+	}
+
+	h := plumbing.NewHash(s)
+	return h, h.String() == s
+}
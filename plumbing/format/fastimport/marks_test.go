@@ -0,0 +1,47 @@
+package fastimport
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+type MarksSuite struct{}
+
+var _ = Suite(&MarksSuite{})
+
+func (s *MarksSuite) TestDecodeEncode(c *C) {
+	input := ":10 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n" +
+		"\n" +
+		":2 918c48b83bd081e863dbe1b80f8998f058cd8294\n"
+
+	m := make(Marks)
+	c.Assert(m.Decode(strings.NewReader(input)), IsNil)
+	c.Assert(m, DeepEquals, Marks{
+		10: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		2:  plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+	})
+
+	c.Assert(m.Next(), Equals, 11)
+	c.Assert(Marks{}.Next(), Equals, 1)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(m.Encode(buf), IsNil)
+	c.Assert(buf.String(), Equals, ":2 918c48b83bd081e863dbe1b80f8998f058cd8294\n"+
+		":10 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n")
+}
+
+func (s *MarksSuite) TestDecodeErrors(c *C) {
+	for _, input := range []string{
+		"foo",
+		"10 6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		":foo 6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		":1 foo",
+	} {
+		err := make(Marks).Decode(strings.NewReader(input))
+		c.Assert(err, NotNil, Commentf("%q", input))
+	}
+}
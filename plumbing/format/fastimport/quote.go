@@ -0,0 +1,115 @@
+package fastimport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var errBadQuote = errors.New("malformed quoted path")
+
+// unquote decodes the C-style quoted string at the beginning of s, returning
+// it and the rest of s after the closing quote.
+func unquote(s string) (string, string, error) {
+	if s == "" || s[0] != '"' {
+		return "", "", errBadQuote
+	}
+
+	var buf bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			return buf.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", errBadQuote
+			}
+
+			switch c := s[i]; c {
+			case 'a':
+				buf.WriteByte('\a')
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case 'v':
+				buf.WriteByte('\v')
+			case '\\', '"':
+				buf.WriteByte(c)
+			case '0', '1', '2', '3':
+				if i+2 >= len(s) || !isOctal(s[i+1]) || !isOctal(s[i+2]) {
+					return "", "", errBadQuote
+				}
+
+				buf.WriteByte((c-'0')<<6 | (s[i+1]-'0')<<3 | (s[i+2] - '0'))
+				i += 2
+			default:
+				return "", "", errBadQuote
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return "", "", errBadQuote
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// quote returns the path quoted as a C-style string if it is needed, also if
+// it contains a space and spaceQuote is true, as the source paths of the
+// copies and renames.
+func quote(path string, spaceQuote bool) string {
+	if !needsQuote(path, spaceQuote) {
+		return path
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&buf, "\\%03o", c)
+				continue
+			}
+
+			buf.WriteByte(c)
+		}
+	}
+
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func needsQuote(path string, spaceQuote bool) bool {
+	if path == "" {
+		return true
+	}
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c < 0x20 || c == 0x7f || c == '"' || c == '\\' || (c == ' ' && spaceQuote) {
+			return true
+		}
+	}
+
+	return false
+}
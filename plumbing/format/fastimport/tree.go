@@ -0,0 +1,237 @@
+package fastimport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// treeBuilder applies the file changes of a commit to the tree of its
+// parent. The trees are loaded from the storer only when a change touches
+// them, and only the modified trees are written.
+type treeBuilder struct {
+	s    storer.EncodedObjectStorer
+	root *treeEntry
+}
+
+type treeEntry struct {
+	mode filemode.FileMode
+	hash plumbing.Hash
+	// children are the entries of a directory, nil until loaded.
+	children map[string]*treeEntry
+	dirty    bool
+}
+
+// newTreeBuilder returns a treeBuilder starting with the tree with the given
+// hash, or an empty tree if zero.
+func newTreeBuilder(s storer.EncodedObjectStorer, tree plumbing.Hash) *treeBuilder {
+	b := &treeBuilder{s: s}
+	b.reset(tree)
+	return b
+}
+
+func (b *treeBuilder) reset(tree plumbing.Hash) {
+	b.root = &treeEntry{mode: filemode.Dir, hash: tree}
+	if tree.IsZero() {
+		b.root.children = make(map[string]*treeEntry)
+		b.root.dirty = true
+	}
+}
+
+// load loads the children of a directory entry.
+func (b *treeBuilder) load(e *treeEntry) error {
+	if e.children != nil {
+		return nil
+	}
+
+	t, err := object.GetTree(b.s, e.hash)
+	if err != nil {
+		return err
+	}
+
+	e.children = make(map[string]*treeEntry, len(t.Entries))
+	for _, te := range t.Entries {
+		e.children[te.Name] = &treeEntry{mode: te.Mode, hash: te.Hash}
+	}
+
+	return nil
+}
+
+// dir returns the directory entry of the parent of path, creating the
+// missing directories if create is true, and marking it and its parents
+// dirty if so is true. A nil entry is returned if the directory does not
+// exist.
+func (b *treeBuilder) dir(parts []string, create, dirty bool) (*treeEntry, error) {
+	e := b.root
+	for _, name := range parts {
+		if err := b.load(e); err != nil {
+			return nil, err
+		}
+
+		if dirty {
+			e.dirty = true
+		}
+
+		child, ok := e.children[name]
+		if !ok || child.mode != filemode.Dir {
+			if !create {
+				return nil, nil
+			}
+
+			child = &treeEntry{
+				mode:     filemode.Dir,
+				children: make(map[string]*treeEntry),
+			}
+
+			e.children[name] = child
+		}
+
+		e = child
+	}
+
+	if err := b.load(e); err != nil {
+		return nil, err
+	}
+
+	if dirty {
+		e.dirty = true
+	}
+
+	return e, nil
+}
+
+// Get returns the entry at path, nil if it does not exist.
+func (b *treeBuilder) Get(path string) (*treeEntry, error) {
+	parts, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := b.dir(parts[:len(parts)-1], false, false)
+	if err != nil || dir == nil {
+		return nil, err
+	}
+
+	return dir.children[parts[len(parts)-1]], nil
+}
+
+// Set sets the entry at path, replacing any existing file or directory.
+func (b *treeBuilder) Set(path string, e *treeEntry) error {
+	parts, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+
+	dir, err := b.dir(parts[:len(parts)-1], true, true)
+	if err != nil {
+		return err
+	}
+
+	dir.children[parts[len(parts)-1]] = e
+	return nil
+}
+
+// Remove removes the entry at path and the directories left empty, returning
+// the removed entry or nil if it does not exist.
+func (b *treeBuilder) Remove(path string) (*treeEntry, error) {
+	e, err := b.Get(path)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	parts, _ := splitPath(path)
+	for i := len(parts) - 1; i >= 0; i-- {
+		dir, err := b.dir(parts[:i], false, true)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(dir.children, parts[i])
+		if len(dir.children) != 0 {
+			break
+		}
+	}
+
+	return e, nil
+}
+
+// Copy returns a copy of the entry, its directories are written to get
+// their hash.
+func (b *treeBuilder) Copy(e *treeEntry) (*treeEntry, error) {
+	h, err := b.write(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &treeEntry{mode: e.mode, hash: h}, nil
+}
+
+// Write writes the modified trees, returning the hash of the root tree.
+func (b *treeBuilder) Write() (plumbing.Hash, error) {
+	return b.write(b.root)
+}
+
+func (b *treeBuilder) write(e *treeEntry) (plumbing.Hash, error) {
+	if e.mode != filemode.Dir || !e.dirty {
+		return e.hash, nil
+	}
+
+	t := &object.Tree{}
+	for name, child := range e.children {
+		h, err := b.write(child)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		// the empty directories are not written
+		if child.mode == filemode.Dir && child.children != nil && len(child.children) == 0 {
+			continue
+		}
+
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: child.mode, Hash: h})
+	}
+
+	sort.Sort(sortableEntries(t.Entries))
+
+	o := b.s.NewEncodedObject()
+	if err := t.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := b.s.SetEncodedObject(o)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	e.hash = h
+	e.dirty = false
+	return h, nil
+}
+
+func splitPath(path string) ([]string, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, fmt.Errorf("invalid empty path")
+	}
+
+	return strings.Split(path, "/"), nil
+}
+
+// sortableEntries sorts the tree entries as git, the directories as if
+// their name ends with a slash.
+type sortableEntries []object.TreeEntry
+
+func (sortableEntries) sortName(te object.TreeEntry) string {
+	if te.Mode == filemode.Dir {
+		return te.Name + "/"
+	}
+	return te.Name
+}
+func (se sortableEntries) Len() int               { return len(se) }
+func (se sortableEntries) Less(i int, j int) bool { return se.sortName(se[i]) < se.sortName(se[j]) }
+func (se sortableEntries) Swap(i int, j int)      { se[i], se[j] = se[j], se[i] }
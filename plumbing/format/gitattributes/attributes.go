@@ -0,0 +1,236 @@
+package gitattributes
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	commentPrefix = "#"
+	eol           = "\n"
+	macroPrefix   = "[attr]"
+	unsetPrefix   = "-"
+	unspecPrefix  = "!"
+	valueSep      = "="
+)
+
+var (
+	// ErrMacroNotAllowed is returned by ParseAttributesLine when a macro is
+	// defined in a file where they are not allowed.
+	ErrMacroNotAllowed = errors.New("macro not allowed")
+	// ErrInvalidAttributeName is returned by ParseAttributesLine when an
+	// attribute name is not valid.
+	ErrInvalidAttributeName = errors.New("invalid attribute name")
+	// ErrNegativePattern is returned by ParseAttributesLine when the pattern
+	// is negated, which is not allowed in gitattributes files.
+	ErrNegativePattern = errors.New("negative patterns are forbidden")
+)
+
+type attributeState byte
+
+const (
+	attributeUnspecified attributeState = iota
+	attributeSet
+	attributeUnset
+	attributeValue
+)
+
+// Attribute is the state of an attribute of a path: set, unset, set to a
+// value or explicitly unspecified.
+type Attribute interface {
+	// Name returns the name of the attribute.
+	Name() string
+	// IsSet returns true if the attribute is set, like "text".
+	IsSet() bool
+	// IsUnset returns true if the attribute is unset, like "-text".
+	IsUnset() bool
+	// IsUnspecified returns true if the attribute is unspecified, like
+	// "!text".
+	IsUnspecified() bool
+	// IsValueSet returns true if the attribute is set to a value, like
+	// "text=auto".
+	IsValueSet() bool
+	// Value returns the value of the attribute, if it is set to a value.
+	Value() string
+	// String returns the attribute as written in a gitattributes file.
+	String() string
+}
+
+type attribute struct {
+	name  string
+	state attributeState
+	value string
+}
+
+func (a *attribute) Name() string        { return a.name }
+func (a *attribute) IsSet() bool         { return a.state == attributeSet }
+func (a *attribute) IsUnset() bool       { return a.state == attributeUnset }
+func (a *attribute) IsUnspecified() bool { return a.state == attributeUnspecified }
+func (a *attribute) IsValueSet() bool    { return a.state == attributeValue }
+func (a *attribute) Value() string       { return a.value }
+
+func (a *attribute) String() string {
+	switch a.state {
+	case attributeSet:
+		return a.name
+	case attributeUnset:
+		return unsetPrefix + a.name
+	case attributeValue:
+		return a.name + valueSep + a.value
+	default:
+		return unspecPrefix + a.name
+	}
+}
+
+// MatchAttribute is a line of a gitattributes file: the attributes given to
+// the paths matching a pattern, or the definition of a macro attribute.
+type MatchAttribute struct {
+	// Name is the name of the macro defined by the line, empty if the line
+	// is not a macro definition.
+	Name string
+	// Pattern the paths are matched against, nil for macro definitions.
+	Pattern Pattern
+	// Attributes given to the paths, or the ones set by the macro.
+	Attributes []Attribute
+}
+
+// ParseAttributesLine parses a line of a gitattributes file in the given
+// domain, the path of the directory containing the file. Macro definitions
+// are only accepted if allowMacro is true, otherwise ErrMacroNotAllowed is
+// returned. Empty lines and comments return a MatchAttribute without pattern
+// or name.
+func ParseAttributesLine(line string, domain []string, allowMacro bool) (m MatchAttribute, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, commentPrefix) {
+		return
+	}
+
+	name, rest, err := splitPattern(line)
+	if err != nil {
+		return
+	}
+
+	if strings.HasPrefix(name, macroPrefix) {
+		if !allowMacro {
+			return m, ErrMacroNotAllowed
+		}
+
+		m.Name = name[len(macroPrefix):]
+		if !validAttributeName(m.Name) {
+			return m, ErrInvalidAttributeName
+		}
+	} else {
+		if strings.HasPrefix(name, unspecPrefix) {
+			return m, ErrNegativePattern
+		}
+
+		m.Pattern = ParsePattern(name, domain)
+	}
+
+	for _, field := range strings.Fields(rest) {
+		a, err := parseAttribute(field)
+		if err != nil {
+			return MatchAttribute{}, err
+		}
+
+		m.Attributes = append(m.Attributes, a)
+	}
+
+	return m, nil
+}
+
+// splitPattern returns the pattern of a line, unquoting it if needed, and
+// the rest of the line.
+func splitPattern(line string) (pattern, rest string, err error) {
+	if !strings.HasPrefix(line, `"`) {
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			return line[:i], line[i+1:], nil
+		}
+
+		return line, "", nil
+	}
+
+	for i := 1; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+
+		if line[i] == '"' {
+			pattern, err = strconv.Unquote(line[:i+1])
+			return pattern, line[i+1:], err
+		}
+	}
+
+	return "", "", strconv.ErrSyntax
+}
+
+func parseAttribute(s string) (Attribute, error) {
+	a := &attribute{state: attributeSet}
+	switch {
+	case strings.HasPrefix(s, unsetPrefix):
+		a.state = attributeUnset
+		s = s[len(unsetPrefix):]
+	case strings.HasPrefix(s, unspecPrefix):
+		a.state = attributeUnspecified
+		s = s[len(unspecPrefix):]
+	default:
+		if i := strings.Index(s, valueSep); i >= 0 {
+			a.state = attributeValue
+			a.value = s[i+1:]
+			s = s[:i]
+		}
+	}
+
+	if !validAttributeName(s) {
+		return nil, ErrInvalidAttributeName
+	}
+
+	a.name = s
+	return a, nil
+}
+
+// validAttributeName reports if the name is made of letters, digits,
+// dashes, dots and underscores, and does not start with a dash.
+func validAttributeName(name string) bool {
+	if name == "" || strings.HasPrefix(name, unsetPrefix) {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReadAttributes reads the lines of a gitattributes file in the given domain.
+// Invalid lines, such as negative patterns or macros where they are not
+// allowed, are ignored as git does.
+func ReadAttributes(r io.Reader, domain []string, allowMacro bool) ([]MatchAttribute, error) {
+	var attributes []MatchAttribute
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		m, err := ParseAttributesLine(s.Text(), domain, allowMacro)
+		if err != nil {
+			continue
+		}
+
+		if m.Name == "" && m.Pattern == nil {
+			continue
+		}
+
+		attributes = append(attributes, m)
+	}
+
+	return attributes, s.Err()
+}
@@ -0,0 +1,98 @@
+package gitattributes
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type AttributesSuite struct{}
+
+var _ = Suite(&AttributesSuite{})
+
+func (s *AttributesSuite) TestParseAttributesLine(c *C) {
+	m, err := ParseAttributesLine("*.txt text -diff !merge eol=crlf", nil, false)
+	c.Assert(err, IsNil)
+	c.Assert(m.Name, Equals, "")
+	c.Assert(m.Pattern.Match([]string{"foo.txt"}), Equals, true)
+	c.Assert(m.Attributes, HasLen, 4)
+
+	text := m.Attributes[0]
+	c.Assert(text.Name(), Equals, "text")
+	c.Assert(text.IsSet(), Equals, true)
+	c.Assert(text.String(), Equals, "text")
+
+	diff := m.Attributes[1]
+	c.Assert(diff.Name(), Equals, "diff")
+	c.Assert(diff.IsUnset(), Equals, true)
+	c.Assert(diff.String(), Equals, "-diff")
+
+	merge := m.Attributes[2]
+	c.Assert(merge.Name(), Equals, "merge")
+	c.Assert(merge.IsUnspecified(), Equals, true)
+	c.Assert(merge.String(), Equals, "!merge")
+
+	eol := m.Attributes[3]
+	c.Assert(eol.Name(), Equals, "eol")
+	c.Assert(eol.IsValueSet(), Equals, true)
+	c.Assert(eol.Value(), Equals, "crlf")
+	c.Assert(eol.String(), Equals, "eol=crlf")
+}
+
+func (s *AttributesSuite) TestParseAttributesLine_quoted(c *C) {
+	m, err := ParseAttributesLine(`"with space.txt" text`, nil, false)
+	c.Assert(err, IsNil)
+	c.Assert(m.Pattern.Match([]string{"with space.txt"}), Equals, true)
+	c.Assert(m.Attributes, HasLen, 1)
+
+	_, err = ParseAttributesLine(`"unterminated text`, nil, false)
+	c.Assert(err, NotNil)
+}
+
+func (s *AttributesSuite) TestParseAttributesLine_macro(c *C) {
+	m, err := ParseAttributesLine("[attr]generated -diff linguist-generated", nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(m.Name, Equals, "generated")
+	c.Assert(m.Pattern, IsNil)
+	c.Assert(m.Attributes, HasLen, 2)
+
+	_, err = ParseAttributesLine("[attr]generated -diff", nil, false)
+	c.Assert(err, Equals, ErrMacroNotAllowed)
+}
+
+func (s *AttributesSuite) TestParseAttributesLine_errors(c *C) {
+	_, err := ParseAttributesLine("!*.txt text", nil, false)
+	c.Assert(err, Equals, ErrNegativePattern)
+
+	_, err = ParseAttributesLine("*.txt te$t", nil, false)
+	c.Assert(err, Equals, ErrInvalidAttributeName)
+
+	_, err = ParseAttributesLine("*.txt --text", nil, false)
+	c.Assert(err, Equals, ErrInvalidAttributeName)
+}
+
+func (s *AttributesSuite) TestParseAttributesLine_empty(c *C) {
+	for _, line := range []string{"", "   ", "# comment"} {
+		m, err := ParseAttributesLine(line, nil, false)
+		c.Assert(err, IsNil)
+		c.Assert(m.Pattern, IsNil)
+		c.Assert(m.Name, Equals, "")
+	}
+}
+
+func (s *AttributesSuite) TestReadAttributes(c *C) {
+	input := strings.NewReader(`# comment
+*.png binary
+[attr]generated -diff
+
+!*.txt text
+docs/** export-ignore
+`)
+
+	attributes, err := ReadAttributes(input, []string{"sub"}, false)
+	c.Assert(err, IsNil)
+	c.Assert(attributes, HasLen, 2)
+	c.Assert(attributes[0].Pattern.Match([]string{"sub", "a.png"}), Equals, true)
+	c.Assert(attributes[0].Pattern.Match([]string{"a.png"}), Equals, false)
+	c.Assert(attributes[1].Pattern.Match([]string{"sub", "docs", "a.md"}), Equals, true)
+}
@@ -0,0 +1,170 @@
+package gitattributes
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/config"
+	gioutil "gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	coreSection       = "core"
+	attributesFileKey = "attributesfile"
+	gitDir            = ".git"
+	gitattributesFile = ".gitattributes"
+	gitconfigFile     = ".gitconfig"
+	globalFile        = ".config/git/attributes"
+	systemFile        = "/etc/gitattributes"
+)
+
+// ReadAttributesFile reads a specific gitattributes file, in the directory
+// given by path. If the file does not exist no error is returned.
+func ReadAttributesFile(fs billy.Filesystem, path []string, attributesFile string, allowMacro bool) (attributes []MatchAttribute, err error) {
+	f, err := fs.Open(fs.Join(fs.Join(path...), attributesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer gioutil.CheckClose(f, &err)
+
+	return ReadAttributes(f, path, allowMacro)
+}
+
+// ReadPatterns reads the .gitattributes files recursively traversing through
+// the directory structure. The result is in the ascending order of priority
+// (last higher). Macros are only allowed in the top level file.
+func ReadPatterns(fs billy.Filesystem, path []string) (attributes []MatchAttribute, err error) {
+	attributes, err = ReadAttributesFile(fs, path, gitattributesFile, len(path) == 0)
+	if err != nil {
+		return
+	}
+
+	fis, err := fs.ReadDir(fs.Join(path...))
+	if os.IsNotExist(err) {
+		return attributes, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() || fi.Name() == gitDir {
+			continue
+		}
+
+		// the path is copied, since it is the domain of the read patterns
+		subpath := make([]string, len(path), len(path)+1)
+		copy(subpath, path)
+
+		var subattributes []MatchAttribute
+		subattributes, err = ReadPatterns(fs, append(subpath, fi.Name()))
+		if err != nil {
+			return
+		}
+
+		attributes = append(attributes, subattributes...)
+	}
+
+	return
+}
+
+func loadPatterns(fs billy.Filesystem, path string) ([]MatchAttribute, error) {
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadAttributes(f, nil, true)
+}
+
+// attributesFile returns the value of core.attributesFile in the given git
+// config file, or an empty string if it is not set.
+func attributesFile(fs billy.Filesystem, path string) (name string, err error) {
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	defer gioutil.CheckClose(f, &err)
+
+	raw := config.New()
+	if err = config.NewDecoder(f).Decode(raw); err != nil {
+		return "", err
+	}
+
+	return raw.Section(coreSection).Options.Get(attributesFileKey), nil
+}
+
+// LoadGlobalPatterns loads gitattributes patterns from the file declared in
+// the core.attributesFile property of the user's ~/.gitconfig file, relative
+// to the home directory, or from ~/.config/git/attributes if it is not
+// declared. If the file does not exist the function will return nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadGlobalPatterns(fs billy.Filesystem) (attributes []MatchAttribute, err error) {
+	usr, err := user.Current()
+	if err != nil {
+		return
+	}
+
+	name, err := attributesFile(fs, fs.Join(usr.HomeDir, gitconfigFile))
+	if err != nil {
+		return
+	}
+
+	if name == "" {
+		return loadPatterns(fs, fs.Join(usr.HomeDir, globalFile))
+	}
+
+	return LoadPatterns(fs, name, usr.HomeDir)
+}
+
+// LoadPatterns loads gitattributes patterns from the file at the given path,
+// declared like the core.attributesFile property: a leading "~/" is expanded
+// to the user's home directory, and relative paths are relative to the base
+// directory. If the file does not exist the function will return nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadPatterns(fs billy.Filesystem, name, base string) (attributes []MatchAttribute, err error) {
+	switch {
+	case strings.HasPrefix(name, "~/"):
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		name = fs.Join(usr.HomeDir, name[2:])
+	case !filepath.IsAbs(name):
+		name = fs.Join(base, name)
+	}
+
+	return loadPatterns(fs, name)
+}
+
+// LoadSystemPatterns loads gitattributes patterns from the system's
+// /etc/gitattributes file. If the file does not exist the function will
+// return nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadSystemPatterns(fs billy.Filesystem) (attributes []MatchAttribute, err error) {
+	return loadPatterns(fs, systemFile)
+}
@@ -0,0 +1,142 @@
+package gitattributes
+
+import (
+	"os/user"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type MatcherSuite struct {
+	GFS  billy.Filesystem // git repository root
+	RFS  billy.Filesystem // root that contains user home
+	MCFS billy.Filesystem // root that contains user home, but missing ~/.gitconfig
+	SFS  billy.Filesystem // root that contains /etc/gitattributes
+}
+
+var _ = Suite(&MatcherSuite{})
+
+func (s *MatcherSuite) SetUpTest(c *C) {
+	// setup generic git repository root
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, ".gitattributes",
+		[]byte("[attr]vendored -diff linguist-vendored\n*.go text\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "vendor/.gitattributes",
+		[]byte("[attr]ignored -text\n* vendored\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "vendor/a/.gitattributes",
+		[]byte("*.go -text\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, ".git/.gitattributes",
+		[]byte("* foo\n"), 0644), IsNil)
+	c.Assert(fs.MkdirAll("another", 0755), IsNil)
+
+	s.GFS = fs
+
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	// setup root that contains user home with a core.attributesfile
+	fs = memfs.New()
+	c.Assert(util.WriteFile(fs, fs.Join(usr.HomeDir, gitconfigFile),
+		[]byte("[core]\n\tattributesfile = "+fs.Join(usr.HomeDir, ".gitattributes_global")+"\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, fs.Join(usr.HomeDir, ".gitattributes_global"),
+		[]byte("*.png binary\n"), 0644), IsNil)
+
+	s.RFS = fs
+
+	// setup root that contains user home with the default global file
+	fs = memfs.New()
+	c.Assert(util.WriteFile(fs, fs.Join(usr.HomeDir, globalFile),
+		[]byte("*.jpg binary\n"), 0644), IsNil)
+
+	s.MCFS = fs
+
+	// setup root that contains /etc/gitattributes
+	fs = memfs.New()
+	c.Assert(util.WriteFile(fs, systemFile, []byte("*.gif binary\n"), 0644), IsNil)
+
+	s.SFS = fs
+}
+
+func (s *MatcherSuite) TestDir_ReadPatterns(c *C) {
+	ps, err := ReadPatterns(s.GFS, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 4)
+	c.Assert(ps[0].Name, Equals, "vendored")
+
+	m := NewMatcher(ps)
+
+	attrs, _ := m.Match([]string{"main.go"}, nil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs["text"].IsSet(), Equals, true)
+
+	attrs, _ = m.Match([]string{"vendor", "foo.go"}, nil)
+	c.Assert(attrs["text"].IsSet(), Equals, true)
+	c.Assert(attrs["linguist-vendored"].IsSet(), Equals, true)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+
+	attrs, _ = m.Match([]string{"vendor", "a", "foo.go"}, nil)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+	c.Assert(attrs["linguist-vendored"].IsSet(), Equals, true)
+
+	// macros are not allowed out of the top level file
+	_, ok := m.Match([]string{"vendor", "foo.txt"}, []string{"ignored"})
+	c.Assert(ok, Equals, false)
+
+	ps, err = ReadPatterns(s.GFS, []string{"missing"})
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 0)
+}
+
+func (s *MatcherSuite) TestDir_LoadGlobalPatterns(c *C) {
+	ps, err := LoadGlobalPatterns(s.RFS)
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 1)
+
+	attrs, ok := NewMatcher(ps).Match([]string{"foo.png"}, []string{"diff"})
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+}
+
+func (s *MatcherSuite) TestDir_LoadGlobalPatternsDefault(c *C) {
+	ps, err := LoadGlobalPatterns(s.MCFS)
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 1)
+	c.Assert(ps[0].Pattern.Match([]string{"foo.jpg"}), Equals, true)
+
+	ps, err = LoadGlobalPatterns(memfs.New())
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 0)
+}
+
+func (s *MatcherSuite) TestDir_LoadSystemPatterns(c *C) {
+	ps, err := LoadSystemPatterns(s.SFS)
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 1)
+	c.Assert(ps[0].Pattern.Match([]string{"foo.gif"}), Equals, true)
+
+	ps, err = LoadSystemPatterns(memfs.New())
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 0)
+}
+
+func (s *MatcherSuite) TestDir_LoadPatterns(c *C) {
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{
+		"~/.gitattributes_global",
+		".gitattributes_global",
+		s.RFS.Join(usr.HomeDir, ".gitattributes_global"),
+	} {
+		ps, err := LoadPatterns(s.RFS, name, usr.HomeDir)
+		c.Assert(err, IsNil)
+		c.Assert(ps, HasLen, 1)
+		c.Assert(ps[0].Pattern.Match([]string{"foo.png"}), Equals, true)
+	}
+
+	ps, err := LoadPatterns(s.RFS, ".gitattributes_global", "/")
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 0)
+}
@@ -0,0 +1,92 @@
+// Package gitattributes implements parsing of gitattributes files and the
+// matching of paths to the attributes defined in them, in the order of
+// definition priorities, as described in the original gitattributes
+// documentation:
+//
+//	A gitattributes file is a simple text file that gives attributes to
+//	pathnames.
+//
+//	Each line in gitattributes file is of form:
+//
+//	    pattern attr1 attr2 ...
+//
+//	That is, a pattern followed by an attributes list, separated by
+//	whitespaces. Leading and trailing whitespaces are ignored. Lines that
+//	begin with # are ignored. Patterns that begin with a double quote are
+//	quoted in C style. When the pattern matches the path in question, the
+//	attributes listed on the line are given to the path.
+//
+//	Each attribute can be in one of these states for a given path:
+//
+//	Set
+//	    The path has the attribute with special value "true"; this is
+//	    specified by listing only the name of the attribute in the attribute
+//	    list.
+//
+//	Unset
+//	    The path has the attribute with special value "false"; this is
+//	    specified by listing the name of the attribute prefixed with a dash -
+//	    in the attribute list.
+//
+//	Set to a value
+//	    The path has the attribute with specified string value; this is
+//	    specified by listing the name of the attribute followed by an equal
+//	    sign = and its value in the attribute list.
+//
+//	Unspecified
+//	    No pattern matches the path, and nothing says if the path has or does
+//	    not have the attribute, the attribute for the path is said to be
+//	    Unspecified.
+//
+//	When more than one pattern matches the path, a later line overrides an
+//	earlier line. This overriding is done per attribute.
+//
+//	The rules by which the pattern matches paths are the same as in
+//	.gitignore files, with a few exceptions:
+//
+//	  - negative patterns are forbidden
+//
+//	  - patterns that match a directory do not recursively match paths inside
+//	    that directory (so using the trailing-slash path/ syntax is pointless
+//	    in an attributes file; use path/** instead)
+//
+//	When deciding what attributes are assigned to a path, Git consults
+//	$GIT_DIR/info/attributes file (which has the highest precedence),
+//	.gitattributes file in the same directory as the path in question, and
+//	its parent directories up to the toplevel of the work tree (the further
+//	the directory that contains .gitattributes is from the path in question,
+//	the lower its precedence). Finally global and system-wide files are
+//	considered (they have the lowest precedence).
+//
+//	[...]
+//
+//	USING MACRO ATTRIBUTES
+//
+//	You do not want any end-of-line conversions applied to, nor textual diffs
+//	produced for, any binary file you track. You would need to specify e.g.
+//
+//	    *.jpg -text -diff
+//
+//	but that may become cumbersome, when you have many attributes. Using
+//	macro attributes, you can define an attribute that, when set, also sets
+//	or unsets a number of other attributes at the same time. The system
+//	knows a built-in macro attribute, binary:
+//
+//	    *.jpg binary
+//
+//	Setting the "binary" attribute also unsets the "text" and "diff"
+//	attributes as above. Note that macro attributes can only be "Set",
+//	though setting one might have the effect of setting or unsetting other
+//	attributes or even returning other attributes to the "Unspecified"
+//	state.
+//
+//	DEFINING MACRO ATTRIBUTES
+//
+//	Custom macro attributes can be defined only in top-level gitattributes
+//	files ($GIT_DIR/info/attributes, the .gitattributes file at the top level
+//	of the working tree, or the global or system-wide gitattributes files),
+//	not in .gitattributes files in working tree subdirectories. The built-in
+//	macro attribute "binary" is equivalent to:
+//
+//	    [attr]binary -diff -merge -text
+package gitattributes
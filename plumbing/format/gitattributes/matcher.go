@@ -0,0 +1,90 @@
+package gitattributes
+
+// maxMacroDepth is the maximum depth of nested macros expanded, to protect
+// against recursive definitions.
+const maxMacroDepth = 16
+
+// binaryMacro is the built-in "binary" macro.
+var binaryMacro = MatchAttribute{
+	Name: "binary",
+	Attributes: []Attribute{
+		&attribute{name: "diff", state: attributeUnset},
+		&attribute{name: "merge", state: attributeUnset},
+		&attribute{name: "text", state: attributeUnset},
+	},
+}
+
+// Matcher defines a matcher of paths to the attributes of a stack of
+// gitattributes lines.
+type Matcher interface {
+	// Match returns the attributes given to the path, only the ones in names
+	// if any given. The returned bool is false if no attribute is found.
+	Match(path []string, names []string) (map[string]Attribute, bool)
+}
+
+type matcher struct {
+	stack  []MatchAttribute
+	macros map[string]MatchAttribute
+}
+
+// NewMatcher constructs a new matcher. The lines must be given in the order
+// of increasing priority, that is the system and global files first, then
+// the .gitattributes files of the repository from the root down to the
+// deepest directories, and finally the info/attributes file. Macros defined
+// in the stack are expanded, besides the built-in "binary" macro.
+func NewMatcher(stack []MatchAttribute) Matcher {
+	m := &matcher{
+		stack:  stack,
+		macros: map[string]MatchAttribute{binaryMacro.Name: binaryMacro},
+	}
+
+	for _, ma := range stack {
+		if ma.Name != "" {
+			m.macros[ma.Name] = ma
+		}
+	}
+
+	return m
+}
+
+func (m *matcher) Match(path []string, names []string) (map[string]Attribute, bool) {
+	var wanted map[string]bool
+	if len(names) > 0 {
+		wanted = make(map[string]bool, len(names))
+		for _, n := range names {
+			wanted[n] = true
+		}
+	}
+
+	found := make(map[string]Attribute)
+	for i := len(m.stack) - 1; i >= 0; i-- {
+		ma := m.stack[i]
+		if ma.Pattern == nil || !ma.Pattern.Match(path) {
+			continue
+		}
+
+		m.expand(ma.Attributes, found, wanted, 0)
+	}
+
+	return found, len(found) > 0
+}
+
+// expand adds to found the given attributes not found yet, with the later
+// ones taking priority, and the attributes of the macros being set.
+func (m *matcher) expand(attrs []Attribute, found map[string]Attribute, wanted map[string]bool, depth int) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		a := attrs[i]
+		if _, ok := found[a.Name()]; ok {
+			continue
+		}
+
+		if wanted == nil || wanted[a.Name()] {
+			found[a.Name()] = a
+		}
+
+		macro, ok := m.macros[a.Name()]
+		if ok && a.IsSet() && depth < maxMacroDepth {
+			m.expand(macro.Attributes, found, wanted, depth+1)
+		}
+	}
+}
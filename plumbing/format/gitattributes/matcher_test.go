@@ -0,0 +1,97 @@
+package gitattributes
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *MatcherSuite) read(c *C, content string, domain []string, allowMacro bool) []MatchAttribute {
+	attributes, err := ReadAttributes(strings.NewReader(content), domain, allowMacro)
+	c.Assert(err, IsNil)
+	return attributes
+}
+
+func (s *MatcherSuite) TestMatcher_Match(c *C) {
+	stack := s.read(c, "*.txt text\n*.txt -text eol=lf\n", nil, true)
+	stack = append(stack, s.read(c, "*.txt eol=crlf\n", []string{"sub"}, false)...)
+
+	m := NewMatcher(stack)
+
+	attrs, ok := m.Match([]string{"foo.txt"}, nil)
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs, HasLen, 2)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+	c.Assert(attrs["eol"].Value(), Equals, "lf")
+
+	attrs, ok = m.Match([]string{"sub", "foo.txt"}, nil)
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+	c.Assert(attrs["eol"].Value(), Equals, "crlf")
+
+	_, ok = m.Match([]string{"foo.go"}, nil)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MatcherSuite) TestMatcher_MatchNames(c *C) {
+	m := NewMatcher(s.read(c, "*.txt text eol=lf diff\n", nil, true))
+
+	attrs, ok := m.Match([]string{"foo.txt"}, []string{"eol", "merge"})
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs["eol"].Value(), Equals, "lf")
+
+	_, ok = m.Match([]string{"foo.txt"}, []string{"merge"})
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MatcherSuite) TestMatcher_MatchUnspecified(c *C) {
+	m := NewMatcher(s.read(c, "* text\n*.bin !text\n", nil, true))
+
+	attrs, ok := m.Match([]string{"foo.bin"}, nil)
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs["text"].IsUnspecified(), Equals, true)
+}
+
+func (s *MatcherSuite) TestMatcher_MatchBinaryMacro(c *C) {
+	m := NewMatcher(s.read(c, "*.png binary\n*.svg binary diff\n*.ico diff binary\n", nil, true))
+
+	attrs, ok := m.Match([]string{"foo.png"}, nil)
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs, HasLen, 4)
+	c.Assert(attrs["binary"].IsSet(), Equals, true)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+	c.Assert(attrs["merge"].IsUnset(), Equals, true)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	attrs, _ = m.Match([]string{"foo.svg"}, nil)
+	c.Assert(attrs["diff"].IsSet(), Equals, true)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	attrs, _ = m.Match([]string{"foo.ico"}, nil)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+
+	attrs, ok = m.Match([]string{"foo.png"}, []string{"text"})
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+}
+
+func (s *MatcherSuite) TestMatcher_MatchCustomMacro(c *C) {
+	stack := s.read(c, "[attr]generated linguist-generated -diff binary\n", nil, true)
+	stack = append(stack, s.read(c, "*.pb.go generated\n", []string{"api"}, false)...)
+	stack = append(stack, s.read(c, "[attr]recursive recursive\n*.r recursive\n", nil, true)...)
+
+	m := NewMatcher(stack)
+
+	attrs, ok := m.Match([]string{"api", "foo.pb.go"}, nil)
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs["generated"].IsSet(), Equals, true)
+	c.Assert(attrs["linguist-generated"].IsSet(), Equals, true)
+	c.Assert(attrs["diff"].IsUnset(), Equals, true)
+	c.Assert(attrs["text"].IsUnset(), Equals, true)
+
+	attrs, ok = m.Match([]string{"foo.r"}, nil)
+	c.Assert(ok, Equals, true)
+	c.Assert(attrs, HasLen, 1)
+}
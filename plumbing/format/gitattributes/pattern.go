@@ -0,0 +1,98 @@
+package gitattributes
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	patternDirSep  = "/"
+	zeroToManyDirs = "**"
+)
+
+// Pattern defines a gitattributes pattern.
+type Pattern interface {
+	// Match matches the given path to the pattern.
+	Match(path []string) bool
+}
+
+type pattern struct {
+	domain  []string
+	pattern []string
+	dirOnly bool
+	isGlob  bool
+}
+
+// ParsePattern parses a gitattributes pattern string into the Pattern
+// structure. Patterns ending with a slash only match directories, so they
+// never match the files given to Match.
+func ParsePattern(p string, domain []string) Pattern {
+	res := pattern{domain: domain}
+
+	if strings.HasSuffix(p, patternDirSep) {
+		res.dirOnly = true
+		p = p[:len(p)-1]
+	}
+
+	if strings.Contains(p, patternDirSep) {
+		res.isGlob = true
+		p = strings.TrimPrefix(p, patternDirSep)
+	}
+
+	res.pattern = strings.Split(p, patternDirSep)
+	return &res
+}
+
+func (p *pattern) Match(path []string) bool {
+	if p.dirOnly || len(path) <= len(p.domain) {
+		return false
+	}
+
+	for i, e := range p.domain {
+		if path[i] != e {
+			return false
+		}
+	}
+
+	path = path[len(p.domain):]
+	if !p.isGlob {
+		match, err := filepath.Match(p.pattern[0], path[len(path)-1])
+		return err == nil && match
+	}
+
+	return globMatch(p.pattern, path)
+}
+
+// globMatch reports if the whole path matches the pattern, where "**"
+// matches zero or more directories, and a trailing "**" everything inside a
+// directory.
+func globMatch(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == zeroToManyDirs {
+		if len(pattern) == 1 {
+			return len(path) > 0
+		}
+
+		for i := 0; i < len(path); i++ {
+			if globMatch(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	match, err := filepath.Match(pattern[0], path[0])
+	if err != nil || !match {
+		return false
+	}
+
+	return globMatch(pattern[1:], path[1:])
+}
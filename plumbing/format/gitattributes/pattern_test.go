@@ -0,0 +1,68 @@
+package gitattributes
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PatternSuite struct{}
+
+var _ = Suite(&PatternSuite{})
+
+func (s *PatternSuite) TestSimpleMatch(c *C) {
+	p := ParsePattern("*.go", nil)
+	c.Assert(p.Match([]string{"main.go"}), Equals, true)
+	c.Assert(p.Match([]string{"cmd", "main.go"}), Equals, true)
+	c.Assert(p.Match([]string{"main.go", "foo"}), Equals, false)
+	c.Assert(p.Match([]string{"main.c"}), Equals, false)
+}
+
+func (s *PatternSuite) TestSimpleMatch_domain(c *C) {
+	p := ParsePattern("*.go", []string{"vendor"})
+	c.Assert(p.Match([]string{"vendor", "foo", "main.go"}), Equals, true)
+	c.Assert(p.Match([]string{"main.go"}), Equals, false)
+	c.Assert(p.Match([]string{"vendor"}), Equals, false)
+}
+
+func (s *PatternSuite) TestSimpleMatch_dirNotRecursive(c *C) {
+	p := ParsePattern("vendor", nil)
+	c.Assert(p.Match([]string{"vendor"}), Equals, true)
+	c.Assert(p.Match([]string{"vendor", "foo.go"}), Equals, false)
+
+	p = ParsePattern("vendor/", nil)
+	c.Assert(p.Match([]string{"vendor"}), Equals, false)
+	c.Assert(p.Match([]string{"vendor", "foo.go"}), Equals, false)
+}
+
+func (s *PatternSuite) TestGlobMatch_anchored(c *C) {
+	p := ParsePattern("/docs/*.md", nil)
+	c.Assert(p.Match([]string{"docs", "README.md"}), Equals, true)
+	c.Assert(p.Match([]string{"src", "docs", "README.md"}), Equals, false)
+	c.Assert(p.Match([]string{"docs", "api", "README.md"}), Equals, false)
+
+	p = ParsePattern("docs/*.md", []string{"src"})
+	c.Assert(p.Match([]string{"src", "docs", "README.md"}), Equals, true)
+	c.Assert(p.Match([]string{"docs", "README.md"}), Equals, false)
+}
+
+func (s *PatternSuite) TestGlobMatch_zeroToManyDirs(c *C) {
+	p := ParsePattern("**/generated/*.go", nil)
+	c.Assert(p.Match([]string{"generated", "foo.go"}), Equals, true)
+	c.Assert(p.Match([]string{"a", "b", "generated", "foo.go"}), Equals, true)
+	c.Assert(p.Match([]string{"a", "generated", "b", "foo.go"}), Equals, false)
+
+	p = ParsePattern("a/**/b", nil)
+	c.Assert(p.Match([]string{"a", "b"}), Equals, true)
+	c.Assert(p.Match([]string{"a", "x", "y", "b"}), Equals, true)
+	c.Assert(p.Match([]string{"a", "x", "y", "c"}), Equals, false)
+}
+
+func (s *PatternSuite) TestGlobMatch_trailingZeroToManyDirs(c *C) {
+	p := ParsePattern("vendor/**", nil)
+	c.Assert(p.Match([]string{"vendor", "foo.go"}), Equals, true)
+	c.Assert(p.Match([]string{"vendor", "a", "b", "foo.go"}), Equals, true)
+	c.Assert(p.Match([]string{"vendor"}), Equals, false)
+}
@@ -40,6 +40,15 @@ type Index interface {
 	EntriesByOffset() (EntryIter, error)
 }
 
+// PrefixIndex is an Index able to look up its objects by a prefix of their
+// hashes.
+type PrefixIndex interface {
+	Index
+	// HashesWithPrefix returns the hashes of the objects starting with the
+	// given prefix, sorted.
+	HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
+}
+
 // MemoryIndex is the in memory representation of an idx file.
 type MemoryIndex struct {
 	Version uint32
@@ -58,7 +67,7 @@ type MemoryIndex struct {
 	offsetHash map[int64]plumbing.Hash
 }
 
-var _ Index = (*MemoryIndex)(nil)
+var _ PrefixIndex = (*MemoryIndex)(nil)
 
 // NewMemoryIndex returns an instance of a new MemoryIndex.
 func NewMemoryIndex() *MemoryIndex {
@@ -209,6 +218,41 @@ func (idx *MemoryIndex) genOffsetHash() error {
 	}
 }
 
+// HashesWithPrefix implements the PrefixIndex interface. Only the fanout bucket of
+// the first byte of the prefix is searched, if any.
+func (idx *MemoryIndex) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	first, last := 0, fanout-1
+	if len(prefix) > 0 {
+		first, last = int(prefix[0]), int(prefix[0])
+	}
+
+	var hashes []plumbing.Hash
+	for k := first; k <= last; k++ {
+		pos := idx.FanoutMapping[k]
+		if pos == noMapping || len(idx.Names) <= pos {
+			continue
+		}
+
+		data := idx.Names[pos]
+		count := len(idx.Offset32[pos]) >> 2
+		name := func(i int) []byte {
+			return data[i*objectIDLength : (i+1)*objectIDLength]
+		}
+
+		i := sort.Search(count, func(i int) bool {
+			return bytes.Compare(name(i), prefix) >= 0
+		})
+
+		for ; i < count && bytes.HasPrefix(name(i), prefix); i++ {
+			var h plumbing.Hash
+			copy(h[:], name(i))
+			hashes = append(hashes, h)
+		}
+	}
+
+	return hashes, nil
+}
+
 // Count implements the Index interface.
 func (idx *MemoryIndex) Count() (int64, error) {
 	return int64(idx.Fanout[fanout-1]), nil
@@ -130,6 +130,28 @@ func (s *IndexSuite) TestEntriesByOffset(c *C) {
 	}
 }
 
+func (s *IndexSuite) TestHashesWithPrefix(c *C) {
+	idx, err := fixtureIndex()
+	c.Assert(err, IsNil)
+
+	hashes, err := idx.HashesWithPrefix([]byte{0x35, 0x85})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{fixtureHashes[8]})
+
+	hashes, err = idx.HashesWithPrefix(fixtureHashes[3][:])
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{fixtureHashes[3]})
+
+	hashes, err = idx.HashesWithPrefix([]byte{0x35, 0x86})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 0)
+
+	hashes, err = idx.HashesWithPrefix(nil)
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, len(fixtureHashes))
+	c.Assert(hashes[0], Equals, fixtureHashes[2])
+}
+
 var fixtureHashes = []plumbing.Hash{
 	plumbing.NewHash("303953e5aa461c203a324821bc1717f9b4fff895"),
 	plumbing.NewHash("5296768e3d9f661387ccbff18c4dea6c997fd78c"),
@@ -1,6 +1,7 @@
 package index
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha1"
 	"errors"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/utils/binary"
 )
 
@@ -24,8 +26,9 @@ var (
 	// ErrInvalidChecksum is returned by Decode if the SHA1 hash mismatch with
 	// the read content
 	ErrInvalidChecksum = errors.New("invalid checksum")
-
-	errUnknownExtension = errors.New("unknown extension")
+	// ErrUnsupportedExtension is returned by Decode if the index contains a
+	// required extension not supported, like 'Split index'
+	ErrUnsupportedExtension = errors.New("unsupported required extension")
 )
 
 const (
@@ -35,10 +38,13 @@ const (
 	nameMask          = 0xfff
 	intentToAddMask   = 1 << 13
 	skipWorkTreeMask  = 1 << 14
+
+	extensionHeaderLength = 8
 )
 
 // A Decoder reads and decodes index files from an input stream.
 type Decoder struct {
+	buf       *bufio.Reader
 	r         io.Reader
 	hash      hash.Hash
 	lastEntry *Entry
@@ -47,8 +53,10 @@ type Decoder struct {
 // NewDecoder returns a new decoder that reads from r.
 func NewDecoder(r io.Reader) *Decoder {
 	h := sha1.New()
+	buf := bufio.NewReader(r)
 	return &Decoder{
-		r:    io.TeeReader(r, h),
+		buf:  buf,
+		r:    io.TeeReader(buf, h),
 		hash: h,
 	}
 }
@@ -205,75 +213,75 @@ func (d *Decoder) padEntry(idx *Index, e *Entry, read int) error {
 }
 
 func (d *Decoder) readExtensions(idx *Index) error {
-	// TODO: support 'Split index' and 'Untracked cache' extensions, take in
-	// count that they are not supported by jgit or libgit
-
-	var expected []byte
-	var err error
-
-	var header [4]byte
 	for {
-		expected = d.hash.Sum(nil)
-
-		var n int
-		if n, err = io.ReadFull(d.r, header[:]); err != nil {
-			if n == 0 {
-				err = io.EOF
-			}
-
+		// the index ends with the checksum, so any data longer than it and an
+		// extension header belongs to an extension
+		_, err := d.buf.Peek(extensionHeaderLength + len(plumbing.ZeroHash))
+		if err == io.EOF {
 			break
 		}
 
-		err = d.readExtension(idx, header[:])
 		if err != nil {
-			break
+			return err
 		}
-	}
-
-	if err != errUnknownExtension {
-		return err
-	}
 
-	return d.readChecksum(expected, header)
-}
+		var header [4]byte
+		if _, err := io.ReadFull(d.r, header[:]); err != nil {
+			return err
+		}
 
-func (d *Decoder) readExtension(idx *Index, header []byte) error {
-	switch {
-	case bytes.Equal(header, treeExtSignature):
 		r, err := d.getExtensionReader()
 		if err != nil {
 			return err
 		}
 
-		idx.Cache = &Tree{}
-		d := &treeExtensionDecoder{r}
-		if err := d.Decode(idx.Cache); err != nil {
+		if err := d.readExtension(idx, header, r); err != nil {
 			return err
 		}
-	case bytes.Equal(header, resolveUndoExtSignature):
-		r, err := d.getExtensionReader()
-		if err != nil {
+
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
 			return err
 		}
+	}
 
+	return d.readChecksum(d.hash.Sum(nil))
+}
+
+func (d *Decoder) readExtension(idx *Index, header [4]byte, r io.Reader) error {
+	switch {
+	case bytes.Equal(header[:], treeExtSignature):
+		idx.Cache = &Tree{}
+		d := &treeExtensionDecoder{r}
+		if err := d.Decode(idx.Cache); err != nil {
+			return err
+		}
+	case bytes.Equal(header[:], resolveUndoExtSignature):
 		idx.ResolveUndo = &ResolveUndo{}
 		d := &resolveUndoDecoder{r}
 		if err := d.Decode(idx.ResolveUndo); err != nil {
 			return err
 		}
-	case bytes.Equal(header, endOfIndexEntryExtSignature):
-		r, err := d.getExtensionReader()
-		if err != nil {
-			return err
-		}
-
+	case bytes.Equal(header[:], endOfIndexEntryExtSignature):
 		idx.EndOfIndexEntry = &EndOfIndexEntry{}
 		d := &endOfIndexEntryDecoder{r}
 		if err := d.Decode(idx.EndOfIndexEntry); err != nil {
 			return err
 		}
+	case bytes.Equal(header[:], indexEntryOffsetTableExtSignature):
+		// the offsets are only valid for the entries as they were read, so
+		// the extension is discarded
+	case header[0] >= 'A' && header[0] <= 'Z':
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		idx.Extensions = append(idx.Extensions, &Extension{
+			Signature: header,
+			Data:      data,
+		})
 	default:
-		return errUnknownExtension
+		return ErrUnsupportedExtension
 	}
 
 	return nil
@@ -288,11 +296,9 @@ func (d *Decoder) getExtensionReader() (io.Reader, error) {
 	return &io.LimitedReader{R: d.r, N: int64(len)}, nil
 }
 
-func (d *Decoder) readChecksum(expected []byte, alreadyRead [4]byte) error {
+func (d *Decoder) readChecksum(expected []byte) error {
 	var h plumbing.Hash
-	copy(h[:4], alreadyRead[:])
-
-	if err := binary.Read(d.r, h[4:]); err != nil {
+	if _, err := io.ReadFull(d.buf, h[:]); err != nil {
 		return err
 	}
 
@@ -340,10 +346,6 @@ func (d *treeExtensionDecoder) Decode(t *Tree) error {
 			return err
 		}
 
-		if e == nil {
-			continue
-		}
-
 		t.Entries = append(t.Entries, *e)
 	}
 }
@@ -368,12 +370,6 @@ func (d *treeExtensionDecoder) readEntry() (*TreeEntry, error) {
 		return nil, err
 	}
 
-	// An entry can be in an invalidated state and is represented by having a
-	// negative number in the entry_count field.
-	if i == -1 {
-		return nil, nil
-	}
-
 	e.Entries = i
 	trees, err := binary.ReadUntil(d.r, '\n')
 	if err != nil {
@@ -387,6 +383,12 @@ func (d *treeExtensionDecoder) readEntry() (*TreeEntry, error) {
 
 	e.Trees = i
 
+	// An entry can be in an invalidated state and is represented by having a
+	// negative number in the entry_count field, without hash.
+	if e.Entries < 0 {
+		return e, nil
+	}
+
 	if err := binary.Read(d.r, &e.Hash); err != nil {
 		return nil, err
 	}
@@ -416,6 +418,7 @@ func (d *resolveUndoDecoder) Decode(ru *ResolveUndo) error {
 func (d *resolveUndoDecoder) readEntry() (*ResolveUndoEntry, error) {
 	e := &ResolveUndoEntry{
 		Stages: make(map[Stage]plumbing.Hash),
+		Modes:  make(map[Stage]filemode.FileMode),
 	}
 
 	path, err := binary.ReadUntil(d.r, '\x00')
@@ -431,7 +434,11 @@ func (d *resolveUndoDecoder) readEntry() (*ResolveUndoEntry, error) {
 		}
 	}
 
-	for s := range e.Stages {
+	for s := AncestorMode; s <= TheirMode; s++ {
+		if _, ok := e.Stages[s]; !ok {
+			continue
+		}
+
 		var hash plumbing.Hash
 		if err := binary.Read(d.r, hash[:]); err != nil {
 			return nil, err
@@ -456,6 +463,7 @@ func (d *resolveUndoDecoder) readStage(e *ResolveUndoEntry, s Stage) error {
 
 	if stage != 0 {
 		e.Stages[s] = plumbing.ZeroHash
+		e.Modes[s] = filemode.FileMode(stage)
 	}
 
 	return nil
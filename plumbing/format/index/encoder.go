@@ -4,41 +4,53 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"sort"
+	"strconv"
 	"time"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/utils/binary"
 )
 
 var (
-	// EncodeVersionSupported is the range of supported index versions
+	// EncodeVersionSupported is the index version written by default, it can
+	// be read by any git version
 	EncodeVersionSupported uint32 = 2
+	// EncodeMaxVersionSupported is the maximum supported index version, the
+	// versions 3 and 4 are only written if set in the Index
+	EncodeMaxVersionSupported uint32 = 4
 
 	// ErrInvalidTimestamp is returned by Encode if a Index with a Entry with
 	// negative timestamp values
 	ErrInvalidTimestamp = errors.New("negative timestamps are not allowed")
 )
 
+// encodeMinVersionSupported is the minimum supported index version.
+const encodeMinVersionSupported uint32 = 2
+
 // An Encoder writes an Index to an output stream.
 type Encoder struct {
-	w    io.Writer
-	hash hash.Hash
+	w         *offsetWriter
+	hash      hash.Hash
+	lastEntry *Entry
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
 	h := sha1.New()
 	mw := io.MultiWriter(w, h)
-	return &Encoder{mw, h}
+	return &Encoder{w: &offsetWriter{w: mw}, hash: h}
 }
 
-// Encode writes the Index to the stream of the encoder.
+// Encode writes the Index to the stream of the encoder. If the index has an
+// EndOfIndexEntry its values are updated with the ones of the written index.
 func (e *Encoder) Encode(idx *Index) error {
-	// TODO: support versions v3 and v4
-	// TODO: support extensions
-	if idx.Version != EncodeVersionSupported {
+	if idx.Version < encodeMinVersionSupported ||
+		idx.Version > EncodeMaxVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
@@ -50,6 +62,10 @@ func (e *Encoder) Encode(idx *Index) error {
 		return err
 	}
 
+	if err := e.encodeExtensions(idx); err != nil {
+		return err
+	}
+
 	return e.encodeFooter()
 }
 
@@ -65,21 +81,19 @@ func (e *Encoder) encodeEntries(idx *Index) error {
 	sort.Sort(byName(idx.Entries))
 
 	for _, entry := range idx.Entries {
-		if err := e.encodeEntry(entry); err != nil {
+		if err := e.encodeEntry(idx, entry); err != nil {
 			return err
 		}
 
-		wrote := entryHeaderLength + len(entry.Name)
-		if err := e.padEntry(wrote); err != nil {
-			return err
-		}
+		e.lastEntry = entry
 	}
 
 	return nil
 }
 
-func (e *Encoder) encodeEntry(entry *Entry) error {
-	if entry.IntentToAdd || entry.SkipWorktree {
+func (e *Encoder) encodeEntry(idx *Index, entry *Entry) error {
+	extended := entry.IntentToAdd || entry.SkipWorktree
+	if extended && idx.Version == 2 {
 		return ErrUnsupportedVersion
 	}
 
@@ -100,6 +114,10 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 		flags |= nameMask
 	}
 
+	if extended {
+		flags |= entryExtended
+	}
+
 	flow := []interface{}{
 		sec, nsec,
 		msec, mnsec,
@@ -117,7 +135,55 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 		return err
 	}
 
-	return binary.Write(e.w, []byte(entry.Name))
+	wrote := entryHeaderLength
+	if extended {
+		var extendedFlags uint16
+		if entry.IntentToAdd {
+			extendedFlags |= intentToAddMask
+		}
+
+		if entry.SkipWorktree {
+			extendedFlags |= skipWorkTreeMask
+		}
+
+		if err := binary.WriteUint16(e.w, extendedFlags); err != nil {
+			return err
+		}
+
+		wrote += 2
+	}
+
+	if idx.Version == 4 {
+		return e.encodeEntryNameV4(entry)
+	}
+
+	if err := binary.Write(e.w, []byte(entry.Name)); err != nil {
+		return err
+	}
+
+	return e.padEntry(wrote + len(entry.Name))
+}
+
+// encodeEntryNameV4 writes the name of the entry compressed against the name
+// of the previous one, as the number of bytes to remove from the end of the
+// previous name followed by the remaining suffix.
+func (e *Encoder) encodeEntryNameV4(entry *Entry) error {
+	var last string
+	if e.lastEntry != nil {
+		last = e.lastEntry.Name
+	}
+
+	common := 0
+	for common < len(last) && common < len(entry.Name) &&
+		last[common] == entry.Name[common] {
+		common++
+	}
+
+	if err := binary.WriteVariableWidthInt(e.w, int64(len(last)-common)); err != nil {
+		return err
+	}
+
+	return binary.Write(e.w, []byte(entry.Name[common:]), byte(0))
 }
 
 func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
@@ -139,12 +205,168 @@ func (e *Encoder) padEntry(wrote int) error {
 	return err
 }
 
+func (e *Encoder) encodeExtensions(idx *Index) error {
+	// the end of index entry extension contains the offset of the first
+	// extension and a hash over the headers of the extensions before it
+	offset := e.w.offset
+	eoie := sha1.New()
+
+	var extensions []*Extension
+	if idx.Cache != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := (&treeExtensionEncoder{buf}).Encode(idx.Cache); err != nil {
+			return err
+		}
+
+		extensions = append(extensions, newExtension(treeExtSignature, buf.Bytes()))
+	}
+
+	if idx.ResolveUndo != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := (&resolveUndoEncoder{buf}).Encode(idx.ResolveUndo); err != nil {
+			return err
+		}
+
+		extensions = append(extensions, newExtension(resolveUndoExtSignature, buf.Bytes()))
+	}
+
+	extensions = append(extensions, idx.Extensions...)
+	for _, ext := range extensions {
+		if err := e.encodeExtension(io.MultiWriter(e.w, eoie), ext); err != nil {
+			return err
+		}
+	}
+
+	if idx.EndOfIndexEntry == nil {
+		return nil
+	}
+
+	idx.EndOfIndexEntry.Offset = uint32(offset)
+	copy(idx.EndOfIndexEntry.Hash[:], eoie.Sum(nil))
+
+	buf := bytes.NewBuffer(nil)
+	if err := binary.Write(buf,
+		idx.EndOfIndexEntry.Offset,
+		idx.EndOfIndexEntry.Hash[:],
+	); err != nil {
+		return err
+	}
+
+	return e.encodeExtension(e.w, newExtension(endOfIndexEntryExtSignature, buf.Bytes()))
+}
+
+// encodeExtension writes the header of the extension to w, and its content to
+// the output of the encoder.
+func (e *Encoder) encodeExtension(w io.Writer, ext *Extension) error {
+	if err := binary.Write(w, ext.Signature[:], uint32(len(ext.Data))); err != nil {
+		return err
+	}
+
+	return binary.Write(e.w, ext.Data)
+}
+
+func newExtension(signature, data []byte) *Extension {
+	ext := &Extension{Data: data}
+	copy(ext.Signature[:], signature)
+	return ext
+}
+
 func (e *Encoder) encodeFooter() error {
 	return binary.Write(e.w, e.hash.Sum(nil))
 }
 
+type treeExtensionEncoder struct {
+	w io.Writer
+}
+
+func (e *treeExtensionEncoder) Encode(t *Tree) error {
+	for _, entry := range t.Entries {
+		if err := e.encodeEntry(&entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *treeExtensionEncoder) encodeEntry(entry *TreeEntry) error {
+	_, err := fmt.Fprintf(e.w, "%s\x00%d %d\n", entry.Path, entry.Entries, entry.Trees)
+	if err != nil {
+		return err
+	}
+
+	if entry.Entries < 0 {
+		return nil
+	}
+
+	return binary.Write(e.w, entry.Hash[:])
+}
+
+type resolveUndoEncoder struct {
+	w io.Writer
+}
+
+func (e *resolveUndoEncoder) Encode(ru *ResolveUndo) error {
+	for _, entry := range ru.Entries {
+		if err := e.encodeEntry(&entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *resolveUndoEncoder) encodeEntry(entry *ResolveUndoEntry) error {
+	if err := binary.Write(e.w, []byte(entry.Path), byte(0)); err != nil {
+		return err
+	}
+
+	var hashes []plumbing.Hash
+	for s := AncestorMode; s <= TheirMode; s++ {
+		var mode filemode.FileMode
+		if h, ok := entry.Stages[s]; ok {
+			mode = entry.Modes[s]
+			if mode == filemode.Empty {
+				mode = filemode.Regular
+			}
+
+			hashes = append(hashes, h)
+		}
+
+		ascii := strconv.FormatUint(uint64(mode), 8)
+		if err := binary.Write(e.w, []byte(ascii), byte(0)); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range hashes {
+		if err := binary.Write(e.w, h[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type offsetWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
 type byName []*Entry
 
-func (l byName) Len() int           { return len(l) }
-func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l byName) Len() int      { return len(l) }
+func (l byName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byName) Less(i, j int) bool {
+	if l[i].Name == l[j].Name {
+		return l[i].Stage < l[j].Stage
+	}
+
+	return l[i].Name < l[j].Name
+}
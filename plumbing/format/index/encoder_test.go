@@ -2,12 +2,15 @@ package index
 
 import (
 	"bytes"
+	"io/ioutil"
 	"strings"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 )
 
 func (s *IndexSuite) TestEncode(c *C) {
@@ -55,8 +58,103 @@ func (s *IndexSuite) TestEncode(c *C) {
 
 }
 
+func (s *IndexSuite) TestEncodeFixtures(c *C) {
+	for _, tag := range []string{"", "merge-conflict", "intent-to-add", "resolve-undo", "index-v4"} {
+		fixs := fixtures.Basic()
+		if tag != "" {
+			fixs = fixs.ByTag(tag)
+		}
+
+		f, err := fixs.One().DotGit().Open("index")
+		c.Assert(err, IsNil)
+
+		expected, err := ioutil.ReadAll(f)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+
+		idx := &Index{}
+		c.Assert(NewDecoder(bytes.NewReader(expected)).Decode(idx), IsNil)
+
+		buf := bytes.NewBuffer(nil)
+		c.Assert(NewEncoder(buf).Encode(idx), IsNil)
+		c.Assert(buf.Bytes(), DeepEquals, expected, Commentf("fixture %q", tag))
+	}
+}
+
+func (s *IndexSuite) TestEncodeV4(c *C) {
+	idx := &Index{
+		Version: 4,
+		Entries: []*Entry{
+			{Name: "foo/bar/qux", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+			{Name: "foo/bar/baz", IntentToAdd: true},
+			{Name: "foo", SkipWorktree: true},
+			{Name: "abc"},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(idx), IsNil)
+
+	output := &Index{}
+	c.Assert(NewDecoder(buf).Decode(output), IsNil)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+
+	var names []string
+	for _, e := range output.Entries {
+		names = append(names, e.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{"abc", "foo", "foo/bar/baz", "foo/bar/qux"})
+}
+
+func (s *IndexSuite) TestEncodeExtensions(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{Name: "foo"}},
+		Cache: &Tree{Entries: []TreeEntry{
+			{Path: "", Entries: 1, Trees: 1, Hash: plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")},
+			{Path: "bar", Entries: -1, Trees: 0},
+		}},
+		ResolveUndo: &ResolveUndo{Entries: []ResolveUndoEntry{{
+			Path: "foo",
+			Stages: map[Stage]plumbing.Hash{
+				OurMode:   plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+				TheirMode: plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c"),
+			},
+			Modes: map[Stage]filemode.FileMode{
+				OurMode:   filemode.Regular,
+				TheirMode: filemode.Executable,
+			},
+		}}},
+		Extensions: []*Extension{
+			{Signature: [4]byte{'U', 'N', 'T', 'R'}, Data: []byte("foo")},
+			{Signature: [4]byte{'F', 'S', 'M', 'N'}, Data: []byte{}},
+		},
+		EndOfIndexEntry: &EndOfIndexEntry{},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(idx), IsNil)
+	c.Assert(idx.EndOfIndexEntry.Offset, Equals, uint32(12+72))
+
+	output := &Index{}
+	c.Assert(NewDecoder(buf).Decode(output), IsNil)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestDecodeUnsupportedExtension(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(&Index{
+		Version:    2,
+		Extensions: []*Extension{{Signature: [4]byte{'l', 'i', 'n', 'k'}}},
+	}), IsNil)
+
+	err := NewDecoder(buf).Decode(&Index{})
+	c.Assert(err, Equals, ErrUnsupportedExtension)
+}
+
 func (s *IndexSuite) TestEncodeUnsuportedVersion(c *C) {
-	idx := &Index{Version: 3}
+	idx := &Index{Version: EncodeMaxVersionSupported + 1}
 
 	buf := bytes.NewBuffer(nil)
 	e := NewEncoder(buf)
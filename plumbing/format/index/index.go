@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -18,10 +20,11 @@ var (
 	// ErrEntryNotFound is returned by Index.Entry, if an entry is not found.
 	ErrEntryNotFound = errors.New("entry not found")
 
-	indexSignature              = []byte{'D', 'I', 'R', 'C'}
-	treeExtSignature            = []byte{'T', 'R', 'E', 'E'}
-	resolveUndoExtSignature     = []byte{'R', 'E', 'U', 'C'}
-	endOfIndexEntryExtSignature = []byte{'E', 'O', 'I', 'E'}
+	indexSignature                    = []byte{'D', 'I', 'R', 'C'}
+	treeExtSignature                  = []byte{'T', 'R', 'E', 'E'}
+	resolveUndoExtSignature           = []byte{'R', 'E', 'U', 'C'}
+	endOfIndexEntryExtSignature       = []byte{'E', 'O', 'I', 'E'}
+	indexEntryOffsetTableExtSignature = []byte{'I', 'E', 'O', 'T'}
 )
 
 // Stage during merge
@@ -47,12 +50,18 @@ type Index struct {
 	// Entries collection of entries represented by this Index. The order of
 	// this collection is not guaranteed
 	Entries []*Entry
-	// Cache represents the 'Cached tree' extension
+	// Cache represents the 'Cached tree' extension, it is invalidated by Add
+	// and Remove, entries modified directly must be invalidated with
+	// Tree.Invalidate
 	Cache *Tree
 	// ResolveUndo represents the 'Resolve undo' extension
 	ResolveUndo *ResolveUndo
 	// EndOfIndexEntry represents the 'End of Index Entry' extension
 	EndOfIndexEntry *EndOfIndexEntry
+	// Extensions are the optional extensions not interpreted by this package,
+	// like the 'Untracked cache' or the 'File System Monitor' ones. They are
+	// written back as they were read, in the same order.
+	Extensions []*Extension
 }
 
 // Add creates a new Entry and returns it. The caller should first check that
@@ -62,6 +71,7 @@ func (i *Index) Add(path string) *Entry {
 		Name: filepath.ToSlash(path),
 	}
 
+	i.invalidateCache(e.Name)
 	i.Entries = append(i.Entries, e)
 	return e
 }
@@ -79,8 +89,11 @@ func (i *Index) Entry(path string) (*Entry, error) {
 }
 
 // Remove remove the entry that match the give path and returns deleted entry.
+// The cached trees containing the path are invalidated even if the entry is
+// not found, since it is usually followed by adding the entry again.
 func (i *Index) Remove(path string) (*Entry, error) {
 	path = filepath.ToSlash(path)
+	i.invalidateCache(path)
 	for index, e := range i.Entries {
 		if e.Name == path {
 			i.Entries = append(i.Entries[:index], i.Entries[index+1:]...)
@@ -91,6 +104,12 @@ func (i *Index) Remove(path string) (*Entry, error) {
 	return nil, ErrEntryNotFound
 }
 
+func (i *Index) invalidateCache(path string) {
+	if i.Cache != nil {
+		i.Cache.Invalidate(path)
+	}
+}
+
 // Glob returns the all entries matching pattern or nil if there is no matching
 // entry. The syntax of patterns is the same as in filepath.Glob.
 func (i *Index) Glob(pattern string) (matches []*Entry, err error) {
@@ -169,12 +188,96 @@ type Tree struct {
 	Entries []TreeEntry
 }
 
+// Invalidate marks as invalid the entries of the trees containing the given
+// path, they should not be used until they are computed again.
+func (t *Tree) Invalidate(path string) {
+	parts := strings.Split(path, "/")
+	t.visit(parts[:len(parts)-1], func(e *TreeEntry) {
+		e.Entries = -1
+		e.Hash = plumbing.ZeroHash
+	})
+}
+
+// Hashes returns the hashes of the valid cached trees by the path of their
+// directory, the path of the root tree is "".
+func (t *Tree) Hashes() map[string]plumbing.Hash {
+	hashes := make(map[string]plumbing.Hash)
+	if len(t.Entries) != 0 {
+		t.hashes(hashes, 0, "")
+	}
+
+	return hashes
+}
+
+func (t *Tree) hashes(m map[string]plumbing.Hash, pos int, dir string) int {
+	e := t.Entries[pos]
+	if e.Entries >= 0 {
+		m[dir] = e.Hash
+	}
+
+	pos++
+	for n := 0; n < e.Trees && pos < len(t.Entries); n++ {
+		pos = t.hashes(m, pos, path.Join(dir, t.Entries[pos].Path))
+	}
+
+	return pos
+}
+
+// visit calls fn with the root entry and the entries of every directory of
+// the given path, while they are found.
+func (t *Tree) visit(dirs []string, fn func(*TreeEntry)) {
+	if len(t.Entries) == 0 {
+		return
+	}
+
+	pos := 0
+	for {
+		e := &t.Entries[pos]
+		fn(e)
+
+		if len(dirs) == 0 {
+			return
+		}
+
+		// the subtrees of an entry are stored right after it, each one
+		// followed by its own subtrees
+		child, found := pos+1, false
+		for n := 0; n < e.Trees && child < len(t.Entries); n++ {
+			if t.Entries[child].Path == dirs[0] {
+				found = true
+				break
+			}
+
+			child = t.skip(child)
+		}
+
+		if !found {
+			return
+		}
+
+		pos, dirs = child, dirs[1:]
+	}
+}
+
+// skip returns the position of the entry following the given one and all of
+// its subtrees.
+func (t *Tree) skip(pos int) int {
+	trees := t.Entries[pos].Trees
+	pos++
+	for n := 0; n < trees && pos < len(t.Entries); n++ {
+		pos = t.skip(pos)
+	}
+
+	return pos
+}
+
 // TreeEntry entry of a cached Tree
 type TreeEntry struct {
 	// Path component (relative to its parent directory)
 	Path string
 	// Entries is the number of entries in the index that is covered by the tree
-	// this entry represents.
+	// this entry represents. It is -1 if the entry has been invalidated, in
+	// that case Hash is not set.
 	Entries int
 	// Trees is the number that represents the number of subtrees this tree has
 	Trees int
@@ -195,6 +298,9 @@ type ResolveUndo struct {
 type ResolveUndoEntry struct {
 	Path   string
 	Stages map[Stage]plumbing.Hash
+	// Modes of the entries at each stage, if not set for a stage present in
+	// Stages it is written as a regular file.
+	Modes map[Stage]filemode.FileMode
 }
 
 // EndOfIndexEntry is the End of Index Entry (EOIE) is used to locate the end of
@@ -211,3 +317,13 @@ type EndOfIndexEntry struct {
 	//	their contents).
 	Hash plumbing.Hash
 }
+
+// Extension is an optional index extension not interpreted by this package,
+// kept so it is not lost when the index is written back.
+type Extension struct {
+	// Signature identifies the extension, the signatures of optional
+	// extensions start with an uppercase letter.
+	Signature [4]byte
+	// Data is the content of the extension.
+	Data []byte
+}
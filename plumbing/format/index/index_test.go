@@ -3,6 +3,8 @@ package index
 import (
 	"path/filepath"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -74,3 +76,51 @@ func (s *IndexSuite) TestIndexGlob(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(m, HasLen, 1)
 }
+
+func (s *IndexSuite) TestTreeInvalidate(c *C) {
+	hash := plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")
+	t := &Tree{Entries: []TreeEntry{
+		{Path: "", Entries: 4, Trees: 2, Hash: hash},
+		{Path: "a", Entries: 2, Trees: 1, Hash: hash},
+		{Path: "b", Entries: 1, Trees: 0, Hash: hash},
+		{Path: "c", Entries: 1, Trees: 0, Hash: hash},
+	}}
+
+	t.Invalidate("a/b/foo")
+	c.Assert(t.Entries[0].Entries, Equals, -1)
+	c.Assert(t.Entries[0].Hash, Equals, plumbing.ZeroHash)
+	c.Assert(t.Entries[1].Entries, Equals, -1)
+	c.Assert(t.Entries[2].Entries, Equals, -1)
+	c.Assert(t.Entries[3].Entries, Equals, 1)
+	c.Assert(t.Entries[3].Hash, Equals, hash)
+}
+
+func (s *IndexSuite) TestIndexAddInvalidatesCache(c *C) {
+	idx := &Index{Cache: &Tree{Entries: []TreeEntry{
+		{Path: "", Entries: 1, Trees: 1},
+		{Path: "c", Entries: 1, Trees: 0},
+	}}}
+
+	idx.Add("foo")
+	c.Assert(idx.Cache.Entries[0].Entries, Equals, -1)
+	c.Assert(idx.Cache.Entries[1].Entries, Equals, 1)
+
+	_, err := idx.Remove("c/bar")
+	c.Assert(err, Equals, ErrEntryNotFound)
+	c.Assert(idx.Cache.Entries[1].Entries, Equals, -1)
+}
+
+func (s *IndexSuite) TestTreeHashes(c *C) {
+	hash := plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")
+	t := &Tree{Entries: []TreeEntry{
+		{Path: "", Entries: -1, Trees: 2},
+		{Path: "a", Entries: 2, Trees: 1, Hash: hash},
+		{Path: "b", Entries: 1, Trees: 0, Hash: hash},
+		{Path: "c", Entries: -1, Trees: 0},
+	}}
+
+	c.Assert(t.Hashes(), DeepEquals, map[string]plumbing.Hash{
+		"a":   hash,
+		"a/b": hash,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/binary"
 )
@@ -57,6 +58,18 @@ func (e *Encoder) Encode(
 	return e.encode(objects)
 }
 
+// EncodeBitmap creates a packfile containing the objects set in b, a bitmap
+// over the objects of the packfile described by pb, usually computed with
+// its reachability bitmaps, so the objects to send are known without walking
+// the history again.
+func (e *Encoder) EncodeBitmap(
+	pb *bitmap.PackBitmap,
+	b *bitmap.Bitmap,
+	packWindow uint,
+) (plumbing.Hash, error) {
+	return e.Encode(pb.Hashes(b), packWindow)
+}
+
 func (e *Encoder) encode(objects []*ObjectToPack) (plumbing.Hash, error) {
 	if err := e.head(len(objects)); err != nil {
 		return plumbing.ZeroHash, err
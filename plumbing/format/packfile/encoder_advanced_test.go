@@ -9,8 +9,10 @@ import (
 	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
 	. "gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 
@@ -133,3 +135,45 @@ func (s *EncoderAdvancedSuite) testEncodeDecode(
 		}
 	}
 }
+
+func (s *EncoderAdvancedSuite) TestEncodeBitmap(c *C) {
+	f := fixtures.Basic().One()
+	storage := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+
+	idx := idxfile.NewMemoryIndex()
+	c.Assert(idxfile.NewDecoder(f.Idx()).Decode(idx), IsNil)
+
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	bi, err := revlist.BuildBitmap(storage, idx, []plumbing.Hash{head})
+	c.Assert(err, IsNil)
+
+	pb, err := bitmap.NewPackBitmap(idx, bi)
+	c.Assert(err, IsNil)
+
+	b, err := pb.Commit(head)
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	_, err = NewEncoder(buf, storage, false).EncodeBitmap(pb, b, 10)
+	c.Assert(err, IsNil)
+
+	w := new(idxfile.Writer)
+	parser, err := NewParser(NewScanner(bytes.NewReader(buf.Bytes())), w)
+	c.Assert(err, IsNil)
+
+	_, err = parser.Parse()
+	c.Assert(err, IsNil)
+
+	index, err := w.Index()
+	c.Assert(err, IsNil)
+
+	count, err := index.Count()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, int64(b.Count()))
+
+	for _, h := range pb.Hashes(b) {
+		ok, err := index.Contains(h)
+		c.Assert(err, IsNil)
+		c.Assert(ok, Equals, true)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"path"
 	"strings"
 
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
@@ -90,7 +91,13 @@ func (c *Change) Patch() (*Patch, error) {
 // If context expires, an non-nil error will be returned
 // Provided context must be non-nil
 func (c *Change) PatchContext(ctx context.Context) (*Patch, error) {
-	return getPatchContext(ctx, "", c)
+	return c.PatchWithOptions(ctx, nil)
+}
+
+// PatchWithOptions is like PatchContext, computing and showing the line
+// differences with the given options, the default ones if nil.
+func (c *Change) PatchWithOptions(ctx context.Context, o *PatchOptions) (*Patch, error) {
+	return getPatchContext(ctx, "", o, c)
 }
 
 func (c *Change) name() string {
@@ -153,5 +160,41 @@ func (c Changes) Patch() (*Patch, error) {
 // If context expires, an non-nil error will be returned
 // Provided context must be non-nil
 func (c Changes) PatchContext(ctx context.Context) (*Patch, error) {
-	return getPatchContext(ctx, "", c...)
+	return c.PatchWithOptions(ctx, nil)
+}
+
+// PatchWithOptions is like PatchContext, computing and showing the line
+// differences with the given options, the default ones if nil.
+func (c Changes) PatchWithOptions(ctx context.Context, o *PatchOptions) (*Patch, error) {
+	return getPatchContext(ctx, "", o, c...)
+}
+
+// FileChange is a change of a file whose content does not need to be in a
+// tree, such as a file of the worktree. For insertions From is nil and for
+// deletions To is nil.
+type FileChange struct {
+	From *File
+	To   *File
+}
+
+// FileChanges is a collection of changes of files.
+type FileChanges []FileChange
+
+// PatchWithOptions returns a Patch with all the changes in chunks, computing
+// and showing the line differences with the given options, the default ones
+// if nil.
+func (c FileChanges) PatchWithOptions(ctx context.Context, o *PatchOptions) (*Patch, error) {
+	return getFilesPatchContext(ctx, "", o, c...)
+}
+
+// fileChangeEntry returns the ChangeEntry of the file, without a tree.
+func fileChangeEntry(f *File) ChangeEntry {
+	if f == nil {
+		return empty
+	}
+
+	return ChangeEntry{
+		Name:      f.Name,
+		TreeEntry: TreeEntry{Name: path.Base(f.Name), Mode: f.Mode, Hash: f.Hash},
+	}
 }
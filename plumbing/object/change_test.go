@@ -3,6 +3,7 @@ package object
 import (
 	"context"
 	"sort"
+	"strings"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
@@ -10,6 +11,7 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	udiff "gopkg.in/src-d/go-git.v4/utils/diff"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 
 	. "gopkg.in/check.v1"
@@ -235,6 +237,103 @@ func (s *ChangeSuite) TestModify(c *C) {
 	c.Assert(str, Equals, "<Action: Modify, Path: utils/difftree/difftree.go>")
 }
 
+func (s *ChangeSuite) TestPatchWithOptions(c *C) {
+	// the same change of TestModify
+	change := &Change{
+		From: ChangeEntry{
+			Name: "utils/difftree/difftree.go",
+			Tree: s.tree(c, plumbing.NewHash("b1f01b730b855c82431918cb338ad47ed558999b")),
+			TreeEntry: TreeEntry{
+				Name: "difftree.go",
+				Mode: filemode.Regular,
+				Hash: plumbing.NewHash("05f583ace3a9a078d8150905a53a4d82567f125f"),
+			},
+		},
+		To: ChangeEntry{
+			Name: "utils/difftree/difftree.go",
+			Tree: s.tree(c, plumbing.NewHash("8b0af31d2544acb5c4f3816a602f11418cbd126e")),
+			TreeEntry: TreeEntry{
+				Name: "difftree.go",
+				Mode: filemode.Regular,
+				Hash: plumbing.NewHash("de927fad935d172929aacf20e71f3bf0b91dd6f9"),
+			},
+		},
+	}
+
+	p, err := change.PatchWithOptions(context.Background(), &PatchOptions{
+		Algorithm: udiff.Patience,
+		Context:   -1,
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(len(p.FilePatches()[0].Chunks()), Equals, 7)
+
+	str := p.String()
+	c.Assert(str[strings.Index(str, "@@"):], Equals, `@@ -14 +14,4 @@ func main() {
+-	r := git.NewMemoryRepository()
++	r, err := git.NewMemoryRepository()
++	if err != nil {
++		panic(err)
++	}
+@@ -16 +19 @@ func main() {
+-	if err := r.Clone(&git.CloneOptions{URL: url}); err != nil {
++	if err = r.Clone(&git.RepositoryCloneOptions{URL: url}); err != nil {
+`)
+
+	p, err = change.PatchWithOptions(context.Background(), &PatchOptions{Context: 1})
+	c.Assert(err, IsNil)
+
+	str = p.String()
+	c.Assert(str[strings.Index(str, "@@"):], Equals, `@@ -13,5 +13,8 @@ func main() {
+ 	fmt.Printf("Retrieving latest commit from: %q ...\n", url)
+-	r := git.NewMemoryRepository()
++	r, err := git.NewMemoryRepository()
++	if err != nil {
++		panic(err)
++	}
+ 
+-	if err := r.Clone(&git.CloneOptions{URL: url}); err != nil {
++	if err = r.Clone(&git.RepositoryCloneOptions{URL: url}); err != nil {
+ 		panic(err)
+`)
+}
+
+func (s *ChangeSuite) TestFileChangesPatchWithOptions(c *C) {
+	newFile := func(name, content string) *File {
+		obj := &plumbing.MemoryObject{}
+		obj.SetType(plumbing.BlobObject)
+		_, err := obj.Write([]byte(content))
+		c.Assert(err, IsNil)
+
+		blob, err := DecodeBlob(obj)
+		c.Assert(err, IsNil)
+		return NewFile(name, filemode.Regular, blob)
+	}
+
+	changes := FileChanges{
+		{From: newFile("foo", "a\nb\n"), To: newFile("foo", "a\nc\n")},
+		{To: newFile("bar/baz", "d\n")},
+	}
+
+	p, err := changes.PatchWithOptions(context.Background(), &PatchOptions{Context: -1})
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, `diff --git a/foo b/foo
+index 422c2b7ab3b3c668038da977e4e93a5fc623169c..0f7bc766052a5a0ee28a393d51d2370f96d8ceb8 100644
+--- a/foo
++++ b/foo
+@@ -2 +2 @@ a
+-b
++c
+diff --git a/bar/baz b/bar/baz
+new file mode 100644
+index 0000000000000000000000000000000000000000..4bcfe98e640c8284511312660fb8709b0afa888e
+--- /dev/null
++++ b/bar/baz
+@@ -0,0 +1 @@
++d
+`)
+}
+
 func (s *ChangeSuite) TestEmptyChangeFails(c *C) {
 	change := &Change{}
 
@@ -79,6 +79,12 @@ func (c *Commit) Tree() (*Tree, error) {
 // Patch returns the Patch between the actual commit and the provided one.
 // Error will be return if context expires. Provided context must be non-nil
 func (c *Commit) PatchContext(ctx context.Context, to *Commit) (*Patch, error) {
+	return c.PatchWithOptions(ctx, to, nil)
+}
+
+// PatchWithOptions is like PatchContext, computing and showing the line
+// differences with the given options, the default ones if nil.
+func (c *Commit) PatchWithOptions(ctx context.Context, to *Commit, o *PatchOptions) (*Patch, error) {
 	fromTree, err := c.Tree()
 	if err != nil {
 		return nil, err
@@ -89,7 +95,7 @@ func (c *Commit) PatchContext(ctx context.Context, to *Commit) (*Patch, error) {
 		return nil, err
 	}
 
-	return fromTree.PatchContext(ctx, toTree)
+	return fromTree.PatchWithOptions(ctx, toTree, o)
 }
 
 // Patch returns the Patch between the actual commit and the provided one.
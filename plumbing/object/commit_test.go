@@ -9,6 +9,7 @@ import (
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	fdiff "gopkg.in/src-d/go-git.v4/plumbing/format/diff"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-git-fixtures.v3"
@@ -134,6 +135,31 @@ Binary files /dev/null and b/binary.jpg differ
 	c.Assert(buf.String(), Equals, patch.String())
 }
 
+func (s *SuiteCommit) TestPatchWithOptionsBinary(c *C) {
+	from := s.commit(c, plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"))
+	to := s.commit(c, plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"))
+
+	patch, err := from.PatchWithOptions(context.Background(), to, &PatchOptions{Binary: true})
+	c.Assert(err, IsNil)
+
+	encoded := patch.String()
+	c.Assert(strings.Contains(encoded, "Binary files"), Equals, false)
+
+	var bp fdiff.BinaryPatch
+	d := fdiff.NewBinaryPatchDecoder(strings.NewReader(encoded))
+	c.Assert(d.Decode(&bp), IsNil)
+	c.Assert(bp.Forward.Type, Equals, fdiff.BinaryLiteral)
+
+	content, err := bp.Apply(nil)
+	c.Assert(err, IsNil)
+
+	f, err := to.File("binary.jpg")
+	c.Assert(err, IsNil)
+	expected, err := f.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, expected)
+}
+
 func (s *SuiteCommit) TestPatchContext(c *C) {
 	from := s.commit(c, plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"))
 	to := s.commit(c, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
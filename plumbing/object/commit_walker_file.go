@@ -9,7 +9,7 @@ import (
 )
 
 type commitFileIter struct {
-	fileName      string
+	pathFilter    func(string) bool
 	sourceIter    CommitIter
 	currentCommit *Commit
 	checkParent   bool
@@ -21,9 +21,17 @@ type commitFileIter struct {
 // If checkParent is true then the function double checks if potential parent (next commit in a path)
 // is one of the parents in the tree (it's used by `git log --all`).
 func NewCommitFileIterFromIter(fileName string, commitIter CommitIter, checkParent bool) CommitIter {
+	return NewCommitPathIterFromIter(func(path string) bool {
+		return path == fileName
+	}, commitIter, checkParent)
+}
+
+// NewCommitPathIterFromIter is like NewCommitFileIterFromIter, but returns the
+// commits changing any of the files whose path satisfies the pathFilter.
+func NewCommitPathIterFromIter(pathFilter func(string) bool, commitIter CommitIter, checkParent bool) CommitIter {
 	iterator := new(commitFileIter)
 	iterator.sourceIter = commitIter
-	iterator.fileName = fileName
+	iterator.pathFilter = pathFilter
 	iterator.checkParent = checkParent
 	return iterator
 }
@@ -98,7 +106,7 @@ func (c *commitFileIter) getNextFileCommit() (*Commit, error) {
 
 func (c *commitFileIter) hasFileChange(changes Changes, parent *Commit) bool {
 	for _, change := range changes {
-		if change.name() != c.fileName {
+		if !c.pathFilter(change.name()) {
 			continue
 		}
 
@@ -128,6 +136,9 @@ func isParentHash(hash plumbing.Hash, commit *Commit) bool {
 func (c *commitFileIter) ForEach(cb func(*Commit) error) error {
 	for {
 		commit, nextErr := c.Next()
+		if nextErr == io.EOF {
+			break
+		}
 		if nextErr != nil {
 			return nextErr
 		}
@@ -138,6 +149,7 @@ func (c *commitFileIter) ForEach(cb func(*Commit) error) error {
 			return err
 		}
 	}
+	return nil
 }
 
 func (c *commitFileIter) Close() {
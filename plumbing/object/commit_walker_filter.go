@@ -0,0 +1,68 @@
+package object
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// CommitFilter returns a boolean for the passed Commit.
+type CommitFilter func(*Commit) bool
+
+type commitFilterIter struct {
+	sourceIter CommitIter
+	isValid    CommitFilter
+	isLimit    CommitFilter
+}
+
+// NewCommitFilterIterFromIter returns a commit iterator which returns only the
+// commits of the commitIter for which isValid returns true. The iteration
+// stops at the first commit for which isLimit returns true, which is not
+// returned. Both filters are optional; a nil filter is ignored.
+func NewCommitFilterIterFromIter(commitIter CommitIter, isValid, isLimit CommitFilter) CommitIter {
+	return &commitFilterIter{
+		sourceIter: commitIter,
+		isValid:    isValid,
+		isLimit:    isLimit,
+	}
+}
+
+func (c *commitFilterIter) Next() (*Commit, error) {
+	for {
+		commit, err := c.sourceIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if c.isLimit != nil && c.isLimit(commit) {
+			return nil, io.EOF
+		}
+
+		if c.isValid == nil || c.isValid(commit) {
+			return commit, nil
+		}
+	}
+}
+
+func (c *commitFilterIter) ForEach(cb func(*Commit) error) error {
+	for {
+		commit, nextErr := c.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+		err := cb(commit)
+		if err == storer.ErrStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *commitFilterIter) Close() {
+	c.sourceIter.Close()
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+type commitFirstParentIterator struct {
+	seenExternal map[plumbing.Hash]bool
+	seen         map[plumbing.Hash]bool
+	next         *Commit
+}
+
+// NewCommitFirstParentIter returns a CommitIter that walks the commit history,
+// starting at the given commit and following only the first parent of each
+// commit, as `git log --first-parent` does. The walk stops at the first commit
+// already seen, either in seenExternal or in ignore.
+func NewCommitFirstParentIter(
+	c *Commit,
+	seenExternal map[plumbing.Hash]bool,
+	ignore []plumbing.Hash,
+) CommitIter {
+	seen := make(map[plumbing.Hash]bool)
+	for _, h := range ignore {
+		seen[h] = true
+	}
+
+	return &commitFirstParentIterator{
+		seenExternal: seenExternal,
+		seen:         seen,
+		next:         c,
+	}
+}
+
+func (w *commitFirstParentIterator) Next() (*Commit, error) {
+	c := w.next
+	if c == nil || w.seen[c.Hash] || w.seenExternal[c.Hash] {
+		w.next = nil
+		return nil, io.EOF
+	}
+
+	w.seen[c.Hash] = true
+	w.next = nil
+	if c.NumParents() > 0 {
+		parent, err := GetCommit(c.s, c.ParentHashes[0])
+		if err != nil {
+			return nil, err
+		}
+
+		w.next = parent
+	}
+
+	return c, nil
+}
+
+func (w *commitFirstParentIterator) ForEach(cb func(*Commit) error) error {
+	for {
+		c, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = cb(c)
+		if err == storer.ErrStop {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *commitFirstParentIterator) Close() {}
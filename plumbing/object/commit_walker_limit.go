@@ -0,0 +1,68 @@
+package object
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+type commitLimitIter struct {
+	sourceIter CommitIter
+	skip       int
+	maxCount   int
+	count      int
+}
+
+// NewCommitLimitIterFromIter returns a commit iterator which skips the first
+// skip commits of the commitIter and returns at most maxCount commits after
+// them. A maxCount lower than one returns all the commits.
+func NewCommitLimitIterFromIter(commitIter CommitIter, skip, maxCount int) CommitIter {
+	return &commitLimitIter{
+		sourceIter: commitIter,
+		skip:       skip,
+		maxCount:   maxCount,
+	}
+}
+
+func (c *commitLimitIter) Next() (*Commit, error) {
+	for ; c.skip > 0; c.skip-- {
+		if _, err := c.sourceIter.Next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.maxCount > 0 && c.count >= c.maxCount {
+		return nil, io.EOF
+	}
+
+	commit, err := c.sourceIter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	c.count++
+	return commit, nil
+}
+
+func (c *commitLimitIter) ForEach(cb func(*Commit) error) error {
+	for {
+		commit, nextErr := c.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+		err := cb(commit)
+		if err == storer.ErrStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *commitLimitIter) Close() {
+	c.sourceIter.Close()
+}
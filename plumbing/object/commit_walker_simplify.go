@@ -0,0 +1,222 @@
+package object
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// HistorySimplifier is a storer.EncodedObjectStorer simplifying the history
+// of the commits for the paths satisfying a filter, as git does by default
+// for `git log -- <path>...`. A commit is TREESAME to a parent if both have
+// the same content for the filtered paths. The parents of a commit TREESAME
+// to any of them are replaced by the first of them, so the walkers of the
+// commits decoded from the HistorySimplifier only follow that parent.
+type HistorySimplifier struct {
+	storer.EncodedObjectStorer
+	pathFilter func(string) bool
+
+	commits  map[plumbing.Hash]*Commit
+	parents  map[plumbing.Hash][]plumbing.Hash
+	treesame map[plumbing.Hash]bool
+}
+
+// NewHistorySimplifier returns a HistorySimplifier over the given storer for
+// the paths satisfying the pathFilter.
+func NewHistorySimplifier(s storer.EncodedObjectStorer, pathFilter func(string) bool) *HistorySimplifier {
+	return &HistorySimplifier{
+		EncodedObjectStorer: s,
+		pathFilter:          pathFilter,
+		commits:             make(map[plumbing.Hash]*Commit),
+		parents:             make(map[plumbing.Hash][]plumbing.Hash),
+		treesame:            make(map[plumbing.Hash]bool),
+	}
+}
+
+// EncodedObject returns the object with the given hash. The parents of the
+// commits are simplified, keeping the hash of the original commit.
+func (s *HistorySimplifier) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.EncodedObjectStorer.EncodedObject(t, h)
+	if err != nil || obj.Type() != plumbing.CommitObject {
+		return obj, err
+	}
+
+	c, err := s.commit(h)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, err := s.simplify(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parents) == len(c.ParentHashes) {
+		return obj, nil
+	}
+
+	simplified := *c
+	simplified.ParentHashes = parents
+
+	o := &plumbing.MemoryObject{}
+	if err := simplified.Encode(o); err != nil {
+		return nil, err
+	}
+
+	return &simplifiedObject{EncodedObject: o, hash: h}, nil
+}
+
+// Commits returns an iterator of the commits of the commitIter which are not
+// TREESAME, that is, the commits changing the filtered paths. The commits
+// are returned with their original parents.
+func (s *HistorySimplifier) Commits(commitIter CommitIter) CommitIter {
+	return &simplifiedCommitIter{s: s, sourceIter: commitIter}
+}
+
+// commit returns the commit with the given hash, as decoded from the
+// underlying storer.
+func (s *HistorySimplifier) commit(h plumbing.Hash) (*Commit, error) {
+	if c, ok := s.commits[h]; ok {
+		return c, nil
+	}
+
+	c, err := GetCommit(s.EncodedObjectStorer, h)
+	if err != nil {
+		return nil, err
+	}
+
+	s.commits[h] = c
+	return c, nil
+}
+
+// simplify returns the simplified parents of the commit: the first parent it
+// is TREESAME to, or all of them if none. A root commit is TREESAME if it
+// has none of the filtered paths.
+func (s *HistorySimplifier) simplify(c *Commit) ([]plumbing.Hash, error) {
+	if parents, ok := s.parents[c.Hash]; ok {
+		return parents, nil
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	parents := c.ParentHashes
+	treesame := false
+	if len(parents) == 0 {
+		treesame, err = s.sameTrees(nil, tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range c.ParentHashes {
+		p, err := s.commit(h)
+		if err != nil {
+			return nil, err
+		}
+
+		parentTree, err := p.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		treesame, err = s.sameTrees(parentTree, tree)
+		if err != nil {
+			return nil, err
+		}
+
+		if treesame {
+			parents = []plumbing.Hash{h}
+			break
+		}
+	}
+
+	s.parents[c.Hash] = parents
+	s.treesame[c.Hash] = treesame
+	return parents, nil
+}
+
+// sameTrees returns whether both trees have the same content for the
+// filtered paths. A nil tree is empty.
+func (s *HistorySimplifier) sameTrees(a, b *Tree) (bool, error) {
+	if a != nil && a.Hash == b.Hash {
+		return true, nil
+	}
+
+	changes, err := DiffTree(a, b)
+	if err != nil {
+		return false, err
+	}
+
+	for _, change := range changes {
+		if s.pathFilter(change.From.Name) || s.pathFilter(change.To.Name) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// simplifiedObject is a commit object with simplified parents, keeping the
+// hash of the original commit.
+type simplifiedObject struct {
+	plumbing.EncodedObject
+	hash plumbing.Hash
+}
+
+func (o *simplifiedObject) Hash() plumbing.Hash {
+	return o.hash
+}
+
+type simplifiedCommitIter struct {
+	s          *HistorySimplifier
+	sourceIter CommitIter
+}
+
+func (c *simplifiedCommitIter) Next() (*Commit, error) {
+	for {
+		commit, err := c.sourceIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		original, err := c.s.commit(commit.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := c.s.simplify(original); err != nil {
+			return nil, err
+		}
+
+		if !c.s.treesame[commit.Hash] {
+			return original, nil
+		}
+	}
+}
+
+func (c *simplifiedCommitIter) ForEach(cb func(*Commit) error) error {
+	for {
+		commit, nextErr := c.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+		err := cb(commit)
+		if err == storer.ErrStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *simplifiedCommitIter) Close() {
+	c.sourceIter.Close()
+}
@@ -228,3 +228,213 @@ func (s *CommitWalkerSuite) TestCommitBSFIteratorWithIgnore(c *C) {
 		c.Assert(commit.Hash.String(), Equals, expected[i])
 	}
 }
+
+func (s *CommitWalkerSuite) TestCommitFirstParentIterator(c *C) {
+	commit := s.commit(c, s.Fixture.Head)
+
+	var commits []*Commit
+	NewCommitFirstParentIter(commit, nil, nil).ForEach(func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+
+	expected := []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	}
+
+	c.Assert(commits, HasLen, len(expected))
+	for i, commit := range commits {
+		c.Assert(commit.Hash.String(), Equals, expected[i])
+	}
+}
+
+func (s *CommitWalkerSuite) TestCommitFirstParentIteratorWithSeenExternal(c *C) {
+	commit := s.commit(c, s.Fixture.Head)
+
+	var commits []*Commit
+	NewCommitFirstParentIter(commit, map[plumbing.Hash]bool{
+		plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea"): true,
+	}, nil).ForEach(func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+
+	c.Assert(commits, HasLen, 3)
+}
+
+func (s *CommitWalkerSuite) TestCommitFilterIterator(c *C) {
+	commit := s.commit(c, s.Fixture.Head)
+
+	isMerge := func(c *Commit) bool {
+		return c.NumParents() > 1
+	}
+
+	var commits []*Commit
+	NewCommitFilterIterFromIter(NewCommitPreorderIter(commit, nil, nil), isMerge, nil).
+		ForEach(func(c *Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+
+	c.Assert(commits, HasLen, 2)
+	c.Assert(commits[0].Hash.String(), Equals, "1669dce138d9b841a518c64b10914d88f5e488ea")
+	c.Assert(commits[1].Hash.String(), Equals, "a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69")
+
+	commits = nil
+	NewCommitFilterIterFromIter(NewCommitPreorderIter(commit, nil, nil), nil, isMerge).
+		ForEach(func(c *Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+
+	c.Assert(commits, HasLen, 3)
+	c.Assert(commits[2].Hash.String(), Equals, "af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+}
+
+func (s *CommitWalkerSuite) TestCommitLimitIterator(c *C) {
+	commit := s.commit(c, s.Fixture.Head)
+
+	var commits []*Commit
+	NewCommitLimitIterFromIter(NewCommitPreorderIter(commit, nil, nil), 2, 3).
+		ForEach(func(c *Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+
+	expected := []string{
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+	}
+
+	c.Assert(commits, HasLen, len(expected))
+	for i, commit := range commits {
+		c.Assert(commit.Hash.String(), Equals, expected[i])
+	}
+
+	commits = nil
+	NewCommitLimitIterFromIter(NewCommitPreorderIter(commit, nil, nil), 7, 0).
+		ForEach(func(c *Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+
+	c.Assert(commits, HasLen, 1)
+
+	commits = nil
+	NewCommitLimitIterFromIter(NewCommitPreorderIter(commit, nil, nil), 10, 0).
+		ForEach(func(c *Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+
+	c.Assert(commits, HasLen, 0)
+}
+
+func (s *CommitWalkerSuite) TestCommitTopoIterator(c *C) {
+	commit := s.commit(c, s.Fixture.Head)
+
+	var commits []*Commit
+	NewCommitTopoIter([]*Commit{commit}, nil).ForEach(func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+
+	expected := []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	}
+
+	c.Assert(commits, HasLen, len(expected))
+	for i, commit := range commits {
+		c.Assert(commit.Hash.String(), Equals, expected[i])
+	}
+}
+
+func (s *CommitWalkerSuite) TestCommitTopoIteratorWithSeenExternal(c *C) {
+	head := s.commit(c, s.Fixture.Head)
+	branch := s.commit(c, plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+
+	var commits []*Commit
+	NewCommitTopoIter([]*Commit{branch, head}, map[plumbing.Hash]bool{
+		plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"): true,
+	}).ForEach(func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+
+	expected := []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+	}
+
+	c.Assert(commits, HasLen, len(expected))
+	for i, commit := range commits {
+		c.Assert(commit.Hash.String(), Equals, expected[i])
+	}
+}
+
+func (s *CommitWalkerSuite) TestCommitAuthorTimeIterator(c *C) {
+	commit := s.commit(c, s.Fixture.Head)
+
+	var commits []*Commit
+	NewCommitAuthorTimeIter([]*Commit{commit}, nil).ForEach(func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+
+	expected := []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	}
+
+	c.Assert(commits, HasLen, len(expected))
+	for i, commit := range commits {
+		c.Assert(commit.Hash.String(), Equals, expected[i])
+	}
+}
+
+func (s *CommitWalkerSuite) TestHistorySimplifier(c *C) {
+	simplifier := NewHistorySimplifier(s.Storer, func(path string) bool {
+		return path == "CHANGELOG"
+	})
+
+	commit, err := GetCommit(simplifier, s.Fixture.Head)
+	c.Assert(err, IsNil)
+
+	var commits []*Commit
+	simplifier.Commits(NewCommitPreorderIter(commit, nil, nil)).ForEach(func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+
+	c.Assert(commits, HasLen, 1)
+	c.Assert(commits[0].Hash.String(), Equals, "b8e471f58bcbca63b07bda20e428190409c2db47")
+	c.Assert(commits[0].ParentHashes, HasLen, 1)
+
+	// the merges are simplified to the parent with the same CHANGELOG
+	merge, err := GetCommit(simplifier, plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69"))
+	c.Assert(err, IsNil)
+	c.Assert(merge.Hash.String(), Equals, "a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69")
+	c.Assert(merge.ParentHashes, DeepEquals, []plumbing.Hash{
+		plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+	})
+}
@@ -0,0 +1,192 @@
+package object
+
+import (
+	"io"
+	"sort"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+type commitTopoIterator struct {
+	start        []*Commit
+	seenExternal map[plumbing.Hash]bool
+	byAuthorTime bool
+
+	walked   bool
+	commits  map[plumbing.Hash]*Commit
+	indegree map[plumbing.Hash]int
+	stack    []*Commit
+	heap     *binaryheap.Heap
+}
+
+// NewCommitTopoIter returns a CommitIter that walks the commit history,
+// starting at the given commits, in topological order: no commit is returned
+// before all its children, and the commits of a line of history are not
+// interleaved with the commits of other lines, as `git log --topo-order`
+// does. The whole history is walked on the first call to Next. Commits in
+// seenExternal, and their ancestors, are not walked.
+func NewCommitTopoIter(c []*Commit, seenExternal map[plumbing.Hash]bool) CommitIter {
+	return &commitTopoIterator{
+		start:        c,
+		seenExternal: seenExternal,
+	}
+}
+
+// NewCommitAuthorTimeIter is like NewCommitTopoIter, but the commits ready
+// to be returned are returned in author time order, as
+// `git log --author-date-order` does.
+func NewCommitAuthorTimeIter(c []*Commit, seenExternal map[plumbing.Hash]bool) CommitIter {
+	return &commitTopoIterator{
+		start:        c,
+		seenExternal: seenExternal,
+		byAuthorTime: true,
+	}
+}
+
+func (w *commitTopoIterator) walk() error {
+	w.walked = true
+	w.commits = make(map[plumbing.Hash]*Commit)
+	w.indegree = make(map[plumbing.Hash]int)
+
+	pending := make([]*Commit, 0, len(w.start))
+	for _, c := range w.start {
+		if w.commits[c.Hash] != nil || w.seenExternal[c.Hash] {
+			continue
+		}
+
+		w.commits[c.Hash] = c
+		pending = append(pending, c)
+	}
+
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for _, h := range c.ParentHashes {
+			if w.seenExternal[h] {
+				continue
+			}
+
+			w.indegree[h]++
+			if w.commits[h] != nil {
+				continue
+			}
+
+			p, err := GetCommit(c.s, h)
+			if err != nil {
+				return err
+			}
+
+			w.commits[h] = p
+			pending = append(pending, p)
+		}
+	}
+
+	// the tips are returned newest first, as git does
+	var tips []*Commit
+	for _, c := range w.start {
+		if w.commits[c.Hash] == c && w.indegree[c.Hash] == 0 {
+			tips = append(tips, c)
+		}
+	}
+
+	sort.SliceStable(tips, func(i, j int) bool {
+		return tips[i].Committer.When.After(tips[j].Committer.When)
+	})
+
+	if w.byAuthorTime {
+		w.heap = binaryheap.NewWith(func(a, b interface{}) int {
+			if a.(*Commit).Author.When.Before(b.(*Commit).Author.When) {
+				return 1
+			}
+			return -1
+		})
+	}
+
+	for i := len(tips) - 1; i >= 0; i-- {
+		w.push(tips[i])
+	}
+
+	return nil
+}
+
+func (w *commitTopoIterator) push(c *Commit) {
+	if w.heap != nil {
+		w.heap.Push(c)
+		return
+	}
+
+	w.stack = append(w.stack, c)
+}
+
+func (w *commitTopoIterator) pop() *Commit {
+	if w.heap != nil {
+		c, ok := w.heap.Pop()
+		if !ok {
+			return nil
+		}
+
+		return c.(*Commit)
+	}
+
+	if len(w.stack) == 0 {
+		return nil
+	}
+
+	c := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	return c
+}
+
+func (w *commitTopoIterator) Next() (*Commit, error) {
+	if !w.walked {
+		if err := w.walk(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := w.pop()
+	if c == nil {
+		return nil, io.EOF
+	}
+
+	for _, h := range c.ParentHashes {
+		if w.seenExternal[h] {
+			continue
+		}
+
+		w.indegree[h]--
+		if w.indegree[h] == 0 {
+			w.push(w.commits[h])
+		}
+	}
+
+	return c, nil
+}
+
+func (w *commitTopoIterator) ForEach(cb func(*Commit) error) error {
+	for {
+		c, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = cb(c)
+		if err == storer.ErrStop {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *commitTopoIterator) Close() {}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"strings"
 
@@ -21,21 +22,92 @@ var (
 	ErrCanceled = errors.New("operation canceled")
 )
 
+// PatchOptions describes how the line differences of a Patch are computed
+// and shown.
+type PatchOptions struct {
+	// Algorithm is the algorithm computing the line differences, diff.Myers
+	// by default.
+	Algorithm diff.Algorithm
+	// Context is the number of unchanged lines shown around the changes,
+	// fdiff.DefaultContextLines if zero. A negative number shows none.
+	Context int
+	// IgnoreWhitespace compares the lines ignoring all their whitespace, as
+	// `git diff --ignore-all-space` does.
+	IgnoreWhitespace bool
+	// IgnoreBlankLines leaves out the changes whose lines are all blank if
+	// they are not close to other changes, as `git diff --ignore-blank-lines`
+	// does.
+	IgnoreBlankLines bool
+	// IgnoreCase compares the lines ignoring the case of their letters.
+	IgnoreCase bool
+	// IndentHeuristic shifts the groups of changed lines to the positions
+	// best aligned with the indentation of the text, as
+	// `git diff --indent-heuristic` does.
+	IndentHeuristic bool
+	// Binary encodes the changes of the binary files as binary patches,
+	// which can be applied, as `git diff --binary` does.
+	Binary bool
+}
+
+// contextLines returns the number of context lines of the options.
+func (o *PatchOptions) contextLines() int {
+	switch {
+	case o.Context == 0:
+		return fdiff.DefaultContextLines
+	case o.Context < 0:
+		return 0
+	default:
+		return o.Context
+	}
+}
+
+func (o *PatchOptions) diffOptions() *diff.Options {
+	return &diff.Options{
+		Algorithm:        o.Algorithm,
+		IgnoreWhitespace: o.IgnoreWhitespace,
+		IgnoreCase:       o.IgnoreCase,
+		IndentHeuristic:  o.IndentHeuristic,
+	}
+}
+
 func getPatch(message string, changes ...*Change) (*Patch, error) {
 	ctx := context.Background()
-	return getPatchContext(ctx, message, changes...)
+	return getPatchContext(ctx, message, nil, changes...)
+}
+
+func getPatchContext(ctx context.Context, message string, o *PatchOptions, changes ...*Change) (*Patch, error) {
+	if o == nil {
+		o = &PatchOptions{}
+	}
+
+	return newPatch(ctx, message, o, len(changes), func(i int) (fdiff.FilePatch, error) {
+		return filePatchWithContext(ctx, changes[i], o)
+	})
 }
 
-func getPatchContext(ctx context.Context, message string, changes ...*Change) (*Patch, error) {
+func getFilesPatchContext(ctx context.Context, message string, o *PatchOptions, changes ...FileChange) (*Patch, error) {
+	if o == nil {
+		o = &PatchOptions{}
+	}
+
+	return newPatch(ctx, message, o, len(changes), func(i int) (fdiff.FilePatch, error) {
+		c := changes[i]
+		return filesPatchWithContext(ctx, c.From, c.To, fileChangeEntry(c.From), fileChangeEntry(c.To), o)
+	})
+}
+
+// newPatch returns the Patch of n changes, whose file patches are returned
+// by filePatch.
+func newPatch(ctx context.Context, message string, o *PatchOptions, n int, filePatch func(i int) (fdiff.FilePatch, error)) (*Patch, error) {
 	var filePatches []fdiff.FilePatch
-	for _, c := range changes {
+	for i := 0; i < n; i++ {
 		select {
 		case <-ctx.Done():
 			return nil, ErrCanceled
 		default:
 		}
 
-		fp, err := filePatchWithContext(ctx, c)
+		fp, err := filePatch(i)
 		if err != nil {
 			return nil, err
 		}
@@ -43,14 +115,19 @@ func getPatchContext(ctx context.Context, message string, changes ...*Change) (*
 		filePatches = append(filePatches, fp)
 	}
 
-	return &Patch{message, filePatches}, nil
+	return &Patch{message: message, filePatches: filePatches, options: o}, nil
 }
 
-func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, error) {
+func filePatchWithContext(ctx context.Context, c *Change, o *PatchOptions) (fdiff.FilePatch, error) {
 	from, to, err := c.Files()
 	if err != nil {
 		return nil, err
 	}
+
+	return filesPatchWithContext(ctx, from, to, c.From, c.To, o)
+}
+
+func filesPatchWithContext(ctx context.Context, from, to *File, fromEntry, toEntry ChangeEntry, o *PatchOptions) (fdiff.FilePatch, error) {
 	fromContent, fIsBinary, err := fileContent(from)
 	if err != nil {
 		return nil, err
@@ -62,10 +139,14 @@ func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, erro
 	}
 
 	if fIsBinary || tIsBinary {
-		return &textFilePatch{from: c.From, to: c.To}, nil
+		return &binaryFilePatch{
+			textFilePatch: textFilePatch{from: fromEntry, to: toEntry},
+			fromFile:      from,
+			toFile:        to,
+		}, nil
 	}
 
-	diffs := diff.Do(fromContent, toContent)
+	diffs := diff.DoWithOptions(fromContent, toContent, o.diffOptions())
 
 	var chunks []fdiff.Chunk
 	for _, d := range diffs {
@@ -90,14 +171,14 @@ func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, erro
 
 	return &textFilePatch{
 		chunks: chunks,
-		from:   c.From,
-		to:     c.To,
+		from:   fromEntry,
+		to:     toEntry,
 	}, nil
 
 }
 
 func filePatch(c *Change) (fdiff.FilePatch, error) {
-	return filePatchWithContext(context.Background(), c)
+	return filePatchWithContext(context.Background(), c, &PatchOptions{})
 }
 
 func fileContent(f *File) (content string, isBinary bool, err error) {
@@ -119,6 +200,7 @@ func fileContent(f *File) (content string, isBinary bool, err error) {
 type Patch struct {
 	message     string
 	filePatches []fdiff.FilePatch
+	options     *PatchOptions
 }
 
 func (t *Patch) FilePatches() []fdiff.FilePatch {
@@ -131,6 +213,11 @@ func (t *Patch) Message() string {
 
 func (p *Patch) Encode(w io.Writer) error {
 	ue := fdiff.NewUnifiedEncoder(w, fdiff.DefaultContextLines)
+	if p.options != nil {
+		ue = fdiff.NewUnifiedEncoder(w, p.options.contextLines()).
+			SetIgnoreBlankLines(p.options.IgnoreBlankLines, p.options.IgnoreWhitespace).
+			SetBinary(p.options.Binary)
+	}
 
 	return ue.Encode(p)
 }
@@ -206,6 +293,36 @@ func (t *textFilePatch) Chunks() []fdiff.Chunk {
 	return t.chunks
 }
 
+// binaryFilePatch is an implementation of fdiff.BinaryFilePatch interface
+type binaryFilePatch struct {
+	textFilePatch
+	fromFile, toFile *File
+}
+
+func (b *binaryFilePatch) BinaryContents() (from, to []byte, err error) {
+	from, err = binaryContent(b.fromFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, err = binaryContent(b.toFile)
+	return from, to, err
+}
+
+func binaryContent(f *File) ([]byte, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
 // textChunk is an implementation of fdiff.Chunk interface
 type textChunk struct {
 	content string
@@ -324,12 +324,18 @@ func (from *Tree) Patch(to *Tree) (*Patch, error) {
 // If context expires, an error will be returned
 // Provided context must be non-nil
 func (from *Tree) PatchContext(ctx context.Context, to *Tree) (*Patch, error) {
+	return from.PatchWithOptions(ctx, to, nil)
+}
+
+// PatchWithOptions is like PatchContext, computing and showing the line
+// differences with the given options, the default ones if nil.
+func (from *Tree) PatchWithOptions(ctx context.Context, to *Tree, o *PatchOptions) (*Patch, error) {
 	changes, err := DiffTreeContext(ctx, from, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return changes.PatchContext(ctx)
+	return changes.PatchWithOptions(ctx, o)
 }
 
 // treeEntryIter facilitates iterating through the TreeEntry objects in a Tree.
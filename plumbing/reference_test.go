@@ -89,6 +89,12 @@ func (s *ReferenceSuite) TestIsNote(c *C) {
 	c.Assert(r.IsNote(), Equals, true)
 }
 
+func (s *ReferenceSuite) TestIsReplace(c *C) {
+	r := ReferenceName("refs/replace/6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(r.IsReplace(), Equals, true)
+	c.Assert(ReferenceName("refs/heads/master").IsReplace(), Equals, false)
+}
+
 func (s *ReferenceSuite) TestIsRemote(c *C) {
 	r := ReferenceName("refs/remotes/origin/master")
 	c.Assert(r.IsRemote(), Equals, true)
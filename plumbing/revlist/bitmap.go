@@ -0,0 +1,409 @@
+package revlist
+
+import (
+	"fmt"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+const (
+	// bitmapSelectionInterval is the distance, in commits, between the
+	// commits selected to have a bitmap besides the given tips.
+	bitmapSelectionInterval = 100
+	// bitmapXorWindow is the number of previous entries tried as a XOR base
+	// for each bitmap entry.
+	bitmapXorWindow = 10
+)
+
+// BitmapObjects returns a bitmap with every object reachable from the given
+// objects, using the commit bitmaps of pb and only reading from s the
+// objects not covered by them. bitmap.ErrObjectNotInPack is returned if any
+// of the reachable objects is not in the packfile of pb.
+func BitmapObjects(
+	s storer.EncodedObjectStorer,
+	pb *bitmap.PackBitmap,
+	objs []plumbing.Hash,
+) (*bitmap.Bitmap, error) {
+	w := &bitmapWalker{
+		s:        s,
+		position: pb.Position,
+		typ:      pb.Type,
+		commit:   pb.Commit,
+	}
+
+	result := bitmap.NewBitmap()
+	if err := w.walk(result, objs); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// bitmapWalker sets in a bitmap the objects reachable from a set of objects,
+// reading only the objects not covered by an existing commit bitmap.
+type bitmapWalker struct {
+	s storer.EncodedObjectStorer
+	// position returns the bit position of an object in the packfile.
+	position func(plumbing.Hash) (uint32, bool)
+	// typ returns the type of the object at a position, or InvalidObject if
+	// it is unknown.
+	typ func(uint32) plumbing.ObjectType
+	// commit returns the stored bitmap of a commit, if any.
+	commit func(plumbing.Hash) (*bitmap.Bitmap, error)
+}
+
+func (w *bitmapWalker) walk(result *bitmap.Bitmap, objs []plumbing.Hash) error {
+	pending := make([]plumbing.Hash, len(objs))
+	copy(pending, objs)
+
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		pos, ok := w.position(h)
+		if !ok {
+			return bitmap.ErrObjectNotInPack
+		}
+
+		if result.Has(pos) {
+			continue
+		}
+
+		t := w.typ(pos)
+		if t == plumbing.BlobObject {
+			result.Set(pos)
+			continue
+		}
+
+		if t == plumbing.CommitObject || t == plumbing.InvalidObject {
+			b, err := w.commit(h)
+			if err == nil {
+				result.Or(b)
+				continue
+			}
+
+			if err != bitmap.ErrBitmapNotFound {
+				return err
+			}
+		}
+
+		next, err := w.references(h)
+		if err != nil {
+			return err
+		}
+
+		// The bit is set before the referenced objects are walked, this is
+		// fine since all of them are walked before returning.
+		result.Set(pos)
+		pending = append(pending, next...)
+	}
+
+	return nil
+}
+
+// references returns the objects directly referenced by the given object.
+func (w *bitmapWalker) references(h plumbing.Hash) ([]plumbing.Hash, error) {
+	o, err := w.s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Type() {
+	case plumbing.CommitObject:
+		c, err := object.DecodeCommit(w.s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		return append([]plumbing.Hash{c.TreeHash}, c.ParentHashes...), nil
+	case plumbing.TreeObject:
+		t, err := object.DecodeTree(w.s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		refs := make([]plumbing.Hash, 0, len(t.Entries))
+		for _, e := range t.Entries {
+			if e.Mode == filemode.Submodule {
+				continue
+			}
+
+			refs = append(refs, e.Hash)
+		}
+
+		return refs, nil
+	case plumbing.TagObject:
+		t, err := object.DecodeTag(w.s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		return []plumbing.Hash{t.Target}, nil
+	case plumbing.BlobObject:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("object type not valid: %s. "+
+			"Object reference: %s", o.Type(), o.Hash())
+	}
+}
+
+// BuildBitmap generates the bitmap index of the packfile described by idx.
+// The packfile must be self contained: every object reachable from its
+// commits has to be in it, otherwise bitmap.ErrObjectNotInPack is returned.
+//
+// Bitmaps are stored for the given tips, usually the objects pointed by
+// references, and for one of every hundred commits of the packfile, so the
+// objects reachable from any other commit are found with a short walk.
+func BuildBitmap(
+	s storer.EncodedObjectStorer,
+	idx idxfile.Index,
+	tips []plumbing.Hash,
+) (*bitmap.Index, error) {
+	b := &bitmapBuilder{
+		s:         s,
+		idx:       idx,
+		positions: make(map[plumbing.Hash]uint32),
+		idxPos:    make(map[plumbing.Hash]uint32),
+		types:     make(map[uint32]plumbing.ObjectType),
+		parents:   make(map[plumbing.Hash][]plumbing.Hash),
+		tags:      make(map[plumbing.Hash]plumbing.Hash),
+		stored:    make(map[plumbing.Hash]*bitmap.Bitmap),
+	}
+
+	if err := b.loadObjects(); err != nil {
+		return nil, err
+	}
+
+	return b.build(tips)
+}
+
+type bitmapBuilder struct {
+	s   storer.EncodedObjectStorer
+	idx idxfile.Index
+
+	positions map[plumbing.Hash]uint32
+	idxPos    map[plumbing.Hash]uint32
+	types     map[uint32]plumbing.ObjectType
+
+	commitBits, treeBits, blobBits, tagBits *bitmap.Bitmap
+
+	// commits are the commits of the packfile, in packfile order.
+	commits []plumbing.Hash
+	parents map[plumbing.Hash][]plumbing.Hash
+	// tags maps the annotated tags to their targets.
+	tags   map[plumbing.Hash]plumbing.Hash
+	stored map[plumbing.Hash]*bitmap.Bitmap
+}
+
+func (b *bitmapBuilder) loadObjects() error {
+	names, err := bitmap.IndexHashes(b.idx)
+	if err != nil {
+		return err
+	}
+
+	for i, h := range names {
+		b.idxPos[h] = uint32(i)
+	}
+
+	iter, err := b.idx.EntriesByOffset()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+
+	b.commitBits, b.treeBits, b.blobBits, b.tagBits =
+		bitmap.NewBitmap(), bitmap.NewBitmap(), bitmap.NewBitmap(), bitmap.NewBitmap()
+
+	for pos := uint32(0); ; pos++ {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		b.positions[e.Hash] = pos
+		if err := b.loadObject(e.Hash, pos); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *bitmapBuilder) loadObject(h plumbing.Hash, pos uint32) error {
+	o, err := b.s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return err
+	}
+
+	b.types[pos] = o.Type()
+	switch o.Type() {
+	case plumbing.CommitObject:
+		b.commitBits.Set(pos)
+
+		c, err := object.DecodeCommit(b.s, o)
+		if err != nil {
+			return err
+		}
+
+		b.commits = append(b.commits, h)
+		b.parents[h] = c.ParentHashes
+	case plumbing.TreeObject:
+		b.treeBits.Set(pos)
+	case plumbing.BlobObject:
+		b.blobBits.Set(pos)
+	case plumbing.TagObject:
+		b.tagBits.Set(pos)
+
+		t, err := object.DecodeTag(b.s, o)
+		if err != nil {
+			return err
+		}
+
+		b.tags[h] = t.Target
+	}
+
+	return nil
+}
+
+func (b *bitmapBuilder) build(tips []plumbing.Hash) (*bitmap.Index, error) {
+	w := &bitmapWalker{
+		s: b.s,
+		position: func(h plumbing.Hash) (uint32, bool) {
+			pos, ok := b.positions[h]
+			return pos, ok
+		},
+		typ: func(pos uint32) plumbing.ObjectType {
+			return b.types[pos]
+		},
+		commit: func(h plumbing.Hash) (*bitmap.Bitmap, error) {
+			if bm, ok := b.stored[h]; ok {
+				return bm, nil
+			}
+
+			return nil, bitmap.ErrBitmapNotFound
+		},
+	}
+
+	idx := &bitmap.Index{
+		Version: bitmap.VersionSupported,
+		Options: bitmap.OptFullDAG,
+		Commits: bitmap.NewEWAH(b.commitBits),
+		Trees:   bitmap.NewEWAH(b.treeBits),
+		Blobs:   bitmap.NewEWAH(b.blobBits),
+		Tags:    bitmap.NewEWAH(b.tagBits),
+	}
+
+	if mi, ok := b.idx.(*idxfile.MemoryIndex); ok {
+		idx.PackfileChecksum = mi.PackfileChecksum
+	}
+
+	var previous []*bitmap.Bitmap
+	for _, h := range b.selectCommits(tips) {
+		bm := bitmap.NewBitmap()
+		if err := w.walk(bm, []plumbing.Hash{h}); err != nil {
+			return nil, err
+		}
+
+		b.stored[h] = bm
+		idx.Entries = append(idx.Entries, newBitmapEntry(b.idxPos[h], bm, previous))
+		previous = append(previous, bm)
+	}
+
+	return idx, nil
+}
+
+// newBitmapEntry returns the entry for a commit bitmap, XORed against the
+// previous bitmap that produces the smallest compressed result.
+func newBitmapEntry(pos uint32, bm *bitmap.Bitmap, previous []*bitmap.Bitmap) *bitmap.Entry {
+	e := &bitmap.Entry{ObjectPos: pos, Bitmap: bitmap.NewEWAH(bm)}
+	for i := 1; i <= bitmapXorWindow && i <= len(previous); i++ {
+		x := bm.Clone()
+		x.Xor(previous[len(previous)-i])
+
+		if c := bitmap.NewEWAH(x); len(c.Words) < len(e.Bitmap.Words) {
+			e.Bitmap = c
+			e.XorOffset = uint8(i)
+		}
+	}
+
+	return e
+}
+
+// selectCommits returns the commits to store a bitmap for, sorted so every
+// commit comes after its ancestors.
+func (b *bitmapBuilder) selectCommits(tips []plumbing.Hash) []plumbing.Hash {
+	wanted := make(map[plumbing.Hash]bool)
+	for _, h := range tips {
+		for {
+			target, ok := b.tags[h]
+			if !ok {
+				break
+			}
+
+			h = target
+		}
+
+		if _, ok := b.parents[h]; ok {
+			wanted[h] = true
+		}
+	}
+
+	var selected []plumbing.Hash
+	for i, h := range b.topoOrder() {
+		if wanted[h] || i%bitmapSelectionInterval == bitmapSelectionInterval-1 {
+			selected = append(selected, h)
+		}
+	}
+
+	return selected
+}
+
+// topoOrder returns the commits of the packfile sorted so every commit comes
+// after all of its parents.
+func (b *bitmapBuilder) topoOrder() []plumbing.Hash {
+	visited := make(map[plumbing.Hash]bool, len(b.commits))
+	order := make([]plumbing.Hash, 0, len(b.commits))
+
+	type frame struct {
+		hash plumbing.Hash
+		next int
+	}
+
+	for _, root := range b.commits {
+		if visited[root] {
+			continue
+		}
+
+		visited[root] = true
+		stack := []*frame{{hash: root}}
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			parents := b.parents[f.hash]
+			if f.next < len(parents) {
+				p := parents[f.next]
+				f.next++
+
+				if _, ok := b.parents[p]; ok && !visited[p] {
+					visited[p] = true
+					stack = append(stack, &frame{hash: p})
+				}
+
+				continue
+			}
+
+			order = append(order, f.hash)
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	return order
+}
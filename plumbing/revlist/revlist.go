@@ -3,11 +3,13 @@
 package revlist
 
 import (
+	"errors"
 	"fmt"
 	"io"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
@@ -16,14 +18,75 @@ import (
 // the reachable objects from the given objects. Ignore param are object hashes
 // that we want to ignore on the result. All that objects must be accessible
 // from the object storer.
+//
+// If the storer implements bitmap.Storer and one of its packfiles contains
+// all the reachable objects, the reachability bitmaps are used instead of
+// walking the history.
 func Objects(
 	s storer.EncodedObjectStorer,
 	objs,
 	ignore []plumbing.Hash,
 ) ([]plumbing.Hash, error) {
+	if bs, ok := s.(bitmap.Storer); ok {
+		result, err := objectsWithBitmaps(s, bs, objs, ignore)
+		if err != errNoBitmap {
+			return result, err
+		}
+	}
+
 	return ObjectsWithStorageForIgnores(s, s, objs, ignore)
 }
 
+// errNoBitmap is returned by objectsWithBitmaps when no packfile bitmap can be
+// used to compute the objects.
+var errNoBitmap = errors.New("no bitmap covers the objects")
+
+func objectsWithBitmaps(
+	s storer.EncodedObjectStorer,
+	bs bitmap.Storer,
+	objs,
+	ignore []plumbing.Hash,
+) ([]plumbing.Hash, error) {
+	pbs, err := bs.PackBitmaps()
+	if err != nil {
+		return nil, errNoBitmap
+	}
+
+	// Missing objects to ignore are allowed, like in the walk.
+	var present []plumbing.Hash
+	for _, h := range ignore {
+		err := s.HasEncodedObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		present = append(present, h)
+	}
+
+	// the bitmaps not covering all the objects, or corrupted, are skipped,
+	// walking the history instead if no other bitmap can be used
+	for _, pb := range pbs {
+		wants, err := BitmapObjects(s, pb, objs)
+		if err != nil {
+			continue
+		}
+
+		haves, err := BitmapObjects(s, pb, present)
+		if err != nil {
+			continue
+		}
+
+		wants.AndNot(haves)
+		return pb.Hashes(wants), nil
+	}
+
+	return nil, errNoBitmap
+}
+
 // ObjectsWithStorageForIgnores is the same as Objects, but a
 // secondary storage layer can be provided, to be used to finding the
 // full set of objects to be ignored while finding the reachable
@@ -1,6 +1,8 @@
 package revlist
 
 import (
+	"fmt"
+	"os"
 	"testing"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -107,6 +109,84 @@ func (s *RevListSuite) TestRevListObjects(c *C) {
 	c.Assert(len(remoteHist), Equals, len(revList))
 }
 
+func (s *RevListSuite) TestRevListObjectsWithBitmaps(c *C) {
+	sto := filesystem.NewStorage(fixtures.Basic().One().DotGit(), cache.NewObjectLRUDefault())
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	idx, err := sto.ObjectPackIndex(packs[0])
+	c.Assert(err, IsNil)
+
+	bi, err := BuildBitmap(sto, idx, []plumbing.Hash{head})
+	c.Assert(err, IsNil)
+	c.Assert(sto.SetPackBitmap(bi), IsNil)
+
+	pbs, err := sto.PackBitmaps()
+	c.Assert(err, IsNil)
+	c.Assert(pbs, HasLen, 1)
+
+	cases := []struct {
+		objs, ignore []plumbing.Hash
+	}{
+		{[]plumbing.Hash{head}, nil},
+		{[]plumbing.Hash{head}, []plumbing.Hash{plumbing.NewHash(someCommit)}},
+		{[]plumbing.Hash{plumbing.NewHash(someCommitBranch)}, []plumbing.Hash{head}},
+		{[]plumbing.Hash{plumbing.NewHash(secondCommit)}, []plumbing.Hash{plumbing.NewHash(initialCommit)}},
+		// missing objects to ignore are skipped
+		{[]plumbing.Hash{head}, []plumbing.Hash{plumbing.NewHash("0000000000000000000000000000000000000001")}},
+	}
+
+	for _, t := range cases {
+		withBitmaps, err := objectsWithBitmaps(sto, sto, t.objs, t.ignore)
+		c.Assert(err, IsNil)
+
+		expected, err := ObjectsWithStorageForIgnores(s.Storer, s.Storer, t.objs, t.ignore)
+		c.Assert(err, IsNil)
+
+		c.Assert(hashListToSet(withBitmaps), DeepEquals, hashListToSet(expected))
+	}
+}
+
+func (s *RevListSuite) TestRevListObjectsWithTruncatedBitmap(c *C) {
+	fs := fixtures.Basic().One().DotGit()
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	idx, err := sto.ObjectPackIndex(packs[0])
+	c.Assert(err, IsNil)
+
+	bi, err := BuildBitmap(sto, idx, []plumbing.Hash{head})
+	c.Assert(err, IsNil)
+	c.Assert(sto.SetPackBitmap(bi), IsNil)
+
+	name := fs.Join("objects", "pack", fmt.Sprintf("pack-%s.bitmap", packs[0]))
+	fi, err := fs.Stat(name)
+	c.Assert(err, IsNil)
+	f, err := fs.OpenFile(name, os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(fi.Size()/2), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	sto = filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	pbs, err := sto.PackBitmaps()
+	c.Assert(err, IsNil)
+	c.Assert(pbs, HasLen, 0)
+
+	objs := []plumbing.Hash{head}
+	hist, err := Objects(sto, objs, nil)
+	c.Assert(err, IsNil)
+
+	expected, err := ObjectsWithStorageForIgnores(sto, sto, objs, nil)
+	c.Assert(err, IsNil)
+	c.Assert(hashListToSet(hist), DeepEquals, hashListToSet(expected))
+}
+
 func (s *RevListSuite) TestRevListObjectsTagObject(c *C) {
 	sto := filesystem.NewStorage(
 		fixtures.ByTag("tags").
@@ -1,9 +1,23 @@
 package storer
 
-import "gopkg.in/src-d/go-git.v4/plumbing/format/index"
+import (
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+)
 
 // IndexStorer generic storage of index.Index
 type IndexStorer interface {
 	SetIndex(*index.Index) error
 	Index() (*index.Index, error)
 }
+
+// IndexModTimeStorer is an optional interface for IndexStorer, returning
+// the time the index was last written. The stat data of the index entries of
+// the files modified at that time or later can not be trusted, as the files
+// may have been modified again right after being added to the index.
+type IndexModTimeStorer interface {
+	// IndexModTime returns the time the index was last written, the zero
+	// time if it was never written.
+	IndexModTime() (time.Time, error)
+}
@@ -1,6 +1,7 @@
 package storer
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"time"
@@ -87,6 +88,14 @@ type PackedObjectStorer interface {
 	DeleteOldObjectPackAndIndex(plumbing.Hash, time.Time) error
 }
 
+// PrefixObjectStorer is an optional interface for looking up objects by an
+// abbreviated hash, without iterating over all the objects.
+type PrefixObjectStorer interface {
+	// HashesWithPrefix returns the hashes of the objects starting with the
+	// given prefix, in no particular order.
+	HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
+}
+
 // PackfileWriter is a optional method for ObjectStorer, it enable direct write
 // of packfile to the storage
 type PackfileWriter interface {
@@ -286,3 +295,28 @@ func ForEachIterator(iter bareIterator, cb func(plumbing.EncodedObject) error) e
 		}
 	}
 }
+
+// HashesWithPrefix returns the hashes of the objects of the storer starting
+// with the given prefix. If the storer does not implement PrefixObjectStorer
+// all the objects are iterated.
+func HashesWithPrefix(s EncodedObjectStorer, prefix []byte) ([]plumbing.Hash, error) {
+	if ps, ok := s.(PrefixObjectStorer); ok {
+		return ps.HashesWithPrefix(prefix)
+	}
+
+	iter, err := s.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		if h := obj.Hash(); bytes.HasPrefix(h[:], prefix) {
+			hashes = append(hashes, h)
+		}
+
+		return nil
+	})
+
+	return hashes, err
+}
@@ -120,6 +120,22 @@ func (s *ObjectSuite) TestObjectSliceIterError(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (s *ObjectSuite) TestHashesWithPrefix(c *C) {
+	storage := &MockObjectStorage{s.Objects}
+
+	hashes, err := HashesWithPrefix(storage, s.Hash[0][:2])
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{s.Hash[0]})
+
+	hashes, err = HashesWithPrefix(storage, nil)
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 2)
+
+	hashes, err = HashesWithPrefix(storage, []byte{s.Hash[0][0] ^ 0xff})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 0)
+}
+
 type MockObjectStorage struct {
 	db []plumbing.EncodedObject
 }
@@ -161,7 +177,7 @@ func (o *MockObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash
 }
 
 func (o *MockObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (EncodedObjectIter, error) {
-	return nil, nil
+	return NewEncodedObjectSliceIter(o.db), nil
 }
 
 func (o *MockObjectStorage) Begin() Transaction {
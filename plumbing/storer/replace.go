@@ -0,0 +1,203 @@
+package storer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// ReplaceRefPrefix is the prefix of the references replacing objects, the
+// reference refs/replace/<hash> points to the object replacing <hash>.
+const ReplaceRefPrefix = "refs/replace/"
+
+// maxReplaceDepth is the maximum number of replacements followed looking up an
+// object, the same used by git, avoiding loops between replace references.
+const maxReplaceDepth = 5
+
+// ReplaceObjectStorer is an EncodedObjectStorer returning, for the objects
+// with a replace reference, the content of the replacement object and, for
+// the commits with a graft, the commit with the grafted parents. The objects
+// keep the hash they were looked up with, so walking the history from a
+// commit sees the replaced parents transparently.
+//
+// The objects returned by IterEncodedObjects are not replaced.
+type ReplaceObjectStorer struct {
+	EncodedObjectStorer
+	// Replacements maps the hash of the replaced objects to the hash of the
+	// objects replacing them.
+	Replacements map[plumbing.Hash]plumbing.Hash
+	// Grafts maps the hash of a commit to its grafted parents.
+	Grafts map[plumbing.Hash][]plumbing.Hash
+}
+
+// NewReplaceObjectStorer returns a ReplaceObjectStorer for the given storer,
+// with the replacements of the replace references found in refs, which may
+// be nil, and the given grafts.
+func NewReplaceObjectStorer(s EncodedObjectStorer, refs ReferenceStorer,
+	grafts map[plumbing.Hash][]plumbing.Hash) (*ReplaceObjectStorer, error) {
+
+	replacements := make(map[plumbing.Hash]plumbing.Hash)
+	if refs != nil {
+		iter, err := refs.IterReferences()
+		if err != nil {
+			return nil, err
+		}
+
+		err = iter.ForEach(func(r *plumbing.Reference) error {
+			name := r.Name().String()
+			if r.Type() != plumbing.HashReference || !strings.HasPrefix(name, ReplaceRefPrefix) {
+				return nil
+			}
+
+			h := plumbing.NewHash(name[len(ReplaceRefPrefix):])
+			if h.IsZero() {
+				return nil
+			}
+
+			replacements[h] = r.Hash()
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ReplaceObjectStorer{
+		EncodedObjectStorer: s,
+		Replacements:        replacements,
+		Grafts:              grafts,
+	}, nil
+}
+
+// EncodedObject honors the EncodedObjectStorer interface.
+func (s *ReplaceObjectStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	target := h
+	for i := 0; i < maxReplaceDepth; i++ {
+		r, ok := s.Replacements[target]
+		if !ok {
+			break
+		}
+
+		target = r
+	}
+
+	obj, err := s.EncodedObjectStorer.EncodedObject(t, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if parents, ok := s.Grafts[h]; ok && obj.Type() == plumbing.CommitObject {
+		obj, err = graftCommit(obj, parents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if obj.Hash() == h {
+		return obj, nil
+	}
+
+	return &replacedObject{EncodedObject: obj, hash: h}, nil
+}
+
+// HasEncodedObject honors the EncodedObjectStorer interface.
+func (s *ReplaceObjectStorer) HasEncodedObject(h plumbing.Hash) error {
+	if _, ok := s.Replacements[h]; ok {
+		return nil
+	}
+
+	return s.EncodedObjectStorer.HasEncodedObject(h)
+}
+
+// replacedObject is an object with the content of another one.
+type replacedObject struct {
+	plumbing.EncodedObject
+	hash plumbing.Hash
+}
+
+func (o *replacedObject) Hash() plumbing.Hash {
+	return o.hash
+}
+
+// graftCommit returns the given commit with its parent lines replaced by the
+// given parents.
+func graftCommit(obj plumbing.EncodedObject, parents []plumbing.Hash) (plumbing.EncodedObject, error) {
+	r, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+
+	header := content
+	var body []byte
+	if i := bytes.Index(content, []byte("\n\n")); i >= 0 {
+		header, body = content[:i+1], content[i+1:]
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, line := range bytes.SplitAfter(header, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("parent ")) {
+			continue
+		}
+
+		buf.Write(line)
+		if bytes.HasPrefix(line, []byte("tree ")) {
+			for _, p := range parents {
+				fmt.Fprintf(buf, "parent %s\n", p)
+			}
+		}
+	}
+
+	buf.Write(body)
+
+	grafted := &plumbing.MemoryObject{}
+	grafted.SetType(plumbing.CommitObject)
+	if _, err := grafted.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return grafted, nil
+}
+
+// DecodeGrafts reads a grafts file, such as .git/info/grafts, with one line
+// per commit, the hash of the commit followed by the hashes of its parents,
+// separated by spaces. Empty lines and lines starting with '#' are ignored.
+func DecodeGrafts(r io.Reader) (map[plumbing.Hash][]plumbing.Hash, error) {
+	grafts := make(map[plumbing.Hash][]plumbing.Hash)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var hashes []plumbing.Hash
+		for _, f := range strings.Fields(line) {
+			h := plumbing.NewHash(f)
+			if len(f) != 40 || h.String() != strings.ToLower(f) {
+				return nil, fmt.Errorf("invalid graft line: %q", line)
+			}
+
+			hashes = append(hashes, h)
+		}
+
+		grafts[hashes[0]] = hashes[1:]
+	}
+
+	return grafts, s.Err()
+}
@@ -0,0 +1,118 @@
+package storer
+
+import (
+	"io/ioutil"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type ReplaceSuite struct{}
+
+var _ = Suite(&ReplaceSuite{})
+
+func newObject(t plumbing.ObjectType, content string) plumbing.EncodedObject {
+	o := &plumbing.MemoryObject{}
+	o.SetType(t)
+	o.Write([]byte(content))
+	return o
+}
+
+func readObject(c *C, o plumbing.EncodedObject) string {
+	r, err := o.Reader()
+	c.Assert(err, IsNil)
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(r.Close(), IsNil)
+	return string(b)
+}
+
+func (s *ReplaceSuite) TestEncodedObject(c *C) {
+	foo := newObject(plumbing.BlobObject, "foo")
+	bar := newObject(plumbing.BlobObject, "bar")
+	qux := newObject(plumbing.BlobObject, "qux")
+
+	st, err := NewReplaceObjectStorer(&MockObjectStorage{
+		db: []plumbing.EncodedObject{foo, bar, qux},
+	}, nil, nil)
+	c.Assert(err, IsNil)
+
+	st.Replacements[foo.Hash()] = bar.Hash()
+	st.Replacements[bar.Hash()] = qux.Hash()
+
+	o, err := st.EncodedObject(plumbing.BlobObject, foo.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(o.Hash(), Equals, foo.Hash())
+	c.Assert(readObject(c, o), Equals, "qux")
+
+	o, err = st.EncodedObject(plumbing.BlobObject, qux.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(o, Equals, qux)
+}
+
+func (s *ReplaceSuite) TestEncodedObjectLoop(c *C) {
+	foo := newObject(plumbing.BlobObject, "foo")
+	bar := newObject(plumbing.BlobObject, "bar")
+
+	st, err := NewReplaceObjectStorer(&MockObjectStorage{
+		db: []plumbing.EncodedObject{foo, bar},
+	}, nil, nil)
+	c.Assert(err, IsNil)
+
+	st.Replacements[foo.Hash()] = bar.Hash()
+	st.Replacements[bar.Hash()] = foo.Hash()
+
+	o, err := st.EncodedObject(plumbing.BlobObject, foo.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(o.Hash(), Equals, foo.Hash())
+}
+
+func (s *ReplaceSuite) TestGrafts(c *C) {
+	tree := "4b825dc642cb6eb9a060e54bf8d69288fbe4904b"
+	parent := plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69")
+	grafted := plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+
+	commit := newObject(plumbing.CommitObject, "tree "+tree+"\n"+
+		"parent "+parent.String()+"\n"+
+		"author foo <foo@foo.foo> 1500000000 +0000\n"+
+		"committer foo <foo@foo.foo> 1500000000 +0000\n"+
+		"\n"+
+		"parent message\n")
+
+	st, err := NewReplaceObjectStorer(&MockObjectStorage{
+		db: []plumbing.EncodedObject{commit},
+	}, nil, map[plumbing.Hash][]plumbing.Hash{
+		commit.Hash(): {grafted, parent},
+	})
+	c.Assert(err, IsNil)
+
+	o, err := st.EncodedObject(plumbing.CommitObject, commit.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(o.Hash(), Equals, commit.Hash())
+	c.Assert(o.Type(), Equals, plumbing.CommitObject)
+	c.Assert(readObject(c, o), Equals, "tree "+tree+"\n"+
+		"parent "+grafted.String()+"\n"+
+		"parent "+parent.String()+"\n"+
+		"author foo <foo@foo.foo> 1500000000 +0000\n"+
+		"committer foo <foo@foo.foo> 1500000000 +0000\n"+
+		"\n"+
+		"parent message\n")
+}
+
+func (s *ReplaceSuite) TestDecodeGrafts(c *C) {
+	grafts, err := DecodeGrafts(strings.NewReader("# grafts\n" +
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 918c48b83bd081e863dbe1b80f8998f058cd8294\n" +
+		"\n" +
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d\n"))
+	c.Assert(err, IsNil)
+	c.Assert(grafts, DeepEquals, map[plumbing.Hash][]plumbing.Hash{
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"): {
+			plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+		},
+		plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"): {},
+	})
+
+	_, err = DecodeGrafts(strings.NewReader("foo bar\n"))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,175 @@
+// Package bundle implements a transport reading the objects and references
+// from a git bundle file, so bundles can be cloned and fetched like any other
+// repository. Bundles are read-only, pushing to them is not supported.
+package bundle
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrReceivePackNotSupported is returned when a git-receive-pack session
+	// is requested, since bundles can not be pushed to.
+	ErrReceivePackNotSupported = errors.New("push to a bundle is not supported")
+	// ErrMissingPrerequisites is returned by CheckPrerequisites when the
+	// repository lacks any of the prerequisite commits of the bundle.
+	ErrMissingPrerequisites = errors.New("repository lacks the prerequisite commits of the bundle")
+)
+
+// DefaultClient is the default bundle client.
+var DefaultClient = NewClient()
+
+type client struct{}
+
+// NewClient returns a new bundle client, the path of the endpoints is the
+// path of the bundle file.
+func NewClient() transport.Transport {
+	return &client{}
+}
+
+// IsBundle returns true if the file at path is a git bundle.
+func IsBundle(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+	return bundle.NewDecoder(f).Decode(&bundle.Bundle{}) == nil
+}
+
+// CheckPrerequisites returns ErrMissingPrerequisites if the given session
+// reads a bundle whose prerequisite commits are not in the object storer s,
+// the packfile of the bundle can not be used without them. Sessions not
+// reading bundles have no prerequisites.
+func CheckPrerequisites(sess transport.UploadPackSession, s storer.EncodedObjectStorer) error {
+	up, ok := sess.(*upSession)
+	if !ok {
+		return nil
+	}
+
+	for _, p := range up.bundle.Prerequisites {
+		_, err := s.EncodedObject(plumbing.CommitObject, p.Hash)
+		if err == plumbing.ErrObjectNotFound {
+			return ErrMissingPrerequisites
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *client) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
+	transport.UploadPackSession, error) {
+
+	f, err := os.Open(ep.Path)
+	if os.IsNotExist(err) {
+		return nil, transport.ErrRepositoryNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundle.Bundle{}
+	if err := bundle.NewDecoder(f).Decode(b); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &upSession{f: f, bundle: b}, nil
+}
+
+func (c *client) NewReceivePackSession(*transport.Endpoint, transport.AuthMethod) (
+	transport.ReceivePackSession, error) {
+
+	return nil, ErrReceivePackNotSupported
+}
+
+type upSession struct {
+	f      *os.File
+	bundle *bundle.Bundle
+	closed bool
+}
+
+// AdvertisedReferences returns the references listed in the header of the
+// bundle.
+func (s *upSession) AdvertisedReferences() (*packp.AdvRefs, error) {
+	ar := packp.NewAdvRefs()
+	if err := ar.Capabilities.Set(capability.Agent, capability.DefaultAgent); err != nil {
+		return nil, err
+	}
+
+	if err := ar.Capabilities.Set(capability.OFSDelta); err != nil {
+		return nil, err
+	}
+
+	for _, ref := range s.bundle.References {
+		if ref.Name() == plumbing.HEAD {
+			h := ref.Hash()
+			ar.Head = &h
+			continue
+		}
+
+		if err := ar.AddReference(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ar.References) == 0 {
+		return nil, transport.ErrEmptyRemoteRepository
+	}
+
+	return ar, nil
+}
+
+// UploadPack returns the packfile of the bundle, it contains all the objects
+// of the bundle whatever the wants and haves of the request are.
+func (s *upSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (
+	*packp.UploadPackResponse, error) {
+
+	if req.IsEmpty() {
+		return nil, transport.ErrEmptyUploadPackRequest
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(req.Shallows) > 0 || req.Depth != nil && !req.Depth.IsZero() {
+		return nil, errors.New("shallow not supported")
+	}
+
+	r := ioutil.NewReadCloser(s.bundle.Packfile, s)
+	return packp.NewUploadPackResponseWithPackfile(req,
+		ioutil.NewContextReadCloser(ctx, r),
+	), nil
+}
+
+// Close closes the bundle file.
+func (s *upSession) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	return s.f.Close()
+}
@@ -0,0 +1,130 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ClientSuite struct {
+	fixtures.Suite
+	dir  string
+	path string
+	pack []byte
+}
+
+var _ = Suite(&ClientSuite{})
+
+var head = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+func (s *ClientSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "bundle-client")
+	c.Assert(err, IsNil)
+	s.dir = dir
+
+	pack, err := ioutil.ReadAll(fixtures.Basic().One().Packfile())
+	c.Assert(err, IsNil)
+	s.pack = pack
+
+	s.path = filepath.Join(dir, "basic.bundle")
+	f, err := os.Create(s.path)
+	c.Assert(err, IsNil)
+
+	err = bundle.NewEncoder(f).Encode(&bundle.Bundle{
+		References: []*plumbing.Reference{
+			plumbing.NewHashReference(plumbing.HEAD, head),
+			plumbing.NewHashReference(plumbing.Master, head),
+		},
+		Packfile: bytes.NewReader(pack),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *ClientSuite) TearDownTest(c *C) {
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *ClientSuite) newSession(c *C, path string) (transport.UploadPackSession, error) {
+	ep, err := transport.NewEndpoint(path)
+	c.Assert(err, IsNil)
+
+	return DefaultClient.NewUploadPackSession(ep, nil)
+}
+
+func (s *ClientSuite) TestIsBundle(c *C) {
+	c.Assert(IsBundle(s.path), Equals, true)
+	c.Assert(IsBundle(s.dir), Equals, false)
+	c.Assert(IsBundle(filepath.Join(s.dir, "missing")), Equals, false)
+	c.Assert(IsBundle(fixtures.Basic().One().Packfile().Name()), Equals, false)
+}
+
+func (s *ClientSuite) TestAdvertisedReferences(c *C) {
+	sess, err := s.newSession(c, s.path)
+	c.Assert(err, IsNil)
+	defer sess.Close()
+
+	ar, err := sess.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	c.Assert(*ar.Head, Equals, head)
+	c.Assert(ar.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/master": head,
+	})
+
+	refs, err := ar.AllReferences()
+	c.Assert(err, IsNil)
+	c.Assert(refs[plumbing.HEAD].Target(), Equals, plumbing.Master)
+}
+
+func (s *ClientSuite) TestUploadPack(c *C) {
+	sess, err := s.newSession(c, s.path)
+	c.Assert(err, IsNil)
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, head)
+
+	res, err := sess.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+
+	pack, err := ioutil.ReadAll(res)
+	c.Assert(err, IsNil)
+	c.Assert(pack, DeepEquals, s.pack)
+
+	c.Assert(res.Close(), IsNil)
+	c.Assert(sess.Close(), IsNil)
+}
+
+func (s *ClientSuite) TestUploadPackEmpty(c *C) {
+	sess, err := s.newSession(c, s.path)
+	c.Assert(err, IsNil)
+	defer sess.Close()
+
+	_, err = sess.UploadPack(context.Background(), packp.NewUploadPackRequest())
+	c.Assert(err, Equals, transport.ErrEmptyUploadPackRequest)
+}
+
+func (s *ClientSuite) TestNotFound(c *C) {
+	_, err := s.newSession(c, filepath.Join(s.dir, "missing.bundle"))
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+}
+
+func (s *ClientSuite) TestReceivePack(c *C) {
+	ep, err := transport.NewEndpoint(s.path)
+	c.Assert(err, IsNil)
+
+	_, err = DefaultClient.NewReceivePackSession(ep, nil)
+	c.Assert(err, Equals, ErrReceivePackNotSupported)
+}
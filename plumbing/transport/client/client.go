@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/bundle"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/file"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/git"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
@@ -19,6 +20,10 @@ var Protocols = map[string]transport.Transport{
 	"ssh":   ssh.DefaultClient,
 	"git":   git.DefaultClient,
 	"file":  file.DefaultClient,
+
+	// bundle is used for the file endpoints of git bundle files, unless the
+	// file protocol has been replaced
+	"bundle": bundle.DefaultClient,
 }
 
 // InstallProtocol adds or modifies an existing protocol.
@@ -32,7 +37,8 @@ func InstallProtocol(scheme string, c transport.Transport) {
 }
 
 // NewClient returns the appropriate client among of the set of known protocols:
-// http://, https://, ssh:// and file://.
+// http://, https://, ssh:// and file://. The file endpoints of git bundle
+// files use the "bundle" client, as long as the default file client is used.
 // See `InstallProtocol` to add or modify protocols.
 func NewClient(endpoint *transport.Endpoint) (transport.Transport, error) {
 	f, ok := Protocols[endpoint.Protocol]
@@ -44,5 +50,11 @@ func NewClient(endpoint *transport.Endpoint) (transport.Transport, error) {
 		return nil, fmt.Errorf("malformed client for scheme %q, client is defined as nil", endpoint.Protocol)
 	}
 
+	if f == file.DefaultClient {
+		if b, ok := Protocols["bundle"]; ok && b != nil && bundle.IsBundle(endpoint.Path) {
+			return b, nil
+		}
+	}
+
 	return f, nil
 }
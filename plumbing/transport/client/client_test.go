@@ -2,10 +2,15 @@ package client
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/file"
 
 	. "gopkg.in/check.v1"
 )
@@ -25,6 +30,59 @@ func (s *ClientSuite) TestNewClientSSH(c *C) {
 	c.Assert(output, NotNil)
 }
 
+func (s *ClientSuite) TestNewClientBundle(c *C) {
+	dir, err := ioutil.TempDir("", "client-bundle")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "repo.bundle")
+	err = ioutil.WriteFile(path, []byte("# v2 git bundle\n\n"), 0644)
+	c.Assert(err, IsNil)
+
+	e, err := transport.NewEndpoint(path)
+	c.Assert(err, IsNil)
+
+	output, err := NewClient(e)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, bundle.DefaultClient)
+
+	e, err = transport.NewEndpoint(dir)
+	c.Assert(err, IsNil)
+
+	output, err = NewClient(e)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, file.DefaultClient)
+}
+
+func (s *ClientSuite) TestNewClientBundleInstallProtocol(c *C) {
+	dir, err := ioutil.TempDir("", "client-bundle")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "repo.bundle")
+	err = ioutil.WriteFile(path, []byte("# v2 git bundle\n\n"), 0644)
+	c.Assert(err, IsNil)
+
+	e, err := transport.NewEndpoint(path)
+	c.Assert(err, IsNil)
+
+	defer InstallProtocol("file", file.DefaultClient)
+	custom := &dummyClient{}
+	InstallProtocol("file", custom)
+
+	output, err := NewClient(e)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, custom)
+
+	InstallProtocol("file", file.DefaultClient)
+	defer InstallProtocol("bundle", bundle.DefaultClient)
+	InstallProtocol("bundle", nil)
+
+	output, err = NewClient(e)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, file.DefaultClient)
+}
+
 func (s *ClientSuite) TestNewClientUnknown(c *C) {
 	e, err := transport.NewEndpoint("unknown://github.com/src-d/go-git")
 	c.Assert(err, IsNil)
@@ -175,13 +175,11 @@ func (s *upSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest
 	), nil
 }
 
+// objectsToUpload returns the objects reachable from the wants that are not
+// reachable from the haves. revlist.Objects walks the haves itself, which
+// lets it use the reachability bitmaps of the storage for both sides.
 func (s *upSession) objectsToUpload(req *packp.UploadPackRequest) ([]plumbing.Hash, error) {
-	haves, err := revlist.Objects(s.storer, req.Haves, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return revlist.Objects(s.storer, req.Wants, haves)
+	return revlist.Objects(s.storer, req.Wants, req.Haves)
 }
 
 func (*upSession) setSupportedCapabilities(c *capability.List) error {
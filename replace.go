@@ -0,0 +1,174 @@
+package git
+
+import (
+	"errors"
+	"os"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const graftsFile = "info/grafts"
+
+var (
+	// ErrReplaceExists is returned by Replace when the object is already
+	// replaced.
+	ErrReplaceExists = errors.New("replace reference already exists")
+	// ErrReplaceNotFound is returned by DeleteReplace when the object is not
+	// replaced.
+	ErrReplaceNotFound = errors.New("replace reference not found")
+	// ErrReplaceTypeMismatch is returned by Replace when the objects are of
+	// different types.
+	ErrReplaceTypeMismatch = errors.New("replacement object is of a different type")
+)
+
+// Replace replaces the object old with the object new, creating the replace
+// reference refs/replace/<old>. The replacement is honoured looking up the
+// objects through the Repository if core.useReplaceRefs is enabled. Both
+// objects must exist and be of the same type.
+func (r *Repository) Replace(old, new plumbing.Hash) error {
+	oldObj, err := r.Storer.EncodedObject(plumbing.AnyObject, old)
+	if err != nil {
+		return err
+	}
+
+	newObj, err := r.Storer.EncodedObject(plumbing.AnyObject, new)
+	if err != nil {
+		return err
+	}
+
+	if oldObj.Type() != newObj.Type() {
+		return ErrReplaceTypeMismatch
+	}
+
+	name := replaceRef(old)
+	_, err = r.Storer.Reference(name)
+	if err == nil {
+		return ErrReplaceExists
+	}
+
+	if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(name, new))
+}
+
+// DeleteReplace removes the replacement of the object old.
+func (r *Repository) DeleteReplace(old plumbing.Hash) error {
+	name := replaceRef(old)
+	_, err := r.Storer.Reference(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrReplaceNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.RemoveReference(name)
+}
+
+// Replacements returns all the replace references, refs/replace/<hash>. For
+// more information: https://git-scm.com/docs/git-replace
+func (r *Repository) Replacements() (storer.ReferenceIter, error) {
+	refIter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewReferenceFilteredIter(
+		func(r *plumbing.Reference) bool {
+			return r.Name().IsReplace()
+		}, refIter), nil
+}
+
+func replaceRef(h plumbing.Hash) plumbing.ReferenceName {
+	return plumbing.ReferenceName(storer.ReplaceRefPrefix + h.String())
+}
+
+// SetUseReplaceRefs sets core.useReplaceRefs in the config of the repository,
+// enabling or disabling the replacement of the objects looked up through the
+// Repository.
+func (r *Repository) SetUseReplaceRefs(enabled bool) error {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.UseReplaceRefs = enabled
+	return r.Storer.SetConfig(cfg)
+}
+
+// objectStorer returns the storer used to look up the objects, replacing the
+// objects with a replace reference and the grafted commits if
+// core.useReplaceRefs is enabled. It is built on every call, so the changes of
+// the config and of the replace references made through the Storer are
+// honoured, the callers keep it for the duration of the operation.
+func (r *Repository) objectStorer() (storage.Storer, error) {
+	// the replacements are never honoured if the config cannot be read, so
+	// the objects can still be looked up
+	cfg, err := r.Storer.Config()
+	if err != nil || !cfg.Core.UseReplaceRefs {
+		return r.Storer, nil
+	}
+
+	grafts, err := r.grafts()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := storer.NewReplaceObjectStorer(r.Storer, r.Storer, grafts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objects.Replacements) == 0 && len(grafts) == 0 {
+		return r.Storer, nil
+	}
+
+	return &replaceStorage{Storer: r.Storer, objects: objects}, nil
+}
+
+// grafts returns the grafts of the info/grafts file of the repository, if its
+// storage is based on a filesystem.
+func (r *Repository) grafts() (grafts map[plumbing.Hash][]plumbing.Hash, err error) {
+	s, ok := r.Storer.(interface {
+		Filesystem() billy.Filesystem
+	})
+
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := s.Filesystem().Open(graftsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return storer.DecodeGrafts(f)
+}
+
+// replaceStorage is a storage.Storer looking up the objects through another
+// storer, such as a storer.ReplaceObjectStorer.
+type replaceStorage struct {
+	storage.Storer
+	objects storer.EncodedObjectStorer
+}
+
+func (s *replaceStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	return s.objects.EncodedObject(t, h)
+}
+
+func (s *replaceStorage) HasEncodedObject(h plumbing.Hash) error {
+	return s.objects.HasEncodedObject(h)
+}
@@ -0,0 +1,177 @@
+package git
+
+import (
+	"errors"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type ReplaceSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ReplaceSuite{})
+
+var (
+	replaceHead    = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	replaceParent  = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	replaceInitial = plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+)
+
+func (s *ReplaceSuite) logHashes(c *C, r *Repository) []plumbing.Hash {
+	iter, err := r.Log(&LogOptions{})
+	c.Assert(err, IsNil)
+
+	var hashes []plumbing.Hash
+	c.Assert(iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash)
+		return nil
+	}), IsNil)
+
+	return hashes
+}
+
+func (s *ReplaceSuite) TestReplace(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	err := r.Replace(replaceParent, replaceInitial)
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference("refs/replace/918c48b83bd081e863dbe1b80f8998f058cd8294", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, replaceInitial)
+
+	err = r.Replace(replaceParent, replaceHead)
+	c.Assert(err, Equals, ErrReplaceExists)
+
+	err = r.Replace(replaceHead, plumbing.NewHash("32858aad3c383ed1ff0a0f9bdf231d54a00c9e88"))
+	c.Assert(err, Equals, ErrReplaceTypeMismatch)
+
+	err = r.Replace(replaceHead, plumbing.NewHash("0000000000000000000000000000000000000001"))
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	iter, err := r.Replacements()
+	c.Assert(err, IsNil)
+
+	var refs []*plumbing.Reference
+	c.Assert(iter.ForEach(func(ref *plumbing.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	}), IsNil)
+	c.Assert(refs, HasLen, 1)
+	c.Assert(refs[0].Name(), Equals, ref.Name())
+}
+
+func (s *ReplaceSuite) TestReplaceLog(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	c.Assert(r.Replace(replaceParent, replaceInitial), IsNil)
+	c.Assert(s.logHashes(c, r), HasLen, 8)
+
+	c.Assert(r.SetUseReplaceRefs(true), IsNil)
+	c.Assert(s.logHashes(c, r), DeepEquals, []plumbing.Hash{replaceHead, replaceParent})
+
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, replaceParent)
+	c.Assert(commit.Message, Equals, "Initial commit\n")
+	c.Assert(commit.NumParents(), Equals, 0)
+
+	c.Assert(r.DeleteReplace(replaceParent), IsNil)
+	c.Assert(s.logHashes(c, r), HasLen, 8)
+
+	err = r.DeleteReplace(replaceParent)
+	c.Assert(err, Equals, ErrReplaceNotFound)
+}
+
+func (s *ReplaceSuite) TestGrafts(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	fs := r.Storer.(*filesystem.Storage).Filesystem()
+	err := util.WriteFile(fs, "info/grafts", []byte(
+		"# skip the history of master\n"+
+			replaceHead.String()+" "+replaceInitial.String()+"\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.logHashes(c, r), HasLen, 8)
+
+	c.Assert(r.SetUseReplaceRefs(true), IsNil)
+	c.Assert(s.logHashes(c, r), DeepEquals, []plumbing.Hash{replaceHead, replaceInitial})
+
+	head, err := r.CommitObject(replaceHead)
+	c.Assert(err, IsNil)
+	c.Assert(head.ParentHashes, DeepEquals, []plumbing.Hash{replaceInitial})
+
+	blame, err := Blame(head, "go/example.go")
+	c.Assert(err, IsNil)
+	for _, l := range blame.Lines {
+		c.Assert(l.Hash, Equals, replaceHead)
+	}
+}
+
+// configStorer fails the reads of the config of a storage if err is set.
+type configStorer struct {
+	storage.Storer
+	err error
+}
+
+func (s *configStorer) Config() (*config.Config, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s.Storer.Config()
+}
+
+func (s *ReplaceSuite) TestObjectStorerStorerChanges(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 1)
+
+	err = r.Storer.SetReference(plumbing.NewHashReference(
+		plumbing.ReferenceName("refs/replace/"+replaceParent.String()), replaceInitial,
+	))
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 1)
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.UseReplaceRefs = true
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 0)
+
+	err = r.Storer.RemoveReference(plumbing.ReferenceName("refs/replace/" + replaceParent.String()))
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 1)
+}
+
+func (s *ReplaceSuite) TestObjectStorerUnreadableConfig(c *C) {
+	st := &configStorer{Storer: s.NewRepository(fixtures.Basic().One()).Storer}
+	r, err := Open(st, nil)
+	c.Assert(err, IsNil)
+
+	st.err = errors.New("foo")
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, replaceParent)
+}
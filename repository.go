@@ -15,14 +15,16 @@ import (
 
 	"golang.org/x/crypto/openpgp"
 	"gopkg.in/src-d/go-git.v4/config"
-	"gopkg.in/src-d/go-git.v4/internal/revision"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 
 	"gopkg.in/src-d/go-billy.v4"
@@ -54,6 +56,7 @@ var (
 	ErrIsBareRepository          = errors.New("worktree not available in a bare repository")
 	ErrUnableToResolveCommit     = errors.New("unable to resolve commit")
 	ErrPackedObjectsNotSupported = errors.New("Packed objects not supported")
+	ErrBitmapsNotSupported       = errors.New("Packfile bitmaps not supported")
 )
 
 // Repository represents a git repository
@@ -320,11 +323,40 @@ func dotGitFileToOSFilesystem(path string, fs billy.Filesystem) (bfs billy.Files
 
 	gitdir := strings.Split(line[len(prefix):], "\n")[0]
 	gitdir = strings.TrimSpace(gitdir)
-	if filepath.IsAbs(gitdir) {
-		return osfs.New(gitdir), nil
+	if !filepath.IsAbs(gitdir) {
+		gitdir = fs.Join(path, gitdir)
 	}
 
-	return osfs.New(fs.Join(path, gitdir)), nil
+	return dotGitCommonDirectory(osfs.New(gitdir))
+}
+
+// dotGitCommonDirectory returns the git directory of a linked worktree,
+// sharing everything but its HEAD, index and other per-worktree files with
+// the git directory pointed by its commondir file. If there is no commondir
+// file the given directory is returned.
+func dotGitCommonDirectory(fs billy.Filesystem) (bfs billy.Filesystem, err error) {
+	f, err := fs.Open(dotgit.CommonDirPath)
+	if os.IsNotExist(err) {
+		return fs, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	b, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	commondir := strings.TrimSpace(string(b))
+	if !filepath.IsAbs(commondir) {
+		commondir = filepath.Join(fs.Root(), commondir)
+	}
+
+	return dotgit.NewRepositoryFilesystem(fs, osfs.New(commondir)), nil
 }
 
 // PlainClone a repository into the path with the given options, isBare defines
@@ -1044,34 +1076,176 @@ func (r *Repository) PushContext(ctx context.Context, o *PushOptions) error {
 
 // Log returns the commit history from the given LogOptions.
 func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
-	fn := commitIterFunc(o.Order)
+	excluded, err := r.excludedCommits(o.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := commitIterFunc(o.Order, excluded)
 	if fn == nil {
 		return nil, fmt.Errorf("invalid Order=%v", o.Order)
 	}
 
-	var (
-		it  object.CommitIter
-		err error
-	)
-	if o.All {
-		it, err = r.logAll(fn)
-	} else {
-		it, err = r.log(o.From, fn)
+	if o.FirstParent {
+		fn = func(c *object.Commit) object.CommitIter {
+			return object.NewCommitFirstParentIter(c, excluded, nil)
+		}
+	}
+
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	// path limited logs walk the history simplified for the paths
+	var simplifier *object.HistorySimplifier
+	if o.FileName != nil || len(o.PathSpecs) > 0 {
+		simplifier = object.NewHistorySimplifier(s, logPathFilter(o.FileName, o.PathSpecs))
+		s = &replaceStorage{Storer: s, objects: simplifier}
+	}
+
+	var it object.CommitIter
+	switch {
+	case isTopoOrder(o.Order) && !o.FirstParent && (o.All || len(o.Include) > 0):
+		it, err = r.logTopo(s, o, excluded)
+	case o.All:
+		it, err = object.NewCommitAllIter(s, fn)
+	case len(o.Include) > 0:
+		it, err = r.logInclude(s, o.From, o.Include, o.Order, excluded, fn)
+	default:
+		it, err = r.log(s, o.From, fn)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	if o.FileName != nil {
-		// for `git log --all` also check parent (if the next commit comes from the real parent)
-		it = r.logWithFile(*o.FileName, it, o.All)
+	if simplifier != nil {
+		it = simplifier.Commits(it)
+	}
+
+	if isValid, isLimit := logFilters(o); isValid != nil || isLimit != nil {
+		it = object.NewCommitFilterIterFromIter(it, isValid, isLimit)
+	}
+
+	if o.Skip > 0 || o.MaxCount > 0 {
+		it = object.NewCommitLimitIterFromIter(it, o.Skip, o.MaxCount)
 	}
 
 	return it, nil
 }
 
-func (r *Repository) log(from plumbing.Hash, commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
+// logPathFilter returns a filter matching the file name and the paths of
+// the path specs, or any file under them.
+func logPathFilter(fileName *string, pathSpecs []string) func(string) bool {
+	var prefixes []string
+	for _, spec := range pathSpecs {
+		spec = strings.Trim(path.Clean(spec), "/")
+		if spec == "." || spec == "" {
+			return func(string) bool { return true }
+		}
+
+		prefixes = append(prefixes, spec)
+	}
+
+	return func(name string) bool {
+		if fileName != nil && name == *fileName {
+			return true
+		}
+
+		for _, prefix := range prefixes {
+			if name == prefix || strings.HasPrefix(name, prefix+"/") {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// logFilters returns the filters of the commits selected by the given
+// options, isValid, and of the commit the log stops at, isLimit. Both are nil
+// if the options select all the commits.
+func logFilters(o *LogOptions) (isValid, isLimit object.CommitFilter) {
+	var filters []object.CommitFilter
+
+	// the commits are sorted by committer time, so the walk can stop at the
+	// first commit older than Since
+	sorted := o.Order == LogOrderCommitterTime && !o.All && !o.FirstParent
+	if o.Since != nil {
+		since := *o.Since
+		if sorted {
+			isLimit = func(c *object.Commit) bool {
+				return c.Committer.When.Before(since)
+			}
+		} else {
+			filters = append(filters, func(c *object.Commit) bool {
+				return !c.Committer.When.Before(since)
+			})
+		}
+	}
+
+	if o.Until != nil {
+		until := *o.Until
+		filters = append(filters, func(c *object.Commit) bool {
+			return !c.Committer.When.After(until)
+		})
+	}
+
+	if o.Author != nil {
+		filters = append(filters, func(c *object.Commit) bool {
+			return o.Author.MatchString(c.Author.String())
+		})
+	}
+
+	if o.Committer != nil {
+		filters = append(filters, func(c *object.Commit) bool {
+			return o.Committer.MatchString(c.Committer.String())
+		})
+	}
+
+	if len(o.Grep) > 0 {
+		filters = append(filters, func(c *object.Commit) bool {
+			for _, re := range o.Grep {
+				if re.MatchString(c.Message) {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	if o.NoMerges {
+		filters = append(filters, func(c *object.Commit) bool {
+			return c.NumParents() <= 1
+		})
+	}
+
+	if o.MergesOnly {
+		filters = append(filters, func(c *object.Commit) bool {
+			return c.NumParents() > 1
+		})
+	}
+
+	if len(filters) > 0 {
+		isValid = func(c *object.Commit) bool {
+			for _, filter := range filters {
+				if !filter(c) {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return isValid, isLimit
+}
+
+func (r *Repository) log(s storer.EncodedObjectStorer, from plumbing.Hash,
+	commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
+
 	h := from
 	if from == plumbing.ZeroHash {
 		head, err := r.Head()
@@ -1082,42 +1256,183 @@ func (r *Repository) log(from plumbing.Hash, commitIterFunc func(*object.Commit)
 		h = head.Hash()
 	}
 
-	commit, err := r.CommitObject(h)
+	commit, err := object.GetCommit(s, h)
 	if err != nil {
 		return nil, err
 	}
 	return commitIterFunc(commit), nil
 }
 
-func (r *Repository) logAll(commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
-	return object.NewCommitAllIter(r.Storer, commitIterFunc)
+// logInclude returns an iterator of the commits reachable from several
+// commits, from and the include ones, visiting each commit once.
+func (r *Repository) logInclude(s storer.EncodedObjectStorer, from plumbing.Hash, include []plumbing.Hash,
+	order LogOrder, seen map[plumbing.Hash]bool, commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
+
+	commits, err := logCommits(s, from, include)
+	if err != nil {
+		return nil, err
+	}
+
+	var iters []object.CommitIter
+	for _, commit := range commits {
+		iters = append(iters, commitIterFunc(commit))
+	}
+
+	return newCommitMultiIter(iters, seen, order == LogOrderCommitterTime), nil
+}
+
+// logTopo returns an iterator of the commits reachable from all the
+// references, or from and the include commits, in topological order.
+func (r *Repository) logTopo(s storage.Storer, o *LogOptions,
+	excluded map[plumbing.Hash]bool) (object.CommitIter, error) {
+
+	var commits []*object.Commit
+	var err error
+	if o.All {
+		commits, err = logAllCommits(s)
+	} else {
+		commits, err = logCommits(s, o.From, o.Include)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Order == LogOrderAuthorTime {
+		return object.NewCommitAuthorTimeIter(commits, excluded), nil
+	}
+
+	return object.NewCommitTopoIter(commits, excluded), nil
+}
+
+// logCommits returns the commits from and include, skipping from if zero.
+func logCommits(s storer.EncodedObjectStorer, from plumbing.Hash,
+	include []plumbing.Hash) ([]*object.Commit, error) {
+
+	if !from.IsZero() {
+		include = append([]plumbing.Hash{from}, include...)
+	}
+
+	var commits []*object.Commit
+	for _, h := range include {
+		commit, err := object.GetCommit(s, h)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// logAllCommits returns the commits HEAD and the references point to,
+// skipping the references to other objects.
+func logAllCommits(s storage.Storer) ([]*object.Commit, error) {
+	refs, err := s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		ref, err := storer.ResolveReference(s, ref.Name())
+		if err == plumbing.ErrReferenceNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		commit, err := object.GetCommit(s, ref.Hash())
+		if err == plumbing.ErrObjectNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		commits = append(commits, commit)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := storer.ResolveReference(s, plumbing.HEAD)
+	if err == nil {
+		commit, err := object.GetCommit(s, head.Hash())
+		if err == nil {
+			commits = append([]*object.Commit{commit}, commits...)
+		}
+	}
+
+	return commits, nil
+}
+
+// excludedCommits returns the commits reachable from the given ones, or nil
+// if none is given.
+func (r *Repository) excludedCommits(exclude []plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	if len(exclude) == 0 {
+		return nil, nil
+	}
+
+	var commits []*object.Commit
+	for _, h := range exclude {
+		c, err := r.CommitObject(h)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, c)
+	}
+
+	return reachableCommits(commits)
 }
 
-func (*Repository) logWithFile(fileName string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
-	return object.NewCommitFileIterFromIter(fileName, commitIter, checkParent)
+// isTopoOrder returns whether the commits are sorted topologically with the
+// given order, which requires walking all of them at once.
+func isTopoOrder(order LogOrder) bool {
+	return order == LogOrderTopo || order == LogOrderAuthorTime
 }
 
-func commitIterFunc(order LogOrder) func(c *object.Commit) object.CommitIter {
+func commitIterFunc(order LogOrder, excluded map[plumbing.Hash]bool) func(c *object.Commit) object.CommitIter {
+	var ignore []plumbing.Hash
+	for h := range excluded {
+		ignore = append(ignore, h)
+	}
+
 	switch order {
 	case LogOrderDefault:
 		return func(c *object.Commit) object.CommitIter {
-			return object.NewCommitPreorderIter(c, nil, nil)
+			return object.NewCommitPreorderIter(c, excluded, nil)
 		}
 	case LogOrderDFS:
 		return func(c *object.Commit) object.CommitIter {
-			return object.NewCommitPreorderIter(c, nil, nil)
+			return object.NewCommitPreorderIter(c, excluded, nil)
 		}
 	case LogOrderDFSPost:
 		return func(c *object.Commit) object.CommitIter {
-			return object.NewCommitPostorderIter(c, nil)
+			return object.NewCommitPostorderIter(c, ignore)
 		}
 	case LogOrderBSF:
 		return func(c *object.Commit) object.CommitIter {
-			return object.NewCommitIterBSF(c, nil, nil)
+			return object.NewCommitIterBSF(c, excluded, nil)
 		}
 	case LogOrderCommitterTime:
 		return func(c *object.Commit) object.CommitIter {
-			return object.NewCommitIterCTime(c, nil, nil)
+			return object.NewCommitIterCTime(c, excluded, nil)
+		}
+	case LogOrderTopo:
+		return func(c *object.Commit) object.CommitIter {
+			return object.NewCommitTopoIter([]*object.Commit{c}, excluded)
+		}
+	case LogOrderAuthorTime:
+		return func(c *object.Commit) object.CommitIter {
+			return object.NewCommitAuthorTimeIter([]*object.Commit{c}, excluded)
 		}
 	}
 	return nil
@@ -1190,88 +1505,138 @@ func (r *Repository) Notes() (storer.ReferenceIter, error) {
 // TreeObject return a Tree with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned
 func (r *Repository) TreeObject(h plumbing.Hash) (*object.Tree, error) {
-	return object.GetTree(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(s, h)
 }
 
 // TreeObjects returns an unsorted TreeIter with all the trees in the repository
 func (r *Repository) TreeObjects() (*object.TreeIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.TreeObject)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewTreeIter(r.Storer, iter), nil
+	iter, err := s.IterEncodedObjects(plumbing.TreeObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewTreeIter(s, iter), nil
 }
 
 // CommitObject return a Commit with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) CommitObject(h plumbing.Hash) (*object.Commit, error) {
-	return object.GetCommit(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetCommit(s, h)
 }
 
 // CommitObjects returns an unsorted CommitIter with all the commits in the repository.
 func (r *Repository) CommitObjects() (object.CommitIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.CommitObject)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewCommitIter(r.Storer, iter), nil
+	iter, err := s.IterEncodedObjects(plumbing.CommitObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewCommitIter(s, iter), nil
 }
 
 // BlobObject returns a Blob with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) BlobObject(h plumbing.Hash) (*object.Blob, error) {
-	return object.GetBlob(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetBlob(s, h)
 }
 
 // BlobObjects returns an unsorted BlobIter with all the blobs in the repository.
 func (r *Repository) BlobObjects() (*object.BlobIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.BlobObject)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.IterEncodedObjects(plumbing.BlobObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewBlobIter(r.Storer, iter), nil
+	return object.NewBlobIter(s, iter), nil
 }
 
 // TagObject returns a Tag with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned. This method only returns
 // annotated Tags, no lightweight Tags.
 func (r *Repository) TagObject(h plumbing.Hash) (*object.Tag, error) {
-	return object.GetTag(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTag(s, h)
 }
 
 // TagObjects returns a unsorted TagIter that can step through all of the annotated
 // tags in the repository.
 func (r *Repository) TagObjects() (*object.TagIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.TagObject)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewTagIter(r.Storer, iter), nil
+	iter, err := s.IterEncodedObjects(plumbing.TagObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewTagIter(s, iter), nil
 }
 
 // Object returns an Object with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) Object(t plumbing.ObjectType, h plumbing.Hash) (object.Object, error) {
-	obj, err := r.Storer.EncodedObject(t, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.EncodedObject(t, h)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.DecodeObject(r.Storer, obj)
+	return object.DecodeObject(s, obj)
 }
 
 // Objects returns an unsorted ObjectIter with all the objects in the repository.
 func (r *Repository) Objects() (*object.ObjectIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.AnyObject)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.IterEncodedObjects(plumbing.AnyObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewObjectIter(r.Storer, iter), nil
+	return object.NewObjectIter(s, iter), nil
 }
 
 // Head returns the reference where HEAD is pointing to.
@@ -1306,153 +1671,21 @@ func (r *Repository) Worktree() (*Worktree, error) {
 	return &Worktree{r: r, Filesystem: r.wt}, nil
 }
 
-func countTrue(vals ...bool) int {
-	sum := 0
-	for _, v := range vals {
-		if v {
-			sum++
-		}
-	}
-	return sum
-}
-
 // ResolveRevision resolves revision to corresponding hash. It will always
 // resolve to a commit hash, not a tree or annotated tag.
 //
 // Implemented resolvers : HEAD, branch, tag, heads/branch, refs/heads/branch,
-// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug})
+// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug}),
+// abbreviated hashes and the rest of the revisions supported by ResolveObject.
 func (r *Repository) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash, error) {
-	p := revision.NewParserFromString(string(rev))
-
-	items, err := p.Parse()
-
+	obj, err := r.ResolveObject(rev)
 	if err != nil {
-		return nil, err
+		return &plumbing.ZeroHash, err
 	}
 
-	var commit *object.Commit
-
-	for _, item := range items {
-		switch item.(type) {
-		case revision.Ref:
-			revisionRef := item.(revision.Ref)
-			var ref *plumbing.Reference
-			var hashCommit, refCommit, tagCommit *object.Commit
-			var rErr, hErr, tErr error
-
-			for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
-				ref, err = storer.ResolveReference(r.Storer, plumbing.ReferenceName(fmt.Sprintf(rule, revisionRef)))
-
-				if err == nil {
-					break
-				}
-			}
-
-			if ref != nil {
-				tag, tObjErr := r.TagObject(ref.Hash())
-				if tObjErr != nil {
-					tErr = tObjErr
-				} else {
-					tagCommit, tErr = tag.Commit()
-				}
-				refCommit, rErr = r.CommitObject(ref.Hash())
-			} else {
-				rErr = plumbing.ErrReferenceNotFound
-				tErr = plumbing.ErrReferenceNotFound
-			}
-
-			maybeHash := plumbing.NewHash(string(revisionRef)).String() == string(revisionRef)
-			if maybeHash {
-				hashCommit, hErr = r.CommitObject(plumbing.NewHash(string(revisionRef)))
-			} else {
-				hErr = plumbing.ErrReferenceNotFound
-			}
-
-			isTag := tErr == nil
-			isCommit := rErr == nil
-			isHash := hErr == nil
-
-			switch {
-			case countTrue(isTag, isCommit, isHash) > 1:
-				return &plumbing.ZeroHash, fmt.Errorf(`refname "%s" is ambiguous`, revisionRef)
-			case isTag:
-				commit = tagCommit
-			case isCommit:
-				commit = refCommit
-			case isHash:
-				commit = hashCommit
-			default:
-				return &plumbing.ZeroHash, plumbing.ErrReferenceNotFound
-			}
-		case revision.CaretPath:
-			depth := item.(revision.CaretPath).Depth
-
-			if depth == 0 {
-				break
-			}
-
-			iter := commit.Parents()
-
-			c, err := iter.Next()
-
-			if err != nil {
-				return &plumbing.ZeroHash, err
-			}
-
-			if depth == 1 {
-				commit = c
-
-				break
-			}
-
-			c, err = iter.Next()
-
-			if err != nil {
-				return &plumbing.ZeroHash, err
-			}
-
-			commit = c
-		case revision.TildePath:
-			for i := 0; i < item.(revision.TildePath).Depth; i++ {
-				c, err := commit.Parents().Next()
-
-				if err != nil {
-					return &plumbing.ZeroHash, err
-				}
-
-				commit = c
-			}
-		case revision.CaretReg:
-			history := object.NewCommitPreorderIter(commit, nil, nil)
-
-			re := item.(revision.CaretReg).Regexp
-			negate := item.(revision.CaretReg).Negate
-
-			var c *object.Commit
-
-			err := history.ForEach(func(hc *object.Commit) error {
-				if !negate && re.MatchString(hc.Message) {
-					c = hc
-					return storer.ErrStop
-				}
-
-				if negate && !re.MatchString(hc.Message) {
-					c = hc
-					return storer.ErrStop
-				}
-
-				return nil
-			})
-			if err != nil {
-				return &plumbing.ZeroHash, err
-			}
-
-			if c == nil {
-				return &plumbing.ZeroHash, fmt.Errorf(`No commit message match regexp : "%s"`, re.String())
-			}
-
-			commit = c
-		}
+	commit, err := peelCommit(obj)
+	if err != nil {
+		return &plumbing.ZeroHash, err
 	}
 
 	return &commit.Hash, nil
@@ -1465,6 +1698,10 @@ type RepackConfig struct {
 	// OnlyDeletePacksOlderThan if set to non-zero value
 	// selects only objects older than the time provided.
 	OnlyDeletePacksOlderThan time.Time
+	// WriteBitmap writes a reachability bitmap for the new packfile, used to
+	// speed up the computation of the objects to send in fetches and pushes.
+	// The storer must implement bitmap.Storer.
+	WriteBitmap bool
 }
 
 func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
@@ -1485,6 +1722,12 @@ func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 		return err
 	}
 
+	if cfg.WriteBitmap {
+		if err := r.writePackBitmap(nh); err != nil {
+			return err
+		}
+	}
+
 	// Delete old packs.
 	for _, h := range hs {
 		// Skip if new hash is the same as an old one.
@@ -1500,6 +1743,44 @@ func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 	return nil
 }
 
+// writePackBitmap writes the bitmap of the given packfile, with a bitmap for
+// every commit pointed by a reference.
+func (r *Repository) writePackBitmap(pack plumbing.Hash) error {
+	bs, ok := r.Storer.(bitmap.Storer)
+	if !ok {
+		return ErrBitmapsNotSupported
+	}
+
+	var tips []plumbing.Hash
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			tips = append(tips, ref.Hash())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	idx, err := bs.ObjectPackIndex(pack)
+	if err != nil {
+		return err
+	}
+
+	bi, err := revlist.BuildBitmap(r.Storer, idx, tips)
+	if err != nil {
+		return err
+	}
+
+	return bs.SetPackBitmap(bi)
+}
+
 // createNewObjectPack is a helper for RepackObjects taking care
 // of creating a new pack. It is used so the the PackfileWriter
 // deferred close has the right scope.
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -1646,6 +1647,185 @@ func (s *RepositorySuite) TestLogFileWithOtherParamsPass(c *C) {
 	c.Assert(iterErr, Equals, io.EOF)
 }
 
+func (s *RepositorySuite) logHashes(c *C, r *Repository, o *LogOptions) []string {
+	iter, err := r.Log(o)
+	c.Assert(err, IsNil)
+	defer iter.Close()
+
+	var hashes []string
+	c.Assert(iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash.String()[:7])
+		return nil
+	}), IsNil)
+
+	return hashes
+}
+
+func (s *RepositorySuite) TestLogSinceUntil(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	since := time.Date(2015, 3, 31, 13, 50, 0, 0, time.FixedZone("", 2*60*60))
+	until := time.Date(2015, 3, 31, 13, 47, 0, 0, time.FixedZone("", 2*60*60))
+
+	for _, order := range []LogOrder{LogOrderDefault, LogOrderBSF, LogOrderCommitterTime} {
+		c.Assert(s.logHashes(c, r, &LogOptions{Order: order, Since: &since}),
+			DeepEquals, []string{"6ecf0ef", "918c48b", "af2d6a6"})
+	}
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Until: &until}),
+		DeepEquals, []string{"35e8510", "b029517", "b8e471f"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{All: true, Order: LogOrderCommitterTime, Since: &since}),
+		DeepEquals, []string{"6ecf0ef", "e8d3ffa", "918c48b", "af2d6a6"})
+
+	from := until.Add(2 * time.Minute)
+	c.Assert(s.logHashes(c, r, &LogOptions{Since: &until, Until: &from}),
+		DeepEquals, []string{"1669dce", "a5b8b09"})
+}
+
+func (s *RepositorySuite) TestLogAuthorCommitterGrep(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Author: regexp.MustCompile("Ripolles")}),
+		DeepEquals, []string{"b8e471f"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Committer: regexp.MustCompile(`^Máximo Cuadros <`)}),
+		DeepEquals, []string{"b029517", "a5b8b09"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Grep: []*regexp.Regexp{
+		regexp.MustCompile("^some"),
+		regexp.MustCompile("changelog"),
+	}}), DeepEquals, []string{"918c48b", "af2d6a6", "a5b8b09", "b8e471f"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{
+		Grep:   []*regexp.Regexp{regexp.MustCompile("changelog")},
+		Author: regexp.MustCompile("Cuadros"),
+	}), DeepEquals, []string{"a5b8b09"})
+}
+
+func (s *RepositorySuite) TestLogMerges(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	c.Assert(s.logHashes(c, r, &LogOptions{NoMerges: true}), DeepEquals, []string{
+		"6ecf0ef", "918c48b", "af2d6a6", "35e8510", "b029517", "b8e471f",
+	})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{MergesOnly: true}),
+		DeepEquals, []string{"1669dce", "a5b8b09"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{NoMerges: true, MergesOnly: true}), HasLen, 0)
+}
+
+func (s *RepositorySuite) TestLogFirstParent(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	expected := []string{"6ecf0ef", "918c48b", "af2d6a6", "1669dce", "35e8510", "b029517"}
+	for _, order := range []LogOrder{LogOrderDefault, LogOrderDFSPost, LogOrderCommitterTime} {
+		c.Assert(s.logHashes(c, r, &LogOptions{Order: order, FirstParent: true}),
+			DeepEquals, expected)
+	}
+
+	c.Assert(s.logHashes(c, r, &LogOptions{FirstParent: true, MergesOnly: true}),
+		DeepEquals, []string{"1669dce"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{
+		FirstParent: true,
+		Exclude:     []plumbing.Hash{plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea")},
+	}), DeepEquals, expected[:3])
+
+	c.Assert(s.logHashes(c, r, &LogOptions{
+		FirstParent: true,
+		Order:       LogOrderCommitterTime,
+		From:        plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		Include:     []plumbing.Hash{plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")},
+		MaxCount:    3,
+	}), DeepEquals, []string{"6ecf0ef", "e8d3ffa", "918c48b"})
+}
+
+func (s *RepositorySuite) TestLogSkipMaxCount(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Skip: 1, MaxCount: 2}),
+		DeepEquals, []string{"918c48b", "af2d6a6"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{MaxCount: 1, NoMerges: true, Skip: 3}),
+		DeepEquals, []string{"35e8510"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Skip: 8}), HasLen, 0)
+	c.Assert(s.logHashes(c, r, &LogOptions{MaxCount: 20}), HasLen, 8)
+}
+
+func (s *RepositorySuite) TestLogPathSpecs(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"vendor"}}),
+		DeepEquals, []string{"6ecf0ef"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"go", "json/"}}),
+		DeepEquals, []string{"918c48b", "af2d6a6"})
+
+	fileName := "vendor/foo.go"
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"./json"}, FileName: &fileName}),
+		DeepEquals, []string{"6ecf0ef", "af2d6a6"})
+
+	// the same commits as `git log --all -- <paths>` in any order, the merges
+	// bringing CHANGELOG from their second parents are not shown
+	for order := LogOrderDefault; order <= LogOrderAuthorTime; order++ {
+		c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"CHANGELOG"}, All: true, Order: order}),
+			DeepEquals, []string{"b8e471f"}, Commentf("order: %d", order))
+
+		c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"CHANGELOG", "json"}, All: true, Order: order}),
+			DeepEquals, []string{"af2d6a6", "b8e471f"}, Commentf("order: %d", order))
+
+		c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"vendor", "go"}, All: true, Order: order}),
+			DeepEquals, []string{"6ecf0ef", "918c48b"}, Commentf("order: %d", order))
+	}
+
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"go/example"}}), HasLen, 0)
+}
+
+func (s *RepositorySuite) TestLogTopoOrder(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Order: LogOrderTopo}), DeepEquals, []string{
+		"6ecf0ef", "918c48b", "af2d6a6", "1669dce", "a5b8b09", "b8e471f", "35e8510", "b029517",
+	})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Order: LogOrderAuthorTime}), DeepEquals, []string{
+		"6ecf0ef", "918c48b", "af2d6a6", "1669dce", "a5b8b09", "35e8510", "b8e471f", "b029517",
+	})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{Order: LogOrderAuthorTime, All: true}), DeepEquals, []string{
+		"6ecf0ef", "e8d3ffa", "918c48b", "af2d6a6", "1669dce", "a5b8b09", "35e8510", "b8e471f", "b029517",
+	})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{
+		Order:   LogOrderTopo,
+		From:    plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		Include: []plumbing.Hash{plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")},
+		Exclude: []plumbing.Hash{plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")},
+	}), DeepEquals, []string{"6ecf0ef", "e8d3ffa", "918c48b"})
+}
+
+func (s *RepositorySuite) TestLogHistorySimplification(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	// a5b8b09 has the same tree as its second parent, so it is hidden
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"."}, Order: LogOrderCommitterTime}), DeepEquals, []string{
+		"6ecf0ef", "918c48b", "af2d6a6", "1669dce", "35e8510", "b8e471f", "b029517",
+	})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"."}, Order: LogOrderTopo}), DeepEquals, []string{
+		"6ecf0ef", "918c48b", "af2d6a6", "1669dce", "b8e471f", "35e8510", "b029517",
+	})
+
+	fileName := "CHANGELOG"
+	c.Assert(s.logHashes(c, r, &LogOptions{FileName: &fileName}), DeepEquals, []string{"b8e471f"})
+
+	c.Assert(s.logHashes(c, r, &LogOptions{PathSpecs: []string{"CHANGELOG", "json"}, All: true, Order: LogOrderTopo}),
+		DeepEquals, []string{"af2d6a6", "b8e471f"})
+}
+
 func (s *RepositorySuite) TestCommit(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -2463,9 +2643,10 @@ func (s *RepositorySuite) TestResolveRevisionWithErrors(c *C) {
 	}
 
 	for rev, rerr := range datas {
-		_, err := r.ResolveRevision(plumbing.Revision(rev))
+		h, err := r.ResolveRevision(plumbing.Revision(rev))
 
 		c.Assert(err.Error(), Equals, rerr)
+		c.Assert(*h, Equals, plumbing.ZeroHash)
 	}
 }
 
@@ -2534,6 +2715,32 @@ func (s *RepositorySuite) TestRepackObjectsWithNoDelete(c *C) {
 	s.testRepackObjects(c, time.Unix(0, 1), 3)
 }
 
+func (s *RepositorySuite) TestRepackObjectsWithBitmap(c *C) {
+	if testing.Short() {
+		c.Skip("skipping test in short mode.")
+	}
+
+	srcFs := fixtures.ByTag("unpacked").One().DotGit()
+	sto := filesystem.NewStorage(srcFs, cache.NewObjectLRUDefault())
+
+	r, err := Open(sto, srcFs)
+	c.Assert(err, IsNil)
+
+	err = r.RepackObjects(&RepackConfig{WriteBitmap: true})
+	c.Assert(err, IsNil)
+
+	pbs, err := sto.PackBitmaps()
+	c.Assert(err, IsNil)
+	c.Assert(pbs, HasLen, 1)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+
+	b, err := pbs[0].Commit(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(b.Count() > 0, Equals, true)
+}
+
 func ExecuteOnPath(c *C, path string, cmds ...string) error {
 	for _, cmd := range cmds {
 		err := executeOnPath(path, cmd)
@@ -0,0 +1,913 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/internal/revision"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+var (
+	// ErrNoUpstream is returned resolving @{upstream} or @{push} for a branch
+	// without a remote configured.
+	ErrNoUpstream = errors.New("no upstream configured for branch")
+	// ErrReflogNotFound is returned resolving the @{<n>}, @{<date>} and
+	// @{-<n>} revisions when the reference has no reflog, or the storage
+	// does not keep reflogs.
+	ErrReflogNotFound = errors.New("reflog not found")
+	// ErrNoCurrentBranch is returned resolving a revision relative to the
+	// current branch when HEAD is detached.
+	ErrNoCurrentBranch = errors.New("HEAD does not point to a branch")
+)
+
+// ErrAmbiguous is returned when an abbreviated hash matches more than one
+// object.
+type ErrAmbiguous struct {
+	// Prefix is the abbreviated hash.
+	Prefix string
+	// Candidates are the hashes of the objects matching the prefix.
+	Candidates []plumbing.Hash
+}
+
+func (e *ErrAmbiguous) Error() string {
+	return fmt.Sprintf("short object ID %s is ambiguous", e.Prefix)
+}
+
+// ResolveObject resolves the revision to the object it names, following the
+// syntax described in gitrevisions(7), as git rev-parse does. Unlike
+// ResolveRevision, the object is not peeled to a commit, so HEAD:README
+// resolves to a blob and v1.0^{tree} to a tree.
+//
+// Supported: references, full and abbreviated hashes, ~<n>, ^<n>,
+// ^{<type>}, ^{}, ^{/<regexp>}, :/<regexp>, <rev>:<path>, :<path>,
+// :<n>:<path>, @{<n>}, @{<date>}, @{-<n>}, @{upstream} and @{push}. The
+// reflog revisions are only supported by storages based on a filesystem.
+func (r *Repository) ResolveObject(rev plumbing.Revision) (object.Object, error) {
+	items, err := revision.NewParserFromString(string(rev)).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		obj  object.Object
+		name string
+	)
+
+	for _, item := range items {
+		switch item := item.(type) {
+		case revision.Ref:
+			name = string(item)
+			obj, err = r.resolveName(name)
+		case revision.TildePath:
+			obj, err = r.resolveAncestor(obj, item.Depth)
+		case revision.CaretPath:
+			obj, err = r.resolveParent(obj, item.Depth)
+		case revision.CaretType:
+			obj, err = peelObject(obj, item.ObjectType)
+		case revision.CaretReg:
+			obj, err = r.resolveCommitMessage(obj, item.Regexp, item.Negate)
+		case revision.ColonReg:
+			obj, err = r.resolveAnyCommitMessage(item.Regexp, item.Negate)
+		case revision.ColonPath:
+			obj, err = r.resolvePath(obj, item.Path, 0)
+		case revision.ColonStagePath:
+			obj, err = r.resolvePath(nil, item.Path, item.Stage)
+		case revision.AtReflog:
+			obj, err = r.resolveReflog(name, func(entries []*reflogEntry) (plumbing.Hash, error) {
+				if item.Depth >= len(entries) {
+					return plumbing.ZeroHash, fmt.Errorf("log only has %d entries", len(entries))
+				}
+
+				return entries[len(entries)-1-item.Depth].New, nil
+			})
+		case revision.AtDate:
+			obj, err = r.resolveReflog(name, func(entries []*reflogEntry) (plumbing.Hash, error) {
+				return reflogAtDate(entries, item.Date), nil
+			})
+		case revision.AtCheckout:
+			obj, err = r.resolveCheckout(item.Depth)
+		case revision.AtUpstream:
+			obj, err = r.resolveUpstream(name, false)
+		case revision.AtPush:
+			obj, err = r.resolveUpstream(name, true)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if obj == nil {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	return obj, nil
+}
+
+// resolveName resolves a reference name or a full or abbreviated hash.
+func (r *Repository) resolveName(name string) (object.Object, error) {
+	var refObj, hashObj object.Object
+
+	ref, err := r.expandRef(name)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	if ref != nil {
+		ref, err = storer.ResolveReference(r.Storer, ref.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		refObj, err = r.Object(plumbing.AnyObject, ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(name) >= minShortHashLength && len(name) <= 40 && isHex(name) {
+		hashes, err := r.hashesWithPrefix(name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(hashes) > 1 && refObj == nil:
+			return nil, &ErrAmbiguous{Prefix: name, Candidates: hashes}
+		case len(hashes) == 1:
+			hashObj, err = r.Object(plumbing.AnyObject, hashes[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch {
+	case refObj != nil && hashObj != nil:
+		return nil, fmt.Errorf(`refname "%s" is ambiguous`, name)
+	case refObj != nil:
+		return refObj, nil
+	case hashObj != nil:
+		return hashObj, nil
+	default:
+		return nil, plumbing.ErrReferenceNotFound
+	}
+}
+
+// expandRef returns the reference named by name, following the rules git
+// uses to expand reference names: the name itself, refs/<name>,
+// refs/tags/<name>, refs/heads/<name>, refs/remotes/<name> and
+// refs/remotes/<name>/HEAD. The returned reference is not resolved.
+func (r *Repository) expandRef(name string) (*plumbing.Reference, error) {
+	for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
+		ref, err := r.Storer.Reference(plumbing.ReferenceName(fmt.Sprintf(rule, name)))
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		return ref, err
+	}
+
+	return nil, plumbing.ErrReferenceNotFound
+}
+
+// peelObject peels the object until an object of the given type, as
+// <rev>^{<type>} does. "object" returns the object itself, "tag" requires
+// the object to be a tag and an empty type peels the tags, as <rev>^{} does.
+func peelObject(obj object.Object, t string) (object.Object, error) {
+	switch t {
+	case "object":
+		return obj, nil
+	case "tag":
+		if obj.Type() != plumbing.TagObject {
+			return nil, fmt.Errorf("object %s is a %s, not a tag", obj.ID(), obj.Type())
+		}
+
+		return obj, nil
+	case "":
+		return peelTags(obj)
+	}
+
+	target, err := plumbing.ParseObjectType(t)
+	if err != nil {
+		return nil, err
+	}
+
+	for obj.Type() != target {
+		switch o := obj.(type) {
+		case *object.Tag:
+			obj, err = o.Object()
+			if err != nil {
+				return nil, err
+			}
+		case *object.Commit:
+			if target != plumbing.TreeObject {
+				return nil, fmt.Errorf("object %s is a commit, not a %s", o.Hash, target)
+			}
+
+			return o.Tree()
+		default:
+			return nil, fmt.Errorf("object %s is a %s, not a %s", obj.ID(), obj.Type(), target)
+		}
+	}
+
+	return obj, nil
+}
+
+// peelTags returns the object pointed by the tags.
+func peelTags(obj object.Object) (object.Object, error) {
+	for {
+		tag, ok := obj.(*object.Tag)
+		if !ok {
+			return obj, nil
+		}
+
+		var err error
+		obj, err = tag.Object()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// peelCommit returns the commit of the object, peeling the tags.
+func peelCommit(obj object.Object) (*object.Commit, error) {
+	obj, err := peelObject(obj, "commit")
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.(*object.Commit), nil
+}
+
+// resolveAncestor returns the ancestor n generations back following the
+// first parents, as <rev>~<n> does.
+func (r *Repository) resolveAncestor(obj object.Object, n int) (object.Object, error) {
+	c, err := peelCommit(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		c, err = c.Parents().Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// resolveParent returns the nth parent of the commit, or the commit itself
+// if n is zero, as <rev>^<n> does.
+func (r *Repository) resolveParent(obj object.Object, n int) (object.Object, error) {
+	c, err := peelCommit(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return c, nil
+	}
+
+	return c.Parent(n - 1)
+}
+
+// resolveCommitMessage returns the youngest commit reachable from the object
+// whose message matches, or doesn't match if negate is true, as
+// <rev>^{/<regexp>} does.
+func (r *Repository) resolveCommitMessage(obj object.Object, re *regexp.Regexp,
+	negate bool) (object.Object, error) {
+
+	c, err := peelCommit(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *object.Commit
+	err = object.NewCommitIterCTime(c, nil, nil).ForEach(func(c *object.Commit) error {
+		if re.MatchString(c.Message) != negate {
+			found = c
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf(`No commit message match regexp : "%s"`, re.String())
+	}
+
+	return found, nil
+}
+
+// resolveAnyCommitMessage returns the youngest commit, reachable from any
+// reference, whose message matches, as :/<regexp> does.
+func (r *Repository) resolveAnyCommitMessage(re *regexp.Regexp, negate bool) (object.Object, error) {
+	iter, err := r.Log(&LogOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var found *object.Commit
+	err = iter.ForEach(func(c *object.Commit) error {
+		if re.MatchString(c.Message) == negate {
+			return nil
+		}
+
+		if found == nil || c.Committer.When.After(found.Committer.When) {
+			found = c
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf(`No commit message match regexp : "%s"`, re.String())
+	}
+
+	return found, nil
+}
+
+// resolvePath returns the object at the given path of the tree of obj, as
+// <rev>:<path> does, or the object of the index entry if obj is nil, as
+// :<n>:<path> does.
+func (r *Repository) resolvePath(obj object.Object, path string, stage int) (object.Object, error) {
+	path = strings.TrimPrefix(path, "./")
+	if obj == nil {
+		return r.resolveIndexPath(path, stage)
+	}
+
+	o, err := peelObject(obj, "tree")
+	if err != nil {
+		return nil, err
+	}
+
+	tree := o.(*object.Tree)
+	if path = strings.Trim(path, "/"); path == "" {
+		return tree, nil
+	}
+
+	e, err := tree.FindEntry(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Object(plumbing.AnyObject, e.Hash)
+}
+
+func (r *Repository) resolveIndexPath(path string, stage int) (object.Object, error) {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Name == path && e.Stage == index.Stage(stage) {
+			return r.Object(plumbing.AnyObject, e.Hash)
+		}
+	}
+
+	return nil, index.ErrEntryNotFound
+}
+
+// currentBranch returns the name of the branch HEAD points to.
+func (r *Repository) currentBranch() (plumbing.ReferenceName, error) {
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+
+	if head.Type() != plumbing.SymbolicReference {
+		return "", ErrNoCurrentBranch
+	}
+
+	return head.Target(), nil
+}
+
+// reflogRefName returns the name of the reference whose reflog is used for
+// the name of a revision, the current branch if name is empty.
+func (r *Repository) reflogRefName(name string) (plumbing.ReferenceName, error) {
+	if name == "" {
+		return r.currentBranch()
+	}
+
+	ref, err := r.expandRef(name)
+	if err != nil {
+		return "", err
+	}
+
+	return ref.Name(), nil
+}
+
+// resolveReflog returns the object selected by fn from the reflog of the
+// reference, as <ref>@{<n>} and <ref>@{<date>} do.
+func (r *Repository) resolveReflog(name string,
+	fn func([]*reflogEntry) (plumbing.Hash, error)) (object.Object, error) {
+
+	refName, err := r.reflogRefName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.reflog(refName)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := fn(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Object(plumbing.AnyObject, h)
+}
+
+// resolveCheckout returns the nth branch checked out before the current
+// one, as @{-<n>} does.
+func (r *Repository) resolveCheckout(n int) (object.Object, error) {
+	entries, err := r.reflog(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	const prefix = "checkout: moving from "
+	for i := len(entries) - 1; i >= 0; i-- {
+		msg := entries[i].Message
+		if !strings.HasPrefix(msg, prefix) {
+			continue
+		}
+
+		if n--; n > 0 {
+			continue
+		}
+
+		from := msg[len(prefix):]
+		if i := strings.LastIndex(from, " to "); i >= 0 {
+			from = from[:i]
+		}
+
+		return r.resolveName(from)
+	}
+
+	return nil, plumbing.ErrReferenceNotFound
+}
+
+// resolveUpstream returns the commit of the remote-tracking branch that the
+// branch name, or the current branch if empty, is merged from, as
+// @{upstream} does, or pushed to, as @{push} does.
+func (r *Repository) resolveUpstream(name string, push bool) (object.Object, error) {
+	branch := plumbing.ReferenceName(name)
+	if name == "" || name == "HEAD" {
+		var err error
+		if branch, err = r.currentBranch(); err != nil {
+			return nil, err
+		}
+	} else if !branch.IsBranch() {
+		branch = plumbing.NewBranchReferenceName(name)
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := upstreamBranch(cfg, branch, push)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := storer.ResolveReference(r.Storer, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Object(plumbing.AnyObject, ref.Hash())
+}
+
+// upstreamBranch returns the reference of the upstream of the given branch,
+// or the reference the branch is pushed to if push is true.
+func upstreamBranch(cfg *config.Config, branch plumbing.ReferenceName,
+	push bool) (plumbing.ReferenceName, error) {
+
+	var remote string
+	merge := branch
+	b, ok := cfg.Branches[branch.Short()]
+	if ok {
+		remote = b.Remote
+		if !push && b.Merge != "" {
+			merge = b.Merge
+		}
+	}
+
+	if push {
+		raw := cfg.Raw.Section("branch").Subsection(branch.Short())
+		for _, name := range []string{
+			raw.Option("pushRemote"),
+			cfg.Raw.Section("remote").Option("pushDefault"),
+		} {
+			if name != "" {
+				remote = name
+				break
+			}
+		}
+	} else if !ok || b.Merge == "" {
+		return "", ErrNoUpstream
+	}
+
+	if remote == "" {
+		return "", ErrNoUpstream
+	}
+
+	if remote == "." {
+		return merge, nil
+	}
+
+	rc, ok := cfg.Remotes[remote]
+	if !ok {
+		return "", ErrRemoteNotFound
+	}
+
+	for _, spec := range rc.Fetch {
+		if spec.Match(merge) {
+			return spec.Dst(merge), nil
+		}
+	}
+
+	return "", ErrNoUpstream
+}
+
+// reflogEntry is an entry of the reflog of a reference.
+type reflogEntry struct {
+	Old, New  plumbing.Hash
+	Committer object.Signature
+	Message   string
+}
+
+// reflog returns the reflog entries of the reference, from the oldest to the
+// newest, read from the logs directory of storages based on a filesystem.
+func (r *Repository) reflog(name plumbing.ReferenceName) (entries []*reflogEntry, err error) {
+	s, ok := r.Storer.(interface {
+		Filesystem() billy.Filesystem
+	})
+
+	if !ok {
+		return nil, ErrReflogNotFound
+	}
+
+	f, err := s.Filesystem().Open(s.Filesystem().Join("logs", name.String()))
+	if os.IsNotExist(err) {
+		return nil, ErrReflogNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return decodeReflog(f)
+}
+
+// decodeReflog decodes the lines of a reflog file, with the format
+// "<old> <new> <name> <<email>> <timestamp> <timezone>\t<message>".
+func decodeReflog(r io.Reader) ([]*reflogEntry, error) {
+	var entries []*reflogEntry
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			continue
+		}
+
+		if len(line) < 83 || line[40] != ' ' || line[81] != ' ' {
+			return nil, fmt.Errorf("malformed reflog line: %q", line)
+		}
+
+		e := &reflogEntry{
+			Old: plumbing.NewHash(line[:40]),
+			New: plumbing.NewHash(line[41:81]),
+		}
+
+		sig := line[82:]
+		if i := strings.IndexByte(sig, '\t'); i >= 0 {
+			sig, e.Message = sig[:i], sig[i+1:]
+		}
+
+		e.Committer.Decode([]byte(sig))
+		entries = append(entries, e)
+	}
+
+	return entries, s.Err()
+}
+
+// reflogAtDate returns the value the reference had at the given date, or its
+// oldest known value if the date is before the first entry.
+func reflogAtDate(entries []*reflogEntry, date time.Time) plumbing.Hash {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Committer.When.After(date) {
+			return entries[i].New
+		}
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash
+	}
+
+	return entries[0].Old
+}
+
+// RevisionRange is a set of commits, the commits reachable from any of the
+// Include commits and not reachable from any of the Exclude commits.
+type RevisionRange struct {
+	Include []plumbing.Hash
+	Exclude []plumbing.Hash
+}
+
+// ResolveRange resolves the revisions to a range of commits, as git rev-list
+// does. Every revision can be a commit, ^<rev> excluding the commits
+// reachable from <rev>, <a>..<b> including the commits reachable from <b>
+// but not from <a>, or <a>...<b> including the commits reachable from any of
+// them but not from both. An omitted side of .. or ... is HEAD.
+//
+// The range is used with the Include and Exclude fields of LogOptions.
+func (r *Repository) ResolveRange(revs ...plumbing.Revision) (*RevisionRange, error) {
+	rr := &RevisionRange{}
+	for _, rev := range revs {
+		s := string(rev)
+		switch {
+		case strings.HasPrefix(s, "^"):
+			h, err := r.resolveCommitHash(s[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			rr.Exclude = append(rr.Exclude, h)
+		case rangeSeparator(s) >= 0:
+			a, b, symmetric := splitRange(s)
+			ha, hb, err := r.resolveRangeSides(a, b)
+			if err != nil {
+				return nil, err
+			}
+
+			if !symmetric {
+				rr.Include = append(rr.Include, hb)
+				rr.Exclude = append(rr.Exclude, ha)
+				break
+			}
+
+			bases, err := r.mergeBases(ha, hb)
+			if err != nil {
+				return nil, err
+			}
+
+			rr.Include = append(rr.Include, ha, hb)
+			rr.Exclude = append(rr.Exclude, bases...)
+		default:
+			h, err := r.resolveCommitHash(s)
+			if err != nil {
+				return nil, err
+			}
+
+			rr.Include = append(rr.Include, h)
+		}
+	}
+
+	return rr, nil
+}
+
+// rangeSeparator returns the position of the .. of the range s, -1 if it is
+// not a range. The dots inside the ^{...} and @{...} groups and in the path
+// after a : are not separators.
+func rangeSeparator(s string) int {
+	var depth int
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '{' && i > 0 && (s[i-1] == '^' || s[i-1] == '@'):
+			depth++
+		case s[i] == '}' && depth > 0:
+			depth--
+		case depth > 0:
+		case s[i] == ':':
+			return -1
+		case strings.HasPrefix(s[i:], ".."):
+			return i
+		}
+	}
+
+	return -1
+}
+
+// splitRange returns the sides of the range s, and whether it is a symmetric
+// one, <a>...<b>.
+func splitRange(s string) (a, b string, symmetric bool) {
+	i := rangeSeparator(s)
+	if strings.HasPrefix(s[i:], "...") {
+		return s[:i], s[i+3:], true
+	}
+
+	return s[:i], s[i+2:], false
+}
+
+func (r *Repository) resolveRangeSides(a, b string) (plumbing.Hash, plumbing.Hash, error) {
+	if a == "" {
+		a = "HEAD"
+	}
+
+	if b == "" {
+		b = "HEAD"
+	}
+
+	ha, err := r.resolveCommitHash(a)
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.ZeroHash, err
+	}
+
+	hb, err := r.resolveCommitHash(b)
+	return ha, hb, err
+}
+
+func (r *Repository) resolveCommitHash(rev string) (plumbing.Hash, error) {
+	obj, err := r.ResolveObject(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	c, err := peelCommit(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return c.Hash, nil
+}
+
+const (
+	mergeBaseParent1 = 1 << iota
+	mergeBaseParent2
+	mergeBaseStale
+	mergeBaseResult
+)
+
+// mergeBases returns the best common ancestors of the commits a and b, the
+// common ancestors that are not ancestors of other common ancestors, as git
+// merge-base does.
+func (r *Repository) mergeBases(a, b plumbing.Hash) ([]plumbing.Hash, error) {
+	if a == b {
+		return []plumbing.Hash{a}, nil
+	}
+
+	ca, err := r.CommitObject(a)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := r.CommitObject(b)
+	if err != nil {
+		return nil, err
+	}
+
+	common, _, err := paintDownToCommon(ca, []*object.Commit{cb})
+	if err != nil {
+		return nil, err
+	}
+
+	// a common ancestor found first may still be an ancestor of another one
+	// if the commit dates are skewed
+	var bases []plumbing.Hash
+	for i, c := range common {
+		var others []*object.Commit
+		for j, o := range common {
+			if i != j {
+				others = append(others, o)
+			}
+		}
+
+		if len(others) != 0 {
+			_, flags, err := paintDownToCommon(c, others)
+			if err != nil {
+				return nil, err
+			}
+
+			if flags[c.Hash]&mergeBaseParent2 != 0 {
+				continue
+			}
+		}
+
+		bases = append(bases, c.Hash)
+	}
+
+	return bases, nil
+}
+
+// mergeBaseEntry is a commit queued walking the history in
+// paintDownToCommon, stale if it was reachable from both sides when queued.
+type mergeBaseEntry struct {
+	commit *object.Commit
+	stale  bool
+}
+
+// paintDownToCommon walks the history from the commit one and the commits
+// others at once, by commit date, painting every commit with the sides it is
+// reachable from. The walk stops when all the queued commits are reachable
+// from both sides. It returns the commits reachable from both sides found
+// first, and the paint of the walked commits.
+func paintDownToCommon(one *object.Commit, others []*object.Commit) ([]*object.Commit, map[plumbing.Hash]int, error) {
+	heap := binaryheap.NewWith(func(a, b interface{}) int {
+		if a.(*mergeBaseEntry).commit.Committer.When.Before(b.(*mergeBaseEntry).commit.Committer.When) {
+			return 1
+		}
+		return -1
+	})
+
+	flags := make(map[plumbing.Hash]int)
+	var nonStale int
+	push := func(c *object.Commit, f int) {
+		flags[c.Hash] |= f
+		e := &mergeBaseEntry{commit: c, stale: flags[c.Hash]&mergeBaseStale != 0}
+		if !e.stale {
+			nonStale++
+		}
+
+		heap.Push(e)
+	}
+
+	push(one, mergeBaseParent1)
+	for _, c := range others {
+		push(c, mergeBaseParent2)
+	}
+
+	var common []*object.Commit
+	for nonStale > 0 {
+		v, _ := heap.Pop()
+		e := v.(*mergeBaseEntry)
+		if !e.stale {
+			nonStale--
+		}
+
+		c := e.commit
+		f := flags[c.Hash] & (mergeBaseParent1 | mergeBaseParent2 | mergeBaseStale)
+		if f == mergeBaseParent1|mergeBaseParent2 {
+			if flags[c.Hash]&mergeBaseResult == 0 {
+				flags[c.Hash] |= mergeBaseResult
+				common = append(common, c)
+			}
+
+			f |= mergeBaseStale
+		}
+
+		err := c.Parents().ForEach(func(p *object.Commit) error {
+			if flags[p.Hash]&f != f {
+				push(p, f)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return common, flags, nil
+}
+
+// reachableCommits returns the hashes of the commits reachable from the given
+// commits, including themselves.
+func reachableCommits(commits []*object.Commit) (map[plumbing.Hash]bool, error) {
+	reachable := make(map[plumbing.Hash]bool)
+	for _, c := range commits {
+		err := object.NewCommitPreorderIter(c, reachable, nil).ForEach(func(c *object.Commit) error {
+			reachable[c.Hash] = true
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return reachable, nil
+}
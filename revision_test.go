@@ -0,0 +1,318 @@
+package git
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type RevisionSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RevisionSuite{})
+
+func (s *RevisionSuite) TestResolveObject(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	datas := map[string]string{
+		"HEAD":                            "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"6ecf0ef":                         "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"6ecf0ef~1":                       "918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"HEAD^{}":                         "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"v1.0.0^{commit}":                 "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD^{tree}":                     "a8d315b2b1c615d43042c3a62402b8a54288cf5c",
+		"HEAD^{object}":                   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD:":                           "a8d315b2b1c615d43042c3a62402b8a54288cf5c",
+		"HEAD:go":                         "a39771a7651f97faf5c72e08224d857fc35133db",
+		"HEAD:go/example.go":              "880cd14280f4b9b6ed3986d6671f907d7cc2a198",
+		"HEAD~1:vendor":                   "",
+		"branch:CHANGELOG":                "d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+		":go/example.go":                  "880cd14280f4b9b6ed3986d6671f907d7cc2a198",
+		":0:go/example.go":                "880cd14280f4b9b6ed3986d6671f907d7cc2a198",
+		":/some code":                     "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		":/^some code\n":                  "918c48b83bd081e863dbe1b80f8998f058cd8294",
+		":/!-vendor":                      "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"HEAD@{2}":                        "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"HEAD@{2016-12-01T20:55:00Z}":     "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"HEAD@{2016-01-01T00:00:00Z}":     "0000000000000000000000000000000000000000",
+		"@{-1}":                           "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"@{u}":                            "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"branch@{upstream}":               "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"branch@{push}":                   "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"refs/heads/master~2^{/binary}":   "35e85108805c84807bc66a02d91535e1e24b38b9",
+		"HEAD~3^2:CHANGELOG":              "d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+		"918c48b83bd081e863dbe1b80f8998f": "918c48b83bd081e863dbe1b80f8998f058cd8294",
+	}
+
+	for rev, hash := range datas {
+		obj, err := r.ResolveObject(plumbing.Revision(rev))
+		if hash == "" || hash == plumbing.ZeroHash.String() {
+			c.Assert(err, NotNil, Commentf("while checking %s", rev))
+			continue
+		}
+
+		c.Assert(err, IsNil, Commentf("while checking %s", rev))
+		c.Check(obj.ID().String(), Equals, hash, Commentf("while checking %s", rev))
+	}
+}
+
+func (s *RevisionSuite) TestResolveObjectTypes(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	obj, err := r.ResolveObject("HEAD:go/example.go")
+	c.Assert(err, IsNil)
+	c.Assert(obj.Type(), Equals, plumbing.BlobObject)
+
+	obj, err = r.ResolveObject("HEAD^{tree}")
+	c.Assert(err, IsNil)
+	c.Assert(obj.Type(), Equals, plumbing.TreeObject)
+
+	_, err = r.ResolveObject("HEAD^{tag}")
+	c.Assert(err, NotNil)
+
+	_, err = r.ResolveObject("HEAD^{blob}")
+	c.Assert(err, NotNil)
+
+	_, err = r.ResolveObject("HEAD:go/example.go~1")
+	c.Assert(err, NotNil)
+
+	_, err = r.ResolveObject("HEAD:missing")
+	c.Assert(err, Equals, object.ErrEntryNotFound)
+}
+
+func (s *RevisionSuite) TestResolveObjectAnnotatedTag(c *C) {
+	r := s.NewRepository(fixtures.ByURL("https://github.com/git-fixtures/tags.git").One())
+
+	obj, err := r.ResolveObject("annotated-tag")
+	c.Assert(err, IsNil)
+	c.Assert(obj.Type(), Equals, plumbing.TagObject)
+
+	tag, err := r.ResolveObject("annotated-tag^{tag}")
+	c.Assert(err, IsNil)
+	c.Assert(tag.ID(), Equals, obj.ID())
+
+	obj, err = r.ResolveObject("annotated-tag^{}")
+	c.Assert(err, IsNil)
+	c.Assert(obj.ID().String(), Equals, "f7b877701fbf855b44c0a9e86f3fdce2c298b07f")
+
+	obj, err = r.ResolveObject("annotated-tag^{tree}")
+	c.Assert(err, IsNil)
+	c.Assert(obj.Type(), Equals, plumbing.TreeObject)
+}
+
+func (s *RevisionSuite) TestResolveObjectUpstreamErrors(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	delete(cfg.Branches, "master")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.ResolveObject("master@{u}")
+	c.Assert(err, Equals, ErrNoUpstream)
+
+	err = r.CreateBranch(&config.Branch{Name: "master", Remote: ".", Merge: "refs/heads/branch"})
+	c.Assert(err, IsNil)
+
+	obj, err := r.ResolveObject("@{u}")
+	c.Assert(err, IsNil)
+	c.Assert(obj.ID().String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	mem, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = mem.ResolveObject("HEAD@{1}")
+	c.Assert(err, NotNil)
+}
+
+func (s *RevisionSuite) TestResolveObjectAmbiguous(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	a, _ := storeCollidingBlobs(c, r)
+
+	_, err = r.ResolveObject(plumbing.Revision(a.String()[:minShortHashLength]))
+	c.Assert(err, FitsTypeOf, &ErrAmbiguous{})
+	c.Assert(err.(*ErrAmbiguous).Candidates, HasLen, 2)
+
+	obj, err := r.ResolveObject(plumbing.Revision(a.String()[:10]))
+	c.Assert(err, IsNil)
+	c.Assert(obj.ID(), Equals, a)
+}
+
+func (s *RevisionSuite) TestResolveRange(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	master := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	branch := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	base := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	rr, err := r.ResolveRange("master..branch")
+	c.Assert(err, IsNil)
+	c.Assert(rr, DeepEquals, &RevisionRange{
+		Include: []plumbing.Hash{branch},
+		Exclude: []plumbing.Hash{master},
+	})
+
+	rr, err = r.ResolveRange("branch..")
+	c.Assert(err, IsNil)
+	c.Assert(rr, DeepEquals, &RevisionRange{
+		Include: []plumbing.Hash{master},
+		Exclude: []plumbing.Hash{branch},
+	})
+
+	rr, err = r.ResolveRange("master...branch")
+	c.Assert(err, IsNil)
+	c.Assert(rr, DeepEquals, &RevisionRange{
+		Include: []plumbing.Hash{master, branch},
+		Exclude: []plumbing.Hash{base},
+	})
+
+	rr, err = r.ResolveRange("HEAD", "^HEAD~2")
+	c.Assert(err, IsNil)
+	c.Assert(rr, DeepEquals, &RevisionRange{
+		Include: []plumbing.Hash{master},
+		Exclude: []plumbing.Hash{plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")},
+	})
+
+	_, err = r.ResolveRange("missing..HEAD")
+	c.Assert(err, NotNil)
+
+	rr, err = r.ResolveRange("HEAD^{/vendor..tuff}")
+	c.Assert(err, IsNil)
+	c.Assert(rr, DeepEquals, &RevisionRange{
+		Include: []plumbing.Hash{master},
+	})
+}
+
+func (s *RevisionSuite) TestRangeSeparator(c *C) {
+	for rev, expected := range map[string]int{
+		"HEAD":                -1,
+		"a..b":                1,
+		"a...b":               1,
+		"..b":                 0,
+		"HEAD^{/fix..typo}":   -1,
+		"HEAD^{/a..b}..c":     12,
+		"@{1.day.ago}..HEAD":  12,
+		"HEAD:dir/a..b":       -1,
+		":/fix..typo":         -1,
+		"HEAD~2..HEAD:a..b":   6,
+		"master@{u}...master": 10,
+	} {
+		c.Assert(rangeSeparator(rev), Equals, expected, Commentf("revision: %s", rev))
+	}
+}
+
+func (s *RevisionSuite) logRange(c *C, r *Repository, order LogOrder, revs ...plumbing.Revision) []string {
+	rr, err := r.ResolveRange(revs...)
+	c.Assert(err, IsNil)
+
+	iter, err := r.Log(&LogOptions{
+		Order:   order,
+		Include: rr.Include,
+		Exclude: rr.Exclude,
+	})
+	c.Assert(err, IsNil)
+
+	var hashes []string
+	c.Assert(iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash.String())
+		return nil
+	}), IsNil)
+
+	return hashes
+}
+
+func (s *RevisionSuite) TestLogRange(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	for _, order := range []LogOrder{
+		LogOrderDFS, LogOrderDFSPost, LogOrderBSF, LogOrderCommitterTime,
+	} {
+		c.Assert(s.logRange(c, r, order, "master..branch"), DeepEquals, []string{
+			"e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		})
+
+		c.Assert(s.logRange(c, r, order, "master...branch"), HasLen, 2)
+		c.Assert(s.logRange(c, r, order, "HEAD~3^2", "^HEAD~3^1"), DeepEquals, []string{
+			"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+			"b8e471f58bcbca63b07bda20e428190409c2db47",
+		})
+
+		c.Assert(s.logRange(c, r, order, "master", "branch"), HasLen, 9)
+	}
+
+	c.Assert(s.logRange(c, r, LogOrderCommitterTime, "master...branch"), DeepEquals, []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881",
+	})
+
+	iter, err := r.Log(&LogOptions{
+		All:     true,
+		Exclude: []plumbing.Hash{plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")},
+	})
+	c.Assert(err, IsNil)
+
+	count := 0
+	c.Assert(iter.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	}), IsNil)
+	c.Assert(count, Equals, 2)
+}
+
+func (s *RevisionSuite) TestMergeBases(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	commit := func(when int64, parents ...plumbing.Hash) plumbing.Hash {
+		sig := object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(when, 0)}
+		obj := r.Storer.NewEncodedObject()
+		c.Assert((&object.Commit{
+			Author:       sig,
+			Committer:    sig,
+			Message:      "foo\n",
+			ParentHashes: parents,
+		}).Encode(obj), IsNil)
+
+		h, err := r.Storer.SetEncodedObject(obj)
+		c.Assert(err, IsNil)
+		return h
+	}
+
+	// a criss-cross merge, a2 and b2 merge a1 and b1 in both directions
+	root := commit(1)
+	a1 := commit(2, root)
+	b1 := commit(3, root)
+	a2 := commit(4, a1, b1)
+	b2 := commit(5, b1, a1)
+
+	// x is older than its parent y, so y is found first as a common ancestor
+	y := commit(6, root)
+	x := commit(1, y)
+	a3 := commit(7, x, y)
+	b3 := commit(8, x, y)
+
+	for _, t := range []struct {
+		a, b     plumbing.Hash
+		expected []plumbing.Hash
+	}{
+		{a1, a1, []plumbing.Hash{a1}},
+		{a1, a2, []plumbing.Hash{a1}},
+		{a2, a1, []plumbing.Hash{a1}},
+		{a1, b1, []plumbing.Hash{root}},
+		{a2, b2, []plumbing.Hash{b1, a1}},
+		{a3, b3, []plumbing.Hash{x}},
+	} {
+		bases, err := r.mergeBases(t.a, t.b)
+		c.Assert(err, IsNil)
+		c.Assert(bases, DeepEquals, t.expected)
+	}
+}
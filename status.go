@@ -26,9 +26,14 @@ func (s Status) IsUntracked(path string) bool {
 	return ok && stat.Worktree == Untracked
 }
 
-// IsClean returns true if all the files are in Unmodified status.
+// IsClean returns true if all the files are in Unmodified status, or are
+// ignored.
 func (s Status) IsClean() bool {
 	for _, status := range s {
+		if status.Worktree == Ignored {
+			continue
+		}
+
 		if status.Worktree != Unmodified || status.Staging != Unmodified {
 			return false
 		}
@@ -45,7 +50,7 @@ func (s Status) String() string {
 		}
 
 		if status.Staging == Renamed {
-			path = fmt.Sprintf("%s -> %s", path, status.Extra)
+			path = fmt.Sprintf("%s -> %s", status.Extra, path)
 		}
 
 		fmt.Fprintf(buf, "%c%c %s\n", status.Staging, status.Worktree, path)
@@ -76,4 +81,5 @@ const (
 	Renamed            StatusCode = 'R'
 	Copied             StatusCode = 'C'
 	UpdatedButUnmerged StatusCode = 'U'
+	Ignored            StatusCode = '!'
 )
@@ -3,6 +3,8 @@ package dotgit
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -44,6 +46,9 @@ var (
 	ErrIdxNotFound = errors.New("idx file not found")
 	// ErrPackfileNotFound is returned by Packfile when the packfile is not found
 	ErrPackfileNotFound = errors.New("packfile not found")
+	// ErrBitmapNotFound is returned by ObjectPackBitmap when the packfile has
+	// no bitmap file
+	ErrBitmapNotFound = errors.New("bitmap file not found")
 	// ErrConfigNotFound is returned by Config when the config is not found
 	ErrConfigNotFound = errors.New("config file not found")
 	// ErrPackedRefsDuplicatedRef is returned when a duplicated reference is
@@ -171,6 +176,11 @@ func (d *DotGit) Index() (billy.File, error) {
 	return d.fs.Open(indexPath)
 }
 
+// IndexStat returns a os.FileInfo of the index file
+func (d *DotGit) IndexStat() (os.FileInfo, error) {
+	return d.fs.Stat(indexPath)
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
@@ -296,6 +306,37 @@ func (d *DotGit) ObjectPackIdx(hash plumbing.Hash) (billy.File, error) {
 	return d.objectPackOpen(hash, `idx`)
 }
 
+// ObjectPackBitmap returns a fs.File of the bitmap file for a given packfile,
+// ErrBitmapNotFound is returned if the packfile has no bitmap
+func (d *DotGit) ObjectPackBitmap(hash plumbing.Hash) (billy.File, error) {
+	err := d.hasPack(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := d.fs.Open(d.objectPackPath(hash, `bitmap`))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBitmapNotFound
+		}
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ObjectPackBitmapWriter returns a file pointer for writing the bitmap file
+// of a given packfile
+func (d *DotGit) ObjectPackBitmapWriter(hash plumbing.Hash) (billy.File, error) {
+	err := d.hasPack(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.fs.Create(d.objectPackPath(hash, `bitmap`))
+}
+
 func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) error {
 	d.cleanPackList()
 
@@ -314,6 +355,12 @@ func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) er
 	if err != nil {
 		return err
 	}
+
+	err = d.fs.Remove(d.objectPackPath(hash, `bitmap`))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	return d.fs.Remove(d.objectPackPath(hash, `idx`))
 }
 
@@ -404,6 +451,52 @@ func (d *DotGit) forEachObjectHash(fun func(plumbing.Hash) error) error {
 	return nil
 }
 
+// ObjectsWithPrefix returns the hashes of the objects found under the
+// .git/objects/ directory starting with the given prefix. Only the directory
+// of the first byte of the prefix is read.
+func (d *DotGit) ObjectsWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	if len(prefix) == 0 || d.options.ExclusiveAccess {
+		objects, err := d.Objects()
+		if err != nil {
+			return nil, err
+		}
+
+		var hashes []plumbing.Hash
+		for _, h := range objects {
+			if bytes.HasPrefix(h[:], prefix) {
+				hashes = append(hashes, h)
+			}
+		}
+
+		return hashes, nil
+	}
+
+	base := hex.EncodeToString(prefix[:1])
+	files, err := d.fs.ReadDir(d.fs.Join(objectsPath, base))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	for _, f := range files {
+		h := plumbing.NewHash(base + f.Name())
+		if h.IsZero() {
+			// Ignore files with badly-formatted names.
+			continue
+		}
+
+		if bytes.HasPrefix(h[:], prefix) {
+			hashes = append(hashes, h)
+		}
+	}
+
+	return hashes, nil
+}
+
 func (d *DotGit) cleanObjectList() {
 	d.objectMap = nil
 	d.objectList = nil
@@ -1055,7 +1148,7 @@ func (d *DotGit) Alternates() ([]*DotGit, error) {
 			// Remove the first ../
 			relpath := filepath.Join(strings.Split(slashPath, "/")[1:]...)
 			normalPath := filepath.FromSlash(relpath)
-			path = filepath.Join(d.fs.Root(), normalPath)
+			path = filepath.Join(d.objectsRoot(), normalPath)
 		}
 		fs := osfs.New(filepath.Dir(path))
 		alternates = append(alternates, New(fs))
@@ -1068,6 +1161,16 @@ func (d *DotGit) Alternates() ([]*DotGit, error) {
 	return alternates, nil
 }
 
+// objectsRoot returns the root of the git directory holding the objects,
+// which is the common one for linked worktrees.
+func (d *DotGit) objectsRoot() string {
+	if fs, ok := d.fs.(*RepositoryFilesystem); ok {
+		return fs.CommonDir().Root()
+	}
+
+	return d.fs.Root()
+}
+
 // Fs returns the underlying filesystem of the DotGit folder.
 func (d *DotGit) Fs() billy.Filesystem {
 	return d.fs
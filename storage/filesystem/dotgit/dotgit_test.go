@@ -590,6 +590,19 @@ func testObjects(c *C, fs billy.Filesystem, dir *DotGit) {
 	c.Assert(hashes[0].String(), Equals, "0097821d427a3c3385898eb13b50dcbc8702b8a3")
 	c.Assert(hashes[1].String(), Equals, "01d5fa556c33743006de7e76e67a2dfcd994ca04")
 	c.Assert(hashes[2].String(), Equals, "03db8e1fbe133a480f2867aac478fd866686d69e")
+
+	hashes, err = dir.ObjectsWithPrefix([]byte{0x03, 0xdb})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 1)
+	c.Assert(hashes[0].String(), Equals, "03db8e1fbe133a480f2867aac478fd866686d69e")
+
+	hashes, err = dir.ObjectsWithPrefix([]byte{0x03, 0xdc})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 0)
+
+	hashes, err = dir.ObjectsWithPrefix(nil)
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 187)
 }
 
 func (s *SuiteDotGit) TestObjectsNoFolder(c *C) {
@@ -0,0 +1,170 @@
+package dotgit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	// CommonDirPath is the file of a linked worktree git directory
+	// containing the path to the git directory shared by all the worktrees.
+	CommonDirPath = "commondir"
+	// WorktreesPath is the directory of the shared git directory containing
+	// the git directories of the linked worktrees.
+	WorktreesPath = "worktrees"
+
+	branchesPath       = "branches"
+	descriptionPath    = "description"
+	hooksPath          = "hooks"
+	infoPath           = "info"
+	logsPath           = "logs"
+	remotesPath        = "remotes"
+	sparseCheckoutPath = "info/sparse-checkout"
+)
+
+// commonPaths are the top level entries of a git directory shared by all
+// the worktrees of a repository.
+var commonPaths = map[string]bool{
+	branchesPath:    true,
+	configPath:      true,
+	descriptionPath: true,
+	hooksPath:       true,
+	infoPath:        true,
+	logsPath:        true,
+	modulePath:      true,
+	objectsPath:     true,
+	packedRefsPath:  true,
+	refsPath:        true,
+	remotesPath:     true,
+	shallowPath:     true,
+	WorktreesPath:   true,
+}
+
+// worktreePaths are the paths under the common entries that belong to each
+// worktree.
+var worktreePaths = []string{
+	"logs/HEAD",
+	"logs/refs/bisect",
+	"logs/refs/rewritten",
+	"logs/refs/worktree",
+	"refs/bisect",
+	"refs/rewritten",
+	"refs/worktree",
+	sparseCheckoutPath,
+}
+
+// RepositoryFilesystem is the git directory of a linked worktree, it routes
+// the paths shared by all the worktrees, such as objects or branches, to the
+// common git directory, and the ones specific to the worktree, such as HEAD
+// or the index, to the git directory of the worktree.
+type RepositoryFilesystem struct {
+	dotGitFs       billy.Filesystem
+	commonDotGitFs billy.Filesystem
+}
+
+// NewRepositoryFilesystem returns the git directory of a linked worktree
+// given its own directory, usually .git/worktrees/<name> in the main
+// repository, and the common one.
+func NewRepositoryFilesystem(dotGitFs, commonDotGitFs billy.Filesystem) *RepositoryFilesystem {
+	return &RepositoryFilesystem{
+		dotGitFs:       dotGitFs,
+		commonDotGitFs: commonDotGitFs,
+	}
+}
+
+// CommonDir returns the git directory shared by all the worktrees.
+func (fs *RepositoryFilesystem) CommonDir() billy.Filesystem {
+	return fs.commonDotGitFs
+}
+
+func (fs *RepositoryFilesystem) mapToRepositoryFsByPath(path string) billy.Filesystem {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, p := range worktreePaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return fs.dotGitFs
+		}
+	}
+
+	root := strings.Split(path, "/")[0]
+	if commonPaths[root] || strings.HasPrefix(root, tmpPackedRefsPrefix) {
+		return fs.commonDotGitFs
+	}
+
+	return fs.dotGitFs
+}
+
+func (fs *RepositoryFilesystem) Create(filename string) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(filename).Create(filename)
+}
+
+func (fs *RepositoryFilesystem) Open(filename string) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(filename).Open(filename)
+}
+
+func (fs *RepositoryFilesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(filename).OpenFile(filename, flag, perm)
+}
+
+func (fs *RepositoryFilesystem) Stat(filename string) (os.FileInfo, error) {
+	return fs.mapToRepositoryFsByPath(filename).Stat(filename)
+}
+
+func (fs *RepositoryFilesystem) Rename(oldpath, newpath string) error {
+	from := fs.mapToRepositoryFsByPath(oldpath)
+	if to := fs.mapToRepositoryFsByPath(newpath); to != from {
+		return fmt.Errorf("cannot rename %q to %q: different git directories", oldpath, newpath)
+	}
+
+	return from.Rename(oldpath, newpath)
+}
+
+func (fs *RepositoryFilesystem) Remove(filename string) error {
+	return fs.mapToRepositoryFsByPath(filename).Remove(filename)
+}
+
+func (fs *RepositoryFilesystem) Join(elem ...string) string {
+	return fs.dotGitFs.Join(elem...)
+}
+
+func (fs *RepositoryFilesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(fs.Join(dir, prefix)).TempFile(dir, prefix)
+}
+
+func (fs *RepositoryFilesystem) ReadDir(path string) ([]os.FileInfo, error) {
+	return fs.mapToRepositoryFsByPath(path).ReadDir(path)
+}
+
+func (fs *RepositoryFilesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.mapToRepositoryFsByPath(filename).MkdirAll(filename, perm)
+}
+
+func (fs *RepositoryFilesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.mapToRepositoryFsByPath(filename).Lstat(filename)
+}
+
+func (fs *RepositoryFilesystem) Symlink(target, link string) error {
+	return fs.mapToRepositoryFsByPath(link).Symlink(target, link)
+}
+
+func (fs *RepositoryFilesystem) Readlink(link string) (string, error) {
+	return fs.mapToRepositoryFsByPath(link).Readlink(link)
+}
+
+func (fs *RepositoryFilesystem) Chroot(path string) (billy.Filesystem, error) {
+	return fs.mapToRepositoryFsByPath(path).Chroot(path)
+}
+
+// Root returns the root of the git directory of the worktree.
+func (fs *RepositoryFilesystem) Root() string {
+	return fs.dotGitFs.Root()
+}
+
+// Capabilities implements the Capable interface, returning the capabilities
+// of the common git directory, where most of the writes happen.
+func (fs *RepositoryFilesystem) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.commonDotGitFs)
+}
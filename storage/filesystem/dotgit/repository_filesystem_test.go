@@ -0,0 +1,71 @@
+package dotgit
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type RepositoryFilesystemSuite struct{}
+
+var _ = Suite(&RepositoryFilesystemSuite{})
+
+func (s *RepositoryFilesystemSuite) TestRouting(c *C) {
+	common := memfs.New()
+	worktree := memfs.New()
+	fs := NewRepositoryFilesystem(worktree, common)
+
+	for name, shared := range map[string]bool{
+		"HEAD":                     false,
+		"index":                    false,
+		"ORIG_HEAD":                false,
+		"logs/HEAD":                false,
+		"refs/bisect/bad":          false,
+		"refs/worktree/foo":        false,
+		"info/sparse-checkout":     false,
+		"config":                   true,
+		"packed-refs":              true,
+		"refs/heads/master":        true,
+		"logs/refs/heads/master":   true,
+		"objects/pack/foo.pack":    true,
+		"info/exclude":             true,
+		"worktrees/foo/commondir":  true,
+		"refs/heads/bisect/master": true,
+	} {
+		c.Assert(util.WriteFile(fs, name, []byte(name), 0644), IsNil)
+
+		_, err := common.Stat(name)
+		c.Assert(err == nil, Equals, shared, Commentf("file %s", name))
+
+		_, err = worktree.Stat(name)
+		c.Assert(err == nil, Equals, !shared, Commentf("file %s", name))
+	}
+}
+
+func (s *RepositoryFilesystemSuite) TestReferences(c *C) {
+	common := memfs.New()
+	dir := New(common)
+	c.Assert(dir.Initialize(), IsNil)
+
+	master := plumbing.NewHashReference(plumbing.Master,
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(dir.SetRef(master, nil), IsNil)
+	c.Assert(dir.SetRef(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master), nil), IsNil)
+
+	linked := New(NewRepositoryFilesystem(memfs.New(), common))
+	c.Assert(linked.SetRef(plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/foo"), nil), IsNil)
+
+	head, err := linked.Ref(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+
+	head, err = dir.Ref(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	ref, err := linked.Ref(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, master.Hash())
+}
@@ -2,6 +2,7 @@ package filesystem
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
@@ -45,3 +46,18 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 	err = d.Decode(idx)
 	return idx, err
 }
+
+// IndexModTime returns the modification time of the index file, the zero
+// time if it does not exist.
+func (s *IndexStorage) IndexModTime() (time.Time, error) {
+	fi, err := s.dir.IndexStat()
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return fi.ModTime(), nil
+}
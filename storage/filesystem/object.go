@@ -1,12 +1,15 @@
 package filesystem
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bitmap"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/objfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
@@ -26,6 +29,11 @@ type ObjectStorage struct {
 
 	dir   *dotgit.DotGit
 	index map[plumbing.Hash]idxfile.Index
+
+	// bitmaps are the loaded reachability bitmaps of the packfiles, nil
+	// for the packfiles without a bitmap file.
+	bitmaps   map[plumbing.Hash]*bitmap.PackBitmap
+	bitmapsMu sync.Mutex
 }
 
 // NewObjectStorage creates a new ObjectStorage with the given .git directory and cache.
@@ -65,6 +73,10 @@ func (s *ObjectStorage) requireIndex() error {
 // Reindex indexes again all packfiles. Useful if git changed packfiles externally
 func (s *ObjectStorage) Reindex() {
 	s.index = nil
+
+	s.bitmapsMu.Lock()
+	s.bitmaps = nil
+	s.bitmapsMu.Unlock()
 }
 
 func (s *ObjectStorage) loadIdxFile(h plumbing.Hash) (err error) {
@@ -85,6 +97,121 @@ func (s *ObjectStorage) loadIdxFile(h plumbing.Hash) (err error) {
 	return err
 }
 
+// PackBitmaps returns the reachability bitmaps of the packfiles that have a
+// bitmap file. The bitmap files that cannot be read or are corrupted are
+// ignored, as git does, so the history is walked instead.
+func (s *ObjectStorage) PackBitmaps() ([]*bitmap.PackBitmap, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	s.bitmapsMu.Lock()
+	defer s.bitmapsMu.Unlock()
+
+	if s.bitmaps == nil {
+		s.bitmaps = make(map[plumbing.Hash]*bitmap.PackBitmap)
+	}
+
+	var result []*bitmap.PackBitmap
+	for h, idx := range s.index {
+		pb, ok := s.bitmaps[h]
+		if !ok {
+			var err error
+			if pb, err = s.loadBitmapFile(h, idx); err != nil {
+				pb = nil
+			}
+
+			s.bitmaps[h] = pb
+		}
+
+		if pb != nil {
+			result = append(result, pb)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ObjectStorage) loadBitmapFile(h plumbing.Hash, idx idxfile.Index) (
+	pb *bitmap.PackBitmap, err error) {
+	f, err := s.dir.ObjectPackBitmap(h)
+	if err == dotgit.ErrBitmapNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	count, err := idx.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	bi := &bitmap.Index{}
+	if err = bitmap.NewDecoder(f, count).Decode(bi); err != nil {
+		return nil, err
+	}
+
+	return bitmap.NewPackBitmap(idx, bi)
+}
+
+// ObjectPackIndex returns the index of the given packfile.
+func (s *ObjectStorage) ObjectPackIndex(pack plumbing.Hash) (idxfile.Index, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	idx, ok := s.index[pack]
+	if !ok {
+		return nil, dotgit.ErrPackfileNotFound
+	}
+
+	return idx, nil
+}
+
+// SetPackBitmap writes the bitmap file of the packfile the given bitmap index
+// belongs to.
+func (s *ObjectStorage) SetPackBitmap(bi *bitmap.Index) (err error) {
+	if err := s.requireIndex(); err != nil {
+		return err
+	}
+
+	h := plumbing.Hash(bi.PackfileChecksum)
+	idx, ok := s.index[h]
+	if !ok {
+		return dotgit.ErrPackfileNotFound
+	}
+
+	pb, err := bitmap.NewPackBitmap(idx, bi)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.dir.ObjectPackBitmapWriter(h)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	if err = bitmap.NewEncoder(f).Encode(bi); err != nil {
+		return err
+	}
+
+	s.bitmapsMu.Lock()
+	defer s.bitmapsMu.Unlock()
+
+	if s.bitmaps == nil {
+		s.bitmaps = make(map[plumbing.Hash]*bitmap.PackBitmap)
+	}
+
+	s.bitmaps[h] = pb
+	return nil
+}
+
 func (s *ObjectStorage) NewEncodedObject() plumbing.EncodedObject {
 	return &plumbing.MemoryObject{}
 }
@@ -462,6 +589,88 @@ func (s *ObjectStorage) findObjectInPackfile(h plumbing.Hash) (plumbing.Hash, pl
 	return plumbing.ZeroHash, plumbing.ZeroHash, -1
 }
 
+// HashesWithPrefix returns the hashes of the loose and packed objects starting
+// with the given prefix, looking up only the matching object directory and
+// fanout buckets of the packfile indexes, and the objects of the alternates.
+func (s *ObjectStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	hashes, err := s.dir.ObjectsWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	seen := hashListAsMap(hashes)
+	add := func(found []plumbing.Hash) {
+		for _, h := range found {
+			if _, ok := seen[h]; ok {
+				continue
+			}
+
+			seen[h] = struct{}{}
+			hashes = append(hashes, h)
+		}
+	}
+
+	for _, index := range s.index {
+		packed, err := indexHashesWithPrefix(index, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		add(packed)
+	}
+
+	dotgits, err := s.dir.Alternates()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, dg := range dotgits {
+		shared, err := NewObjectStorage(dg, s.objectCache).HashesWithPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		add(shared)
+	}
+
+	return hashes, nil
+}
+
+// indexHashesWithPrefix returns the hashes of the objects of the index
+// starting with the given prefix, iterating all its entries if it does not
+// implement idxfile.PrefixIndex.
+func indexHashesWithPrefix(index idxfile.Index, prefix []byte) ([]plumbing.Hash, error) {
+	if pi, ok := index.(idxfile.PrefixIndex); ok {
+		return pi.HashesWithPrefix(prefix)
+	}
+
+	iter, err := index.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+	var hashes []plumbing.Hash
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return hashes, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.HasPrefix(e.Hash[:], prefix) {
+			hashes = append(hashes, e.Hash)
+		}
+	}
+}
+
 // IterEncodedObjects returns an iterator for all the objects in the packfile
 // with the given type.
 func (s *ObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
@@ -744,5 +953,9 @@ func (s *ObjectStorage) ObjectPacks() ([]plumbing.Hash, error) {
 }
 
 func (s *ObjectStorage) DeleteOldObjectPackAndIndex(h plumbing.Hash, t time.Time) error {
+	s.bitmapsMu.Lock()
+	delete(s.bitmaps, h)
+	s.bitmapsMu.Unlock()
+
 	return s.dir.DeleteOldObjectPackAndIndex(h, t)
 }
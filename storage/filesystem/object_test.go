@@ -465,3 +465,40 @@ func BenchmarkGetObjectFromPackfile(b *testing.B) {
 		})
 	}
 }
+
+func (s *FsSuite) TestHashesWithPrefixAlternates(c *C) {
+	fs := fixtures.ByTag("alternates").One().Worktree()
+	dotgitFs, err := fs.Chroot(filepath.Join("rep2", ".git"))
+	c.Assert(err, IsNil)
+
+	o := NewObjectStorage(dotgit.New(dotgitFs), cache.NewObjectLRUDefault())
+	head, err := o.dir.Ref(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	head, err = o.dir.Ref(head.Target())
+	c.Assert(err, IsNil)
+
+	h := head.Hash()
+	hashes, err := o.HashesWithPrefix(h[:2])
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{h})
+}
+
+func (s *FsSuite) TestPackBitmapsConcurrent(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+	c.Assert(o.requireIndex(), IsNil)
+
+	errs := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := o.PackBitmaps()
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < 4; i++ {
+		c.Assert(<-errs, IsNil)
+	}
+
+	c.Assert(o.bitmaps, HasLen, 1)
+}
@@ -2,6 +2,7 @@
 package memory
 
 import (
+	"bytes"
 	"fmt"
 	"time"
 
@@ -66,11 +67,13 @@ func (c *ConfigStorage) Config() (*config.Config, error) {
 }
 
 type IndexStorage struct {
-	index *index.Index
+	index   *index.Index
+	modTime time.Time
 }
 
 func (c *IndexStorage) SetIndex(idx *index.Index) error {
 	c.index = idx
+	c.modTime = time.Now()
 	return nil
 }
 
@@ -82,6 +85,12 @@ func (c *IndexStorage) Index() (*index.Index, error) {
 	return c.index, nil
 }
 
+// IndexModTime returns the time the index was last set, the zero time if it
+// was never set.
+func (c *IndexStorage) IndexModTime() (time.Time, error) {
+	return c.modTime, nil
+}
+
 type ObjectStorage struct {
 	Objects map[plumbing.Hash]plumbing.EncodedObject
 	Commits map[plumbing.Hash]plumbing.EncodedObject
@@ -186,6 +195,17 @@ func (o *ObjectStorage) ForEachObjectHash(fun func(plumbing.Hash) error) error {
 	return nil
 }
 
+func (o *ObjectStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	var hashes []plumbing.Hash
+	for h := range o.Objects {
+		if bytes.HasPrefix(h[:], prefix) {
+			hashes = append(hashes, h)
+		}
+	}
+
+	return hashes, nil
+}
+
 func (o *ObjectStorage) ObjectPacks() ([]plumbing.Hash, error) {
 	return nil, nil
 }
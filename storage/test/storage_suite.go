@@ -488,6 +488,43 @@ func (s *BaseStorageSuite) TestDeltaObjectStorer(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *BaseStorageSuite) TestPrefixObjectStorer(c *C) {
+	ps, ok := s.Storer.(storer.PrefixObjectStorer)
+	if !ok {
+		c.Skip("not a storer.PrefixObjectStorer")
+	}
+
+	for _, o := range s.testObjects {
+		_, err := s.Storer.SetEncodedObject(o.Object)
+		c.Assert(err, IsNil)
+	}
+
+	if pwr, ok := s.Storer.(storer.PackfileWriter); ok {
+		pw, err := pwr.PackfileWriter()
+		c.Assert(err, IsNil)
+
+		_, err = io.Copy(pw, fixtures.Basic().One().Packfile())
+		c.Assert(err, IsNil)
+		c.Assert(pw.Close(), IsNil)
+
+		h := plumbing.NewHash("32858aad3c383ed1ff0a0f9bdf231d54a00c9e88")
+		hashes, err := ps.HashesWithPrefix(h[:3])
+		c.Assert(err, IsNil)
+		c.Assert(hashes, DeepEquals, []plumbing.Hash{h})
+	}
+
+	for _, o := range s.testObjects {
+		h := o.Object.Hash()
+		hashes, err := ps.HashesWithPrefix(h[:2])
+		c.Assert(err, IsNil)
+		c.Assert(hashes, DeepEquals, []plumbing.Hash{h})
+	}
+
+	hashes, err := ps.HashesWithPrefix([]byte{0xdc, 0xf5, 0xb1, 0x6f})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 0)
+}
+
 func objectEquals(a plumbing.EncodedObject, b plumbing.EncodedObject) error {
 	ha := a.Hash()
 	hb := b.Hash()
@@ -70,6 +70,21 @@ func (o *ObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.Encode
 	}), nil
 }
 
+// HashesWithPrefix honors the storer.PrefixObjectStorer interface.
+func (o *ObjectStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	base, err := storer.HashesWithPrefix(o.EncodedObjectStorer, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	temporal, err := storer.HashesWithPrefix(o.temporal, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(base, temporal...), nil
+}
+
 // Commit it copies the objects of the temporal storage into the base storage.
 func (o *ObjectStorage) Commit() error {
 	iter, err := o.temporal.IterEncodedObjects(plumbing.AnyObject)
@@ -0,0 +1,327 @@
+package diff
+
+const (
+	// indentMaxSliding is the maximum number of positions a group of changed
+	// lines is shifted by the indent heuristic.
+	indentMaxSliding = 100
+	// maxIndent is the maximum indentation measured by the indent heuristic.
+	maxIndent = 200
+	// maxBlanks is the maximum number of blank lines measured around a split.
+	maxBlanks = 20
+
+	// the weights of the indent heuristic, tuned by git
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+)
+
+// lineGroup is a group of consecutive changed lines, from start to end, not
+// included. The group is empty if both are the same.
+type lineGroup struct {
+	start, end int
+}
+
+func (f *lineFile) firstGroup() lineGroup {
+	g := lineGroup{}
+	for f.isChanged(g.end) {
+		g.end++
+	}
+
+	return g
+}
+
+// nextGroup moves g to the next group, returning false at the end of the file.
+func (f *lineFile) nextGroup(g *lineGroup) bool {
+	if g.end == len(f.lines) {
+		return false
+	}
+
+	g.start = g.end + 1
+	g.end = g.start
+	for f.isChanged(g.end) {
+		g.end++
+	}
+
+	return true
+}
+
+// previousGroup moves g to the previous group, returning false at the start
+// of the file.
+func (f *lineFile) previousGroup(g *lineGroup) bool {
+	if g.start == 0 {
+		return false
+	}
+
+	g.end = g.start - 1
+	g.start = g.end
+	for f.isChanged(g.start - 1) {
+		g.start--
+	}
+
+	return true
+}
+
+// slideDown shifts g down a line if the lines are the same, merging it with
+// the next group if they get together.
+func (f *lineFile) slideDown(g *lineGroup) bool {
+	if g.end >= len(f.lines) || f.classes[g.start] != f.classes[g.end] {
+		return false
+	}
+
+	f.setChanged(g.start, false)
+	f.setChanged(g.end, true)
+	g.start++
+	g.end++
+	for f.isChanged(g.end) {
+		g.end++
+	}
+
+	return true
+}
+
+// slideUp shifts g up a line if the lines are the same, merging it with the
+// previous group if they get together.
+func (f *lineFile) slideUp(g *lineGroup) bool {
+	if g.start == 0 || f.classes[g.start-1] != f.classes[g.end-1] {
+		return false
+	}
+
+	g.start--
+	g.end--
+	f.setChanged(g.start, true)
+	f.setChanged(g.end, false)
+	for f.isChanged(g.start - 1) {
+		g.start--
+	}
+
+	return true
+}
+
+// compact shifts the groups of changed lines of f, merging them if
+// possible, to line up with the changes of the other file, o. Otherwise they
+// are shifted to the last possible lines or, with indentHeuristic, to the
+// best position for the indentation of the text. The groups of both files
+// are walked in sync: the lines out of the groups are equal in both.
+func (f *lineFile) compact(o *lineFile, indentHeuristic bool) {
+	g, og := f.firstGroup(), o.firstGroup()
+	for {
+		if g.end != g.start {
+			var size, earliestEnd int
+			endMatchingOther := -1
+			for {
+				size = g.end - g.start
+				endMatchingOther = -1
+				for f.slideUp(&g) {
+					o.previousGroup(&og)
+				}
+
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+
+				for f.slideDown(&g) {
+					o.nextGroup(&og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+
+				if size == g.end-g.start {
+					break
+				}
+			}
+
+			switch {
+			case g.end == earliestEnd:
+			case endMatchingOther != -1:
+				for og.end == og.start {
+					f.slideUp(&g)
+					o.previousGroup(&og)
+				}
+			case indentHeuristic:
+				shift := earliestEnd
+				if g.end-size-1 > shift {
+					shift = g.end - size - 1
+				}
+
+				if g.end-indentMaxSliding > shift {
+					shift = g.end - indentMaxSliding
+				}
+
+				bestShift := -1
+				var best splitScore
+				for ; shift <= g.end; shift++ {
+					var score splitScore
+					score.add(f.measureSplit(shift))
+					score.add(f.measureSplit(shift - size))
+					if bestShift == -1 || score.compare(best) <= 0 {
+						best = score
+						bestShift = shift
+					}
+				}
+
+				for g.end > bestShift {
+					f.slideUp(&g)
+					o.previousGroup(&og)
+				}
+			}
+		}
+
+		if !f.nextGroup(&g) {
+			break
+		}
+
+		o.nextGroup(&og)
+	}
+}
+
+// splitMeasurement describes the text around a split between two lines.
+type splitMeasurement struct {
+	endOfFile  bool
+	indent     int
+	preBlank   int
+	preIndent  int
+	postBlank  int
+	postIndent int
+}
+
+// splitScore is the badness of a position of a group of changed lines.
+type splitScore struct {
+	effectiveIndent int
+	penalty         int
+}
+
+// measureSplit measures the split before the given line.
+func (f *lineFile) measureSplit(split int) splitMeasurement {
+	var m splitMeasurement
+	if split >= len(f.lines) {
+		m.endOfFile = true
+		m.indent = -1
+	} else {
+		m.indent = lineIndent(f.lines[split])
+	}
+
+	m.preIndent = -1
+	for i := split - 1; i >= 0; i-- {
+		m.preIndent = lineIndent(f.lines[i])
+		if m.preIndent != -1 {
+			break
+		}
+
+		m.preBlank++
+		if m.preBlank == maxBlanks {
+			m.preIndent = 0
+			break
+		}
+	}
+
+	m.postIndent = -1
+	for i := split + 1; i < len(f.lines); i++ {
+		m.postIndent = lineIndent(f.lines[i])
+		if m.postIndent != -1 {
+			break
+		}
+
+		m.postBlank++
+		if m.postBlank == maxBlanks {
+			m.postIndent = 0
+			break
+		}
+	}
+
+	return m
+}
+
+// lineIndent returns the indentation of the line, a tab being 8 columns, or
+// -1 if it is blank.
+func lineIndent(l string) int {
+	indent := 0
+	for i := 0; i < len(l); i++ {
+		switch l[i] {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 8 - indent%8
+		case '\n', '\v', '\f', '\r':
+		default:
+			return indent
+		}
+
+		if indent >= maxIndent {
+			return maxIndent
+		}
+	}
+
+	return -1
+}
+
+func (s *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 {
+		s.penalty += startOfFilePenalty
+	}
+
+	if m.endOfFile {
+		s.penalty += endOfFilePenalty
+	}
+
+	postBlank := 0
+	if m.indent == -1 {
+		postBlank = 1 + m.postBlank
+	}
+
+	totalBlank := m.preBlank + postBlank
+	s.penalty += totalBlankWeight * totalBlank
+	s.penalty += postBlankWeight * postBlank
+
+	indent := m.indent
+	if indent == -1 {
+		indent = m.postIndent
+	}
+
+	anyBlanks := totalBlank != 0
+	s.effectiveIndent += indent
+
+	switch {
+	case indent == -1, m.preIndent == -1, indent == m.preIndent:
+	case indent > m.preIndent:
+		if anyBlanks {
+			s.penalty += relativeIndentWithBlankPenalty
+		} else {
+			s.penalty += relativeIndentPenalty
+		}
+	case m.postIndent != -1 && m.postIndent > indent:
+		if anyBlanks {
+			s.penalty += relativeOutdentWithBlankPenalty
+		} else {
+			s.penalty += relativeOutdentPenalty
+		}
+	default:
+		if anyBlanks {
+			s.penalty += relativeDedentWithBlankPenalty
+		} else {
+			s.penalty += relativeDedentPenalty
+		}
+	}
+}
+
+// compare returns a negative number if s is better than o, a positive one
+// if it is worse, or zero if they are as good.
+func (s splitScore) compare(o splitScore) int {
+	cmp := 0
+	if s.effectiveIndent > o.effectiveIndent {
+		cmp = 1
+	} else if s.effectiveIndent < o.effectiveIndent {
+		cmp = -1
+	}
+
+	return indentWeight*cmp + s.penalty - o.penalty
+}
@@ -1,9 +1,10 @@
 // Package diff implements line oriented diffs, similar to the ancient
 // Unix diff command.
 //
-// The current implementation is just a wrapper around Sergi's
-// go-diff/diffmatchpatch library, which is a go port of Neil
-// Fraser's google-diff-match-patch code
+// Do is just a wrapper around Sergi's go-diff/diffmatchpatch library,
+// which is a go port of Neil Fraser's google-diff-match-patch code.
+// DoWithOptions implements the algorithms of git, returning the same
+// diffmatchpatch types.
 package diff
 
 import (
@@ -38,6 +39,62 @@ func DoWithTimeout (src, dst string, timeout time.Duration) (diffs []diffmatchpa
 	return diffs
 }
 
+// Algorithm is an algorithm computing the line differences of DoWithOptions.
+type Algorithm int
+
+const (
+	// Myers is the O(ND) algorithm of Eugene W. Myers, the default of git.
+	Myers Algorithm = iota
+	// Patience matches first the lines unique in both texts, as
+	// `git diff --patience` does.
+	Patience
+	// Histogram matches first the least common lines in both texts, as
+	// `git diff --histogram` does.
+	Histogram
+)
+
+// Options are the options of DoWithOptions.
+type Options struct {
+	// Algorithm is the algorithm computing the line differences, Myers by
+	// default.
+	Algorithm Algorithm
+	// IgnoreWhitespace compares the lines ignoring all their whitespace, as
+	// `git diff --ignore-all-space` does.
+	IgnoreWhitespace bool
+	// IgnoreCase compares the lines ignoring the case of their letters.
+	IgnoreCase bool
+	// IndentHeuristic shifts the groups of changed lines to the positions
+	// best aligned with the indentation of the text, as
+	// `git diff --indent-heuristic` does.
+	IndentHeuristic bool
+}
+
+// DoWithOptions computes the line oriented modifications needed to turn the
+// src string into the dst string with the given options, as git does. The
+// groups of changed lines are shifted to line up with the changes of the
+// other text if possible, or to be the last possible lines otherwise. The
+// lines compared as equal are returned with their text in dst.
+func DoWithOptions(src, dst string, o *Options) []diffmatchpatch.Diff {
+	if o == nil {
+		o = &Options{}
+	}
+
+	a, b := newLineFiles(src, dst, o)
+	d := &differ{a: a, b: b}
+	switch o.Algorithm {
+	case Patience:
+		d.patience(0, len(a.lines), 0, len(b.lines))
+	case Histogram:
+		d.histogram(0, len(a.lines), 0, len(b.lines))
+	default:
+		d.myers(0, len(a.lines), 0, len(b.lines))
+	}
+
+	a.compact(b, o.IndentHeuristic)
+	b.compact(a, o.IndentHeuristic)
+	return lineDiffs(a, b)
+}
+
 // Dst computes and returns the destination text.
 func Dst(diffs []diffmatchpatch.Diff) string {
 	var text bytes.Buffer
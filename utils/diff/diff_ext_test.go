@@ -1,6 +1,7 @@
 package diff_test
 
 import (
+	"strings"
 	"testing"
 
 	"gopkg.in/src-d/go-git.v4/utils/diff"
@@ -107,3 +108,94 @@ func (s *suiteCommon) TestDo(c *C) {
 		c.Assert(diffs, DeepEquals, t.exp, Commentf("subtest %d", i))
 	}
 }
+
+func (s *suiteCommon) TestDoWithOptionsDoTests(c *C) {
+	for _, algorithm := range []diff.Algorithm{diff.Myers, diff.Patience, diff.Histogram} {
+		for i, t := range doTests {
+			diffs := diff.DoWithOptions(t.src, t.dst, &diff.Options{Algorithm: algorithm})
+			c.Assert(diffs, DeepEquals, t.exp, Commentf("algorithm %d, subtest %d", algorithm, i))
+		}
+
+		for i, t := range diffTests {
+			diffs := diff.DoWithOptions(t.src, t.dst, &diff.Options{Algorithm: algorithm})
+			c.Assert(diff.Src(diffs), Equals, t.src, Commentf("algorithm %d, subtest %d", algorithm, i))
+			c.Assert(diff.Dst(diffs), Equals, t.dst, Commentf("algorithm %d, subtest %d", algorithm, i))
+		}
+	}
+}
+
+var doWithOptionsTests = [...]struct {
+	src, dst string
+	opts     diff.Options
+	exp      string
+}{
+	{
+		src:  "b\na\n\nb\nb\nc\n",
+		dst:  "a\nc\n}\nb\n\nb\n",
+		opts: diff.Options{Algorithm: diff.Myers},
+		exp:  "-b\n a\n-\n+c\n+}\n b\n+\n b\n-c\n",
+	},
+	{
+		src:  "b\na\n\nb\nb\nc\n",
+		dst:  "a\nc\n}\nb\n\nb\n",
+		opts: diff.Options{Algorithm: diff.Patience},
+		exp:  "-b\n a\n-\n-b\n-b\n c\n+}\n+b\n+\n+b\n",
+	},
+	{
+		src:  "b\na\n\nb\nb\nc\n",
+		dst:  "a\nc\n}\nb\n\nb\n",
+		opts: diff.Options{Algorithm: diff.Histogram},
+		exp:  "-b\n a\n+c\n+}\n+b\n \n b\n-b\n-c\n",
+	},
+	{
+		src:  "if a {\n\tx()\n}\n",
+		dst:  "if a {\n}\nif a {\n\tx()\n}\n",
+		opts: diff.Options{},
+		exp:  " if a {\n+}\n+if a {\n \tx()\n }\n",
+	},
+	{
+		src:  "if a {\n\tx()\n}\n",
+		dst:  "if a {\n}\nif a {\n\tx()\n}\n",
+		opts: diff.Options{IndentHeuristic: true},
+		exp:  "+if a {\n+}\n if a {\n \tx()\n }\n",
+	},
+	{
+		src:  "Foo\nbar\n\n",
+		dst:  "foo\n  bar \n\n\nbaz\n",
+		opts: diff.Options{IgnoreWhitespace: true},
+		exp:  "-Foo\n+foo\n   bar \n \n+\n+baz\n",
+	},
+	{
+		src:  "Foo\nbar\n\n",
+		dst:  "foo\n  bar \n\n\nbaz\n",
+		opts: diff.Options{IgnoreWhitespace: true, IgnoreCase: true},
+		exp:  " foo\n   bar \n \n+\n+baz\n",
+	},
+}
+
+func (s *suiteCommon) TestDoWithOptions(c *C) {
+	for i, t := range doWithOptionsTests {
+		diffs := diff.DoWithOptions(t.src, t.dst, &t.opts)
+		c.Assert(formatLineDiffs(diffs), Equals, t.exp, Commentf("subtest %d", i))
+	}
+}
+
+// formatLineDiffs returns the lines of the diffs prefixed by their operation.
+func formatLineDiffs(diffs []diffmatchpatch.Diff) string {
+	prefixes := map[diffmatchpatch.Operation]string{
+		diffmatchpatch.DiffEqual:  " ",
+		diffmatchpatch.DiffDelete: "-",
+		diffmatchpatch.DiffInsert: "+",
+	}
+
+	var s string
+	for _, d := range diffs {
+		for _, l := range strings.SplitAfter(d.Text, "\n") {
+			if l != "" {
+				s += prefixes[d.Type] + l
+			}
+		}
+	}
+
+	return s
+}
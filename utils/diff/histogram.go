@@ -0,0 +1,130 @@
+package diff
+
+// histogramMaxChain is the maximum number of occurrences of a line in the
+// first range for it to be matched by the histogram algorithm.
+const histogramMaxChain = 64
+
+// histogramRecord are the occurrences of a line in the range of the first
+// file: the first of them and their count.
+type histogramRecord struct {
+	first, count int
+}
+
+// histogramRegion is a range of lines equal in both files, both ends
+// included.
+type histogramRegion struct {
+	a0, a1, b0, b1 int
+}
+
+// histogram marks the changed lines of the ranges of both files with the
+// histogram algorithm: the longest sequence of equal lines with the least
+// occurrences in the first range is matched, recursing on both sides of it.
+// The ranges whose common lines are too frequent fall back to the Myers
+// algorithm.
+func (d *differ) histogram(a0, a1, b0, b1 int) {
+	for {
+		if a0 == a1 || b0 == b1 {
+			d.changeAll(a0, a1, b0, b1)
+			return
+		}
+
+		lcs, found, fallback := d.histogramLCS(a0, a1, b0, b1)
+		if fallback {
+			d.myers(a0, a1, b0, b1)
+			return
+		}
+
+		if !found {
+			d.changeAll(a0, a1, b0, b1)
+			return
+		}
+
+		d.histogram(a0, lcs.a0, b0, lcs.b0)
+		a0, b0 = lcs.a1+1, lcs.b1+1
+	}
+}
+
+// histogramLCS returns the longest common sequence of the ranges with the
+// least occurrences in the first range, whether it is found, and whether
+// the ranges should fall back to Myers because all their common lines have
+// too many occurrences.
+func (d *differ) histogramLCS(a0, a1, b0, b1 int) (lcs histogramRegion, found, fallback bool) {
+	a, b := d.a.classes, d.b.classes
+	records := make(map[int]*histogramRecord)
+	// the next occurrence of each line of the first range, or -1
+	next := make([]int, a1-a0)
+	lineRecords := make([]*histogramRecord, a1-a0)
+	for i := a1 - 1; i >= a0; i-- {
+		r, ok := records[a[i]]
+		if ok {
+			next[i-a0] = r.first
+			r.first = i
+			r.count++
+		} else {
+			r = &histogramRecord{first: i, count: 1}
+			records[a[i]] = r
+			next[i-a0] = -1
+		}
+
+		lineRecords[i-a0] = r
+	}
+
+	maxCount := histogramMaxChain + 1
+	common := false
+	for j := b0; j < b1; {
+		jNext := j + 1
+		r, ok := records[b[j]]
+		switch {
+		case !ok:
+		case r.count > maxCount:
+			common = true
+		default:
+			common = true
+			as := r.first
+			for {
+				np := next[as-a0]
+				bs, ae, be := j, as, j
+				count := r.count
+				for a0 < as && b0 < bs && a[as-1] == b[bs-1] {
+					as--
+					bs--
+					if count > 1 && lineRecords[as-a0].count < count {
+						count = lineRecords[as-a0].count
+					}
+				}
+
+				for ae < a1-1 && be < b1-1 && a[ae+1] == b[be+1] {
+					ae++
+					be++
+					if count > 1 && lineRecords[ae-a0].count < count {
+						count = lineRecords[ae-a0].count
+					}
+				}
+
+				if jNext <= be {
+					jNext = be + 1
+				}
+
+				if lcs.a1-lcs.a0 < ae-as || count < maxCount {
+					lcs = histogramRegion{a0: as, a1: ae, b0: bs, b1: be}
+					maxCount = count
+					found = true
+				}
+
+				for np != -1 && np <= ae {
+					np = next[np-a0]
+				}
+
+				if np == -1 {
+					break
+				}
+
+				as = np
+			}
+		}
+
+		j = jNext
+	}
+
+	return lcs, found, common && !found
+}
@@ -0,0 +1,143 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// lineFile is a text split in lines, each of them with the class of the
+// lines equal to it and whether it is changed.
+type lineFile struct {
+	lines   []string
+	classes []int
+	// changed has a false sentinel at both ends, line i is at i+1
+	changed []bool
+}
+
+// newLineFiles returns the lineFiles of both texts, with the same classes
+// for the lines equal with the given options.
+func newLineFiles(src, dst string, o *Options) (a, b *lineFile) {
+	classes := make(map[string]int)
+	return newLineFile(src, classes, o), newLineFile(dst, classes, o)
+}
+
+func newLineFile(text string, classes map[string]int, o *Options) *lineFile {
+	f := &lineFile{lines: splitLines(text)}
+	f.classes = make([]int, len(f.lines))
+	f.changed = make([]bool, len(f.lines)+2)
+	for i, l := range f.lines {
+		key := lineKey(l, o)
+		class, ok := classes[key]
+		if !ok {
+			class = len(classes)
+			classes[key] = class
+		}
+
+		f.classes[i] = class
+	}
+
+	return f
+}
+
+// splitLines splits the text in lines, keeping their line endings.
+func splitLines(text string) []string {
+	var lines []string
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+
+		lines = append(lines, text[:i])
+		text = text[i:]
+	}
+
+	return lines
+}
+
+// lineKey returns the key identifying the lines equal to l.
+func lineKey(l string, o *Options) string {
+	if o.IgnoreWhitespace {
+		l = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+
+			return r
+		}, l)
+	}
+
+	if o.IgnoreCase {
+		l = strings.ToLower(l)
+	}
+
+	return l
+}
+
+func (f *lineFile) isChanged(i int) bool {
+	return f.changed[i+1]
+}
+
+func (f *lineFile) setChanged(i int, changed bool) {
+	f.changed[i+1] = changed
+}
+
+// change marks the lines from i to j, not included, as changed.
+func (f *lineFile) change(i, j int) {
+	for ; i < j; i++ {
+		f.setChanged(i, true)
+	}
+}
+
+// differ computes the changed lines of two lineFiles.
+type differ struct {
+	a, b *lineFile
+}
+
+// changeAll marks as changed the lines of both ranges.
+func (d *differ) changeAll(a0, a1, b0, b1 int) {
+	d.a.change(a0, a1)
+	d.b.change(b0, b1)
+}
+
+// lineDiffs returns the diffs of the changed lines of both lineFiles, the
+// deleted lines before the inserted ones.
+func lineDiffs(a, b *lineFile) []diffmatchpatch.Diff {
+	diffs := []diffmatchpatch.Diff{}
+	var text bytes.Buffer
+	op := diffmatchpatch.DiffEqual
+	add := func(t diffmatchpatch.Operation, l string) {
+		if t != op && text.Len() > 0 {
+			diffs = append(diffs, diffmatchpatch.Diff{Type: op, Text: text.String()})
+			text.Reset()
+		}
+
+		op = t
+		text.WriteString(l)
+	}
+
+	i, j := 0, 0
+	for i < len(a.lines) || j < len(b.lines) {
+		switch {
+		case i < len(a.lines) && a.isChanged(i):
+			add(diffmatchpatch.DiffDelete, a.lines[i])
+			i++
+		case j < len(b.lines) && b.isChanged(j):
+			add(diffmatchpatch.DiffInsert, b.lines[j])
+			j++
+		default:
+			add(diffmatchpatch.DiffEqual, b.lines[j])
+			i++
+			j++
+		}
+	}
+
+	if text.Len() > 0 {
+		diffs = append(diffs, diffmatchpatch.Diff{Type: op, Text: text.String()})
+	}
+
+	return diffs
+}
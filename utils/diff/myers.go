@@ -0,0 +1,460 @@
+package diff
+
+const (
+	// myersMaxCostMin is the minimum edit cost after which the furthest
+	// reaching path is taken.
+	myersMaxCostMin = 256
+	// myersHeuristicMinCost is the edit cost after which good snakes are
+	// looked for.
+	myersHeuristicMinCost = 256
+	// myersSnakeCount is the length of a good snake.
+	myersSnakeCount = 20
+	// myersHeuristicFactor weighs the edit cost of the good snakes.
+	myersHeuristicFactor = 4
+	// myersMaxEqualLimit caps the number of matches of a line for it to be
+	// discarded if surrounded by lines without matches.
+	myersMaxEqualLimit = 1024
+	// myersScanWindow is the number of lines looked around a line with
+	// many matches to discard it.
+	myersScanWindow = 100
+	// myersDiscardRun weighs the lines with many matches around a line to
+	// discard it.
+	myersDiscardRun = 4
+)
+
+// myers marks the changed lines of the ranges of both files with the
+// algorithm of Myers, as git does: the common lines at both ends are
+// skipped, the lines without matches are changed, and the rest are split
+// by the middle snake of the edit script, which is cut short with some
+// heuristics for expensive ranges.
+func (d *differ) myers(a0, a1, b0, b1 int) {
+	counts := make(map[int][2]int)
+	for i := a0; i < a1; i++ {
+		c := counts[d.a.classes[i]]
+		c[0]++
+		counts[d.a.classes[i]] = c
+	}
+
+	for i := b0; i < b1; i++ {
+		c := counts[d.b.classes[i]]
+		c[1]++
+		counts[d.b.classes[i]] = c
+	}
+
+	s0, s1 := a0, b0
+	for s0 < a1 && s1 < b1 && d.a.classes[s0] == d.b.classes[s1] {
+		s0++
+		s1++
+	}
+
+	e0, e1 := a1, b1
+	for e0 > s0 && e1 > s1 && d.a.classes[e0-1] == d.b.classes[e1-1] {
+		e0--
+		e1--
+	}
+
+	m := &myersRanges{
+		a: d.a.relevantLines(s0, e0, a1-a0, counts, 1),
+		b: d.b.relevantLines(s1, e1, b1-b0, counts, 0),
+	}
+
+	m.ha = m.a.classes(d.a)
+	m.hb = m.b.classes(d.b)
+	n := len(m.ha) + len(m.hb) + 3
+	m.kvdf = make([]int, n)
+	m.kvdb = make([]int, n)
+	m.koff = len(m.hb) + 1
+	m.maxCost = bogoSqrt(n)
+	if m.maxCost < myersMaxCostMin {
+		m.maxCost = myersMaxCostMin
+	}
+
+	m.compare(0, len(m.ha), 0, len(m.hb), false)
+	for i, l := range m.a {
+		if m.changedA[i] {
+			d.a.setChanged(l, true)
+		}
+	}
+
+	for i, l := range m.b {
+		if m.changedB[i] {
+			d.b.setChanged(l, true)
+		}
+	}
+}
+
+// myersLines are the lines of a file compared by myers.
+type myersLines []int
+
+func (l myersLines) classes(f *lineFile) []int {
+	classes := make([]int, len(l))
+	for i, line := range l {
+		classes[i] = f.classes[line]
+	}
+
+	return classes
+}
+
+// relevantLines returns the lines of the range worth comparing, marking the
+// rest as changed: those without matches in the other file and those with
+// many matches surrounded by lines without them. The lines matching are
+// counted in the other position of the counts.
+func (f *lineFile) relevantLines(start, end, total int, counts map[int][2]int, other int) myersLines {
+	limit := bogoSqrt(total)
+	if limit > myersMaxEqualLimit {
+		limit = myersMaxEqualLimit
+	}
+
+	// 0 without matches, 1 with some, 2 with many
+	discard := make([]int, end-start)
+	for i := start; i < end; i++ {
+		matches := counts[f.classes[i]][other]
+		switch {
+		case matches == 0:
+		case matches >= limit:
+			discard[i-start] = 2
+		default:
+			discard[i-start] = 1
+		}
+	}
+
+	var lines myersLines
+	for i := range discard {
+		if discard[i] == 1 || discard[i] == 2 && !isMultimatchDiscarded(discard, i) {
+			lines = append(lines, start+i)
+		} else {
+			f.setChanged(start+i, true)
+		}
+	}
+
+	return lines
+}
+
+// isMultimatchDiscarded returns whether the line i, with many matches, is
+// discarded because it is among lines without or with many matches.
+func isMultimatchDiscarded(discard []int, i int) bool {
+	s, e := 0, len(discard)-1
+	if i-s > myersScanWindow {
+		s = i - myersScanWindow
+	}
+
+	if e-i > myersScanWindow {
+		e = i + myersScanWindow
+	}
+
+	none0, many0 := 0, 1
+	for r := 1; i-r >= s; r++ {
+		if discard[i-r] == 0 {
+			none0++
+		} else if discard[i-r] == 2 {
+			many0++
+		} else {
+			break
+		}
+	}
+
+	if none0 == 0 {
+		return false
+	}
+
+	none1, many1 := 0, 1
+	for r := 1; i+r <= e; r++ {
+		if discard[i+r] == 0 {
+			none1++
+		} else if discard[i+r] == 2 {
+			many1++
+		} else {
+			break
+		}
+	}
+
+	if none1 == 0 {
+		return false
+	}
+
+	none := none0 + none1
+	many := many0 + many1
+	return many*myersDiscardRun < many+none
+}
+
+// bogoSqrt is an approximation of the square root of n.
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+
+	return i
+}
+
+// myersRanges are the relevant lines of both files compared by myers.
+type myersRanges struct {
+	a, b               myersLines
+	ha, hb             []int
+	changedA, changedB map[int]bool
+	// the furthest reaching forward and backward paths of each diagonal,
+	// the diagonal k is at k+koff
+	kvdf, kvdb []int
+	koff       int
+	maxCost    int
+}
+
+// compare marks the changed lines of the ranges, splitting them by the
+// middle snake.
+func (m *myersRanges) compare(off1, lim1, off2, lim2 int, needMin bool) {
+	if m.changedA == nil {
+		m.changedA = make(map[int]bool)
+		m.changedB = make(map[int]bool)
+	}
+
+	for off1 < lim1 && off2 < lim2 && m.ha[off1] == m.hb[off2] {
+		off1++
+		off2++
+	}
+
+	for off1 < lim1 && off2 < lim2 && m.ha[lim1-1] == m.hb[lim2-1] {
+		lim1--
+		lim2--
+	}
+
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			m.changedB[off2] = true
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			m.changedA[off1] = true
+		}
+	default:
+		s := m.split(off1, lim1, off2, lim2, needMin)
+		m.compare(off1, s.i1, off2, s.i2, s.minLow)
+		m.compare(s.i1, lim1, s.i2, lim2, s.minHigh)
+	}
+}
+
+// myersSplit is a point splitting the ranges compared, and whether the
+// edit script must be minimal before and after it.
+type myersSplit struct {
+	i1, i2          int
+	minLow, minHigh bool
+}
+
+func (m *myersRanges) split(off1, lim1, off2, lim2 int, needMin bool) myersSplit {
+	const lineMax = int(^uint(0) >> 1)
+	ha, hb := m.ha, m.hb
+	kvdf := func(k int) *int { return &m.kvdf[k+m.koff] }
+	kvdb := func(k int) *int { return &m.kvdb[k+m.koff] }
+
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	*kvdf(fmid) = off1
+	*kvdb(bmid) = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+		if fmin > dmin {
+			fmin--
+			*kvdf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+
+		if fmax < dmax {
+			fmax++
+			*kvdf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if *kvdf(d - 1) >= *kvdf(d + 1) {
+				i1 = *kvdf(d - 1) + 1
+			} else {
+				i1 = *kvdf(d + 1)
+			}
+
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && ha[i1] == hb[i2] {
+				i1++
+				i2++
+			}
+
+			if i1-prev1 > myersSnakeCount {
+				gotSnake = true
+			}
+
+			*kvdf(d) = i1
+			if odd && bmin <= d && d <= bmax && *kvdb(d) <= i1 {
+				return myersSplit{i1: i1, i2: i2, minLow: true, minHigh: true}
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			*kvdb(bmin - 1) = lineMax
+		} else {
+			bmin++
+		}
+
+		if bmax < dmax {
+			bmax++
+			*kvdb(bmax + 1) = lineMax
+		} else {
+			bmax--
+		}
+
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if *kvdb(d - 1) < *kvdb(d + 1) {
+				i1 = *kvdb(d - 1)
+			} else {
+				i1 = *kvdb(d + 1) - 1
+			}
+
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && ha[i1-1] == hb[i2-1] {
+				i1--
+				i2--
+			}
+
+			if prev1-i1 > myersSnakeCount {
+				gotSnake = true
+			}
+
+			*kvdb(d) = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= *kvdf(d) {
+				return myersSplit{i1: i1, i2: i2, minLow: true, minHigh: true}
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		if gotSnake && ec > myersHeuristicMinCost {
+			if s, ok := m.forwardSnake(off1, lim1, off2, lim2, fmin, fmax, fmid, ec); ok {
+				return s
+			}
+
+			if s, ok := m.backwardSnake(off1, lim1, off2, lim2, bmin, bmax, bmid, ec); ok {
+				return s
+			}
+		}
+
+		if ec >= m.maxCost {
+			return m.furthestSplit(off1, lim1, off2, lim2, fmin, fmax, bmin, bmax)
+		}
+	}
+}
+
+// forwardSnake returns the split at the end of the best good snake of the
+// forward paths, if any.
+func (m *myersRanges) forwardSnake(off1, lim1, off2, lim2, fmin, fmax, fmid, ec int) (myersSplit, bool) {
+	var s myersSplit
+	best := 0
+	for d := fmax; d >= fmin; d -= 2 {
+		dd := fmid - d
+		if d > fmid {
+			dd = d - fmid
+		}
+
+		i1 := m.kvdf[d+m.koff]
+		i2 := i1 - d
+		v := (i1 - off1) + (i2 - off2) - dd
+		if v > myersHeuristicFactor*ec && v > best &&
+			off1+myersSnakeCount <= i1 && i1 < lim1 &&
+			off2+myersSnakeCount <= i2 && i2 < lim2 {
+			for k := 1; m.ha[i1-k] == m.hb[i2-k]; k++ {
+				if k == myersSnakeCount {
+					best = v
+					s = myersSplit{i1: i1, i2: i2, minLow: true}
+					break
+				}
+			}
+		}
+	}
+
+	return s, best > 0
+}
+
+// backwardSnake returns the split at the start of the best good snake of
+// the backward paths, if any.
+func (m *myersRanges) backwardSnake(off1, lim1, off2, lim2, bmin, bmax, bmid, ec int) (myersSplit, bool) {
+	var s myersSplit
+	best := 0
+	for d := bmax; d >= bmin; d -= 2 {
+		dd := bmid - d
+		if d > bmid {
+			dd = d - bmid
+		}
+
+		i1 := m.kvdb[d+m.koff]
+		i2 := i1 - d
+		v := (lim1 - i1) + (lim2 - i2) - dd
+		if v > myersHeuristicFactor*ec && v > best &&
+			off1 < i1 && i1 <= lim1-myersSnakeCount &&
+			off2 < i2 && i2 <= lim2-myersSnakeCount {
+			for k := 0; m.ha[i1+k] == m.hb[i2+k]; k++ {
+				if k == myersSnakeCount-1 {
+					best = v
+					s = myersSplit{i1: i1, i2: i2, minHigh: true}
+					break
+				}
+			}
+		}
+	}
+
+	return s, best > 0
+}
+
+// furthestSplit returns the split at the furthest reaching path, forward or
+// backward.
+func (m *myersRanges) furthestSplit(off1, lim1, off2, lim2, fmin, fmax, bmin, bmax int) myersSplit {
+	const lineMax = int(^uint(0) >> 1)
+	fbest, fbest1 := -1, -1
+	for d := fmax; d >= fmin; d -= 2 {
+		i1 := m.kvdf[d+m.koff]
+		if i1 > lim1 {
+			i1 = lim1
+		}
+
+		i2 := i1 - d
+		if lim2 < i2 {
+			i1, i2 = lim2+d, lim2
+		}
+
+		if fbest < i1+i2 {
+			fbest, fbest1 = i1+i2, i1
+		}
+	}
+
+	bbest, bbest1 := lineMax, lineMax
+	for d := bmax; d >= bmin; d -= 2 {
+		i1 := m.kvdb[d+m.koff]
+		if i1 < off1 {
+			i1 = off1
+		}
+
+		i2 := i1 - d
+		if i2 < off2 {
+			i1, i2 = off2+d, off2
+		}
+
+		if i1+i2 < bbest {
+			bbest, bbest1 = i1+i2, i1
+		}
+	}
+
+	if (lim1+lim2)-bbest < fbest-(off1+off2) {
+		return myersSplit{i1: fbest1, i2: fbest - fbest1, minLow: true}
+	}
+
+	return myersSplit{i1: bbest1, i2: bbest - bbest1, minHigh: true}
+}
@@ -0,0 +1,148 @@
+package diff
+
+// patienceEntry is a line of the range of the first file, with its first
+// position in both ranges.
+type patienceEntry struct {
+	a, b     int
+	unique   bool
+	previous *patienceEntry
+	next     *patienceEntry
+}
+
+// patience marks the changed lines of the ranges of both files with the
+// patience algorithm: the longest common sequence of the lines unique in
+// both ranges is matched, recursing between them. The ranges without unique
+// common lines fall back to the Myers algorithm.
+func (d *differ) patience(a0, a1, b0, b1 int) {
+	if a0 == a1 || b0 == b1 {
+		d.changeAll(a0, a1, b0, b1)
+		return
+	}
+
+	entries, matches := d.patienceEntries(a0, a1, b0, b1)
+	if !matches {
+		d.changeAll(a0, a1, b0, b1)
+		return
+	}
+
+	first := longestCommonSequence(entries)
+	if first == nil {
+		d.myers(a0, a1, b0, b1)
+		return
+	}
+
+	a, b := d.a.classes, d.b.classes
+	for {
+		var next0, next1 int
+		if first != nil {
+			next0, next1 = first.a, first.b
+			for next0 > a0 && next1 > b0 && a[next0-1] == b[next1-1] {
+				next0--
+				next1--
+			}
+		} else {
+			next0, next1 = a1, b1
+		}
+
+		for a0 < next0 && b0 < next1 && a[a0] == b[b0] {
+			a0++
+			b0++
+		}
+
+		if next0 > a0 || next1 > b0 {
+			d.patience(a0, next0, b0, next1)
+		}
+
+		if first == nil {
+			return
+		}
+
+		for first.next != nil && first.next.a == first.a+1 && first.next.b == first.b+1 {
+			first = first.next
+		}
+
+		a0, b0 = first.a+1, first.b+1
+		first = first.next
+	}
+}
+
+// patienceEntries returns the entries of the lines of the range of the
+// first file, in order, and whether any of them is in the second range.
+func (d *differ) patienceEntries(a0, a1, b0, b1 int) ([]*patienceEntry, bool) {
+	var entries []*patienceEntry
+	byClass := make(map[int]*patienceEntry)
+	for i := a0; i < a1; i++ {
+		class := d.a.classes[i]
+		if e, ok := byClass[class]; ok {
+			e.unique = false
+			continue
+		}
+
+		e := &patienceEntry{a: i, b: -1, unique: true}
+		byClass[class] = e
+		entries = append(entries, e)
+	}
+
+	matches := false
+	for i := b0; i < b1; i++ {
+		e, ok := byClass[d.b.classes[i]]
+		if !ok {
+			continue
+		}
+
+		matches = true
+		if e.b != -1 {
+			e.unique = false
+			continue
+		}
+
+		e.b = i
+	}
+
+	return entries, matches
+}
+
+// longestCommonSequence returns the first entry of the longest sequence of
+// the entries unique in both ranges in the same order in both of them,
+// linked by their next field, or nil if there is none.
+func longestCommonSequence(entries []*patienceEntry) *patienceEntry {
+	var sequence []*patienceEntry
+	for _, e := range entries {
+		if !e.unique || e.b == -1 {
+			continue
+		}
+
+		// the last entry of the sequence before b
+		left, right := -1, len(sequence)
+		for left+1 < right {
+			middle := left + (right-left)/2
+			if sequence[middle].b > e.b {
+				right = middle
+			} else {
+				left = middle
+			}
+		}
+
+		if left >= 0 {
+			e.previous = sequence[left]
+		}
+
+		if left+1 == len(sequence) {
+			sequence = append(sequence, e)
+		} else {
+			sequence[left+1] = e
+		}
+	}
+
+	if len(sequence) == 0 {
+		return nil
+	}
+
+	e := sequence[len(sequence)-1]
+	for e.previous != nil {
+		e.previous.next = e
+		e = e.previous
+	}
+
+	return e
+}
@@ -0,0 +1,287 @@
+// Package eol implements the end-of-line conversion done by git between the
+// content of the files in the worktree and the content stored in the
+// repository, driven by the core.autocrlf and core.eol configuration and the
+// text and eol gitattributes.
+//
+// https://git-scm.com/docs/gitattributes#_end_of_line_conversion
+package eol
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+const (
+	textAttribute = "text"
+	eolAttribute  = "eol"
+	crlfAttribute = "crlf"
+
+	textValue   = "text"
+	binaryValue = "binary"
+	autoValue   = "auto"
+	inputValue  = "input"
+	lfValue     = "lf"
+	crlfValue   = "crlf"
+)
+
+// Action is the end-of-line conversion applied to a file.
+type Action int
+
+const (
+	// Binary files are never converted.
+	Binary Action = iota
+	// TextInput files are text, CRLF is converted to LF when the files are
+	// added, and they are checked out with LF.
+	TextInput
+	// TextCRLF files are text, CRLF is converted to LF when the files are
+	// added, and LF is converted to CRLF when they are checked out.
+	TextCRLF
+	// AutoInput files are converted as TextInput files, only if their
+	// content is detected as text.
+	AutoInput
+	// AutoCRLF files are converted as TextCRLF files, only if their content
+	// is detected as text.
+	AutoCRLF
+)
+
+// Config is the end-of-line configuration of a repository.
+type Config struct {
+	// AutoCRLF is the value of core.autocrlf: "true", "input" or "false".
+	AutoCRLF string
+	// EOL is the value of core.eol: "lf", "crlf" or "native".
+	EOL string
+}
+
+// autoCRLF returns the normalized value of core.autocrlf, an empty string
+// when it is false.
+func (c Config) autoCRLF() string {
+	switch strings.ToLower(c.AutoCRLF) {
+	case "true", "yes", "on", "1":
+		return "true"
+	case inputValue:
+		return inputValue
+	default:
+		return ""
+	}
+}
+
+// IsZero returns true if the config never converts files without the text
+// or eol attributes.
+func (c Config) IsZero() bool {
+	return c.autoCRLF() == ""
+}
+
+// eolIsCRLF returns true if text files are checked out with CRLF.
+func (c Config) eolIsCRLF() bool {
+	switch c.autoCRLF() {
+	case "true":
+		return true
+	case inputValue:
+		return false
+	}
+
+	switch strings.ToLower(c.EOL) {
+	case crlfValue:
+		return true
+	case lfValue:
+		return false
+	default:
+		return runtime.GOOS == "windows"
+	}
+}
+
+// NewAction returns the Action for a file with the given gitattributes, the
+// same way git does it.
+func NewAction(attrs map[string]gitattributes.Attribute, c Config) Action {
+	text := textKind(attrs[textAttribute])
+	if text == "" {
+		text = textKind(attrs[crlfAttribute])
+	}
+
+	if text != binaryValue {
+		switch eolValue(attrs[eolAttribute]) {
+		case lfValue:
+			if text == autoValue {
+				return AutoInput
+			}
+
+			return TextInput
+		case crlfValue:
+			if text == autoValue {
+				return AutoCRLF
+			}
+
+			return TextCRLF
+		}
+	}
+
+	switch text {
+	case binaryValue:
+		return Binary
+	case inputValue:
+		return TextInput
+	case textValue:
+		if c.eolIsCRLF() {
+			return TextCRLF
+		}
+
+		return TextInput
+	case autoValue:
+		if c.eolIsCRLF() {
+			return AutoCRLF
+		}
+
+		return AutoInput
+	}
+
+	switch c.autoCRLF() {
+	case "true":
+		return AutoCRLF
+	case inputValue:
+		return AutoInput
+	default:
+		return Binary
+	}
+}
+
+// textKind returns the kind of file declared by the text attribute, or the
+// legacy crlf attribute, an empty string if it is unspecified.
+func textKind(a gitattributes.Attribute) string {
+	switch {
+	case a == nil || a.IsUnspecified():
+		return ""
+	case a.IsSet():
+		return textValue
+	case a.IsUnset():
+		return binaryValue
+	}
+
+	switch a.Value() {
+	case autoValue, inputValue:
+		return a.Value()
+	default:
+		return ""
+	}
+}
+
+func eolValue(a gitattributes.Attribute) string {
+	if a == nil || !a.IsValueSet() {
+		return ""
+	}
+
+	return a.Value()
+}
+
+// IsAuto returns true if the files are only converted when their content is
+// detected as text.
+func (a Action) IsAuto() bool {
+	return a == AutoInput || a == AutoCRLF
+}
+
+// Clean returns the content stored in the repository for the given content of
+// a file in the worktree, the CRLF line endings are converted to LF.
+func (a Action) Clean(content []byte) []byte {
+	if a == Binary {
+		return content
+	}
+
+	s := newStats(content)
+	if a.IsAuto() && s.isBinary() {
+		return content
+	}
+
+	if s.crlf == 0 {
+		return content
+	}
+
+	return bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+}
+
+// Smudge returns the content written to the worktree for the given content
+// stored in the repository, the LF line endings are converted to CRLF if the
+// files are checked out with CRLF.
+func (a Action) Smudge(content []byte) []byte {
+	if a != TextCRLF && a != AutoCRLF {
+		return content
+	}
+
+	s := newStats(content)
+	if s.lonelf == 0 {
+		return content
+	}
+
+	if a.IsAuto() && (s.lonecr != 0 || s.crlf != 0 || s.isBinary()) {
+		return content
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(content)+s.lonelf))
+	var last byte
+	for _, c := range content {
+		if c == '\n' && last != '\r' {
+			buf.WriteByte('\r')
+		}
+
+		buf.WriteByte(c)
+		last = c
+	}
+
+	return buf.Bytes()
+}
+
+// IsBinary returns true if the content is detected as binary by git, the
+// content of files with a NUL byte, a lone CR or too many non printable
+// characters are not converted by the auto actions.
+func IsBinary(content []byte) bool {
+	return newStats(content).isBinary()
+}
+
+type stats struct {
+	nul, lonecr, lonelf, crlf int
+	printable, nonprintable   int
+}
+
+func newStats(content []byte) *stats {
+	s := &stats{}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\r':
+			if i+1 < len(content) && content[i+1] == '\n' {
+				s.crlf++
+				i++
+			} else {
+				s.lonecr++
+			}
+		case c == '\n':
+			s.lonelf++
+		case c == 127:
+			s.nonprintable++
+		case c < 32:
+			switch c {
+			case '\b', '\t', '\033', '\014':
+				s.printable++
+			case 0:
+				s.nul++
+				s.nonprintable++
+			default:
+				s.nonprintable++
+			}
+		default:
+			s.printable++
+		}
+	}
+
+	// a trailing EOF character is not considered non printable
+	if len(content) > 0 && content[len(content)-1] == '\032' {
+		s.nonprintable--
+	}
+
+	return s
+}
+
+func (s *stats) isBinary() bool {
+	return s.lonecr != 0 || s.nul != 0 || (s.printable>>7) < s.nonprintable
+}
@@ -0,0 +1,115 @@
+package eol
+
+import (
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type EOLSuite struct{}
+
+var _ = Suite(&EOLSuite{})
+
+func (s *EOLSuite) attributes(c *C, line string) map[string]gitattributes.Attribute {
+	stack, err := gitattributes.ReadAttributes(strings.NewReader(line), nil, true)
+	c.Assert(err, IsNil)
+
+	attrs, _ := gitattributes.NewMatcher(stack).Match([]string{"foo"}, nil)
+	return attrs
+}
+
+func (s *EOLSuite) TestNewAction(c *C) {
+	lf := Config{EOL: "lf"}
+	crlf := Config{EOL: "crlf"}
+
+	for _, t := range []struct {
+		attrs  string
+		config Config
+		action Action
+	}{
+		{"", Config{}, Binary},
+		{"", Config{AutoCRLF: "false"}, Binary},
+		{"", Config{AutoCRLF: "true"}, AutoCRLF},
+		{"", Config{AutoCRLF: "input"}, AutoInput},
+		{"foo text", lf, TextInput},
+		{"foo text", crlf, TextCRLF},
+		{"foo text", Config{AutoCRLF: "true", EOL: "lf"}, TextCRLF},
+		{"foo text", Config{AutoCRLF: "input", EOL: "crlf"}, TextInput},
+		{"foo -text", Config{AutoCRLF: "true"}, Binary},
+		{"foo binary", Config{AutoCRLF: "true"}, Binary},
+		{"foo -text eol=crlf", Config{}, Binary},
+		{"foo text=auto", lf, AutoInput},
+		{"foo text=auto", crlf, AutoCRLF},
+		{"foo text=auto eol=lf", crlf, AutoInput},
+		{"foo text=auto eol=crlf", lf, AutoCRLF},
+		{"foo eol=crlf", Config{}, TextCRLF},
+		{"foo eol=lf", Config{AutoCRLF: "true"}, TextInput},
+		{"foo text eol=lf", crlf, TextInput},
+		{"foo crlf", lf, TextInput},
+		{"foo -crlf", Config{AutoCRLF: "true"}, Binary},
+		{"foo crlf=input", crlf, TextInput},
+		{"foo !text", Config{AutoCRLF: "input"}, AutoInput},
+	} {
+		comment := Commentf("%q %#v", t.attrs, t.config)
+		c.Assert(NewAction(s.attributes(c, t.attrs), t.config), Equals, t.action, comment)
+	}
+}
+
+func (s *EOLSuite) TestConfigIsZero(c *C) {
+	c.Assert(Config{}.IsZero(), Equals, true)
+	c.Assert(Config{AutoCRLF: "false", EOL: "crlf"}.IsZero(), Equals, true)
+	c.Assert(Config{AutoCRLF: "true"}.IsZero(), Equals, false)
+	c.Assert(Config{AutoCRLF: "input"}.IsZero(), Equals, false)
+}
+
+func (s *EOLSuite) TestClean(c *C) {
+	for _, t := range []struct {
+		action   Action
+		content  string
+		expected string
+	}{
+		{Binary, "foo\r\nbar\r\n", "foo\r\nbar\r\n"},
+		{TextInput, "foo\r\nbar\r\n", "foo\nbar\n"},
+		{TextCRLF, "foo\r\nbar\n", "foo\nbar\n"},
+		{TextInput, "foo\rbar\r\n", "foo\rbar\n"},
+		{AutoInput, "foo\r\nbar\r\n", "foo\nbar\n"},
+		{AutoCRLF, "foo\r\nbar\r\n", "foo\nbar\n"},
+		{AutoCRLF, "foo\rbar\r\n", "foo\rbar\r\n"},
+		{AutoCRLF, "foo\x00\r\n", "foo\x00\r\n"},
+	} {
+		comment := Commentf("%d %q", t.action, t.content)
+		c.Assert(string(t.action.Clean([]byte(t.content))), Equals, t.expected, comment)
+	}
+}
+
+func (s *EOLSuite) TestSmudge(c *C) {
+	for _, t := range []struct {
+		action   Action
+		content  string
+		expected string
+	}{
+		{Binary, "foo\nbar\n", "foo\nbar\n"},
+		{TextInput, "foo\nbar\n", "foo\nbar\n"},
+		{AutoInput, "foo\nbar\n", "foo\nbar\n"},
+		{TextCRLF, "foo\nbar\n", "foo\r\nbar\r\n"},
+		{TextCRLF, "foo\r\nbar\n", "foo\r\nbar\r\n"},
+		{AutoCRLF, "foo\nbar\n", "foo\r\nbar\r\n"},
+		{AutoCRLF, "foo\r\nbar\n", "foo\r\nbar\n"},
+		{AutoCRLF, "foo\rbar\n", "foo\rbar\n"},
+		{AutoCRLF, "foo\x00\n", "foo\x00\n"},
+	} {
+		comment := Commentf("%d %q", t.action, t.content)
+		c.Assert(string(t.action.Smudge([]byte(t.content))), Equals, t.expected, comment)
+	}
+}
+
+func (s *EOLSuite) TestIsBinary(c *C) {
+	c.Assert(IsBinary([]byte("foo\r\nbar\n\tqux\x1a")), Equals, false)
+	c.Assert(IsBinary([]byte("foo\x00bar")), Equals, true)
+	c.Assert(IsBinary([]byte("foo\rbar")), Equals, true)
+	c.Assert(IsBinary([]byte("\x01\x02\x03")), Equals, true)
+}
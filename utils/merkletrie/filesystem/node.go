@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"path"
@@ -24,6 +25,9 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	filter     Filter
+	skip       func(path string, isDir bool) bool
+	knownHash  func(path string, file os.FileInfo) (plumbing.Hash, bool)
 
 	path     string
 	hash     []byte
@@ -43,6 +47,51 @@ func NewRootNode(
 	return &node{fs: fs, submodules: submodules, isDir: true}
 }
 
+// Filter converts the content of the files of the filesystem to the content
+// stored in the repository, like the end-of-line conversion done by git when
+// the files are added.
+type Filter interface {
+	// Applies returns true if the content of the file at the given path is
+	// converted.
+	Applies(path string) bool
+	// Clean writes to w the content stored in the repository for the
+	// content of the file at path, read from r.
+	Clean(path string, r io.Reader, w io.Writer) error
+}
+
+// Options are the options of the root node returned by
+// NewRootNodeWithOptions.
+type Options struct {
+	// Filter, if set, converts the content of the files before computing
+	// their hashes.
+	Filter Filter
+	// Skip, if set, returns true for the paths of the files and directories
+	// left out of the tree. The files left out are not hashed and the
+	// directories left out are not read.
+	Skip func(path string, isDir bool) bool
+	// KnownHash, if set, returns the hash of the regular file at path with
+	// the given file info, and true, if it is known without reading the
+	// file, such as when its stat data matches the one stored in the index.
+	KnownHash func(path string, file os.FileInfo) (plumbing.Hash, bool)
+}
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem, like NewRootNode, with the given options.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	o Options,
+) noder.Noder {
+	return &node{
+		fs:         fs,
+		submodules: submodules,
+		filter:     o.Filter,
+		skip:       o.Skip,
+		knownHash:  o.KnownHash,
+		isDir:      true,
+	}
+}
+
 // Hash the hash of a filesystem is the result of concatenating the computed
 // plumbing.Hash of the file as a Blob and its plumbing.FileMode; that way the
 // difftree algorithm will detect changes in the contents of files and also in
@@ -100,6 +149,10 @@ func (n *node) calculateChildren() error {
 			continue
 		}
 
+		if n.skip != nil && n.skip(path.Join(n.path, file.Name()), file.IsDir()) {
+			continue
+		}
+
 		c, err := n.newChildNode(file)
 		if err != nil {
 			return err
@@ -122,6 +175,9 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
+		filter:     n.filter,
+		skip:       n.skip,
+		knownHash:  n.knownHash,
 
 		path:  path,
 		hash:  hash,
@@ -162,6 +218,16 @@ func (n *node) calculateHash(path string, file os.FileInfo) ([]byte, error) {
 }
 
 func (n *node) doCalculateHashForRegular(path string, file os.FileInfo) (plumbing.Hash, error) {
+	if n.knownHash != nil {
+		if h, ok := n.knownHash(path, file); ok {
+			return h, nil
+		}
+	}
+
+	if n.filter != nil && n.filter.Applies(path) {
+		return n.doCalculateHashForFiltered(path)
+	}
+
 	f, err := n.fs.Open(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -177,6 +243,29 @@ func (n *node) doCalculateHashForRegular(path string, file os.FileInfo) (plumbin
 	return h.Sum(), nil
 }
 
+func (n *node) doCalculateHashForFiltered(path string) (plumbing.Hash, error) {
+	f, err := n.fs.Open(path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	defer f.Close()
+
+	// the converted content is buffered, since its size is needed to compute
+	// the hash
+	buf := bytes.NewBuffer(nil)
+	if err := n.filter.Clean(path, f, buf); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := plumbing.NewHasher(plumbing.BlobObject, int64(buf.Len()))
+	if _, err := io.Copy(h, buf); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h.Sum(), nil
+}
+
 func (n *node) doCalculateHashForSymlink(path string, file os.FileInfo) (plumbing.Hash, error) {
 	target, err := n.fs.Readlink(path)
 	if err != nil {
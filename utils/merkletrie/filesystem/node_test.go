@@ -3,6 +3,7 @@ package filesystem
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"testing"
@@ -195,6 +196,76 @@ func (s *NoderSuite) TestDiffDirectory(c *C) {
 	c.Assert(a, Equals, merkletrie.Modify)
 }
 
+type crlfFilter struct{}
+
+func (crlfFilter) Applies(path string) bool {
+	return path != "bin"
+}
+
+func (crlfFilter) Clean(path string, r io.Reader, w io.Writer) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1))
+	return err
+}
+
+func (s *NoderSuite) TestDiffWithFilter(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo\n"), 0644)
+	WriteFile(fsA, "qux/bar", []byte("bar\n"), 0644)
+	WriteFile(fsA, "bin", []byte("bin\n"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo\r\n"), 0644)
+	WriteFile(fsB, "qux/bar", []byte("bar\r\n"), 0644)
+	WriteFile(fsB, "bin", []byte("bin\r\n"), 0644)
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNode(fsA, nil),
+		NewRootNodeWithOptions(fsB, nil, Options{Filter: crlfFilter{}}),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 1)
+	c.Assert(ch[0].To.String(), Equals, "bin")
+}
+
+func (s *NoderSuite) TestDiffWithSkip(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo"), 0644)
+	WriteFile(fsA, "qux/bar", []byte("foo"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("bar"), 0644)
+	WriteFile(fsB, "qux/bar", []byte("bar"), 0644)
+	WriteFile(fsB, "qux/baz", []byte("bar"), 0644)
+
+	var skipped []string
+	skip := func(path string, isDir bool) bool {
+		if path == "qux" || path == "qux/bar" {
+			return false
+		}
+
+		skipped = append(skipped, path)
+		return true
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNodeWithOptions(fsA, nil, Options{Skip: skip}),
+		NewRootNodeWithOptions(fsB, nil, Options{Skip: skip}),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 1)
+	c.Assert(ch[0].To.String(), Equals, "qux/bar")
+	c.Assert(skipped, DeepEquals, []string{"foo", "foo", "qux/baz"})
+}
+
 func WriteFile(fs billy.Filesystem, filename string, data []byte, perm os.FileMode) error {
 	f, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
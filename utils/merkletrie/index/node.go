@@ -4,6 +4,8 @@ import (
 	"path"
 	"strings"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
 )
@@ -19,12 +21,20 @@ type node struct {
 	entry    *index.Entry
 	children []noder.Noder
 	isDir    bool
+	hash     []byte
 }
 
 // NewRootNode returns the root node of a computed tree from a index.Index,
+// the directories with a valid entry in the cached tree of the index have
+// the hash of their tree, so they are not walked when compared with a tree.
 func NewRootNode(idx *index.Index) noder.Noder {
 	const rootNode = ""
 
+	var cached map[string]plumbing.Hash
+	if idx.Cache != nil {
+		cached = idx.Cache.Hashes()
+	}
+
 	m := map[string]*node{rootNode: {isDir: true}}
 
 	for _, e := range idx.Entries {
@@ -44,6 +54,9 @@ func NewRootNode(idx *index.Index) noder.Noder {
 				n.entry = e
 			} else {
 				n.isDir = true
+				if h, ok := cached[fullpath]; ok {
+					n.hash = append(h[:], filemode.Dir.Bytes()...)
+				}
 			}
 
 			m[n.path] = n
@@ -64,9 +77,14 @@ func (n *node) String() string {
 // contents of files and also in their mode.
 //
 // If the node is computed and not based on a index.Entry the hash is equals
-// to a 24-bytes slices of zero values.
+// to the hash of its cached tree and the directory mode, or to a 24-bytes
+// slices of zero values if it is not cached.
 func (n *node) Hash() []byte {
 	if n.entry == nil {
+		if n.hash != nil {
+			return n.hash
+		}
+
 		return make([]byte, 24)
 	}
 
@@ -42,6 +42,43 @@ func (s *NoderSuite) TestDiff(c *C) {
 	c.Assert(ch, HasLen, 0)
 }
 
+func (s *NoderSuite) TestDiffCachedTree(c *C) {
+	tree := plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")
+	cache := func() *index.Tree {
+		return &index.Tree{Entries: []index.TreeEntry{
+			{Path: "", Entries: -1, Trees: 1},
+			{Path: "bar", Entries: 1, Trees: 0, Hash: tree},
+		}}
+	}
+
+	// the entries in bar differ, but since both cached trees are equal the
+	// directory is not walked
+	indexA := &index.Index{
+		Entries: []*index.Entry{
+			{Name: "foo", Hash: plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")},
+			{Name: "bar/foo", Hash: plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")},
+		},
+		Cache: cache(),
+	}
+
+	indexB := &index.Index{
+		Entries: []*index.Entry{
+			{Name: "foo", Hash: plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")},
+			{Name: "bar/foo", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+		},
+		Cache: cache(),
+	}
+
+	ch, err := merkletrie.DiffTree(NewRootNode(indexA), NewRootNode(indexB), isEquals)
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 0)
+
+	indexB.Cache.Invalidate("bar/foo")
+	ch, err = merkletrie.DiffTree(NewRootNode(indexA), NewRootNode(indexB), isEquals)
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 1)
+}
+
 func (s *NoderSuite) TestDiffChange(c *C) {
 	indexA := &index.Index{
 		Entries: []*index.Entry{{
@@ -8,11 +8,13 @@ import (
 	stdioutil "io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/filter"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
@@ -38,6 +40,9 @@ type Worktree struct {
 	Filesystem billy.Filesystem
 	// External excludes not found in the repository .gitignore
 	Excludes []gitignore.Pattern
+	// Filters are the clean and smudge filter drivers by name, applied to the
+	// files declared with the filter=<name> gitattribute, like lfs.
+	Filters map[string]filter.Filter
 
 	r *Repository
 }
@@ -341,9 +346,70 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 
 	}
 
+	// the index matches now the tree, so all its subtrees can be cached
+	idx.Cache, err = newCacheTree(w.r.Storer, t)
+	if err != nil {
+		return err
+	}
+
+	if err := w.applySparseCheckout(idx); err != nil {
+		return err
+	}
+
 	return w.r.Storer.SetIndex(idx)
 }
 
+// newCacheTree returns the cached tree of an index with the contents of the
+// given tree.
+func newCacheTree(s storer.EncodedObjectStorer, t *object.Tree) (*index.Tree, error) {
+	cache := &index.Tree{}
+	if _, err := appendCacheTree(s, cache, "", t); err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// appendCacheTree appends to cache the entries of the given tree and all its
+// subtrees, returning the number of index entries under the tree.
+func appendCacheTree(s storer.EncodedObjectStorer, cache *index.Tree, name string, t *object.Tree) (int, error) {
+	var subtrees []string
+	hashes := make(map[string]plumbing.Hash)
+	for _, e := range t.Entries {
+		if e.Mode == filemode.Dir {
+			subtrees = append(subtrees, e.Name)
+			hashes[e.Name] = e.Hash
+		}
+	}
+
+	sort.Sort(cacheTreeNames(subtrees))
+
+	pos := len(cache.Entries)
+	cache.Entries = append(cache.Entries, index.TreeEntry{
+		Path:  name,
+		Trees: len(subtrees),
+		Hash:  t.Hash,
+	})
+
+	count := len(t.Entries) - len(subtrees)
+	for _, name := range subtrees {
+		sub, err := object.GetTree(s, hashes[name])
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := appendCacheTree(s, cache, name, sub)
+		if err != nil {
+			return 0, err
+		}
+
+		count += n
+	}
+
+	cache.Entries[pos].Entries = count
+	return count, nil
+}
+
 func (w *Worktree) resetWorktree(t *object.Tree) error {
 	changes, err := w.diffStagingWithWorktree(true)
 	if err != nil {
@@ -355,16 +421,30 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 		return err
 	}
 
+	filter, err := w.newWorktreeFilter(t, nil)
+	if err != nil {
+		return err
+	}
+
+	// the entries are updated with the hashes they already have in the index,
+	// so the cached tree is kept out of the updates, it is still valid
+	cache := idx.Cache
+	idx.Cache = nil
 	for _, ch := range changes {
-		if err := w.checkoutChange(ch, t, idx); err != nil {
+		if err := w.checkoutChange(ch, t, idx, filter); err != nil {
 			return err
 		}
 	}
 
+	idx.Cache = cache
+	if err := w.removeSkipWorktreeFiles(idx, filter); err != nil {
+		return err
+	}
+
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index) error {
+func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index, filter *worktreeFilter) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -391,7 +471,7 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		return w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(name, a, t, e, idx, filter)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
@@ -478,6 +558,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	t *object.Tree,
 	e *object.TreeEntry,
 	idx *index.Index,
+	filter *worktreeFilter,
 ) error {
 	switch a {
 	case merkletrie.Modify:
@@ -496,7 +577,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 			return err
 		}
 
-		if err := w.checkoutFile(f); err != nil {
+		if err := w.checkoutFile(f, filter); err != nil {
 			return err
 		}
 
@@ -506,7 +587,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	return nil
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+func (w *Worktree) checkoutFile(f *object.File, filter *worktreeFilter) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
@@ -530,6 +611,11 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 
 	defer ioutil.CheckClose(to, &err)
 
+	if filter.Applies(f.Name) {
+		err = filter.Smudge(f.Name, from, to)
+		return
+	}
+
 	_, err = io.Copy(to, from)
 	return
 }
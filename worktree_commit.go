@@ -44,6 +44,11 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
+	// BuildTree updates the cached tree of the index
+	if err := w.r.Storer.SetIndex(idx); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	commit, err := w.buildCommitObject(msg, opts, tree)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -135,14 +140,27 @@ type buildTreeHelper struct {
 
 	trees   map[string]*object.Tree
 	entries map[string]*object.TreeEntry
+	// cached are the hashes of the valid trees in the cached tree of the
+	// index, they are not built again.
+	cached map[string]plumbing.Hash
+	// counts are the number of index entries under each tree.
+	counts map[string]int
+	hashes map[string]plumbing.Hash
 }
 
 // BuildTree builds the tree objects and push its to the storer, the hash
-// of the root tree is returned.
+// of the root tree is returned. The trees still valid in the cached tree of
+// the index are reused, and the cached tree is replaced with the built trees.
 func (h *buildTreeHelper) BuildTree(idx *index.Index) (plumbing.Hash, error) {
 	const rootNode = ""
 	h.trees = map[string]*object.Tree{rootNode: {}}
 	h.entries = map[string]*object.TreeEntry{}
+	h.counts = map[string]int{}
+	h.hashes = map[string]plumbing.Hash{}
+	h.cached = nil
+	if idx.Cache != nil {
+		h.cached = idx.Cache.Hashes()
+	}
 
 	for _, e := range idx.Entries {
 		if err := h.commitIndexEntry(e); err != nil {
@@ -150,7 +168,18 @@ func (h *buildTreeHelper) BuildTree(idx *index.Index) (plumbing.Hash, error) {
 		}
 	}
 
-	return h.copyTreeToStorageRecursive(rootNode, h.trees[rootNode])
+	hash, ok := h.cachedTree(rootNode)
+	if !ok {
+		var err error
+		hash, err = h.copyTreeToStorageRecursive(rootNode, h.trees[rootNode])
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	idx.Cache = &index.Tree{}
+	h.buildCacheTree(idx.Cache, rootNode, rootNode)
+	return hash, nil
 }
 
 func (h *buildTreeHelper) commitIndexEntry(e *index.Entry) error {
@@ -161,6 +190,8 @@ func (h *buildTreeHelper) commitIndexEntry(e *index.Entry) error {
 		parent := fullpath
 		fullpath = path.Join(fullpath, part)
 
+		h.counts[parent]++
+
 		h.doBuildTree(e, parent, fullpath)
 	}
 
@@ -210,6 +241,12 @@ func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tr
 
 		path := path.Join(parent, e.Name)
 
+		if hash, ok := h.cachedTree(path); ok {
+			e.Hash = hash
+			t.Entries[i] = e
+			continue
+		}
+
 		var err error
 		e.Hash, err = h.copyTreeToStorageRecursive(path, h.trees[path])
 		if err != nil {
@@ -224,5 +261,67 @@ func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tr
 		return plumbing.ZeroHash, err
 	}
 
-	return h.s.SetEncodedObject(o)
+	hash, err := h.s.SetEncodedObject(o)
+	h.hashes[parent] = hash
+	return hash, err
+}
+
+// cachedTree returns the hash of the tree at the given path if it is still
+// valid in the cached tree of the index and it is in the storer, so the tree
+// and its subtrees are not built again.
+func (h *buildTreeHelper) cachedTree(path string) (plumbing.Hash, bool) {
+	hash, ok := h.cached[path]
+	if !ok || h.s.HasEncodedObject(hash) != nil {
+		return plumbing.ZeroHash, false
+	}
+
+	h.hashes[path] = hash
+	return hash, true
+}
+
+// buildCacheTree appends to cache the entries of the given built tree and all
+// its subtrees.
+func (h *buildTreeHelper) buildCacheTree(cache *index.Tree, name, fullpath string) {
+	var subtrees []string
+	for _, e := range h.trees[fullpath].Entries {
+		if e.Mode == filemode.Dir {
+			subtrees = append(subtrees, e.Name)
+		}
+	}
+
+	sort.Sort(cacheTreeNames(subtrees))
+	entry := index.TreeEntry{
+		Path:    name,
+		Entries: h.counts[fullpath],
+		Trees:   len(subtrees),
+	}
+
+	// the subtrees of a reused tree were not built, their hashes are the
+	// cached ones, if any
+	var ok bool
+	if entry.Hash, ok = h.hashes[fullpath]; !ok {
+		if entry.Hash, ok = h.cached[fullpath]; !ok {
+			entry.Entries = -1
+		}
+	}
+
+	cache.Entries = append(cache.Entries, entry)
+
+	for _, name := range subtrees {
+		h.buildCacheTree(cache, name, path.Join(fullpath, name))
+	}
+}
+
+// cacheTreeNames sorts the names of the subtrees in a cached tree as git
+// does, shorter names first.
+type cacheTreeNames []string
+
+func (n cacheTreeNames) Len() int      { return len(n) }
+func (n cacheTreeNames) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n cacheTreeNames) Less(i, j int) bool {
+	if len(n[i]) != len(n[j]) {
+		return len(n[i]) < len(n[j])
+	}
+
+	return n[i] < n[j]
 }
@@ -12,6 +12,7 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 
@@ -249,6 +250,121 @@ func (s *WorktreeSuite) TestCommitTreeSort(c *C) {
 	c.Assert(err, IsNil, Commentf("%s", buf.Bytes()))
 }
 
+func (s *WorktreeSuite) TestCommitCacheTree(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{})
+	c.Assert(err, IsNil)
+
+	head, err := s.Repository.Head()
+	c.Assert(err, IsNil)
+	commit, err := s.Repository.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+
+	idx, err := s.Repository.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Cache.Hashes()[""], Equals, commit.TreeHash)
+	c.Assert(idx.Cache.Entries[0].Entries, Equals, len(idx.Entries))
+
+	util.WriteFile(fs, "go/foo", []byte("foo"), 0644)
+	_, err = w.Add("go/foo")
+	c.Assert(err, IsNil)
+
+	idx, err = s.Repository.Storer.Index()
+	c.Assert(err, IsNil)
+	hashes := idx.Cache.Hashes()
+	c.Assert(hashes, HasLen, 3)
+	c.Assert(hashes["json"], Not(Equals), plumbing.ZeroHash)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err = s.Repository.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	// the tree built reusing the cached trees is the same than the one built
+	// from scratch
+	idx, err = s.Repository.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Cache.Hashes()[""], Equals, commit.TreeHash)
+
+	idx.Cache = nil
+	h := &buildTreeHelper{fs: fs, s: s.Repository.Storer}
+	tree, err := h.BuildTree(idx)
+	c.Assert(err, IsNil)
+	c.Assert(tree, Equals, commit.TreeHash)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+// countingStorer counts the objects stored and looked up in a storer.
+type countingStorer struct {
+	storage.Storer
+	set, has int
+}
+
+func (s *countingStorer) SetEncodedObject(o plumbing.EncodedObject) (plumbing.Hash, error) {
+	s.set++
+	return s.Storer.SetEncodedObject(o)
+}
+
+func (s *countingStorer) HasEncodedObject(h plumbing.Hash) error {
+	s.has++
+	return s.Storer.HasEncodedObject(h)
+}
+
+func (s *WorktreeSuite) TestCommitCacheTreeSkipsUnchangedTrees(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"a/b/c/foo", "a/b/bar", "x/y/qux", "baz"} {
+		c.Assert(util.WriteFile(fs, name, []byte(name), 0644), IsNil)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "x/y/qux", []byte("qux"), 0644), IsNil)
+	_, err = w.Add("x/y/qux")
+	c.Assert(err, IsNil)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	st := &countingStorer{Storer: r.Storer}
+	h := &buildTreeHelper{fs: fs, s: st}
+	tree, err := h.BuildTree(idx)
+	c.Assert(err, IsNil)
+
+	// only the trees of x/y, x and the root are written, and the subtrees of
+	// the reused tree of a are not visited
+	c.Assert(st.set, Equals, 3)
+	c.Assert(st.has, Equals, 1)
+
+	hashes := idx.Cache.Hashes()
+	c.Assert(hashes, HasLen, 6)
+	c.Assert(hashes[""], Equals, tree)
+
+	idx.Cache = nil
+	h = &buildTreeHelper{fs: fs, s: r.Storer}
+	expected, err := h.BuildTree(idx)
+	c.Assert(err, IsNil)
+	c.Assert(tree, Equals, expected)
+	c.Assert(idx.Cache.Hashes(), DeepEquals, hashes)
+}
+
 func assertStorageStatus(
 	c *C, r *Repository,
 	treesCount, blobCount, commitCount int, head plumbing.Hash,
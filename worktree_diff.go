@@ -0,0 +1,355 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
+)
+
+// Diff returns the patch of the changes of the worktree, or of the index if
+// o.Cached is set, as `git diff` does. The untracked files are not part of
+// the changes of the worktree.
+func (w *Worktree) Diff(o *DiffOptions) (*object.Patch, error) {
+	if o == nil {
+		o = &DiffOptions{}
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := w.diffTree(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes object.FileChanges
+	switch {
+	case o.Cached:
+		changes, err = w.diffTreeWithStagingFiles(t, o.Paths)
+	case o.Against != "":
+		changes, err = w.diffTreeWithWorktreeFiles(t, idx, o.Paths)
+	default:
+		changes, err = w.diffStagingWithWorktreeFiles(idx, o.Paths)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return changes.PatchWithOptions(context.Background(), o.PatchOptions)
+}
+
+// diffTree returns the tree of the revision the diff is against, nil if the
+// worktree is compared with the index or HEAD does not exist yet.
+func (w *Worktree) diffTree(o *DiffOptions) (*object.Tree, error) {
+	if o.Against != "" {
+		h, err := w.r.ResolveRevision(o.Against)
+		if err != nil {
+			return nil, err
+		}
+
+		return w.getTreeFromCommitHash(*h)
+	}
+
+	if !o.Cached {
+		return nil, nil
+	}
+
+	ref, err := w.r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return w.getTreeFromCommitHash(ref.Hash())
+}
+
+func (w *Worktree) diffTreeWithStagingFiles(t *object.Tree, paths []string) (object.FileChanges, error) {
+	changes, err := w.diffTreeWithStaging(t, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var res object.FileChanges
+	for _, ch := range filterChangesByPaths(changes, paths) {
+		from, err := w.storedDiffFile(ch.From)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := w.storedDiffFile(ch.To)
+		if err != nil {
+			return nil, err
+		}
+
+		res = appendFileChange(res, from, to)
+	}
+
+	return res, nil
+}
+
+func (w *Worktree) diffStagingWithWorktreeFiles(idx *index.Index, paths []string) (object.FileChanges, error) {
+	changes, err := w.diffStagingWithWorktree(false)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := w.newWorktreeFilter(nil, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res object.FileChanges
+	for _, ch := range filterChangesByPaths(changes, paths) {
+		if len(ch.From) == 0 {
+			// untracked file
+			continue
+		}
+
+		from, err := w.storedDiffFile(ch.From)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := w.worktreeDiffFile(ch.To, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		res = appendFileChange(res, from, to)
+	}
+
+	return res, nil
+}
+
+// diffTreeWithWorktreeFiles returns the changes from the tree to the files
+// of the worktree which are in the index, as `git diff <rev>` does. The files
+// of the tree which are not in the index are deleted.
+func (w *Worktree) diffTreeWithWorktreeFiles(t *object.Tree, idx *index.Index, paths []string) (object.FileChanges, error) {
+	cache, err := w.newIndexStatCache(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	to, filter, err := w.worktreeRootNode(idx, nil, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := merkletrie.DiffTree(object.NewTreeRootNode(t), to, diffTreeIsEquals)
+	if err != nil {
+		return nil, err
+	}
+
+	changes = excludeSkipWorktreeChanges(idx, changes)
+
+	tracked := make(map[string]bool, len(idx.Entries))
+	for _, e := range idx.Entries {
+		tracked[e.Name] = true
+	}
+
+	changed := make(map[string]bool)
+	var res object.FileChanges
+	for _, ch := range filterChangesByPaths(changes, paths) {
+		name := nameFromAction(&ch)
+		changed[name] = true
+		if !tracked[name] {
+			if len(ch.From) == 0 {
+				// untracked file
+				continue
+			}
+
+			ch.To = nil
+		}
+
+		from, err := w.storedDiffFile(ch.From)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := w.worktreeDiffFile(ch.To, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		res = appendFileChange(res, from, to)
+	}
+
+	match := diffPathFilter(paths)
+	err = t.Files().ForEach(func(f *object.File) error {
+		if !tracked[f.Name] && !changed[f.Name] && match(f.Name) {
+			res = appendFileChange(res, f, nil)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return fileChangeName(res[i]) < fileChangeName(res[j])
+	})
+
+	return res, nil
+}
+
+// storedDiffFile returns the file of a node of a tree or the index, whose
+// content is stored in the repository, nil if there is no node or it is not
+// a file.
+func (w *Worktree) storedDiffFile(p noder.Path) (*object.File, error) {
+	h, mode, ok := diffFileHash(p)
+	if !ok {
+		return nil, nil
+	}
+
+	blob, err := object.GetBlob(w.r.Storer, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewFile(p.String(), mode, blob), nil
+}
+
+// worktreeDiffFile returns the file of a node of the worktree, with its
+// content converted by the filter as if it was added to the index, nil if
+// there is no node or it is not a file.
+func (w *Worktree) worktreeDiffFile(p noder.Path, filter *worktreeFilter) (*object.File, error) {
+	_, mode, ok := diffFileHash(p)
+	if !ok {
+		return nil, nil
+	}
+
+	name := p.String()
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := w.Filesystem.Readlink(name)
+		if err != nil {
+			return nil, err
+		}
+
+		content = []byte(target)
+	} else if filter.Applies(name) {
+		buf := bytes.NewBuffer(nil)
+		if err := w.fillFilteredObjectFromFile(buf, name, filter); err != nil {
+			return nil, err
+		}
+
+		content = buf.Bytes()
+	} else {
+		content, err = w.readWorktreeFile(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.BlobObject)
+	if _, err := obj.Write(content); err != nil {
+		return nil, err
+	}
+
+	blob, err := object.DecodeBlob(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewFile(name, mode, blob), nil
+}
+
+func (w *Worktree) readWorktreeFile(name string) ([]byte, error) {
+	f, err := w.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// diffFileHash returns the hash and the mode of the file of a node, and false
+// if there is no node or it is not a file.
+func diffFileHash(p noder.Path) (plumbing.Hash, filemode.FileMode, bool) {
+	if len(p) == 0 || p.IsDir() {
+		return plumbing.ZeroHash, filemode.Empty, false
+	}
+
+	hash := p.Hash()
+	if len(hash) != 24 {
+		return plumbing.ZeroHash, filemode.Empty, false
+	}
+
+	mode := filemode.FileMode(binary.LittleEndian.Uint32(hash[20:]))
+	if !mode.IsFile() {
+		return plumbing.ZeroHash, filemode.Empty, false
+	}
+
+	var h plumbing.Hash
+	copy(h[:], hash[:20])
+	return h, mode, true
+}
+
+func appendFileChange(changes object.FileChanges, from, to *object.File) object.FileChanges {
+	if from == nil && to == nil {
+		return changes
+	}
+
+	return append(changes, object.FileChange{From: from, To: to})
+}
+
+func fileChangeName(c object.FileChange) string {
+	if c.From != nil {
+		return c.From.Name
+	}
+
+	return c.To.Name
+}
+
+// filterChangesByPaths returns the changes of the files matching the paths,
+// or inside them, all of them if there are no paths.
+func filterChangesByPaths(changes merkletrie.Changes, paths []string) merkletrie.Changes {
+	if len(paths) == 0 {
+		return changes
+	}
+
+	match := diffPathFilter(paths)
+	var res merkletrie.Changes
+	for _, ch := range changes {
+		if match(nameFromAction(&ch)) {
+			res = append(res, ch)
+		}
+	}
+
+	return res
+}
+
+// diffPathFilter returns a function matching the files matching the paths,
+// or inside them, any file if there are no paths.
+func diffPathFilter(paths []string) func(string) bool {
+	if len(paths) == 0 {
+		return func(string) bool { return true }
+	}
+
+	return logPathFilter(nil, paths)
+}
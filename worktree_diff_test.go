@@ -0,0 +1,138 @@
+package git
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) newDiffWorktree(c *C) *Worktree {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("a\nb\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(w.Filesystem, "qux/bar", []byte("x\n"), 0644), IsNil)
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "qux/bar", []byte("y\n"), 0644), IsNil)
+	_, err = w.Add("qux/bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("a\nc\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(w.Filesystem, "untracked", []byte("z\n"), 0644), IsNil)
+	return w
+}
+
+const (
+	diffFooPatch = `diff --git a/foo b/foo
+index 422c2b7ab3b3c668038da977e4e93a5fc623169c..0f7bc766052a5a0ee28a393d51d2370f96d8ceb8 100644
+--- a/foo
++++ b/foo
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`
+	diffBarPatch = `diff --git a/qux/bar b/qux/bar
+index 587be6b4c3f93f93c489c0111bba5596147a26cb..975fbec8256d3e8a3797e7a3611380f27c49f4ac 100644
+--- a/qux/bar
++++ b/qux/bar
+@@ -1 +1 @@
+-x
++y
+`
+)
+
+func (s *WorktreeSuite) TestDiff(c *C) {
+	w := s.newDiffWorktree(c)
+
+	patch, err := w.Diff(nil)
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, diffFooPatch)
+}
+
+func (s *WorktreeSuite) TestDiffCached(c *C) {
+	w := s.newDiffWorktree(c)
+
+	patch, err := w.Diff(&DiffOptions{Cached: true})
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, diffBarPatch)
+
+	patch, err = w.Diff(&DiffOptions{Cached: true, Against: "HEAD", Paths: []string{"foo"}})
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, "")
+}
+
+func (s *WorktreeSuite) TestDiffAgainst(c *C) {
+	w := s.newDiffWorktree(c)
+
+	patch, err := w.Diff(&DiffOptions{Against: "HEAD"})
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, diffFooPatch+diffBarPatch)
+
+	patch, err = w.Diff(&DiffOptions{Against: "HEAD", Paths: []string{"qux/"}})
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, diffBarPatch)
+}
+
+func (s *WorktreeSuite) TestDiffAgainstUntrackedInIndex(c *C) {
+	w := s.newDiffWorktree(c)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	_, err = idx.Remove("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(w.r.Storer.SetIndex(idx), IsNil)
+
+	patch, err := w.Diff(&DiffOptions{Against: "HEAD", Paths: []string{"qux"}})
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, `diff --git a/qux/bar b/qux/bar
+deleted file mode 100644
+index 587be6b4c3f93f93c489c0111bba5596147a26cb..0000000000000000000000000000000000000000
+--- a/qux/bar
++++ /dev/null
+@@ -1 +0,0 @@
+-x
+`)
+}
+
+func (s *WorktreeSuite) TestDiffDeletedAndInitial(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("a\n"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	patch, err := w.Diff(&DiffOptions{Cached: true})
+	c.Assert(err, IsNil)
+	c.Assert(patch.String(), Equals, `diff --git a/foo b/foo
+new file mode 100644
+index 0000000000000000000000000000000000000000..78981922613b2afb6025042ff6bd878ac1994e85
+--- /dev/null
++++ b/foo
+@@ -0,0 +1 @@
++a
+`)
+
+	c.Assert(w.Filesystem.Remove("foo"), IsNil)
+	patch, err = w.Diff(&DiffOptions{PatchOptions: &object.PatchOptions{Context: -1}})
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(patch.String(), "diff --git a/foo b/foo\ndeleted file mode 100644\n"), Equals, true)
+	c.Assert(patch.Stats(), DeepEquals, object.FileStats{{Name: "foo", Deletion: 1}})
+}
@@ -0,0 +1,277 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filter"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/eol"
+)
+
+const filterAttribute = "filter"
+
+// filterAttributes are the gitattributes driving the conversion of the files,
+// the end-of-line conversion ones and the filter driver.
+var filterAttributes = []string{"text", "eol", "crlf", filterAttribute}
+
+// worktreeFilter converts the content of the files between the worktree and
+// the repository, with the filter drivers and the end-of-line conversion
+// declared by the configuration and the gitattributes. It implements
+// filesystem.Filter, a nil worktreeFilter never converts any file.
+type worktreeFilter struct {
+	s       storer.EncodedObjectStorer
+	idx     *index.Index
+	eol     eol.Config
+	drivers map[string]filter.Filter
+
+	// global and info are the attributes around the ones of the
+	// .gitattributes files, which are read by load one directory at a time,
+	// the first time a file in the directory is converted.
+	global   []gitattributes.MatchAttribute
+	info     []gitattributes.MatchAttribute
+	load     func(dir []string) ([]gitattributes.MatchAttribute, error)
+	stacks   map[string][]gitattributes.MatchAttribute
+	matchers map[string]gitattributes.Matcher
+}
+
+// newWorktreeFilter returns the filter of the files of the worktree, the
+// gitattributes are read from the given tree, or from the worktree if t is
+// nil. The files with CR characters in the given index are not converted by
+// the auto end-of-line conversion, as git does; idx may be nil.
+func (w *Worktree) newWorktreeFilter(t *object.Tree, idx *index.Index) (*worktreeFilter, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	global, err := w.r.globalAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := w.r.infoAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	load := func(dir []string) ([]gitattributes.MatchAttribute, error) {
+		return gitattributes.ReadAttributesFile(w.Filesystem, dir, gitattributesFile, len(dir) == 0)
+	}
+
+	if t != nil {
+		load = func(dir []string) ([]gitattributes.MatchAttribute, error) {
+			return readTreeAttributes(t, dir)
+		}
+	}
+
+	return &worktreeFilter{
+		s:        w.r.Storer,
+		idx:      idx,
+		eol:      eol.Config{AutoCRLF: cfg.Core.AutoCRLF, EOL: cfg.Core.EOL},
+		drivers:  w.Filters,
+		global:   global,
+		info:     info,
+		load:     load,
+		stacks:   make(map[string][]gitattributes.MatchAttribute),
+		matchers: make(map[string]gitattributes.Matcher),
+	}, nil
+}
+
+// actions returns the filter driver and the end-of-line conversion of the
+// file at path, the driver is nil if none is declared or it is not found.
+func (f *worktreeFilter) actions(path string) (filter.Filter, eol.Action, error) {
+	if f == nil {
+		return nil, eol.Binary, nil
+	}
+
+	m, err := f.matcher(attributesDirs(path))
+	if err != nil {
+		return nil, eol.Binary, err
+	}
+
+	attrs, _ := m.Match(attributesPath(path), filterAttributes)
+
+	var driver filter.Filter
+	if a, ok := attrs[filterAttribute]; ok && a.IsValueSet() {
+		driver = f.drivers[a.Value()]
+	}
+
+	return driver, eol.NewAction(attrs, f.eol), nil
+}
+
+// matcher returns the matcher of the files in the directory dir, with the
+// attributes of its .gitattributes file and the ones of its parents.
+func (f *worktreeFilter) matcher(dir []string) (gitattributes.Matcher, error) {
+	key := strings.Join(dir, "/")
+	if m, ok := f.matchers[key]; ok {
+		return m, nil
+	}
+
+	attributes, err := f.dirAttributes(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := make([]gitattributes.MatchAttribute, 0, len(f.global)+len(attributes)+len(f.info))
+	stack = append(append(append(stack, f.global...), attributes...), f.info...)
+
+	m := gitattributes.NewMatcher(stack)
+	f.matchers[key] = m
+	return m, nil
+}
+
+// dirAttributes returns the attributes of the .gitattributes files of the
+// directory dir and its parents, in the ascending order of priority.
+func (f *worktreeFilter) dirAttributes(dir []string) ([]gitattributes.MatchAttribute, error) {
+	key := strings.Join(dir, "/")
+	if attributes, ok := f.stacks[key]; ok {
+		return attributes, nil
+	}
+
+	var parent []gitattributes.MatchAttribute
+	if len(dir) > 0 {
+		var err error
+		parent, err = f.dirAttributes(dir[:len(dir)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attributes, err := f.load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := make([]gitattributes.MatchAttribute, 0, len(parent)+len(attributes))
+	stack = append(append(stack, parent...), attributes...)
+
+	f.stacks[key] = stack
+	return stack, nil
+}
+
+// Applies returns true if the content of the file at path is converted, or
+// if its gitattributes can not be read, so the error is returned converting
+// it.
+func (f *worktreeFilter) Applies(path string) bool {
+	driver, a, err := f.actions(path)
+	return err != nil || driver != nil || a != eol.Binary
+}
+
+// Clean writes to w the content stored in the repository for the content of
+// the file at path of the worktree read from r, it is converted by the filter
+// driver and then the end-of-line conversion. The content is streamed to the
+// driver, it is only buffered when the end-of-line conversion applies.
+func (f *worktreeFilter) Clean(path string, r io.Reader, w io.Writer) error {
+	driver, a, err := f.actions(path)
+	if err != nil {
+		return err
+	}
+
+	if a == eol.Binary {
+		if driver != nil {
+			return driver.Clean(path, r, w)
+		}
+
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	if driver != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := driver.Clean(path, r, buf); err != nil {
+			return err
+		}
+
+		r = buf
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if a.IsAuto() && bytes.Contains(content, []byte("\r\n")) {
+		cr, err := f.hasCRInIndex(path)
+		if err != nil {
+			return err
+		}
+
+		if cr {
+			_, err := w.Write(content)
+			return err
+		}
+	}
+
+	_, err = w.Write(a.Clean(content))
+	return err
+}
+
+// Smudge writes to w the content of the file at path of the worktree for the
+// content stored in the repository read from r, it is converted by the
+// end-of-line conversion and then the filter driver. The content is streamed
+// to the driver, it is only buffered when the end-of-line conversion applies.
+func (f *worktreeFilter) Smudge(path string, r io.Reader, w io.Writer) error {
+	driver, a, err := f.actions(path)
+	if err != nil {
+		return err
+	}
+
+	if a != eol.Binary {
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		r = bytes.NewReader(a.Smudge(content))
+	}
+
+	if driver != nil {
+		return driver.Smudge(path, r, w)
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// hasCRInIndex returns true if the blob of the file at path in the index has
+// any CR character.
+func (f *worktreeFilter) hasCRInIndex(path string) (bool, error) {
+	if f.idx == nil {
+		return false, nil
+	}
+
+	e, err := f.idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	obj, err := f.s.EncodedObject(plumbing.BlobObject, e.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	r, err := obj.Reader()
+	if err != nil {
+		return false, err
+	}
+
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.IndexByte(content, '\r') >= 0, nil
+}
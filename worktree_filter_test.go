@@ -0,0 +1,403 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filter"
+	"gopkg.in/src-d/go-git.v4/plumbing/filter/lfs"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) setAutoCRLF(c *C, autocrlf string) {
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+
+	cfg.Core.AutoCRLF = autocrlf
+	c.Assert(s.Repository.Storer.SetConfig(cfg), IsNil)
+}
+
+func (s *WorktreeSuite) blobContent(c *C, h plumbing.Hash) string {
+	obj, err := s.Repository.Storer.EncodedObject(plumbing.BlobObject, h)
+	c.Assert(err, IsNil)
+
+	r, err := obj.Reader()
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *WorktreeSuite) readFile(c *C, fs billy.Filesystem, name string) []byte {
+	f, err := fs.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return content
+}
+
+func (s *WorktreeSuite) TestCheckoutAutoCRLF(c *C) {
+	s.setAutoCRLF(c, "true")
+
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	content := s.readFile(c, w.Filesystem, "CHANGELOG")
+	c.Assert(string(content), Equals, "Initial changelog\r\n")
+
+	content = s.readFile(c, w.Filesystem, "binary.jpg")
+	c.Assert(bytes.Contains(content, []byte("\r\n")), Equals, false)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestAddAutoCRLF(c *C) {
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	s.setAutoCRLF(c, "input")
+
+	err = util.WriteFile(w.Filesystem, "CHANGELOG", []byte("Initial changelog\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo\r\nbar\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Add("foo")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\nbar\n")
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("foo").Staging, Equals, Added)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	// the content is detected as binary
+	err = util.WriteFile(w.Filesystem, "bar", []byte("foo\r\nbar\x00\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err = w.Add("bar")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\r\nbar\x00\r\n")
+}
+
+func (s *WorktreeSuite) TestAutoCRLFWithCRInIndex(c *C) {
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo\r\nbar\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Add("foo")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\r\nbar\r\n")
+
+	// the files already stored with CRLF are not normalized
+	s.setAutoCRLF(c, "true")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo\r\nbar\r\nqux\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err = w.Add("foo")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\r\nbar\r\nqux\r\n")
+}
+
+func (s *WorktreeSuite) TestEOLAttributes(c *C) {
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, ".gitattributes",
+		[]byte("*.txt text eol=crlf\n*.bat -text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo.txt", []byte("foo\r\nbar\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo.bat", []byte("foo\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = w.AddGlob("*")
+	c.Assert(err, IsNil)
+
+	idx, err := s.Repository.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry("foo.txt")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, e.Hash), Equals, "foo\nbar\n")
+
+	e, err = idx.Entry("foo.bat")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, e.Hash), Equals, "foo\r\n")
+
+	commit, err := w.Commit("eol", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	// the attributes of the checked out tree are used
+	w.Filesystem = memfs.New()
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+
+	content := s.readFile(c, w.Filesystem, "foo.txt")
+	c.Assert(string(content), Equals, "foo\r\nbar\r\n")
+
+	content = s.readFile(c, w.Filesystem, "CHANGELOG")
+	c.Assert(string(content), Equals, "Initial changelog\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestFilterDriverLFS(c *C) {
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repo.git/info/lfs/objects/batch" {
+			_, _ = w.Write(objects[path.Base(r.URL.Path)])
+			return
+		}
+
+		var req struct {
+			Objects []struct {
+				Oid  string `json:"oid"`
+				Size int64  `json:"size"`
+			} `json:"objects"`
+		}
+
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		c.Assert(req.Objects, HasLen, 1)
+
+		fmt.Fprintf(w, `{"objects":[{"oid":%q,"size":%d,"actions":{"download":{"href":%q}}}]}`,
+			req.Objects[0].Oid, req.Objects[0].Size, "http://"+r.Host+"/objects/"+req.Objects[0].Oid)
+	}))
+
+	defer server.Close()
+
+	endpoint := server.URL + "/repo.git/info/lfs"
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+		Filters: map[string]filter.Filter{
+			lfs.Name: lfs.NewFilter(memfs.New(), endpoint, server.Client()),
+		},
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, ".gitattributes", []byte("*.psd filter=lfs -text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	content := bytes.Repeat([]byte("foo\r\n"), 1024)
+	err = util.WriteFile(w.Filesystem, "foo.psd", content, 0644)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Add("foo.psd")
+	c.Assert(err, IsNil)
+
+	p, err := lfs.DecodePointer([]byte(s.blobContent(c, hash)))
+	c.Assert(err, IsNil)
+	c.Assert(p.Size, Equals, int64(len(content)))
+
+	_, err = w.Add(".gitattributes")
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo.psd").Worktree, Equals, Unmodified)
+
+	commit, err := w.Commit("lfs", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	// without the driver the pointer is checked out
+	w.Filesystem = memfs.New()
+	w.Filters = nil
+
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+	c.Assert(string(s.readFile(c, w.Filesystem, "foo.psd")), Equals, p.String())
+
+	// the object is fetched from the server, missing in the local storage
+	w.Filesystem = memfs.New()
+	w.Filters = map[string]filter.Filter{
+		lfs.Name: lfs.NewFilter(memfs.New(), endpoint, server.Client()),
+	}
+
+	objects[p.Oid] = content
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+	c.Assert(s.readFile(c, w.Filesystem, "foo.psd"), DeepEquals, content)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+// openCountFS counts the files opened in a filesystem by name.
+type openCountFS struct {
+	billy.Filesystem
+	opens map[string]int
+}
+
+func (fs *openCountFS) Open(filename string) (billy.File, error) {
+	fs.opens[filename]++
+	return fs.Filesystem.Open(filename)
+}
+
+func (s *WorktreeSuite) TestEOLAttributesReadPerDirectory(c *C) {
+	fs := &openCountFS{Filesystem: memfs.New(), opens: make(map[string]int)}
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(fs, "go/.gitattributes", []byte("*.go text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(fs, "go/example.go", []byte("foo\r\nbar\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	fs.opens = make(map[string]int)
+	status, err := w.StatusWithOptions(&StatusOptions{Paths: []string{"go"}})
+	c.Assert(err, IsNil)
+	c.Assert(status.File("go/example.go").Worktree, Equals, Modified)
+
+	// only the .gitattributes files of the directories of the hashed files
+	// are read, go/.gitattributes is also hashed as an untracked file
+	c.Assert(fs.opens[".gitattributes"], Equals, 1)
+	c.Assert(fs.opens["go/.gitattributes"], Equals, 2)
+	c.Assert(fs.opens["vendor/.gitattributes"], Equals, 0)
+	c.Assert(fs.opens["json/.gitattributes"], Equals, 0)
+
+	hash, err := w.Add("go/example.go")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\nbar\n")
+}
+
+// streamFilter is a filter driver counting its calls, and the ones the files
+// of the worktree are streamed to it.
+type streamFilter struct {
+	cleans, smudges                 int
+	streamedCleans, streamedSmudges int
+}
+
+func (f *streamFilter) Clean(path string, r io.Reader, w io.Writer) error {
+	f.cleans++
+	if _, ok := r.(billy.File); ok {
+		f.streamedCleans++
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func (f *streamFilter) Smudge(path string, r io.Reader, w io.Writer) error {
+	f.smudges++
+	if _, ok := w.(billy.File); ok {
+		f.streamedSmudges++
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func (s *WorktreeSuite) TestFilterDriverStreaming(c *C) {
+	driver := &streamFilter{}
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+		Filters:    map[string]filter.Filter{"stream": driver},
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, ".gitattributes",
+		[]byte("*.bin filter=stream\n*.txt filter=stream text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo.bin", []byte("foo\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo.txt", []byte("foo\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Add("foo.bin")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\r\n")
+
+	// the end-of-line conversion is applied to the output of the driver
+	hash, err = w.Add("foo.txt")
+	c.Assert(err, IsNil)
+	c.Assert(s.blobContent(c, hash), Equals, "foo\n")
+
+	_, err = w.Add(".gitattributes")
+	c.Assert(err, IsNil)
+
+	commit, err := w.Commit("stream", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	w.Filesystem = memfs.New()
+	err = w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+	c.Assert(err, IsNil)
+
+	c.Assert(string(s.readFile(c, w.Filesystem, "foo.bin")), Equals, "foo\r\n")
+	c.Assert(string(s.readFile(c, w.Filesystem, "foo.txt")), Equals, "foo\n")
+
+	// the files are read and written by the driver, even with the
+	// end-of-line conversion
+	c.Assert(driver.cleans > 0, Equals, true)
+	c.Assert(driver.streamedCleans, Equals, driver.cleans)
+	c.Assert(driver.smudges, Equals, 2)
+	c.Assert(driver.streamedSmudges, Equals, 2)
+}
@@ -0,0 +1,248 @@
+package git
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+const (
+	// maxRenameScore is the score of the files with the same content.
+	maxRenameScore = 60000
+	// minRenameScore is the minimum score of the renamed files, a 50% of
+	// similarity, as the default one of git.
+	minRenameScore = 30000
+
+	// spanHashBase is the modulus of the hashes of the spans of a file.
+	spanHashBase = 107927
+	// maxSpanLength is the maximum length of a span of a file.
+	maxSpanLength = 64
+)
+
+// renameFile is a deleted or added file, candidate to be part of a rename.
+type renameFile struct {
+	name  string
+	hash  plumbing.Hash
+	size  int64
+	spans map[uint32]int
+}
+
+// detectRenames replaces the deleted and added files of the staging area of
+// the status with renames, pairing the files with the same content and
+// then the most similar ones, as git does.
+func (w *Worktree) detectRenames(s Status, changes merkletrie.Changes) error {
+	var deleted, added []*renameFile
+	for _, ch := range changes {
+		a, err := ch.Action()
+		if err != nil {
+			return err
+		}
+
+		switch a {
+		case merkletrie.Delete:
+			if h, _, ok := diffFileHash(ch.From); ok {
+				deleted = append(deleted, &renameFile{name: ch.From.String(), hash: h})
+			}
+		case merkletrie.Insert:
+			if h, _, ok := diffFileHash(ch.To); ok {
+				added = append(added, &renameFile{name: ch.To.String(), hash: h})
+			}
+		}
+	}
+
+	if len(deleted) == 0 || len(added) == 0 {
+		return nil
+	}
+
+	renames := make(map[*renameFile]*renameFile)
+	used := make(map[*renameFile]bool)
+	for _, to := range added {
+		if from := exactRename(to, deleted, used); from != nil {
+			renames[to] = from
+			used[from] = true
+		}
+	}
+
+	type candidate struct {
+		from, to *renameFile
+		score    int
+	}
+
+	var candidates []candidate
+	for _, to := range added {
+		if renames[to] != nil {
+			continue
+		}
+
+		for _, from := range deleted {
+			if used[from] {
+				continue
+			}
+
+			score, err := w.renameScore(from, to)
+			if err != nil {
+				return err
+			}
+
+			if score >= minRenameScore {
+				candidates = append(candidates, candidate{from: from, to: to, score: score})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	for _, c := range candidates {
+		if renames[c.to] != nil || used[c.from] {
+			continue
+		}
+
+		renames[c.to] = c.from
+		used[c.from] = true
+	}
+
+	for to, from := range renames {
+		delete(s, from.name)
+		fs := s.File(to.name)
+		fs.Staging = Renamed
+		fs.Extra = from.name
+	}
+
+	return nil
+}
+
+// exactRename returns the unused deleted file with the same content than the
+// added one, preferring the ones with the same base name, nil if there is
+// none. Empty files are never renamed.
+func exactRename(to *renameFile, deleted []*renameFile, used map[*renameFile]bool) *renameFile {
+	if to.hash == plumbing.ZeroHash || to.hash == emptyBlobHash {
+		return nil
+	}
+
+	var match *renameFile
+	for _, from := range deleted {
+		if used[from] || from.hash != to.hash {
+			continue
+		}
+
+		if path.Base(from.name) == path.Base(to.name) {
+			return from
+		}
+
+		if match == nil {
+			match = from
+		}
+	}
+
+	return match
+}
+
+var emptyBlobHash = plumbing.ComputeHash(plumbing.BlobObject, nil)
+
+// renameScore returns the similarity of the content of the files, from 0 to
+// maxRenameScore, as the estimation of git.
+func (w *Worktree) renameScore(from, to *renameFile) (int, error) {
+	if err := w.loadRenameSpans(from); err != nil {
+		return 0, err
+	}
+
+	if err := w.loadRenameSpans(to); err != nil {
+		return 0, err
+	}
+
+	maxSize, baseSize := from.size, to.size
+	if maxSize < baseSize {
+		maxSize, baseSize = baseSize, maxSize
+	}
+
+	if to.size == 0 || maxSize*(maxRenameScore-minRenameScore) < (maxSize-baseSize)*maxRenameScore {
+		return 0, nil
+	}
+
+	var copied int64
+	for h, n := range from.spans {
+		if m, ok := to.spans[h]; ok {
+			if m < n {
+				n = m
+			}
+
+			copied += int64(n)
+		}
+	}
+
+	return int(copied * maxRenameScore / maxSize), nil
+}
+
+// loadRenameSpans reads the content of the file, and counts the bytes of
+// its spans, split after each new line or each maxSpanLength bytes, by
+// their hash.
+func (w *Worktree) loadRenameSpans(f *renameFile) error {
+	if f.spans != nil {
+		return nil
+	}
+
+	blob, err := object.GetBlob(w.r.Storer, f.hash)
+	if err != nil {
+		return err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	isBinary, err := binary.IsBinary(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	f.size = int64(len(content))
+	f.spans = spanHashes(content, !isBinary)
+	return nil
+}
+
+// spanHashes returns the number of bytes of the spans of the content by
+// their hash, ignoring the carriage returns before new lines in text.
+func spanHashes(content []byte, isText bool) map[uint32]int {
+	spans := make(map[uint32]int)
+	var accum1, accum2 uint32
+	var n int
+	for i := 0; i < len(content); i++ {
+		c := uint32(content[i])
+		if isText && c == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			continue
+		}
+
+		old := accum1
+		accum1 = (accum1 << 7) ^ (accum2 >> 25)
+		accum2 = (accum2 << 7) ^ (old >> 25)
+		accum1 += c
+		n++
+		if n < maxSpanLength && c != '\n' {
+			continue
+		}
+
+		spans[(accum1+accum2*0x61)%spanHashBase] += n
+		accum1, accum2, n = 0, 0, 0
+	}
+
+	if n > 0 {
+		spans[(accum1+accum2*0x61)%spanHashBase] += n
+	}
+
+	return spans
+}
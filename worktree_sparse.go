@@ -0,0 +1,341 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+const sparseCheckoutFile = "info/sparse-checkout"
+
+var (
+	// ErrSparseCheckoutNotSupported is returned by SparseCheckout when the
+	// storage of the repository is not based on a filesystem, the patterns
+	// are stored as a file in the git directory.
+	ErrSparseCheckoutNotSupported = errors.New("sparse checkout not supported by the storage")
+)
+
+// SparseCheckout limits the files checked out in the worktree to the given
+// directories, using cone mode patterns, like `git sparse-checkout set
+// --cone`. The files in the root of the worktree are always checked out.
+// Calling it without directories disables the sparse checkout and restores
+// all the files of the worktree.
+//
+// The index entries outside the directories are flagged as SkipWorktree and
+// removed from the worktree if they are not modified.
+func (w *Worktree) SparseCheckout(dirs []string) error {
+	fs, err := w.gitDirFilesystem()
+	if err != nil {
+		return err
+	}
+
+	unstaged, err := w.containsUnstagedChanges()
+	if err != nil {
+		return err
+	}
+
+	if unstaged {
+		return ErrUnstagedChanges
+	}
+
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		cfg.Core.SparseCheckout = false
+		cfg.Core.SparseCheckoutCone = false
+		if err := w.clearSkipWorktree(); err != nil {
+			return err
+		}
+	} else {
+		cfg.Core.SparseCheckout = true
+		cfg.Core.SparseCheckoutCone = true
+		if err := util.WriteFile(fs, sparseCheckoutFile,
+			encodeSparseCheckoutCone(dirs), 0644); err != nil {
+			return err
+		}
+	}
+
+	if err := w.r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	head, err := w.r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		// nothing is checked out yet, the patterns are applied by the
+		// first checkout
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t, err := w.getTreeFromCommitHash(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	if err := w.resetIndex(t); err != nil {
+		return err
+	}
+
+	return w.resetWorktree(t)
+}
+
+func (w *Worktree) gitDirFilesystem() (billy.Filesystem, error) {
+	fs, ok := w.r.Storer.(interface {
+		Filesystem() billy.Filesystem
+	})
+
+	if !ok {
+		return nil, ErrSparseCheckoutNotSupported
+	}
+
+	return fs.Filesystem(), nil
+}
+
+// encodeSparseCheckoutCone returns the content of a sparse-checkout file in
+// cone mode for the given directories: every file in the root and in the
+// parents of the directories, and every file under the directories.
+func encodeSparseCheckoutCone(dirs []string) []byte {
+	var cleaned []string
+	for _, d := range dirs {
+		d = strings.Trim(path.Clean("/"+strings.Replace(d, "\\", "/", -1)), "/")
+		if d != "" {
+			cleaned = append(cleaned, d)
+		}
+	}
+
+	sort.Strings(cleaned)
+
+	buf := bytes.NewBufferString("/*\n!/*/\n")
+	if len(cleaned) == 0 {
+		return buf.Bytes()
+	}
+
+	parents := make(map[string]bool)
+	var last string
+	for _, d := range cleaned {
+		// the directory is already included by a previous one
+		if last != "" && (d == last || strings.HasPrefix(d, last+"/")) {
+			continue
+		}
+
+		last = d
+		parts := strings.Split(d, "/")
+		for i := 1; i < len(parts); i++ {
+			p := strings.Join(parts[:i], "/")
+			if parents[p] {
+				continue
+			}
+
+			parents[p] = true
+			buf.WriteString("/" + p + "/\n!/" + p + "/*/\n")
+		}
+
+		buf.WriteString("/" + d + "/\n")
+	}
+
+	return buf.Bytes()
+}
+
+// sparseCheckoutMatcher returns a matcher with the patterns of the
+// sparse-checkout file, matching the paths to check out, or nil if sparse
+// checkout is not enabled. Cone mode patterns are a subset of the gitignore
+// patterns, so both modes are read the same way.
+func (w *Worktree) sparseCheckoutMatcher() (gitignore.Matcher, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Core.SparseCheckout {
+		return nil, nil
+	}
+
+	fs, err := w.gitDirFilesystem()
+	if err == ErrSparseCheckoutNotSupported {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := readSparseCheckoutPatterns(fs)
+	if err != nil || ps == nil {
+		return nil, err
+	}
+
+	return gitignore.NewMatcher(ps), nil
+}
+
+func readSparseCheckoutPatterns(fs billy.Filesystem) (ps []gitignore.Pattern, err error) {
+	f, err := fs.Open(sparseCheckoutFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	data, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ps = []gitignore.Pattern{}
+	for _, s := range strings.Split(string(data), "\n") {
+		s = strings.TrimRight(s, "\r")
+		if strings.HasPrefix(s, "#") || len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+
+		ps = append(ps, gitignore.ParsePattern(s, nil))
+	}
+
+	return ps, nil
+}
+
+// applySparseCheckout sets the SkipWorktree flag of the index entries not
+// matched by the sparse-checkout patterns, if sparse checkout is enabled.
+func (w *Worktree) applySparseCheckout(idx *index.Index) error {
+	m, err := w.sparseCheckoutMatcher()
+	if err != nil || m == nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		e.SkipWorktree = !m.Match(strings.Split(e.Name, "/"), false)
+		if e.SkipWorktree && idx.Version < 3 {
+			// extended flags are not supported before version 3
+			idx.Version = 3
+		}
+	}
+
+	return nil
+}
+
+func (w *Worktree) clearSkipWorktree() error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		e.SkipWorktree = false
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// removeSkipWorktreeFiles removes from the worktree the files of the entries
+// flagged as SkipWorktree, unless they contain changes.
+func (w *Worktree) removeSkipWorktreeFiles(idx *index.Index, filter *worktreeFilter) error {
+	for _, e := range idx.Entries {
+		if !e.SkipWorktree {
+			continue
+		}
+
+		fi, err := w.Filesystem.Lstat(e.Name)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			continue
+		}
+
+		h, err := w.hashFile(e.Name, fi, filter)
+		if err != nil {
+			return err
+		}
+
+		if h != e.Hash {
+			continue
+		}
+
+		if err := rmFileAndDirIfEmpty(w.Filesystem, e.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hashFile returns the blob hash of a file of the worktree, without storing
+// it, its content is converted by the filter.
+func (w *Worktree) hashFile(path string, fi os.FileInfo, filter *worktreeFilter) (plumbing.Hash, error) {
+	buf := bytes.NewBuffer(nil)
+
+	var err error
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		err = w.fillEncodedObjectFromSymlink(buf, path, fi)
+	case filter.Applies(path):
+		err = w.fillFilteredObjectFromFile(buf, path, filter)
+	default:
+		err = w.fillEncodedObjectFromFile(buf, path, fi)
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := plumbing.NewHasher(plumbing.BlobObject, int64(buf.Len()))
+	if _, err := io.Copy(h, buf); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h.Sum(), nil
+}
+
+// excludeSkipWorktreeChanges removes from the changes between the index and
+// the worktree the ones of entries flagged as SkipWorktree.
+func excludeSkipWorktreeChanges(idx *index.Index, changes merkletrie.Changes) merkletrie.Changes {
+	skip := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if e.SkipWorktree {
+			skip[e.Name] = true
+		}
+	}
+
+	if len(skip) == 0 {
+		return changes
+	}
+
+	var res merkletrie.Changes
+	for _, ch := range changes {
+		if skip[nameFromAction(&ch)] {
+			continue
+		}
+
+		res = append(res, ch)
+	}
+
+	return res
+}
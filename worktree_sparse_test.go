@@ -0,0 +1,129 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) TestSparseCheckout(c *C) {
+	w, err := s.Repository.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{})
+	c.Assert(err, IsNil)
+
+	err = w.SparseCheckout([]string{"json", "vendor/"})
+	c.Assert(err, IsNil)
+
+	assertFiles(c, w.Filesystem, map[string]bool{
+		".gitignore":      true,
+		"CHANGELOG":       true,
+		"json/long.json":  true,
+		"json/short.json": true,
+		"vendor/foo.go":   true,
+		"go/example.go":   false,
+		"php/crappy.php":  false,
+	})
+
+	_, err = w.Filesystem.Lstat("go")
+	c.Assert(err, NotNil)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.SparseCheckout, Equals, true)
+	c.Assert(cfg.Core.SparseCheckoutCone, Equals, true)
+
+	idx, err := s.Repository.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(3))
+
+	e, err := idx.Entry("go/example.go")
+	c.Assert(err, IsNil)
+	c.Assert(e.SkipWorktree, Equals, true)
+
+	e, err = idx.Entry("json/long.json")
+	c.Assert(err, IsNil)
+	c.Assert(e.SkipWorktree, Equals, false)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	err = w.SparseCheckout(nil)
+	c.Assert(err, IsNil)
+
+	assertFiles(c, w.Filesystem, map[string]bool{
+		"go/example.go":  true,
+		"php/crappy.php": true,
+	})
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestSparseCheckoutPatterns(c *C) {
+	fs := s.Repository.Storer.(interface {
+		Filesystem() billy.Filesystem
+	}).Filesystem()
+
+	err := util.WriteFile(fs, "info/sparse-checkout", []byte("# comment\n*.go\n!vendor/\n"), 0644)
+	c.Assert(err, IsNil)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.SparseCheckout = true
+	c.Assert(s.Repository.Storer.SetConfig(cfg), IsNil)
+
+	w, err := s.Repository.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{})
+	c.Assert(err, IsNil)
+
+	assertFiles(c, w.Filesystem, map[string]bool{
+		"go/example.go":  true,
+		"vendor/foo.go":  false,
+		"CHANGELOG":      false,
+		"json/long.json": false,
+	})
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	err = w.Clean(&CleanOptions{Dir: true})
+	c.Assert(err, IsNil)
+
+	assertFiles(c, w.Filesystem, map[string]bool{
+		"go/example.go": true,
+	})
+}
+
+func (s *WorktreeSuite) TestSparseCheckoutNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.SparseCheckout([]string{"foo"})
+	c.Assert(err, Equals, ErrSparseCheckoutNotSupported)
+}
+
+func (s *WorktreeSuite) TestEncodeSparseCheckoutCone(c *C) {
+	data := encodeSparseCheckoutCone([]string{"a/b/c", "/d/", "a/e", "a/b/c/f", "a\\b\\g"})
+	c.Assert(string(data), Equals, "/*\n!/*/\n"+
+		"/a/\n!/a/*/\n/a/b/\n!/a/b/*/\n/a/b/c/\n/a/b/g/\n/a/e/\n/d/\n")
+}
+
+func assertFiles(c *C, fs billy.Filesystem, files map[string]bool) {
+	for name, exists := range files {
+		_, err := fs.Lstat(name)
+		c.Assert(err == nil, Equals, exists, Commentf("file %s", name))
+	}
+}
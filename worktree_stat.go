@@ -0,0 +1,127 @@
+package git
+
+import (
+	"os"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+// indexStatCache trusts the hashes of the index entries of the files whose
+// stat data did not change since they were added to the index, so their
+// content is not read again, as git does.
+type indexStatCache struct {
+	entries map[string]*index.Entry
+	// modTime is the time the index was written, the entries of the files
+	// modified at that time or later are racy, their files are read as they
+	// may have been modified again without changing their stat data.
+	modTime time.Time
+	// rehashed are the files of the index which were read, with their stat
+	// data.
+	rehashed map[string]os.FileInfo
+}
+
+func (w *Worktree) newIndexStatCache(idx *index.Index) (*indexStatCache, error) {
+	c := &indexStatCache{
+		entries:  make(map[string]*index.Entry, len(idx.Entries)),
+		rehashed: make(map[string]os.FileInfo),
+	}
+
+	// the files of the unmerged entries and the intent to add ones are
+	// always read
+	for _, e := range idx.Entries {
+		if e.Stage == 0 && !e.IntentToAdd {
+			c.entries[e.Name] = e
+		}
+	}
+
+	if s, ok := w.r.Storer.(storer.IndexModTimeStorer); ok {
+		var err error
+		if c.modTime, err = s.IndexModTime(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// knownHash returns the hash of the index entry of the file at path, and
+// true, if its stat data matches the one of the file and it is not racy.
+func (c *indexStatCache) knownHash(path string, fi os.FileInfo) (plumbing.Hash, bool) {
+	e, ok := c.entries[path]
+	if !ok {
+		return plumbing.ZeroHash, false
+	}
+
+	if !matchesStat(e, fi) || c.isRacy(e) {
+		c.rehashed[path] = fi
+		return plumbing.ZeroHash, false
+	}
+
+	return e.Hash, true
+}
+
+// isRacy returns true if the file of the entry was modified when the index
+// was written or later, including when the time the index was written is
+// not known.
+func (c *indexStatCache) isRacy(e *index.Entry) bool {
+	return !e.ModifiedAt.Before(c.modTime)
+}
+
+// matchesStat returns true if the mode, size, times and, when they are
+// available, the device, inode and owner of the file are the ones of the
+// entry.
+func matchesStat(e *index.Entry, fi os.FileInfo) bool {
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil || mode != e.Mode {
+		return false
+	}
+
+	if e.Size != uint32(fi.Size()) || !e.ModifiedAt.Equal(fi.ModTime()) {
+		return false
+	}
+
+	var sys index.Entry
+	if fillSystemInfo != nil {
+		fillSystemInfo(&sys, fi.Sys())
+	}
+
+	return sys.CreatedAt.Equal(e.CreatedAt) &&
+		sys.Dev == e.Dev && sys.Inode == e.Inode &&
+		sys.UID == e.UID && sys.GID == e.GID
+}
+
+// refresh updates the stat data of the entries of the files which were
+// read and did not change, returning true if any entry was updated.
+func (c *indexStatCache) refresh(changes merkletrie.Changes) bool {
+	if len(c.rehashed) == 0 {
+		return false
+	}
+
+	changed := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		changed[nameFromAction(&ch)] = true
+	}
+
+	var updated bool
+	for path, fi := range c.rehashed {
+		if changed[path] {
+			continue
+		}
+
+		e := c.entries[path]
+		e.ModifiedAt = fi.ModTime()
+		e.Size = uint32(fi.Size())
+		if fillSystemInfo != nil {
+			fillSystemInfo(e, fi.Sys())
+		}
+
+		updated = true
+	}
+
+	return updated
+}
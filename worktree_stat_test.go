@@ -0,0 +1,143 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) TestIndexStatCache(c *C) {
+	dir, err := ioutil.TempDir("", "stat")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "foo")
+	c.Assert(ioutil.WriteFile(name, []byte("foo"), 0644), IsNil)
+	fi, err := os.Lstat(name)
+	c.Assert(err, IsNil)
+
+	h := plumbing.NewHash("19102815663d23f8b75a47e7a01965dcdc96468c")
+	e := &index.Entry{Name: "foo", Hash: h, Mode: filemode.Regular, ModifiedAt: fi.ModTime(), Size: 3}
+	if fillSystemInfo != nil {
+		fillSystemInfo(e, fi.Sys())
+	}
+
+	cache := &indexStatCache{
+		entries:  map[string]*index.Entry{"foo": e},
+		modTime:  fi.ModTime().Add(time.Second),
+		rehashed: make(map[string]os.FileInfo),
+	}
+
+	known, ok := cache.knownHash("foo", fi)
+	c.Assert(ok, Equals, true)
+	c.Assert(known, Equals, h)
+
+	_, ok = cache.knownHash("bar", fi)
+	c.Assert(ok, Equals, false)
+	c.Assert(cache.rehashed, HasLen, 0)
+
+	// racy entry
+	cache.modTime = fi.ModTime()
+	_, ok = cache.knownHash("foo", fi)
+	c.Assert(ok, Equals, false)
+	c.Assert(cache.rehashed["foo"], Equals, fi)
+
+	cache.modTime = time.Time{}
+	_, ok = cache.knownHash("foo", fi)
+	c.Assert(ok, Equals, false)
+
+	cache.modTime = fi.ModTime().Add(time.Second)
+	e.Size = 4
+	_, ok = cache.knownHash("foo", fi)
+	c.Assert(ok, Equals, false)
+
+	e.Size = 3
+	e.Mode = filemode.Executable
+	_, ok = cache.knownHash("foo", fi)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *WorktreeSuite) TestStatusTrustsIndexStatData(c *C) {
+	dir, err := ioutil.TempDir("", "stat")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := Init(memory.NewStorage(), osfs.New(dir))
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644), IsNil)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	c.Assert(os.Chtimes(filepath.Join(dir, "foo"), past, past), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	// the content is not read if the stat data matches, so an entry with
+	// another hash is not detected
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	idx.Entries[0].Hash = plumbing.NewHash("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	c.Assert(r.Storer.SetIndex(idx), IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	// while it is if the stat data changes
+	now := time.Now().Add(-time.Minute).Truncate(time.Second)
+	c.Assert(os.Chtimes(filepath.Join(dir, "foo"), now, now), IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestStatusRefreshIndex(c *C) {
+	dir, err := ioutil.TempDir("", "stat")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := Init(memory.NewStorage(), osfs.New(dir))
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	c.Assert(os.Chtimes(filepath.Join(dir, "foo"), past, past), IsNil)
+
+	status, err := w.StatusWithOptions(&StatusOptions{RefreshIndex: true})
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, false)
+	c.Assert(status.File("foo").Staging, Equals, Added)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries[0].ModifiedAt.Equal(past), Equals, true)
+
+	cache, err := w.newIndexStatCache(idx)
+	c.Assert(err, IsNil)
+	fi, err := os.Lstat(filepath.Join(dir, "foo"))
+	c.Assert(err, IsNil)
+	_, ok := cache.knownHash("foo", fi)
+	c.Assert(ok, Equals, true)
+}
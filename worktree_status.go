@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -32,6 +33,12 @@ var (
 
 // Status returns the working tree status.
 func (w *Worktree) Status() (Status, error) {
+	return w.StatusWithOptions(&StatusOptions{})
+}
+
+// StatusWithOptions returns the working tree status, computed as described
+// by the given options.
+func (w *Worktree) StatusWithOptions(o *StatusOptions) (Status, error) {
 	var hash plumbing.Hash
 
 	ref, err := w.r.Head()
@@ -43,13 +50,26 @@ func (w *Worktree) Status() (Status, error) {
 		hash = ref.Hash()
 	}
 
-	return w.status(hash)
+	return w.status(hash, o)
 }
 
-func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
+func (w *Worktree) status(commit plumbing.Hash, o *StatusOptions) (Status, error) {
 	s := make(Status)
 
-	left, err := w.diffCommitWithStaging(commit, false)
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := w.commitTree(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := newTrackedPaths(idx)
+	skip := statusSkip(o, tracked)
+
+	left, err := w.diffTreeWithIndex(t, idx, false, pathsSkip(o.Paths))
 	if err != nil {
 		return nil, err
 	}
@@ -73,17 +93,41 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		}
 	}
 
-	right, err := w.diffStagingWithWorktree(false)
+	if o.DetectRenames {
+		if err := w.detectRenames(s, left); err != nil {
+			return nil, err
+		}
+	}
+
+	cache, err := w.newIndexStatCache(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := w.diffIndexWithWorktree(idx, false, skip, cache)
 	if err != nil {
 		return nil, err
 	}
 
+	if o.RefreshIndex && cache.refresh(right) {
+		if err := w.r.Storer.SetIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	right, ignored := w.splitIgnoredChanges(right)
+	var untracked []string
 	for _, ch := range right {
 		a, err := ch.Action()
 		if err != nil {
 			return nil, err
 		}
 
+		if a == merkletrie.Insert {
+			untracked = append(untracked, ch.To.String())
+			continue
+		}
+
 		fs := s.File(nameFromAction(&ch))
 		if fs.Staging == Untracked {
 			fs.Staging = Unmodified
@@ -92,17 +136,142 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		switch a {
 		case merkletrie.Delete:
 			fs.Worktree = Deleted
-		case merkletrie.Insert:
-			fs.Worktree = Untracked
-			fs.Staging = Untracked
 		case merkletrie.Modify:
 			fs.Worktree = Modified
 		}
 	}
 
+	if o.Untracked != UntrackedFilesNo {
+		for _, name := range untracked {
+			fs := s.File(tracked.untrackedName(name, o.Untracked, nil))
+			fs.Worktree = Untracked
+			fs.Staging = Untracked
+		}
+	}
+
+	if o.Ignored {
+		dirs := untrackedDirs(untracked)
+		for _, ch := range ignored {
+			if len(ch.From) != 0 {
+				continue
+			}
+
+			fs := s.File(tracked.untrackedName(ch.To.String(), o.Untracked, dirs))
+			fs.Worktree = Ignored
+			fs.Staging = Ignored
+		}
+	}
+
 	return s, nil
 }
 
+// trackedPaths are the paths of the files of the index and of their
+// directories.
+type trackedPaths struct {
+	files, dirs map[string]bool
+}
+
+func newTrackedPaths(idx *index.Index) *trackedPaths {
+	t := &trackedPaths{files: make(map[string]bool), dirs: make(map[string]bool)}
+	for _, e := range idx.Entries {
+		t.files[e.Name] = true
+		for dir := path.Dir(e.Name); dir != "." && !t.dirs[dir]; dir = path.Dir(dir) {
+			t.dirs[dir] = true
+		}
+	}
+
+	return t
+}
+
+// untrackedName returns the name reported for an untracked file, with
+// UntrackedFilesNormal its first directory without tracked files, nor
+// reported untracked files if dirs is set, with a trailing slash.
+func (t *trackedPaths) untrackedName(name string, mode UntrackedFilesMode, dirs map[string]bool) string {
+	if mode != UntrackedFilesNormal {
+		return name
+	}
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != '/' {
+			continue
+		}
+
+		dir := name[:i]
+		if !t.dirs[dir] && !t.files[dir] && !dirs[dir] {
+			return dir + "/"
+		}
+	}
+
+	return name
+}
+
+// untrackedDirs returns the directories with untracked files.
+func untrackedDirs(untracked []string) map[string]bool {
+	dirs := make(map[string]bool)
+	for _, name := range untracked {
+		for dir := path.Dir(name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	return dirs
+}
+
+// statusSkip returns the function skipping the paths of the worktree left out
+// of a status, nil if none are.
+func statusSkip(o *StatusOptions, tracked *trackedPaths) func(string, bool) bool {
+	skipPaths := pathsSkip(o.Paths)
+	skipUntracked := o.Untracked == UntrackedFilesNo && !o.Ignored
+	if skipPaths == nil && !skipUntracked {
+		return nil
+	}
+
+	return func(name string, isDir bool) bool {
+		if skipPaths != nil && skipPaths(name, isDir) {
+			return true
+		}
+
+		if !skipUntracked || tracked.files[name] {
+			return false
+		}
+
+		return !isDir || !tracked.dirs[name]
+	}
+}
+
+// pathsSkip returns the function skipping the files which do not match the
+// paths, nor are inside them, and the directories which can not have any of
+// them, nil if there are no paths.
+func pathsSkip(paths []string) func(string, bool) bool {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var prefixes []string
+	for _, p := range paths {
+		p = strings.Trim(path.Clean(p), "/")
+		if p == "." || p == "" {
+			return nil
+		}
+
+		prefixes = append(prefixes, p)
+	}
+
+	return func(name string, isDir bool) bool {
+		for _, prefix := range prefixes {
+			if name == prefix || strings.HasPrefix(name, prefix+"/") {
+				return false
+			}
+
+			if isDir && strings.HasPrefix(prefix, name+"/") {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
 func nameFromAction(ch *merkletrie.Change) string {
 	name := ch.To.String()
 	if name == "" {
@@ -118,13 +287,28 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
-	from := mindex.NewRootNode(idx)
-	submodules, err := w.getSubmodulesStatus()
+	cache, err := w.newIndexStatCache(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := w.diffIndexWithWorktree(idx, reverse, nil, cache)
 	if err != nil {
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	return w.excludeIgnoredChanges(c), nil
+}
+
+// diffIndexWithWorktree returns the changes between the index and the
+// worktree, leaving out the paths for which skip, if set, returns true. The
+// files whose stat data matches the one of the cache are not read.
+func (w *Worktree) diffIndexWithWorktree(idx *index.Index, reverse bool, skip func(string, bool) bool, cache *indexStatCache) (merkletrie.Changes, error) {
+	from := newSkipNoder(mindex.NewRootNode(idx), skip)
+	to, _, err := w.worktreeRootNode(idx, skip, cache)
+	if err != nil {
+		return nil, err
+	}
 
 	var c merkletrie.Changes
 	if reverse {
@@ -137,24 +321,57 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
-	return w.excludeIgnoredChanges(c), nil
+	return excludeSkipWorktreeChanges(idx, c), nil
+}
+
+// worktreeRootNode returns the root node of the files of the worktree, and
+// the filter converting their content. The paths for which skip, if set,
+// returns true are left out, and the hashes of the files known by the cache,
+// if set, are not computed.
+func (w *Worktree) worktreeRootNode(idx *index.Index, skip func(string, bool) bool, cache *indexStatCache) (noder.Noder, *worktreeFilter, error) {
+	submodules, err := w.getSubmodulesStatus()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filter, err := w.newWorktreeFilter(nil, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o := filesystem.Options{Skip: skip}
+	if filter != nil {
+		o.Filter = filter
+	}
+
+	if cache != nil {
+		o.KnownHash = cache.knownHash
+	}
+
+	return filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, o), filter, nil
 }
 
 func (w *Worktree) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.Changes {
+	res, _ := w.splitIgnoredChanges(changes)
+	return res
+}
+
+// splitIgnoredChanges returns the changes of the paths which are not ignored
+// and the ones of the ignored paths.
+func (w *Worktree) splitIgnoredChanges(changes merkletrie.Changes) (res, ignored merkletrie.Changes) {
 	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
 	if err != nil {
-		return changes
+		return changes, nil
 	}
 
 	patterns = append(patterns, w.Excludes...)
 
 	if len(patterns) == 0 {
-		return changes
+		return changes, nil
 	}
 
 	m := gitignore.NewMatcher(patterns)
 
-	var res merkletrie.Changes
 	for _, ch := range changes {
 		var path []string
 		for _, n := range ch.To {
@@ -168,12 +385,13 @@ func (w *Worktree) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.
 		if len(path) != 0 {
 			isDir := (len(ch.To) > 0 && ch.To.IsDir()) || (len(ch.From) > 0 && ch.From.IsDir())
 			if m.Match(path, isDir) {
+				ignored = append(ignored, ch)
 				continue
 			}
 		}
 		res = append(res, ch)
 	}
-	return res
+	return res, ignored
 }
 
 func (w *Worktree) getSubmodulesStatus() (map[string]plumbing.Hash, error) {
@@ -202,34 +420,46 @@ func (w *Worktree) getSubmodulesStatus() (map[string]plumbing.Hash, error) {
 }
 
 func (w *Worktree) diffCommitWithStaging(commit plumbing.Hash, reverse bool) (merkletrie.Changes, error) {
-	var t *object.Tree
-	if !commit.IsZero() {
-		c, err := w.r.CommitObject(commit)
-		if err != nil {
-			return nil, err
-		}
-
-		t, err = c.Tree()
-		if err != nil {
-			return nil, err
-		}
+	t, err := w.commitTree(commit)
+	if err != nil {
+		return nil, err
 	}
 
 	return w.diffTreeWithStaging(t, reverse)
 }
 
-func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie.Changes, error) {
-	var from noder.Noder
-	if t != nil {
-		from = object.NewTreeRootNode(t)
+// commitTree returns the tree of the commit, nil if the hash is zero.
+func (w *Worktree) commitTree(commit plumbing.Hash) (*object.Tree, error) {
+	if commit.IsZero() {
+		return nil, nil
 	}
 
+	c, err := w.r.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Tree()
+}
+
+func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie.Changes, error) {
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return nil, err
 	}
 
-	to := mindex.NewRootNode(idx)
+	return w.diffTreeWithIndex(t, idx, reverse, nil)
+}
+
+// diffTreeWithIndex returns the changes between the tree and the index,
+// leaving out the paths for which skip, if set, returns true.
+func (w *Worktree) diffTreeWithIndex(t *object.Tree, idx *index.Index, reverse bool, skip func(string, bool) bool) (merkletrie.Changes, error) {
+	var from noder.Noder
+	if t != nil {
+		from = newSkipNoder(object.NewTreeRootNode(t), skip)
+	}
+
+	to := newSkipNoder(mindex.NewRootNode(idx), skip)
 
 	if reverse {
 		return merkletrie.DiffTree(to, from, diffTreeIsEquals)
@@ -238,6 +468,50 @@ func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie
 	return merkletrie.DiffTree(from, to, diffTreeIsEquals)
 }
 
+// skipNoder is a noder.Noder leaving out the descendants of a noder for
+// which skip returns true, given their path.
+type skipNoder struct {
+	noder.Noder
+	path string
+	skip func(string, bool) bool
+}
+
+// newSkipNoder returns n leaving out its descendants for which skip returns
+// true, or n if skip is nil.
+func newSkipNoder(n noder.Noder, skip func(string, bool) bool) noder.Noder {
+	if skip == nil {
+		return n
+	}
+
+	return &skipNoder{Noder: n, skip: skip}
+}
+
+func (n *skipNoder) Children() ([]noder.Noder, error) {
+	children, err := n.Noder.Children()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]noder.Noder, 0, len(children))
+	for _, c := range children {
+		p := c.Name()
+		if n.path != "" {
+			p = n.path + "/" + p
+		}
+
+		if !n.skip(p, c.IsDir()) {
+			res = append(res, &skipNoder{Noder: c, path: p, skip: n.skip})
+		}
+	}
+
+	return res, nil
+}
+
+func (n *skipNoder) NumChildren() (int, error) {
+	children, err := n.Children()
+	return len(children), err
+}
+
 var emptyNoderHash = make([]byte, 24)
 
 // diffTreeIsEquals is a implementation of noder.Equals, used to compare
@@ -275,14 +549,19 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	filter, err := w.newWorktreeFilter(nil, idx)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	var h plumbing.Hash
 	var added bool
 
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(idx, s, path)
+		added, h, err = w.doAddFile(idx, s, filter, path)
 	} else {
-		added, err = w.doAddDirectory(idx, s, path)
+		added, err = w.doAddDirectory(idx, s, filter, path)
 	}
 
 	if err != nil {
@@ -296,7 +575,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return h, w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string) (added bool, err error) {
+func (w *Worktree) doAddDirectory(idx *index.Index, s Status, filter *worktreeFilter, directory string) (added bool, err error) {
 	files, err := w.Filesystem.ReadDir(directory)
 	if err != nil {
 		return false, err
@@ -311,9 +590,9 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string)
 				// ignore special git directory
 				continue
 			}
-			a, err = w.doAddDirectory(idx, s, name)
+			a, err = w.doAddDirectory(idx, s, filter, name)
 		} else {
-			a, _, err = w.doAddFile(idx, s, name)
+			a, _, err = w.doAddFile(idx, s, filter, name)
 		}
 
 		if err != nil {
@@ -351,6 +630,11 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
+	filter, err := w.newWorktreeFilter(nil, idx)
+	if err != nil {
+		return err
+	}
+
 	var saveIndex bool
 	for _, file := range files {
 		fi, err := w.Filesystem.Lstat(file)
@@ -360,9 +644,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(idx, s, file)
+			added, err = w.doAddDirectory(idx, s, filter, file)
 		} else {
-			added, _, err = w.doAddFile(idx, s, file)
+			added, _, err = w.doAddFile(idx, s, filter, file)
 		}
 
 		if err != nil {
@@ -383,12 +667,12 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
-func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added bool, h plumbing.Hash, err error) {
+func (w *Worktree) doAddFile(idx *index.Index, s Status, filter *worktreeFilter, path string) (added bool, h plumbing.Hash, err error) {
 	if s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
 
-	h, err = w.copyFileToStorage(path)
+	h, err = w.copyFileToStorage(path, filter)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
@@ -405,12 +689,16 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added boo
 	return true, h, err
 }
 
-func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error) {
+func (w *Worktree) copyFileToStorage(path string, filter *worktreeFilter) (hash plumbing.Hash, err error) {
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
+	if fi.Mode()&os.ModeSymlink == 0 && filter.Applies(path) {
+		return w.copyFilteredFileToStorage(path, filter)
+	}
+
 	obj := w.r.Storer.NewEncodedObject()
 	obj.SetType(plumbing.BlobObject)
 	obj.SetSize(fi.Size())
@@ -435,6 +723,34 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 	return w.r.Storer.SetEncodedObject(obj)
 }
 
+// copyFilteredFileToStorage stores the content of the file converted by the
+// filter, as the content stored in the repository.
+func (w *Worktree) copyFilteredFileToStorage(path string, filter *worktreeFilter) (hash plumbing.Hash, err error) {
+	// the converted content is buffered, since its size is needed to store
+	// the object
+	buf := bytes.NewBuffer(nil)
+	if err := w.fillFilteredObjectFromFile(buf, path, filter); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	obj := w.r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(buf.Len()))
+
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	defer ioutil.CheckClose(writer, &err)
+
+	if _, err := io.Copy(writer, buf); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.r.Storer.SetEncodedObject(obj)
+}
+
 func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, fi os.FileInfo) (err error) {
 	src, err := w.Filesystem.Open(path)
 	if err != nil {
@@ -450,6 +766,18 @@ func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, fi os.F
 	return err
 }
 
+// fillFilteredObjectFromFile writes to dst the content of the file converted
+// by the filter, the file is streamed to it.
+func (w *Worktree) fillFilteredObjectFromFile(dst io.Writer, path string, filter *worktreeFilter) (err error) {
+	src, err := w.Filesystem.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(src, &err)
+	return filter.Clean(path, src, dst)
+}
+
 func (w *Worktree) fillEncodedObjectFromSymlink(dst io.Writer, path string, fi os.FileInfo) error {
 	target, err := w.Filesystem.Readlink(path)
 	if err != nil {
@@ -470,6 +798,10 @@ func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h p
 		return w.doAddFileToIndex(idx, filename, h)
 	}
 
+	if idx.Cache != nil {
+		idx.Cache.Invalidate(e.Name)
+	}
+
 	return w.doUpdateFileToIndex(e, filename, h)
 }
 
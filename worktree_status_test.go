@@ -0,0 +1,143 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *WorktreeSuite) newStatusWorktree(c *C) *Worktree {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	var content string
+	for i := 0; i < 20; i++ {
+		content += fmt.Sprintf("line %d of the file\n", i)
+	}
+
+	files := map[string]string{
+		"a.txt":      content,
+		"dir/b.txt":  "b\n",
+		"dir/c.txt":  "cccc\n",
+		".gitignore": "*.log\n",
+	}
+
+	for name, content := range files {
+		c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	_, err = w.Move("dir/c.txt", "dir/d.txt")
+	c.Assert(err, IsNil)
+	_, err = w.Remove("a.txt")
+	c.Assert(err, IsNil)
+
+	content = strings.Replace(content, "line 3 ", "LINE 3 ", 1)
+	c.Assert(util.WriteFile(w.Filesystem, "moved.txt", []byte(content), 0644), IsNil)
+	_, err = w.Add("moved.txt")
+	c.Assert(err, IsNil)
+
+	files = map[string]string{
+		"new/x":      "x\n",
+		"new/y":      "y\n",
+		"new/z.log":  "z\n",
+		"dir/e.txt":  "e\n",
+		"u.txt":      "u\n",
+		"debug.log":  "d\n",
+		"logs/l.log": "l\n",
+		"dir/b.txt":  "b2\n",
+	}
+
+	for name, content := range files {
+		c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+	}
+
+	return w
+}
+
+func statusLines(s Status) []string {
+	lines := strings.Split(strings.TrimSuffix(s.String(), "\n"), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func (s *WorktreeSuite) TestStatusWithOptions(c *C) {
+	w := s.newStatusWorktree(c)
+
+	for _, t := range []struct {
+		options  StatusOptions
+		expected []string
+	}{{
+		StatusOptions{},
+		[]string{" M dir/b.txt", "?? dir/e.txt", "?? new/x", "?? new/y", "?? u.txt",
+			"A  dir/d.txt", "A  moved.txt", "D  a.txt", "D  dir/c.txt"},
+	}, {
+		StatusOptions{DetectRenames: true},
+		[]string{" M dir/b.txt", "?? dir/e.txt", "?? new/x", "?? new/y", "?? u.txt",
+			"R  a.txt -> moved.txt", "R  dir/c.txt -> dir/d.txt"},
+	}, {
+		StatusOptions{Untracked: UntrackedFilesNormal},
+		[]string{" M dir/b.txt", "?? dir/e.txt", "?? new/", "?? u.txt",
+			"A  dir/d.txt", "A  moved.txt", "D  a.txt", "D  dir/c.txt"},
+	}, {
+		StatusOptions{Untracked: UntrackedFilesNo, DetectRenames: true},
+		[]string{" M dir/b.txt", "R  a.txt -> moved.txt", "R  dir/c.txt -> dir/d.txt"},
+	}, {
+		StatusOptions{Untracked: UntrackedFilesNormal, Ignored: true},
+		[]string{" M dir/b.txt", "!! debug.log", "!! logs/", "!! new/z.log",
+			"?? dir/e.txt", "?? new/", "?? u.txt",
+			"A  dir/d.txt", "A  moved.txt", "D  a.txt", "D  dir/c.txt"},
+	}, {
+		StatusOptions{Ignored: true},
+		[]string{" M dir/b.txt", "!! debug.log", "!! logs/l.log", "!! new/z.log",
+			"?? dir/e.txt", "?? new/x", "?? new/y", "?? u.txt",
+			"A  dir/d.txt", "A  moved.txt", "D  a.txt", "D  dir/c.txt"},
+	}, {
+		StatusOptions{Untracked: UntrackedFilesNormal, Paths: []string{"dir"}, DetectRenames: true},
+		[]string{" M dir/b.txt", "?? dir/e.txt", "R  dir/c.txt -> dir/d.txt"},
+	}} {
+		status, err := w.StatusWithOptions(&t.options)
+		c.Assert(err, IsNil)
+		c.Assert(statusLines(status), DeepEquals, t.expected, Commentf("options: %+v", t.options))
+	}
+}
+
+func (s *WorktreeSuite) TestStatusWithOptionsPathsSkipsDirectories(c *C) {
+	w := s.newStatusWorktree(c)
+
+	var read []string
+	skip := statusSkip(&StatusOptions{Paths: []string{"dir/b.txt"}}, nil)
+	for _, p := range []struct {
+		name  string
+		isDir bool
+	}{{"dir", true}, {"dir/b.txt", false}, {"dir/e.txt", false}, {"new", true}, {"u.txt", false}} {
+		if !skip(p.name, p.isDir) {
+			read = append(read, p.name)
+		}
+	}
+
+	c.Assert(read, DeepEquals, []string{"dir", "dir/b.txt"})
+
+	status, err := w.StatusWithOptions(&StatusOptions{Paths: []string{"dir/b.txt"}})
+	c.Assert(err, IsNil)
+	c.Assert(statusLines(status), DeepEquals, []string{" M dir/b.txt"})
+	c.Assert(status.IsClean(), Equals, false)
+
+	status, err = w.StatusWithOptions(&StatusOptions{Paths: []string{".gitignore", "logs"}, Ignored: true})
+	c.Assert(err, IsNil)
+	c.Assert(statusLines(status), DeepEquals, []string{"!! logs/l.log"})
+	c.Assert(status.IsClean(), Equals, true)
+}
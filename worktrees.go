@@ -0,0 +1,479 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	stdioutil "io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+var (
+	// ErrLinkedWorktreesNotSupported is returned when managing the linked
+	// worktrees of a repository whose storage is not based on a filesystem.
+	ErrLinkedWorktreesNotSupported = errors.New("linked worktrees not supported by the storage")
+	// ErrWorktreeNotFound is returned when a linked worktree does not exist.
+	ErrWorktreeNotFound = errors.New("worktree not found")
+	// ErrInvalidWorktreeName is returned when the name of a linked worktree
+	// is empty, "." or "..", or contains a path separator.
+	ErrInvalidWorktreeName = errors.New("invalid worktree name")
+	// ErrWorktreeAlreadyExists is returned by AddWorktree when the path of
+	// the new worktree already exists and is not empty.
+	ErrWorktreeAlreadyExists = errors.New("worktree already exists")
+	// ErrWorktreeLocked is returned when removing or locking a locked
+	// worktree.
+	ErrWorktreeLocked = errors.New("worktree is locked")
+	// ErrWorktreeNotLocked is returned when unlocking a worktree which is not
+	// locked.
+	ErrWorktreeNotLocked = errors.New("worktree is not locked")
+	// ErrBranchCheckedOut is returned by AddWorktree when the branch is
+	// already checked out in another worktree.
+	ErrBranchCheckedOut = errors.New("branch is already checked out in a worktree")
+)
+
+const (
+	worktreeGitDirFile = "gitdir"
+	worktreeLockedFile = "locked"
+	worktreeHeadFile   = "HEAD"
+)
+
+// LinkedWorktree is a worktree of a repository besides the main one, sharing
+// its objects, references and configuration, as created by
+// `git worktree add`.
+type LinkedWorktree struct {
+	// Name of the worktree, the name of its git directory under
+	// .git/worktrees.
+	Name string
+	// Path is the directory of the worktree.
+	Path string
+	// Head is the HEAD of the worktree, usually a symbolic reference to the
+	// branch checked out.
+	Head *plumbing.Reference
+	// Locked is true if the worktree is locked, so it is not pruned even
+	// if its directory is not found.
+	Locked bool
+	// LockReason is the reason given when the worktree was locked, if any.
+	LockReason string
+	// Prunable is true if the directory of the worktree does not exist
+	// anymore.
+	Prunable bool
+}
+
+// AddWorktree creates a new worktree in the given path, linked to this
+// repository, with the given branch checked out, like `git worktree add`.
+// If the branch does not exist it is created pointing to HEAD, and if it is
+// empty a branch named as the base name of the path is used.
+//
+// The worktree shares the objects, references and configuration with the
+// repository, while its HEAD and index are stored in .git/worktrees/<name>.
+// The returned repository is the one of the new worktree.
+func (r *Repository) AddWorktree(path string, branch plumbing.ReferenceName) (*Repository, error) {
+	common, err := r.commonDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNewWorktreePath(path); err != nil {
+		return nil, err
+	}
+
+	if branch == "" {
+		branch = plumbing.NewBranchReferenceName(filepath.Base(path))
+	}
+
+	commit, err := r.worktreeBranchCommit(common, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := newWorktreeName(common, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	dir := common.Join(dotgit.WorktreesPath, name)
+	if err := util.WriteFile(common, common.Join(dir, dotgit.CommonDirPath),
+		[]byte("../..\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	if err := util.WriteFile(common, common.Join(dir, worktreeGitDirFile),
+		[]byte(filepath.Join(path, GitDirName)+"\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	wt := osfs.New(path)
+	if err := util.WriteFile(wt, GitDirName,
+		[]byte(fmt.Sprintf("gitdir: %s\n", filepath.Join(common.Root(), dir))), 0644); err != nil {
+		return nil, err
+	}
+
+	gitdir, err := common.Chroot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := filesystem.NewStorage(
+		dotgit.NewRepositoryFilesystem(gitdir, common),
+		cache.NewObjectLRUDefault(),
+	)
+
+	if err := s.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch)); err != nil {
+		return nil, err
+	}
+
+	linked := newRepository(s, wt)
+	w, err := linked.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	return linked, w.Reset(&ResetOptions{Commit: commit, Mode: HardReset})
+}
+
+// Worktrees returns the worktrees linked to the repository, like
+// `git worktree list`, the main worktree is not included.
+func (r *Repository) Worktrees() ([]*LinkedWorktree, error) {
+	common, err := r.commonDir()
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := common.ReadDir(dotgit.WorktreesPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []*LinkedWorktree
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+
+		wt, err := readLinkedWorktree(common, fi.Name())
+		if err == ErrWorktreeNotFound || err == ErrInvalidWorktreeName {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		worktrees = append(worktrees, wt)
+	}
+
+	return worktrees, nil
+}
+
+// LockWorktree locks the given linked worktree, so it is not pruned nor
+// removed, with an optional reason.
+func (r *Repository) LockWorktree(name, reason string) error {
+	common, wt, err := r.linkedWorktree(name)
+	if err != nil {
+		return err
+	}
+
+	if wt.Locked {
+		return ErrWorktreeLocked
+	}
+
+	return util.WriteFile(common,
+		common.Join(dotgit.WorktreesPath, name, worktreeLockedFile),
+		[]byte(reason), 0644)
+}
+
+// UnlockWorktree unlocks the given linked worktree.
+func (r *Repository) UnlockWorktree(name string) error {
+	common, wt, err := r.linkedWorktree(name)
+	if err != nil {
+		return err
+	}
+
+	if !wt.Locked {
+		return ErrWorktreeNotLocked
+	}
+
+	return common.Remove(common.Join(dotgit.WorktreesPath, name, worktreeLockedFile))
+}
+
+// RemoveWorktree removes the given linked worktree, deleting its directory
+// and its git directory. Locked worktrees can not be removed, and
+// ErrWorktreeNotClean is returned if the worktree contains changes or
+// untracked files.
+func (r *Repository) RemoveWorktree(name string) error {
+	common, wt, err := r.linkedWorktree(name)
+	if err != nil {
+		return err
+	}
+
+	if wt.Locked {
+		return ErrWorktreeLocked
+	}
+
+	if !wt.Prunable {
+		linked, err := PlainOpen(wt.Path)
+		if err != nil {
+			return err
+		}
+
+		w, err := linked.Worktree()
+		if err != nil {
+			return err
+		}
+
+		status, err := w.Status()
+		if err != nil {
+			return err
+		}
+
+		if !status.IsClean() {
+			return ErrWorktreeNotClean
+		}
+
+		if err := os.RemoveAll(wt.Path); err != nil {
+			return err
+		}
+	}
+
+	return util.RemoveAll(common, common.Join(dotgit.WorktreesPath, name))
+}
+
+// PruneWorktrees removes the git directories of the linked worktrees whose
+// directories do not exist anymore, unless they are locked.
+func (r *Repository) PruneWorktrees() error {
+	common, err := r.commonDir()
+	if err != nil {
+		return err
+	}
+
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return err
+	}
+
+	for _, wt := range worktrees {
+		if !wt.Prunable || wt.Locked {
+			continue
+		}
+
+		if err := util.RemoveAll(common, common.Join(dotgit.WorktreesPath, wt.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commonDir returns the git directory shared by all the worktrees of the
+// repository.
+func (r *Repository) commonDir() (billy.Filesystem, error) {
+	s, ok := r.Storer.(interface {
+		Filesystem() billy.Filesystem
+	})
+
+	if !ok {
+		return nil, ErrLinkedWorktreesNotSupported
+	}
+
+	fs := s.Filesystem()
+	if rfs, ok := fs.(*dotgit.RepositoryFilesystem); ok {
+		return rfs.CommonDir(), nil
+	}
+
+	return fs, nil
+}
+
+func (r *Repository) linkedWorktree(name string) (billy.Filesystem, *LinkedWorktree, error) {
+	common, err := r.commonDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wt, err := readLinkedWorktree(common, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return common, wt, nil
+}
+
+// worktreeBranchCommit returns the commit to check out in a new worktree for
+// the given branch, creating the branch at HEAD if it does not exist.
+func (r *Repository) worktreeBranchCommit(common billy.Filesystem, branch plumbing.ReferenceName) (plumbing.Hash, error) {
+	if !branch.IsBranch() {
+		return plumbing.ZeroHash, ErrInvalidReference
+	}
+
+	ref, err := r.Reference(branch, true)
+	if err == plumbing.ErrReferenceNotFound {
+		head, err := r.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		ref = plumbing.NewHashReference(branch, head.Hash())
+		return ref.Hash(), r.Storer.SetReference(ref)
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	mainHead, err := readWorktreeFile(common, worktreeHeadFile)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if plumbing.NewReferenceFromStrings(worktreeHeadFile, mainHead).Target() == branch {
+		return plumbing.ZeroHash, ErrBranchCheckedOut
+	}
+
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	for _, wt := range worktrees {
+		if wt.Head != nil && wt.Head.Target() == branch {
+			return plumbing.ZeroHash, ErrBranchCheckedOut
+		}
+	}
+
+	return ref.Hash(), nil
+}
+
+// readLinkedWorktree reads the linked worktree with the given name, only the
+// directories of .git/worktrees with a gitdir file are linked worktrees.
+func readLinkedWorktree(common billy.Filesystem, name string) (*LinkedWorktree, error) {
+	if !isValidWorktreeName(name) {
+		return nil, ErrInvalidWorktreeName
+	}
+
+	dir := common.Join(dotgit.WorktreesPath, name)
+	if _, err := common.Stat(common.Join(dir, worktreeGitDirFile)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrWorktreeNotFound
+		}
+
+		return nil, err
+	}
+
+	wt := &LinkedWorktree{Name: name}
+
+	gitdir, err := readWorktreeFile(common, common.Join(dir, worktreeGitDirFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if gitdir == "" {
+		wt.Prunable = true
+	} else {
+		wt.Path = filepath.Dir(gitdir)
+		if _, err := os.Stat(gitdir); os.IsNotExist(err) {
+			wt.Prunable = true
+		}
+	}
+
+	head, err := readWorktreeFile(common, common.Join(dir, worktreeHeadFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if head != "" {
+		wt.Head = plumbing.NewReferenceFromStrings(worktreeHeadFile, head)
+	}
+
+	if _, err := common.Stat(common.Join(dir, worktreeLockedFile)); err == nil {
+		wt.Locked = true
+		wt.LockReason, err = readWorktreeFile(common, common.Join(dir, worktreeLockedFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return wt, nil
+}
+
+// isValidWorktreeName returns true if the name is a single component of a
+// path, so it always refers to a directory inside .git/worktrees.
+func isValidWorktreeName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`)
+}
+
+// readWorktreeFile returns the trimmed content of a file of a worktree git
+// directory, or an empty string if it does not exist.
+func readWorktreeFile(fs billy.Filesystem, name string) (content string, err error) {
+	f, err := fs.Open(name)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	b, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// newWorktreeName returns a name for the git directory of a new worktree,
+// the base name of its path followed by a number if it is already used.
+func newWorktreeName(common billy.Filesystem, base string) (string, error) {
+	name := base
+	for i := 1; ; i++ {
+		_, err := common.Stat(common.Join(dotgit.WorktreesPath, name))
+		if os.IsNotExist(err) {
+			return name, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+func checkNewWorktreePath(path string) error {
+	fis, err := stdioutil.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(fis) != 0 {
+		return ErrWorktreeAlreadyExists
+	}
+
+	return nil
+}
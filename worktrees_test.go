@@ -0,0 +1,254 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type WorktreesSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&WorktreesSuite{})
+
+func (s *WorktreesSuite) clone(c *C) (*Repository, string) {
+	dir := c.MkDir()
+	r, err := PlainClone(filepath.Join(dir, "main"), false, &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	return r, dir
+}
+
+func (s *WorktreesSuite) TestAddWorktree(c *C) {
+	r, dir := s.clone(c)
+
+	path := filepath.Join(dir, "feature")
+	linked, err := r.AddWorktree(path, "")
+	c.Assert(err, IsNil)
+
+	head, err := linked.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.NewBranchReferenceName("feature"))
+	c.Assert(head.Hash(), Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	_, err = os.Stat(filepath.Join(path, "CHANGELOG"))
+	c.Assert(err, IsNil)
+
+	w, err := linked.Worktree()
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	// the main worktree keeps its own HEAD and sees the new branch
+	head, err = r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+
+	ref, err := r.Reference(plumbing.NewBranchReferenceName("feature"), false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	for _, name := range []string{"HEAD", "index", "commondir", "gitdir"} {
+		_, err = os.Stat(filepath.Join(dir, "main", GitDirName, "worktrees", "feature", name))
+		c.Assert(err, IsNil, Commentf("file %s", name))
+	}
+}
+
+func (s *WorktreesSuite) TestAddWorktreeCommit(c *C) {
+	r, dir := s.clone(c)
+
+	path := filepath.Join(dir, "feature")
+	_, err := r.AddWorktree(path, "refs/heads/feature")
+	c.Assert(err, IsNil)
+
+	err = ioutil.WriteFile(filepath.Join(path, "foo"), []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	linked, err := PlainOpen(path)
+	c.Assert(err, IsNil)
+
+	w, err := linked.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference("refs/heads/feature", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hash)
+
+	_, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+}
+
+func (s *WorktreesSuite) TestAddWorktreeBranchCheckedOut(c *C) {
+	r, dir := s.clone(c)
+
+	_, err := r.AddWorktree(filepath.Join(dir, "master"), plumbing.Master)
+	c.Assert(err, Equals, ErrBranchCheckedOut)
+
+	_, err = r.AddWorktree(filepath.Join(dir, "foo"), "refs/heads/foo")
+	c.Assert(err, IsNil)
+
+	_, err = r.AddWorktree(filepath.Join(dir, "bar"), "refs/heads/foo")
+	c.Assert(err, Equals, ErrBranchCheckedOut)
+}
+
+func (s *WorktreesSuite) TestAddWorktreeAlreadyExists(c *C) {
+	r, dir := s.clone(c)
+
+	_, err := r.AddWorktree(filepath.Join(dir, "main"), "refs/heads/foo")
+	c.Assert(err, Equals, ErrWorktreeAlreadyExists)
+}
+
+func (s *WorktreesSuite) TestAddWorktreeNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	_, err = r.AddWorktree(c.MkDir(), "")
+	c.Assert(err, Equals, ErrLinkedWorktreesNotSupported)
+}
+
+func (s *WorktreesSuite) TestWorktrees(c *C) {
+	r, dir := s.clone(c)
+
+	_, err := r.AddWorktree(filepath.Join(dir, "foo"), "")
+	c.Assert(err, IsNil)
+
+	_, err = r.AddWorktree(filepath.Join(dir, "other", "foo"), "refs/heads/bar")
+	c.Assert(err, IsNil)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 2)
+
+	c.Assert(worktrees[0].Name, Equals, "foo")
+	c.Assert(worktrees[0].Path, Equals, filepath.Join(dir, "foo"))
+	c.Assert(worktrees[0].Head.Target(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(worktrees[0].Prunable, Equals, false)
+
+	c.Assert(worktrees[1].Name, Equals, "foo1")
+	c.Assert(worktrees[1].Path, Equals, filepath.Join(dir, "other", "foo"))
+	c.Assert(worktrees[1].Head.Target(), Equals, plumbing.ReferenceName("refs/heads/bar"))
+
+	// the linked worktrees list the same worktrees
+	linked, err := PlainOpen(filepath.Join(dir, "foo"))
+	c.Assert(err, IsNil)
+
+	worktrees, err = linked.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 2)
+}
+
+func (s *WorktreesSuite) TestLockAndPruneWorktrees(c *C) {
+	r, dir := s.clone(c)
+
+	_, err := r.AddWorktree(filepath.Join(dir, "foo"), "")
+	c.Assert(err, IsNil)
+
+	_, err = r.AddWorktree(filepath.Join(dir, "bar"), "")
+	c.Assert(err, IsNil)
+
+	c.Assert(r.LockWorktree("foo", "on a removable disk"), IsNil)
+	c.Assert(r.LockWorktree("foo", ""), Equals, ErrWorktreeLocked)
+	c.Assert(r.LockWorktree("qux", ""), Equals, ErrWorktreeNotFound)
+
+	c.Assert(os.RemoveAll(filepath.Join(dir, "foo")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(dir, "bar")), IsNil)
+
+	c.Assert(r.PruneWorktrees(), IsNil)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 1)
+	c.Assert(worktrees[0].Name, Equals, "foo")
+	c.Assert(worktrees[0].Locked, Equals, true)
+	c.Assert(worktrees[0].LockReason, Equals, "on a removable disk")
+	c.Assert(worktrees[0].Prunable, Equals, true)
+
+	c.Assert(r.UnlockWorktree("foo"), IsNil)
+	c.Assert(r.UnlockWorktree("foo"), Equals, ErrWorktreeNotLocked)
+	c.Assert(r.PruneWorktrees(), IsNil)
+
+	worktrees, err = r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 0)
+}
+
+func (s *WorktreesSuite) TestRemoveWorktree(c *C) {
+	r, dir := s.clone(c)
+
+	path := filepath.Join(dir, "foo")
+	_, err := r.AddWorktree(path, "")
+	c.Assert(err, IsNil)
+
+	err = ioutil.WriteFile(filepath.Join(path, "foo"), []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	c.Assert(r.RemoveWorktree("foo"), Equals, ErrWorktreeNotClean)
+
+	c.Assert(os.Remove(filepath.Join(path, "foo")), IsNil)
+	c.Assert(r.LockWorktree("foo", ""), IsNil)
+	c.Assert(r.RemoveWorktree("foo"), Equals, ErrWorktreeLocked)
+
+	c.Assert(r.UnlockWorktree("foo"), IsNil)
+	c.Assert(r.RemoveWorktree("foo"), IsNil)
+
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 0)
+
+	// the branch is not removed with the worktree
+	_, err = r.Reference("refs/heads/foo", false)
+	c.Assert(err, IsNil)
+}
+
+func (s *WorktreesSuite) TestWorktreeInvalidNames(c *C) {
+	r, dir := s.clone(c)
+
+	_, err := r.AddWorktree(filepath.Join(dir, "foo"), "")
+	c.Assert(err, IsNil)
+
+	// a directory without a gitdir file is not a linked worktree
+	gitdir := filepath.Join(dir, "main", ".git")
+	c.Assert(os.MkdirAll(filepath.Join(gitdir, "worktrees", "bar"), 0755), IsNil)
+
+	for _, name := range []string{"", ".", "..", "foo/..", "../worktrees/foo", `..\foo`} {
+		c.Assert(r.RemoveWorktree(name), Equals, ErrInvalidWorktreeName, Commentf("name: %q", name))
+		c.Assert(r.LockWorktree(name, ""), Equals, ErrInvalidWorktreeName, Commentf("name: %q", name))
+		c.Assert(r.UnlockWorktree(name), Equals, ErrInvalidWorktreeName, Commentf("name: %q", name))
+	}
+
+	c.Assert(r.RemoveWorktree("bar"), Equals, ErrWorktreeNotFound)
+
+	worktrees, err := r.Worktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 1)
+	c.Assert(worktrees[0].Name, Equals, "foo")
+
+	_, err = os.Stat(filepath.Join(gitdir, "HEAD"))
+	c.Assert(err, IsNil)
+	_, err = os.Stat(filepath.Join(dir, "foo"))
+	c.Assert(err, IsNil)
+}